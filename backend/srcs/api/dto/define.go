@@ -1,6 +1,7 @@
 package api
 
 import (
+	"backend/core"
 	"time"
 )
 
@@ -108,6 +109,9 @@ type Module struct {
 	// LastDeployStatus is the status of the latest deployment ("success", "failed", or "")
 	LastDeployStatus string `json:"last_deploy_status" example:"success"`
 
+	// Health is computed from the page health probes (only filled by GetModule)
+	Health *core.ModuleHealth `json:"health,omitempty"`
+
 	// Note: Git live details (current/ latest commit, behind, last fetch/pull)
 	// are provided by /git/status and are intentionally not duplicated here
 }
@@ -0,0 +1,108 @@
+package gitcredentials
+
+import (
+	"backend/api/auth"
+	"backend/core"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterRoutes(r chi.Router) {
+	r.Get("/", listGitCredentials)
+	r.Post("/", createGitCredential)
+	r.Get("/{credentialID}", getGitCredential)
+	r.Put("/{credentialID}", updateGitCredential)
+	r.Delete("/{credentialID}", deleteGitCredential)
+}
+
+func listGitCredentials(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	creds, err := core.ListGitCredentials(r.Context())
+	if err != nil {
+		log.Printf("error listing git credentials: %v\n", err)
+		http.Error(w, "Failed to list git credentials", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"git_credentials": creds})
+}
+
+func getGitCredential(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	cred, err := core.GetGitCredential(r.Context(), chi.URLParam(r, "credentialID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(cred)
+}
+
+func createGitCredential(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var input GitCredentialInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	var actor *core.User
+	if u, ok := r.Context().Value(auth.UserCtxKey).(*core.User); ok && u != nil {
+		actor = u
+	}
+	cred, err := core.CreateGitCredential(r.Context(), core.GitCredentialInput{
+		Name:     input.Name,
+		Username: input.Username,
+		Token:    input.Token,
+	}, actor)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(cred)
+}
+
+func updateGitCredential(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var input GitCredentialInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	cred, err := core.UpdateGitCredential(r.Context(), chi.URLParam(r, "credentialID"), core.GitCredentialInput{
+		Name:     input.Name,
+		Username: input.Username,
+		Token:    input.Token,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(cred)
+}
+
+func deleteGitCredential(w http.ResponseWriter, r *http.Request) {
+	if err := core.DeleteGitCredential(r.Context(), chi.URLParam(r, "credentialID")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, core.ErrInvalidInput):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, core.ErrNotFound):
+		http.Error(w, "Git credential not found", http.StatusNotFound)
+	case errors.Is(err, core.ErrConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, core.ErrSecretsKeyMissing):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		log.Printf("error handling git credential: %v\n", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,133 @@
+package modules
+
+import (
+	"backend/core"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxBundleBody leaves room for the icons embedded in a bundle.
+const maxBundleBody = 32 << 20
+
+// ExportModule returns a portable bundle of a module.
+// @Summary      Export Module Bundle
+// @Description  Returns a versioned bundle with the module metadata, pages and their flags, page roles (by name), icons, page health checks, OIDC redirect URIs and scopes, update policy, deploy strategy, resource limits, git options and release tracking. Secret names are always listed; their values are only included, sealed, when recipient_key is the bundle key of the target instance (GET /admin/modules/import/key there). Webhooks and compose policy overrides are not exported.
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID       path      string  true   "Module ID"
+// @Param        recipient_key  query     string  false  "Bundle public key of the target instance"
+// @Success      200            {object}  core.ModuleBundle
+// @Failure      400            {string}  string  "Invalid recipient key"
+// @Failure      404            {string}  string  "module not found"
+// @Failure      503            {string}  string  "Master key is not configured"
+// @Failure      500            {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/export [get]
+func ExportModule(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	module, err := core.GetModule(moduleID)
+	if err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	bundle, err := core.ExportModuleBundle(r.Context(), module, r.URL.Query().Get("recipient_key"))
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, core.ErrSecretsKeyMissing):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		default:
+			log.Printf("error exporting module %s: %v\n", moduleID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.bundle.json"`, module.Slug))
+	json.NewEncoder(w).Encode(bundle)
+}
+
+// GetModuleBundleKey returns the key other instances seal bundle secrets for.
+// @Summary      Get Module Bundle Key
+// @Description  Pass public_key as recipient_key when exporting a module from another instance, so its secrets can be imported here. The key is derived from the active master key and changes when it is rotated; bundles sealed for older versions can be imported as long as that version is loaded.
+// @Tags         Modules
+// @Produce      json
+// @Success      200  {object}  core.ModuleBundleKey
+// @Failure      503  {string}  string  "Master key is not configured"
+// @Failure      500  {string}  string  "Internal server error"
+// @Router       /admin/modules/import/key [get]
+func GetModuleBundleKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	key, err := core.ModuleBundleRecipientKey()
+	if err != nil {
+		if errors.Is(err, core.ErrSecretsKeyMissing) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		log.Printf("error deriving bundle key: %v\n", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(key)
+}
+
+// ImportModule creates a module from a bundle.
+// @Summary      Import Module Bundle
+// @Description  Creates a new module from a bundle exported by another instance, with new IDs (id_map maps bundle IDs to them), then clones its repository. Page slugs already used here, duplicated slugs and secrets that cannot be decrypted are conflicts: nothing is created and the report is returned with 409; rename pages with page_slugs. Roles missing here are skipped with a warning. dry_run only returns the report.
+// @Tags         Modules
+// @Accept       json
+// @Produce      json
+// @Param        input  body      ModuleImportInput        true  "Bundle and options"
+// @Success      200    {object}  core.ModuleImportReport  "Dry run"
+// @Success      201    {object}  core.ModuleImportReport  "Imported"
+// @Failure      400    {string}  string                   "Invalid bundle"
+// @Failure      409    {object}  core.ModuleImportReport  "Conflicts"
+// @Failure      500    {string}  string                   "Internal server error"
+// @Router       /admin/modules/import [post]
+func ImportModule(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var input ModuleImportInput
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBundleBody)).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	actor := requestActor(r)
+	report, err := core.ImportModuleBundle(r.Context(), input.Bundle, core.ModuleImportOptions{
+		Name:      input.Name,
+		SSHKeyID:  input.SSHKeyID,
+		PageSlugs: input.PageSlugs,
+		DryRun:    input.DryRun,
+	}, actor)
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, core.ErrConflict):
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(report)
+		default:
+			log.Printf("error importing module bundle: %v\n", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	if input.DryRun {
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	if module, err := core.GetModule(report.ModuleID); err == nil {
+		if _, err := core.EnqueueModuleJob(r.Context(), module, core.ModuleJobClone, core.ModuleJobParams{}, actor); err != nil {
+			log.Printf("error queueing clone of %s: %v\n", module.ID, err)
+		}
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(report)
+}
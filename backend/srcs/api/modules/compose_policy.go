@@ -0,0 +1,166 @@
+package modules
+
+import (
+	"backend/core"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// LintModuleConfig checks the saved compose file of a module against the policy.
+// @Summary      Lint Module Compose File
+// @Description  Parses the module docker-compose.yml as deployed (variables and relative paths resolved) and lists policy violations: privileged containers, host namespaces, Docker socket and host system mounts, dangerous capabilities, unconfined security options (errors), other host bind mounts and devices (warnings). allowed is false when a deploy would be rejected.
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {object}  core.ComposePolicyReport
+// @Failure      400       {string}  string  "Invalid compose file"
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/docker/config/lint [get]
+func LintModuleConfig(w http.ResponseWriter, r *http.Request) {
+	lintModuleConfig(w, r, "")
+}
+
+// LintModuleConfigDraft checks a compose file before it is saved.
+// @Summary      Lint Draft Module Compose File
+// @Description  Same as the GET variant, for a compose file that would replace the saved one.
+// @Tags         Modules
+// @Accept       json
+// @Produce      json
+// @Param        moduleID  path      string          true  "Module ID"
+// @Param        input     body      ComposeRequest  true  "Compose file"
+// @Success      200       {object}  core.ComposePolicyReport
+// @Failure      400       {string}  string  "Invalid compose file"
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/docker/config/lint [post]
+func LintModuleConfigDraft(w http.ResponseWriter, r *http.Request) {
+	var input ComposeRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Config == "" {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+	lintModuleConfig(w, r, input.Config)
+}
+
+func lintModuleConfig(w http.ResponseWriter, r *http.Request, content string) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	module, err := core.GetModule(moduleID)
+	if err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	report, err := core.LintModuleCompose(r.Context(), module, content)
+	if err != nil {
+		if errors.Is(err, core.ErrInvalidInput) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("error linting compose file of %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetModulePolicyOverrides lists the policy rules allowed for a module.
+// @Summary      List Module Compose Policy Overrides
+// @Description  Returns the overrides of the module with who granted them and why, and the rules that can be overridden.
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {object}  ModulePolicyOverridesResponse
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/docker/config/policy-overrides [get]
+func GetModulePolicyOverrides(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	overrides, err := core.ListModulePolicyOverrides(r.Context(), moduleID)
+	if err != nil {
+		log.Printf("error listing policy overrides for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(ModulePolicyOverridesResponse{Overrides: overrides, Rules: core.ComposePolicyRules()})
+}
+
+// PutModulePolicyOverride allows a policy rule for a module.
+// @Summary      Override Module Compose Policy Rule
+// @Description  Violations of the rule no longer block deploys of this module; they are still written to the deploy log. The reason and the admin are recorded.
+// @Tags         Modules
+// @Accept       json
+// @Produce      json
+// @Param        moduleID  path      string                      true  "Module ID"
+// @Param        rule      path      string                      true  "Rule ID"
+// @Param        input     body      ModulePolicyOverrideInput   true  "Reason"
+// @Success      200       {object}  core.ModulePolicyOverride
+// @Failure      400       {string}  string  "Invalid input"
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/docker/config/policy-overrides/{rule} [put]
+func PutModulePolicyOverride(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	var input ModulePolicyOverrideInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	override, err := core.SetModulePolicyOverride(r.Context(), moduleID, chi.URLParam(r, "rule"), input.Reason, requestActor(r))
+	if err != nil {
+		if errors.Is(err, core.ErrInvalidInput) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("error overriding policy rule for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(override)
+}
+
+// DeleteModulePolicyOverride removes a policy override of a module.
+// @Summary      Remove Module Compose Policy Override
+// @Tags         Modules
+// @Param        moduleID  path      string  true  "Module ID"
+// @Param        rule      path      string  true  "Rule ID"
+// @Success      204       "No Content"
+// @Failure      404       {string}  string  "Override not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/docker/config/policy-overrides/{rule} [delete]
+func DeleteModulePolicyOverride(w http.ResponseWriter, r *http.Request) {
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	if err := core.DeleteModulePolicyOverride(r.Context(), moduleID, chi.URLParam(r, "rule"), requestActor(r)); err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			http.Error(w, "Override not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("error removing policy override for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
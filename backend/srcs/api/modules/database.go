@@ -0,0 +1,116 @@
+package modules
+
+import (
+	"backend/core"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetModuleDatabase returns the managed database of a module.
+// @Summary      Get Module Database
+// @Description  The credentials are only available to the module, in its secrets.
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {object}  core.ModuleDatabase
+// @Failure      404       {string}  string  "module not found or no managed database"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/database [get]
+func GetModuleDatabase(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	db, err := core.GetModuleDatabase(r.Context(), moduleID)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			http.Error(w, "no managed database", http.StatusNotFound)
+			return
+		}
+		log.Printf("error getting managed database for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(db)
+}
+
+// PostModuleDatabase provisions a managed database for a module.
+// @Summary      Provision Module Database
+// @Description  Creates a database and its owner role in the platform Postgres, and an internal network joining that Postgres to the module. DATABASE_URL, PGHOST, PGPORT, PGDATABASE, PGUSER and PGPASSWORD are set as module secrets, and every service joins the network from the next deploy. On a module that already has a database, the password is rotated and the network and secrets are repaired.
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {object}  core.ModuleDatabase  "Password rotated"
+// @Success      201       {object}  core.ModuleDatabase  "Provisioned"
+// @Failure      404       {string}  string  "module not found"
+// @Failure      409       {string}  string  "Provisioning already in progress"
+// @Failure      500       {string}  string  "Internal server error"
+// @Failure      503       {string}  string  "Managed databases are disabled"
+// @Router       /admin/modules/{moduleID}/database [post]
+func PostModuleDatabase(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	mod, err := core.GetModule(moduleID)
+	if err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	db, created, err := core.ProvisionModuleDatabase(r.Context(), mod, requestActor(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrManagedDatabaseDisabled):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		case errors.Is(err, core.ErrConflict):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Printf("error provisioning managed database for %s: %v\n", moduleID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(db)
+}
+
+// DeleteModuleDatabase takes a module off its managed database.
+// @Summary      Deprovision Module Database
+// @Description  Locks the role out and removes the credentials from the module secrets. The database is then dumped to MANAGED_DB_DUMP_DIR and dropped; the dump is kept for MANAGED_DB_DUMP_RETENTION. Deleting a module does the same.
+// @Tags         Modules
+// @Param        moduleID  path  string  true  "Module ID"
+// @Success      204       "No Content"
+// @Failure      404       {string}  string  "module not found or no managed database"
+// @Failure      500       {string}  string  "Internal server error"
+// @Failure      503       {string}  string  "Managed databases are disabled"
+// @Router       /admin/modules/{moduleID}/database [delete]
+func DeleteModuleDatabase(w http.ResponseWriter, r *http.Request) {
+	moduleID := chi.URLParam(r, "moduleID")
+	mod, err := core.GetModule(moduleID)
+	if err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	if err := core.DeprovisionModuleDatabase(r.Context(), mod, requestActor(r)); err != nil {
+		switch {
+		case errors.Is(err, core.ErrNotFound):
+			http.Error(w, "no managed database", http.StatusNotFound)
+		case errors.Is(err, core.ErrManagedDatabaseDisabled):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		default:
+			log.Printf("error deprovisioning managed database for %s: %v\n", moduleID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
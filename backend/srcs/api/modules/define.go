@@ -2,6 +2,7 @@ package modules
 
 import (
 	api "backend/api/dto"
+	"backend/core"
 	"time"
 )
 
@@ -107,6 +108,8 @@ type ModuleGitInput struct {
 	GitBranch string `json:"git_branch,omitempty" example:"main"`
 	// SSHKeyID allows reusing an existing SSH key managed by Pan Bagnat. Leave empty to generate a new one.
 	SSHKeyID string `json:"ssh_key_id,omitempty" example:"ssh-key_01H..."`
+	// GitCredentialID clones an https:// URL with an HTTPS token instead of the SSH key.
+	GitCredentialID string `json:"git_credential_id,omitempty" example:"git-credential_01H..."`
 }
 
 // ModuleRemoteUpdateInput describes the payload for updating a module’s Git remote.
@@ -145,3 +148,197 @@ type ModulePageInput struct {
 	// NetworkName is the docker network the proxy should join for this page (optional)
 	NetworkName *string `json:"network_name,omitempty" example:"piscine-monitor-net"`
 }
+
+// ModuleManifestApplyInput confirms the manifest diff an admin reviewed.
+// swagger:model ModuleManifestApplyInput
+type ModuleManifestApplyInput struct {
+	// ManifestHash is the manifest_hash returned by GET /manifest.
+	ManifestHash string `json:"manifest_hash" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+}
+
+// ModuleSecretInput carries a secret value. It is never returned by the API.
+// swagger:model ModuleSecretInput
+type ModuleSecretInput struct {
+	// Value is the plaintext secret, encrypted before being stored.
+	Value string `json:"value" example:"s3cr3t-db-password"`
+}
+
+// ModuleUpdatePolicyInput configures automatic updates of a module.
+// swagger:model ModuleUpdatePolicyInput
+type ModuleUpdatePolicyInput struct {
+	// Mode is one of manual, notify, auto_pull or auto_pull_deploy.
+	Mode string `json:"mode" example:"auto_pull_deploy"`
+	// CheckIntervalSeconds is how often the upstream is fetched (minimum 300, default 3600).
+	CheckIntervalSeconds int `json:"check_interval_seconds,omitempty" example:"3600"`
+	// Timezone is the IANA zone maintenance windows are expressed in (default UTC).
+	Timezone string `json:"timezone,omitempty" example:"Europe/Paris"`
+	// MaintenanceWindows restricts automatic pulls and deploys; empty means any time.
+	MaintenanceWindows []core.MaintenanceWindow `json:"maintenance_windows,omitempty"`
+}
+
+// ModuleRollbackInput optionally selects the deployment to roll back to.
+// swagger:model ModuleRollbackInput
+type ModuleRollbackInput struct {
+	// DeploymentID is a previous successful deployment. Defaults to the last
+	// successful deployment of a commit other than the current one.
+	DeploymentID string `json:"deployment_id,omitempty" example:"deployment_01HZ0MMK4S6VQW4WPHB6NZ7R7X"`
+}
+
+// ModuleRollbackResponse is returned once a rollback is queued.
+// swagger:model ModuleRollbackResponse
+type ModuleRollbackResponse struct {
+	Target core.ModuleDeployment `json:"target"`
+	Job    core.ModuleJob        `json:"job"`
+}
+
+// PageHealthCheckInput configures the HTTP probe of a page.
+// swagger:model PageHealthCheckInput
+type PageHealthCheckInput struct {
+	// Enabled defaults to true.
+	Enabled         *bool  `json:"enabled,omitempty" example:"true"`
+	Path            string `json:"path" example:"/healthz"`
+	ExpectedStatus  int    `json:"expected_status" example:"200"`
+	IntervalSeconds int    `json:"interval_seconds" example:"60"`
+	TimeoutSeconds  int    `json:"timeout_seconds" example:"5"`
+}
+
+// ModuleDeploySettingsInput selects how a module is redeployed.
+// swagger:model ModuleDeploySettingsInput
+type ModuleDeploySettingsInput struct {
+	// Strategy is recreate (default) or blue_green.
+	Strategy string `json:"strategy" example:"blue_green"`
+	// HealthTimeoutSeconds bounds how long a blue/green deploy waits for the
+	// new stack before rolling back (10 to 3600, default 120).
+	HealthTimeoutSeconds int `json:"health_timeout_seconds,omitempty" example:"120"`
+}
+
+// ModuleWebhookInput enables or disables a module push webhook.
+// swagger:model ModuleWebhookInput
+type ModuleWebhookInput struct {
+	Enabled *bool `json:"enabled" example:"true"`
+}
+
+// ModuleResourceLimitsInput sets the per-container limits of a module. A
+// missing or null field removes that limit.
+// swagger:model ModuleResourceLimitsInput
+type ModuleResourceLimitsInput struct {
+	CPUs     *float64 `json:"cpus,omitempty" example:"1.5"`
+	MemoryMB *int     `json:"memory_mb,omitempty" example:"512"`
+	Pids     *int     `json:"pids,omitempty" example:"256"`
+	DiskMB   *int     `json:"disk_mb,omitempty" example:"2048"`
+}
+
+// ModulePolicyOverrideInput records why a compose policy rule is allowed.
+// swagger:model ModulePolicyOverrideInput
+type ModulePolicyOverrideInput struct {
+	Reason string `json:"reason" example:"Monitoring agent needs read access to the Docker socket"`
+}
+
+// ModulePolicyOverridesResponse lists the overrides of a module and the
+// rules of the compose policy.
+// swagger:model ModulePolicyOverridesResponse
+type ModulePolicyOverridesResponse struct {
+	Overrides []core.ModulePolicyOverride `json:"overrides"`
+	Rules     []core.ComposePolicyRule    `json:"rules"`
+}
+
+// ModuleImportInput is a bundle from GET /admin/modules/{id}/export and how
+// to import it.
+// swagger:model ModuleImportInput
+type ModuleImportInput struct {
+	Bundle core.ModuleBundle `json:"bundle"`
+	// Name overrides the module name from the bundle.
+	Name string `json:"name,omitempty" example:"Wiki"`
+	// SSHKeyID reuses an existing deploy key instead of generating one.
+	SSHKeyID string `json:"ssh_key_id,omitempty"`
+	// PageSlugs renames pages, from bundle slug to new slug.
+	PageSlugs map[string]string `json:"page_slugs,omitempty"`
+	DryRun    bool              `json:"dry_run,omitempty"`
+}
+
+// ModuleTemplateInput registers or updates a template repository.
+// swagger:model ModuleTemplateInput
+type ModuleTemplateInput struct {
+	Name        string `json:"name" example:"Static site"`
+	Description string `json:"description,omitempty" example:"nginx serving ./public"`
+	GitURL      string `json:"git_url" example:"git@github.com:pan-bagnat/template-static.git"`
+	GitBranch   string `json:"git_branch,omitempty" example:"main"`
+	// SSHKeyID clones private templates; public ones need none.
+	SSHKeyID    string `json:"ssh_key_id,omitempty"`
+	DefaultPort int    `json:"default_port,omitempty" example:"80"`
+}
+
+// ModuleFromTemplateInput creates a module from a template.
+// swagger:model ModuleFromTemplateInput
+type ModuleFromTemplateInput struct {
+	Name string `json:"name" example:"Club wiki"`
+	// Port replaces {{module_port}}; the template's default port when empty.
+	Port int `json:"port,omitempty" example:"3000"`
+	// GitURL is an optional empty remote, set as origin of the new repository.
+	GitURL    string `json:"git_url,omitempty" example:"git@github.com:club/wiki.git"`
+	GitBranch string `json:"git_branch,omitempty" example:"main"`
+	SSHKeyID  string `json:"ssh_key_id,omitempty"`
+	// Push pushes the first commit to git_url with the module's SSH key.
+	Push bool `json:"push,omitempty"`
+}
+
+// ModuleFromTemplateResponse is the new module and the job building its repository.
+// swagger:model ModuleFromTemplateResponse
+type ModuleFromTemplateResponse struct {
+	Module api.Module     `json:"module"`
+	Job    core.ModuleJob `json:"job"`
+}
+
+// ModuleGitCredentialInput selects the HTTPS credential of a module.
+// swagger:model ModuleGitCredentialInput
+type ModuleGitCredentialInput struct {
+	// GitCredentialID is empty to use the module's SSH key.
+	GitCredentialID string `json:"git_credential_id" example:"git-credential_01HZ0MMK4S6VQW4WPHB6NZ7R7X"`
+}
+
+// ModuleGitOptionsInput sets the opt-in repository features of a module.
+// swagger:model ModuleGitOptionsInput
+type ModuleGitOptionsInput struct {
+	Submodules bool `json:"submodules" example:"true"`
+	LFS        bool `json:"lfs" example:"false"`
+}
+
+// GitCommitInput commits the staged changes of a module working tree.
+// swagger:model GitCommitInput
+type GitCommitInput struct {
+	Message string `json:"message" example:"Fix healthcheck path"`
+	All     bool   `json:"all" example:"false"`
+	Amend   bool   `json:"amend" example:"false"`
+}
+
+// ModuleReleaseTrackingInput makes a module follow git tags matching a
+// semver constraint. An empty constraint follows the branch again.
+// swagger:model ModuleReleaseTrackingInput
+type ModuleReleaseTrackingInput struct {
+	Constraint  string `json:"constraint" example:"^1.2"`
+	Prereleases bool   `json:"prereleases" example:"false"`
+}
+
+// ModulePreviewInput previews a branch of a module. ttl_seconds overrides the
+// lifetime from the preview settings.
+// swagger:model ModulePreviewInput
+type ModulePreviewInput struct {
+	Branch     string `json:"branch" example:"feature-x"`
+	TTLSeconds int    `json:"ttl_seconds,omitempty" example:"86400"`
+}
+
+// swagger:model ModulePreviewResponse
+type ModulePreviewResponse struct {
+	Preview core.ModulePreview `json:"preview"`
+	Jobs    []core.ModuleJob   `json:"jobs"`
+}
+
+// ModulePreviewSettingsInput sets the preview lifetime, maintainers and
+// copied secrets of a module.
+// swagger:model ModulePreviewSettingsInput
+type ModulePreviewSettingsInput struct {
+	TTLSeconds        int      `json:"ttl_seconds" example:"604800"`
+	MaintainerRoleIDs []string `json:"maintainer_role_ids" example:"role_01HZXYZDE0470"`
+	// PreviewSecrets are the module secrets copied into new previews; none when empty.
+	PreviewSecrets []string `json:"preview_secrets" example:"SENTRY_DSN"`
+}
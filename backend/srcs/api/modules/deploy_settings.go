@@ -0,0 +1,83 @@
+package modules
+
+import (
+	"backend/core"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetModuleDeploySettings returns the deploy strategy of a module.
+// @Summary      Get Module Deploy Strategy
+// @Description  Returns the deploy strategy and the compose project currently serving the module. Modules without settings use recreate.
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {object}  core.ModuleDeploySettings
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/deploy-strategy [get]
+func GetModuleDeploySettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	module, err := core.GetModule(moduleID)
+	if err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	settings, err := core.GetModuleDeploySettings(r.Context(), module)
+	if err != nil {
+		log.Printf("error getting deploy settings for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(settings)
+}
+
+// PutModuleDeploySettings sets the deploy strategy of a module.
+// @Summary      Set Module Deploy Strategy
+// @Description  recreate runs compose up in place. blue_green starts the new stack under a second compose project, waits for its containers and pages to be healthy, switches the pages to it and removes the old stack; if health never goes green within health_timeout_seconds the pages stay on the old stack. Modules with container_name or published host ports are always recreated.
+// @Tags         Modules
+// @Accept       json
+// @Produce      json
+// @Param        moduleID  path      string                     true  "Module ID"
+// @Param        input     body      ModuleDeploySettingsInput  true  "Strategy"
+// @Success      200       {object}  core.ModuleDeploySettings
+// @Failure      400       {string}  string  "Invalid input"
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/deploy-strategy [put]
+func PutModuleDeploySettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	module, err := core.GetModule(moduleID)
+	if err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	var input ModuleDeploySettingsInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	settings, err := core.SetModuleDeploySettings(r.Context(), module, core.ModuleDeploySettings{
+		Strategy:             input.Strategy,
+		HealthTimeoutSeconds: input.HealthTimeoutSeconds,
+	})
+	if err != nil {
+		if errors.Is(err, core.ErrInvalidInput) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("error setting deploy settings for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(settings)
+}
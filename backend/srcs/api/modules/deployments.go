@@ -0,0 +1,158 @@
+package modules
+
+import (
+	"backend/api/auth"
+	"backend/core"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func requestActor(r *http.Request) *core.User {
+	user, _ := r.Context().Value(auth.UserCtxKey).(*core.User)
+	return user
+}
+
+// GetModuleDeployments lists the deployment history of a module.
+// @Summary      List Module Deployments
+// @Description  Returns the deployments of a module, most recent first, with actor, commits, compose file hash, duration and status.
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID  path      string  true   "Module ID"
+// @Param        limit     query     int     false  "Max number of deployments (default 50)"
+// @Success      200       {array}   core.ModuleDeployment
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/deployments [get]
+func GetModuleDeployments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	deployments, err := core.ListModuleDeployments(r.Context(), moduleID, limit)
+	if err != nil {
+		log.Printf("error listing deployments for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(deployments)
+}
+
+// GetModuleDeployment returns one deployment of a module.
+// @Summary      Get Module Deployment
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID      path      string  true  "Module ID"
+// @Param        deploymentID  path      string  true  "Deployment ID"
+// @Success      200           {object}  core.ModuleDeployment
+// @Failure      404           {string}  string  "Deployment not found"
+// @Failure      500           {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/deployments/{deploymentID} [get]
+func GetModuleDeployment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	deploymentID := chi.URLParam(r, "deploymentID")
+
+	deployment, err := core.GetModuleDeployment(r.Context(), moduleID, deploymentID)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			http.Error(w, "Deployment not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("error getting deployment %s: %v\n", deploymentID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(deployment)
+}
+
+// GetModuleDeploymentLogs returns the log lines written during a deployment.
+// @Summary      Get Module Deployment Logs
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID      path      string  true  "Module ID"
+// @Param        deploymentID  path      string  true  "Deployment ID"
+// @Success      200           {array}   core.ModuleLog
+// @Failure      404           {string}  string  "Deployment not found"
+// @Failure      500           {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/deployments/{deploymentID}/logs [get]
+func GetModuleDeploymentLogs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	deploymentID := chi.URLParam(r, "deploymentID")
+
+	if _, err := core.GetModuleDeployment(r.Context(), moduleID, deploymentID); err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			http.Error(w, "Deployment not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("error getting deployment %s: %v\n", deploymentID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	logs, err := core.GetDeploymentLogs(r.Context(), deploymentID)
+	if err != nil {
+		log.Printf("error getting logs of deployment %s: %v\n", deploymentID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(logs)
+}
+
+// RollbackModuleDeployment queues a job that checks out a previous successful commit and redeploys it.
+// @Summary      Roll Back Module
+// @Description  Queues a job that checks out the commit of the previous successful deployment (or the one given) and redeploys it. Refused when the working tree has local changes or conflicts.
+// @Tags         Modules
+// @Accept       json
+// @Produce      json
+// @Param        moduleID  path      string               true   "Module ID"
+// @Param        input     body      ModuleRollbackInput  false  "Target deployment"
+// @Success      202       {object}  ModuleRollbackResponse  "Target deployment and queued job"
+// @Failure      400       {string}  string  "Invalid input"
+// @Failure      404       {string}  string  "No deployment to roll back to"
+// @Failure      409       {string}  string  "Dirty working tree"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/deployments/rollback [post]
+func RollbackModuleDeployment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	module, err := core.GetModule(moduleID)
+	if err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	var input ModuleRollbackInput
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+			return
+		}
+	}
+
+	target, job, err := core.RollbackModule(r.Context(), module, input.DeploymentID, requestActor(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, core.ErrConflict):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, core.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			log.Printf("error rolling back %s: %v\n", moduleID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(ModuleRollbackResponse{Target: target, Job: job})
+}
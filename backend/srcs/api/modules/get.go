@@ -111,6 +111,11 @@ func GetModule(w http.ResponseWriter, r *http.Request) {
 	// Do not refresh git here to avoid blocking settings; use dedicated endpoints instead
 
 	dest := api.ModuleToAPIModule(module)
+	if health, err := core.GetModuleHealth(r.Context(), module.ID); err == nil {
+		dest.Health = &health
+	} else {
+		log.Printf("Failed fetching module health: %s\n", err.Error())
+	}
 	destJSON, err := json.Marshal(dest)
 	if err != nil {
 		http.Error(w, "Failed to convert struct to JSON", http.StatusInternalServerError)
@@ -527,12 +532,13 @@ func GetAllContainers(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(items)
 }
 
-// ComposeDeploy triggers docker compose build && up using the repo's docker-compose.yml
+// ComposeDeploy queues a docker compose build && up using the repo's docker-compose.yml
 // @Security     SessionAuth
 // @Summary      Compose Deploy
 // @Tags         Docker
+// @Produce      json
 // @Param        moduleID   path      string  true  "Module ID"
-// @Success      202        "Deployment started"
+// @Success      202        {object}  core.ModuleJob  "Deployment queued"
 // @Router       /admin/modules/{moduleID}/docker/compose/deploy [post]
 func ComposeDeploy(w http.ResponseWriter, r *http.Request) {
 	moduleID := chi.URLParam(r, "moduleID")
@@ -541,8 +547,15 @@ func ComposeDeploy(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "module not found", http.StatusNotFound)
 		return
 	}
-	go core.DeployModule(module)
+	job, err := core.EnqueueModuleJob(r.Context(), module, core.ModuleJobDeploy, core.ModuleJobParams{}, requestActor(r))
+	if err != nil {
+		log.Printf("error queueing deploy of %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
 }
 
 // DeleteContainerGlobal removes a container by name regardless of module scope (for orphans management).
@@ -565,7 +578,7 @@ func DeleteContainerGlobal(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// ComposeRebuild triggers a build --no-cache and up -d for the module.
+// ComposeRebuild runs a build --no-cache and up -d for the module through its job queue.
 // @Security     SessionAuth
 // @Summary      Compose Rebuild
 // @Tags         Docker
@@ -579,14 +592,14 @@ func ComposeRebuild(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "module not found", http.StatusNotFound)
 		return
 	}
-	if err := core.ComposeRebuild(module); err != nil {
+	if _, err := core.RunModuleJob(r.Context(), module, core.ModuleJobRebuild, core.ModuleJobParams{}, requestActor(r)); err != nil {
 		http.Error(w, fmt.Sprintf("rebuild failed: %v", err), http.StatusInternalServerError)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// ComposeDown performs docker compose down --remove-orphans for the module project.
+// ComposeDown performs docker compose down --remove-orphans for the module project through its job queue.
 // @Security     SessionAuth
 // @Summary      Compose Down
 // @Tags         Docker
@@ -600,7 +613,7 @@ func ComposeDown(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "module not found", http.StatusNotFound)
 		return
 	}
-	if err := core.ComposeDown(module); err != nil {
+	if _, err := core.RunModuleJob(r.Context(), module, core.ModuleJobDown, core.ModuleJobParams{}, requestActor(r)); err != nil {
 		http.Error(w, fmt.Sprintf("down failed: %v", err), http.StatusInternalServerError)
 		return
 	}
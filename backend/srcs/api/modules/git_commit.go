@@ -3,6 +3,8 @@ package modules
 import (
 	"backend/core"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"os/exec"
 
@@ -78,3 +80,94 @@ func GitLatestCommit(w http.ResponseWriter, r *http.Request) {
 	logSSHKeyUsage(r, mod, "git show upstream")
 	_ = json.NewEncoder(w).Encode(map[string]string{"hash": hash, "subject": subj})
 }
+
+// GitCommitHandler commits the staged changes of a module working tree.
+// @Summary      Commit Module Changes
+// @Description  Commits what is staged (everything when all is set) with the acting user as author. amend replaces HEAD, keeping its author and, without message, its message; it is refused once HEAD is on the upstream branch. Nothing is pushed. Returns 409 while the module has a queued or running job.
+// @Tags         Modules,Git
+// @Accept       json
+// @Produce      json
+// @Param        moduleID  path      string          true  "Module ID"
+// @Param        input     body      GitCommitInput  true  "Commit"
+// @Success      201       {object}  core.GitCommit
+// @Failure      400       {string}  string  "Invalid input"
+// @Failure      404       {string}  string  "module not found"
+// @Failure      409       {string}  string  "Nothing staged, merge in progress, HEAD already pushed or a module job is active"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/git/commit [post]
+func GitCommitHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	mod, err := core.GetModule(moduleID)
+	if err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+	var input GitCommitInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	commit, err := core.GitCommitModule(mod, core.GitCommitInput{
+		Message: input.Message,
+		All:     input.All,
+		Amend:   input.Amend,
+	}, requestActor(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, core.ErrConflict):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Printf("error committing in %s: %v\n", moduleID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(commit)
+}
+
+// GitPushHandler pushes the current branch of a module to origin.
+// @Summary      Push Module Branch
+// @Description  Pushes the checked out branch to origin with the module's SSH key or HTTPS credential. A push refused by the remote (diverged branch, protected branch, hook) returns 409 with the reason, a hint and the remote's messages; the local commits are kept. A push that could not reach the remote or was not authorized returns 502. Returns 409 while the module has a queued or running job.
+// @Tags         Modules,Git
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {object}  core.GitPushResult
+// @Failure      404       {string}  string  "module not found"
+// @Failure      409       {object}  core.GitPushResult
+// @Failure      502       {object}  core.GitPushResult
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/git/push [post]
+func GitPushHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	mod, err := core.GetModule(moduleID)
+	if err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	res, err := core.GitPushModule(mod, requestActor(r))
+	logSSHKeyUsage(r, mod, "git push")
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrPushRejected):
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(res)
+		case errors.Is(err, core.ErrConflict):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case res.Status == core.GitPushFailed:
+			w.WriteHeader(http.StatusBadGateway)
+			json.NewEncoder(w).Encode(res)
+		default:
+			log.Printf("error pushing %s: %v\n", moduleID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,81 @@
+package modules
+
+import (
+	"backend/core"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetModuleGitCredential returns the HTTPS credential selected for a module.
+// @Summary      Get Module Git Credential
+// @Description  Returns the HTTPS credential the module uses for its https:// remote, or null when git uses the module's SSH key.
+// @Tags         Modules,Git
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {object}  core.GitCredential
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/git/credential [get]
+func GetModuleGitCredential(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	cred, err := core.GetModuleGitCredential(r.Context(), moduleID)
+	if err != nil {
+		log.Printf("error getting git credential for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(cred)
+}
+
+// PutModuleGitCredential selects the HTTPS credential of a module.
+// @Summary      Set Module Git Credential
+// @Description  The credential is fed to git through a credential helper for https:// remotes; SSH remotes keep using the module's SSH key. An empty git_credential_id goes back to the SSH key.
+// @Tags         Modules,Git
+// @Accept       json
+// @Produce      json
+// @Param        moduleID  path      string                       true  "Module ID"
+// @Param        input     body      ModuleGitCredentialInput     true  "Credential to use"
+// @Success      200       {object}  core.GitCredential
+// @Failure      400       {string}  string  "Invalid input"
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/git/credential [put]
+func PutModuleGitCredential(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	var input ModuleGitCredentialInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	cred, err := core.SetModuleGitCredential(r.Context(), moduleID, input.GitCredentialID, requestActor(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, core.ErrNotFound):
+			http.Error(w, "module not found", http.StatusNotFound)
+		default:
+			log.Printf("error setting git credential for %s: %v\n", moduleID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	json.NewEncoder(w).Encode(cred)
+}
@@ -0,0 +1,112 @@
+package modules
+
+import (
+	"backend/core"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GitDiff returns a structured diff of a module repository.
+// @Summary      Get Module Git Diff
+// @Description  kind=working compares the working tree and index to HEAD, untracked files included; kind=upstream compares HEAD to its upstream branch as last fetched; kind=commits compares from to to (HEAD by default). Files come with their status, line counts and hunks, each line with its old and new line numbers. Diffs over 4 MiB are cut at a file boundary and marked truncated.
+// @Tags         Modules,Git
+// @Produce      json
+// @Param        moduleID  path      string  true   "Module ID"
+// @Param        kind      query     string  false  "working (default), upstream or commits"
+// @Param        from      query     string  false  "Base ref, for kind=commits"
+// @Param        to        query     string  false  "Target ref, for kind=commits (default HEAD)"
+// @Param        path      query     string  false  "Limit the diff to a file or directory"
+// @Param        context   query     int     false  "Unchanged lines around changes (default 3, max 50)"
+// @Success      200       {object}  core.GitDiff
+// @Failure      400       {string}  string  "Invalid input"
+// @Failure      404       {string}  string  "module not found"
+// @Failure      409       {string}  string  "No upstream branch or no commit yet"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/git/diff [get]
+func GitDiff(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	mod, err := core.GetModule(moduleID)
+	if err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+	q := r.URL.Query()
+	req := core.GitDiffRequest{
+		Kind:    q.Get("kind"),
+		From:    q.Get("from"),
+		To:      q.Get("to"),
+		Path:    q.Get("path"),
+		Context: -1,
+	}
+	if req.Kind == "" {
+		req.Kind = core.GitDiffWorking
+	}
+	if v := q.Get("context"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "context must be a positive number", http.StatusBadRequest)
+			return
+		}
+		req.Context = n
+	}
+
+	diff, err := core.GitDiffModule(mod, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, core.ErrConflict):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Printf("error diffing %s: %v\n", moduleID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	json.NewEncoder(w).Encode(diff)
+}
+
+// GitFileAtRef returns the content of a file at a revision.
+// @Summary      Get Module File At Ref
+// @Description  Reads a file as committed at ref (HEAD by default). Text is returned as is; binary content is base64 encoded. Files over 5 MiB are refused.
+// @Tags         Modules,Git
+// @Produce      json
+// @Param        moduleID  path      string  true   "Module ID"
+// @Param        path      query     string  true   "File path in the repository"
+// @Param        ref       query     string  false  "Commit, branch or tag (default HEAD)"
+// @Success      200       {object}  core.GitFileAtRef
+// @Failure      400       {string}  string  "Invalid input"
+// @Failure      404       {string}  string  "File not found at ref"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/git/file [get]
+func GitFileAtRef(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	mod, err := core.GetModule(moduleID)
+	if err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+	ref, path := r.URL.Query().Get("ref"), r.URL.Query().Get("path")
+
+	file, err := core.GitReadFileAtRef(mod, ref, path)
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, core.ErrNotFound):
+			http.Error(w, "File not found at ref", http.StatusNotFound)
+		default:
+			log.Printf("error reading %s at %s for %s: %v\n", path, ref, moduleID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	json.NewEncoder(w).Encode(file)
+}
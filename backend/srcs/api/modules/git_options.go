@@ -0,0 +1,75 @@
+package modules
+
+import (
+	"backend/core"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetModuleGitOptions returns the repository options of a module.
+// @Summary      Get Module Git Options
+// @Tags         Modules,Git
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {object}  core.ModuleGitOptions
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/git/options [get]
+func GetModuleGitOptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	opts, err := core.GetModuleGitOptions(r.Context(), moduleID)
+	if err != nil {
+		log.Printf("error getting git options for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(opts)
+}
+
+// PutModuleGitOptions sets the repository options of a module.
+// @Summary      Set Module Git Options
+// @Description  submodules initializes and updates submodules recursively, with the module's credentials; lfs downloads Git LFS objects (git-lfs must be installed in the backend image). Both apply on the next clone, pull or checkout.
+// @Tags         Modules,Git
+// @Accept       json
+// @Produce      json
+// @Param        moduleID  path      string                 true  "Module ID"
+// @Param        input     body      ModuleGitOptionsInput  true  "Options"
+// @Success      200       {object}  core.ModuleGitOptions
+// @Failure      400       {string}  string  "Invalid JSON input"
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/git/options [put]
+func PutModuleGitOptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	var input ModuleGitOptionsInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	opts, err := core.SetModuleGitOptions(r.Context(), moduleID, core.ModuleGitOptions{
+		Submodules: input.Submodules,
+		LFS:        input.LFS,
+	})
+	if err != nil {
+		log.Printf("error setting git options for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(opts)
+}
@@ -4,107 +4,22 @@ import (
 	"backend/core"
 	"backend/database"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
-	"mime"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"log"
 )
 
-func ensureIconDir() (string, error) {
-	dir := "./assets/module-icons"
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return "", err
-	}
-	return dir, nil
-}
-
-func ensurePageIconDir() (string, error) {
-	dir := "./assets/page-icons"
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return "", err
-	}
-	return dir, nil
-}
-
-func detectImageExt(data []byte) string {
-	ct := http.DetectContentType(data)
-	switch ct {
-	case "image/png":
-		return ".png"
-	case "image/jpeg":
-		return ".jpg"
-	case "image/webp":
-		return ".webp"
-	case "image/gif":
-		return ".gif"
-	default:
-		// try by sniffing from header
-		if strings.Contains(ct, "png") {
-			return ".png"
-		}
-		if strings.Contains(ct, "jpeg") {
-			return ".jpg"
-		}
-		if strings.Contains(ct, "webp") {
-			return ".webp"
-		}
-		if strings.Contains(ct, "gif") {
-			return ".gif"
-		}
-		return ".png"
-	}
-}
-
-func saveModuleIcon(moduleID string, data []byte, hintedName string) (string, error) {
-	dir, err := ensureIconDir()
-	if err != nil {
-		return "", err
-	}
-	ext := filepath.Ext(hintedName)
-	if ext == "" || len(ext) > 5 {
-		ext = detectImageExt(data)
-		if ext == "" {
-			ext = ".png"
-		}
-	}
-	// Normalize ext
-	if mt := mime.TypeByExtension(ext); mt == "" {
-		ext = detectImageExt(data)
-	}
-	dst := filepath.Join(dir, fmt.Sprintf("%s%s", moduleID, ext))
-	if err := os.WriteFile(dst, data, 0o644); err != nil {
-		return "", err
-	}
-	// public URL
-	return "/assets/module-icons/" + filepath.Base(dst), nil
-}
-
-func savePageIcon(pageID string, data []byte, hintedName string) (string, error) {
-	dir, err := ensurePageIconDir()
-	if err != nil {
-		return "", err
-	}
-	ext := filepath.Ext(hintedName)
-	if ext == "" || len(ext) > 5 {
-		ext = detectImageExt(data)
-		if ext == "" {
-			ext = ".png"
-		}
-	}
-	if mt := mime.TypeByExtension(ext); mt == "" {
-		ext = detectImageExt(data)
-	}
-	dst := filepath.Join(dir, fmt.Sprintf("%s%s", pageID, ext))
-	if err := os.WriteFile(dst, data, 0o644); err != nil {
-		return "", err
+// iconSaveError rejects content that is not an accepted image with a 400.
+func iconSaveError(w http.ResponseWriter, err error) {
+	if errors.Is(err, core.ErrInvalidInput) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	return "/assets/page-icons/" + filepath.Base(dst), nil
+	http.Error(w, "failed to save icon", http.StatusInternalServerError)
 }
 
 // POST /admin/modules/{moduleID}/icon/upload (multipart/form-data: file)
@@ -115,7 +30,7 @@ func SetModuleIconUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	file, hdr, err := r.FormFile("file")
+	file, _, err := r.FormFile("file")
 	if err != nil {
 		http.Error(w, "missing file", http.StatusBadRequest)
 		return
@@ -128,9 +43,9 @@ func SetModuleIconUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	url, err := saveModuleIcon(moduleID, data, hdr.Filename)
+	url, err := core.SaveModuleIcon(moduleID, data)
 	if err != nil {
-		http.Error(w, "failed to save icon", http.StatusInternalServerError)
+		iconSaveError(w, err)
 		return
 	}
 
@@ -168,9 +83,9 @@ func SetModuleIconFromURL(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to read image", http.StatusBadRequest)
 		return
 	}
-	url, err := saveModuleIcon(moduleID, data, filepath.Base(body.URL))
+	url, err := core.SaveModuleIcon(moduleID, data)
 	if err != nil {
-		http.Error(w, "failed to save icon", http.StatusInternalServerError)
+		iconSaveError(w, err)
 		return
 	}
 	if _, err := core.PatchModule(core.ModulePatch{ID: moduleID, IconURL: &url}); err != nil {
@@ -206,9 +121,9 @@ func SetModuleIconFromRepo(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to read file from repo", http.StatusBadRequest)
 		return
 	}
-	url, err := saveModuleIcon(moduleID, data, filepath.Base(body.Path))
+	url, err := core.SaveModuleIcon(moduleID, data)
 	if err != nil {
-		http.Error(w, "failed to save icon", http.StatusInternalServerError)
+		iconSaveError(w, err)
 		return
 	}
 	if _, err := core.PatchModule(core.ModulePatch{ID: moduleID, IconURL: &url}); err != nil {
@@ -237,10 +152,10 @@ func SetPageIconUpload(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to read", http.StatusBadRequest)
 		return
 	}
-	url, err := savePageIcon(pageID, data, "upload")
+	url, err := core.SavePageIcon(pageID, data)
 	if err != nil {
 		log.Printf("page icon upload: save error: %v", err)
-		http.Error(w, "failed to save", http.StatusInternalServerError)
+		iconSaveError(w, err)
 		return
 	}
 	if _, err := database.PatchModulePage(database.ModulePagePatch{ID: pageID, IconURL: &url}); err != nil {
@@ -283,10 +198,10 @@ func SetPageIconFromURL(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to read", http.StatusBadRequest)
 		return
 	}
-	url, err := savePageIcon(pageID, data, filepath.Base(body.URL))
+	url, err := core.SavePageIcon(pageID, data)
 	if err != nil {
 		log.Printf("page icon url: save error: %v", err)
-		http.Error(w, "failed to save", http.StatusInternalServerError)
+		iconSaveError(w, err)
 		return
 	}
 	if _, err := database.PatchModulePage(database.ModulePagePatch{ID: pageID, IconURL: &url}); err != nil {
@@ -325,10 +240,10 @@ func SetPageIconFromRepo(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to read from repo", http.StatusBadRequest)
 		return
 	}
-	url, err := savePageIcon(pageID, data, filepath.Base(body.Path))
+	url, err := core.SavePageIcon(pageID, data)
 	if err != nil {
 		log.Printf("page icon repo: save error: %v", err)
-		http.Error(w, "failed to save", http.StatusInternalServerError)
+		iconSaveError(w, err)
 		return
 	}
 	if _, err := database.PatchModulePage(database.ModulePagePatch{ID: pageID, IconURL: &url}); err != nil {
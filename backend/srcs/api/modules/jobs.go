@@ -0,0 +1,111 @@
+package modules
+
+import (
+	"backend/core"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetModuleJobs lists the queued and past jobs of a module.
+// @Summary      List Module Jobs
+// @Description  Returns the jobs (deploy, rebuild, down, pull, clone, rollback) of a module, most recent first. Jobs of a module run one at a time, in queue order.
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID  path      string  true   "Module ID"
+// @Param        status    query     string  false  "Comma separated statuses (queued, running, succeeded, failed, cancelled)"
+// @Param        limit     query     int     false  "Max number of jobs (default 50)"
+// @Success      200       {array}   core.ModuleJob
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/jobs [get]
+func GetModuleJobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+	var statuses []string
+	for _, s := range strings.Split(r.URL.Query().Get("status"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			statuses = append(statuses, s)
+		}
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	jobs, err := core.ListModuleJobs(r.Context(), moduleID, statuses, limit)
+	if err != nil {
+		log.Printf("error listing jobs for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// GetModuleJob returns one job of a module.
+// @Summary      Get Module Job
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Param        jobID     path      string  true  "Job ID"
+// @Success      200       {object}  core.ModuleJob
+// @Failure      404       {string}  string  "Job not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/jobs/{jobID} [get]
+func GetModuleJob(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	jobID := chi.URLParam(r, "jobID")
+
+	job, err := core.GetModuleJob(r.Context(), moduleID, jobID)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("error getting job %s: %v\n", jobID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(job)
+}
+
+// CancelModuleJob cancels a queued job or stops a running one.
+// @Summary      Cancel Module Job
+// @Description  A queued job is cancelled right away. A running job has its current command interrupted (SIGINT, then SIGKILL after a grace period) and ends as cancelled.
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Param        jobID     path      string  true  "Job ID"
+// @Success      202       {object}  core.ModuleJob
+// @Failure      404       {string}  string  "Job not found"
+// @Failure      409       {string}  string  "Job already finished"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/jobs/{jobID}/cancel [post]
+func CancelModuleJob(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	jobID := chi.URLParam(r, "jobID")
+
+	job, err := core.CancelModuleJob(r.Context(), moduleID, jobID)
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrNotFound):
+			http.Error(w, "Job not found", http.StatusNotFound)
+		case errors.Is(err, core.ErrConflict):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Printf("error cancelling job %s: %v\n", jobID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
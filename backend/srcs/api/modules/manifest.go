@@ -0,0 +1,95 @@
+package modules
+
+import (
+	"backend/core"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetModuleManifest shows the module manifest and what applying it would change.
+// @Summary      Get Module Manifest Diff
+// @Description  Parses pan-bagnat.yml from the module checkout, validates it and compares it with the current pages, icon, OIDC client and .env.
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {object}  core.ModuleManifestDiff
+// @Failure      400       {string}  string  "Invalid manifest"
+// @Failure      404       {string}  string  "Module or manifest not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/manifest [get]
+func GetModuleManifest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	mod, err := core.GetModule(moduleID)
+	if err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	diff, err := core.DiffModuleManifest(mod)
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrNotFound):
+			http.Error(w, "module has no "+core.ModuleManifestFile, http.StatusNotFound)
+		case errors.Is(err, core.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			log.Printf("manifest diff error for %s: %v\n", moduleID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	json.NewEncoder(w).Encode(diff)
+}
+
+// ApplyModuleManifest applies the reviewed manifest diff.
+// @Summary      Apply Module Manifest
+// @Description  Creates and updates pages, the icon and the OIDC client as declared in pan-bagnat.yml. Pages missing from the manifest are kept. The hash must match the reviewed diff.
+// @Tags         Modules
+// @Accept       json
+// @Produce      json
+// @Param        moduleID  path      string                    true  "Module ID"
+// @Param        input     body      ModuleManifestApplyInput  true  "Reviewed manifest hash"
+// @Success      200       {object}  core.ModuleManifestDiff   "The applied changes"
+// @Failure      400       {string}  string  "Invalid input or manifest"
+// @Failure      404       {string}  string  "Module or manifest not found"
+// @Failure      409       {string}  string  "Manifest changed or conflicts with other modules"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/manifest/apply [post]
+func ApplyModuleManifest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	mod, err := core.GetModule(moduleID)
+	if err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	var input ModuleManifestApplyInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || strings.TrimSpace(input.ManifestHash) == "" {
+		http.Error(w, "manifest_hash is required", http.StatusBadRequest)
+		return
+	}
+
+	diff, err := core.ApplyModuleManifest(mod, strings.TrimSpace(input.ManifestHash))
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrNotFound):
+			http.Error(w, "module has no "+core.ModuleManifestFile, http.StatusNotFound)
+		case errors.Is(err, core.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, core.ErrConflict):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Printf("manifest apply error for %s: %v\n", moduleID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	json.NewEncoder(w).Encode(diff)
+}
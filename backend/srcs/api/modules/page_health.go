@@ -0,0 +1,183 @@
+package modules
+
+import (
+	"backend/core"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetModuleHealth returns the health computed from the page probes of a module.
+// @Summary      Get Module Health
+// @Description  Returns healthy, degraded, down or unknown (no probe result yet), with the latest state of every page probe.
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {object}  core.ModuleHealth
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/health [get]
+func GetModuleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	health, err := core.GetModuleHealth(r.Context(), moduleID)
+	if err != nil {
+		log.Printf("error getting health of %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(health)
+}
+
+// GetPageHealthCheck returns the probe of a page and its latest result.
+// @Summary      Get Page Health Check
+// @Tags         Modules,Pages
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Param        pageID    path      string  true  "Page ID"
+// @Success      200       {object}  core.PageHealthCheck
+// @Failure      404       {string}  string  "Page or health check not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/pages/{pageID}/health-check [get]
+func GetPageHealthCheck(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	pageID := chi.URLParam(r, "pageID")
+
+	check, err := core.GetPageHealthCheck(r.Context(), moduleID, pageID)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			http.Error(w, "Page or health check not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("error getting health check of page %s: %v\n", pageID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(check)
+}
+
+// PutPageHealthCheck creates or replaces the probe of a page.
+// @Summary      Set Page Health Check
+// @Description  The page is probed through its gateway every interval_seconds; it is up when the answer has expected_status, and down after two failed probes in a row.
+// @Tags         Modules,Pages
+// @Accept       json
+// @Produce      json
+// @Param        moduleID  path      string                true  "Module ID"
+// @Param        pageID    path      string                true  "Page ID"
+// @Param        input     body      PageHealthCheckInput  true  "Probe settings"
+// @Success      200       {object}  core.PageHealthCheck
+// @Failure      400       {string}  string  "Invalid input"
+// @Failure      404       {string}  string  "Page not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/pages/{pageID}/health-check [put]
+func PutPageHealthCheck(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	pageID := chi.URLParam(r, "pageID")
+
+	var input PageHealthCheckInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+	check := core.PageHealthCheck{
+		Enabled:         input.Enabled == nil || *input.Enabled,
+		Path:            input.Path,
+		ExpectedStatus:  input.ExpectedStatus,
+		IntervalSeconds: input.IntervalSeconds,
+		TimeoutSeconds:  input.TimeoutSeconds,
+	}
+
+	saved, err := core.SetPageHealthCheck(r.Context(), moduleID, pageID, check)
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, core.ErrNotFound):
+			http.Error(w, "Page not found", http.StatusNotFound)
+		default:
+			log.Printf("error setting health check of page %s: %v\n", pageID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	json.NewEncoder(w).Encode(saved)
+}
+
+// DeletePageHealthCheck removes the probe of a page and its history.
+// @Summary      Delete Page Health Check
+// @Tags         Modules,Pages
+// @Param        moduleID  path      string  true  "Module ID"
+// @Param        pageID    path      string  true  "Page ID"
+// @Success      204       "No Content"
+// @Failure      404       {string}  string  "Page or health check not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/pages/{pageID}/health-check [delete]
+func DeletePageHealthCheck(w http.ResponseWriter, r *http.Request) {
+	moduleID := chi.URLParam(r, "moduleID")
+	pageID := chi.URLParam(r, "pageID")
+
+	if err := core.DeletePageHealthCheck(r.Context(), moduleID, pageID); err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			http.Error(w, "Page or health check not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("error deleting health check of page %s: %v\n", pageID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetPageHealthHistory returns past probe results of a page.
+// @Summary      Get Page Health History
+// @Tags         Modules,Pages
+// @Produce      json
+// @Param        moduleID  path      string  true   "Module ID"
+// @Param        pageID    path      string  true   "Page ID"
+// @Param        since     query     string  false  "RFC 3339 timestamp (default 24h ago)"
+// @Param        limit     query     int     false  "Max number of results (default 500)"
+// @Success      200       {array}   core.PageHealthResult
+// @Failure      400       {string}  string  "Invalid since"
+// @Failure      404       {string}  string  "Page not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/pages/{pageID}/health-check/history [get]
+func GetPageHealthHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	pageID := chi.URLParam(r, "pageID")
+
+	since := time.Now().Add(-24 * time.Hour)
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			http.Error(w, "Invalid since", http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	history, err := core.GetPageHealthHistory(r.Context(), moduleID, pageID, since, limit)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			http.Error(w, "Page not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("error getting health history of page %s: %v\n", pageID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(history)
+}
@@ -29,10 +29,11 @@ func PostModule(w http.ResponseWriter, r *http.Request) {
 
 	// Parse input
 	var input struct {
-		GitURL    string `json:"git_url"`
-		GitBranch string `json:"git_branch"`
-		Name      string `json:"name"`
-		SSHKeyID  string `json:"ssh_key_id"`
+		GitURL          string `json:"git_url"`
+		GitBranch       string `json:"git_branch"`
+		Name            string `json:"name"`
+		SSHKeyID        string `json:"ssh_key_id"`
+		GitCredentialID string `json:"git_credential_id"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
@@ -48,12 +49,24 @@ func PostModule(w http.ResponseWriter, r *http.Request) {
 		actor = u
 	}
 
+	if input.GitCredentialID != "" {
+		if _, err := core.GetGitCredential(r.Context(), input.GitCredentialID); err != nil {
+			http.Error(w, "Unknown git_credential_id", http.StatusBadRequest)
+			return
+		}
+	}
+
 	module, err := core.ImportModule(actor, input.Name, input.GitURL, input.GitBranch, input.SSHKeyID)
 	if err != nil {
 		log.Printf("failed to import module: %v", err)
 		http.Error(w, "Failed to import module", http.StatusInternalServerError)
 		return
 	}
+	if input.GitCredentialID != "" {
+		if _, err := core.SetModuleGitCredential(r.Context(), module.ID, input.GitCredentialID, actor); err != nil {
+			log.Printf("failed to set git credential of module %s: %v", module.ID, err)
+		}
+	}
 
 	dest := api.ModuleToAPIModule(module)
 	destJSON, err := json.Marshal(dest)
@@ -62,7 +75,7 @@ func PostModule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = core.CloneModuleRepo(module)
+	_, err = core.RunModuleJob(r.Context(), module, core.ModuleJobClone, core.ModuleJobParams{}, actor)
 	if err != nil {
 		log.Printf("error while cloning module %s: %s\n", module.ID, err.Error())
 	}
@@ -109,7 +122,7 @@ func GitClone(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = core.CloneModuleRepo(module)
+	_, err = core.RunModuleJob(r.Context(), module, core.ModuleJobClone, core.ModuleJobParams{}, requestActor(r))
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte("error while cloning module " + moduleID))
@@ -157,7 +170,7 @@ func GitPull(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = core.PullModuleRepo(module)
+	_, err = core.RunModuleJob(r.Context(), module, core.ModuleJobPull, core.ModuleJobParams{}, requestActor(r))
 	if err != nil {
 		log.Printf("error while cloning module %s: %s\n", moduleID, err.Error())
 		w.WriteHeader(http.StatusInternalServerError)
@@ -313,7 +326,11 @@ func DeployConfig(w http.ResponseWriter, r *http.Request) {
 	}
 
 	core.SaveModuleConfig(module, req.Config)
-	go core.DeployModule(module)
+	if _, err := core.EnqueueModuleJob(r.Context(), module, core.ModuleJobDeploy, core.ModuleJobParams{}, requestActor(r)); err != nil {
+		log.Printf("error queueing deploy of %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
 	w.WriteHeader(http.StatusAccepted)
 	w.Write([]byte("Deployment started for module " + moduleID))
 }
@@ -0,0 +1,182 @@
+package modules
+
+import (
+	"backend/core"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetModulePreviews lists the branch previews of a module.
+// @Summary      List Module Previews
+// @Tags         Modules,Git
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {array}   core.ModulePreview
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/previews [get]
+func GetModulePreviews(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	previews, err := core.ListModulePreviews(r.Context(), moduleID)
+	if err != nil {
+		log.Printf("error listing previews for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(previews)
+}
+
+// PostModulePreview previews a branch of a module.
+// @Summary      Create Module Preview
+// @Description  Registers a module cloned from branch, with the slug `<slug>-preview-<branch>`, the same remote, credentials, git options and resource limits, the secrets listed in the preview settings, and a copy of every page under `<page>-preview-<branch>`. The copied pages require a login and are restricted to the maintainer roles of the module. A clone and a deploy job are queued. Asking again for a branch that already has a preview extends it and queues a pull and deploy instead. Previews are deleted once expired or when their branch is gone from the remote.
+// @Tags         Modules,Git
+// @Accept       json
+// @Produce      json
+// @Param        moduleID  path      string              true  "Module ID"
+// @Param        input     body      ModulePreviewInput  true  "Branch"
+// @Success      202       {object}  ModulePreviewResponse
+// @Failure      400       {string}  string  "Invalid input"
+// @Failure      404       {string}  string  "module not found"
+// @Failure      409       {string}  string  "Slug already used or remote unreachable"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/previews [post]
+func PostModulePreview(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	mod, err := core.GetModule(moduleID)
+	if err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	var input ModulePreviewInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	preview, jobs, err := core.CreateModulePreview(r.Context(), mod, core.ModulePreviewInput{
+		Branch:     input.Branch,
+		TTLSeconds: input.TTLSeconds,
+	}, requestActor(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, core.ErrConflict):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Printf("error creating preview for %s: %v\n", moduleID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(ModulePreviewResponse{Preview: preview, Jobs: jobs})
+}
+
+// DeleteModulePreview deletes a preview before it expires.
+// @Summary      Delete Module Preview
+// @Description  Stops and deletes the preview module, its pages and its repository.
+// @Tags         Modules,Git
+// @Param        moduleID   path  string  true  "Module ID"
+// @Param        previewID  path  string  true  "Module ID of the preview"
+// @Success      204        "No Content"
+// @Failure      404        {string}  string  "preview not found"
+// @Failure      500        {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/previews/{previewID} [delete]
+func DeleteModulePreview(w http.ResponseWriter, r *http.Request) {
+	moduleID := chi.URLParam(r, "moduleID")
+	previewID := chi.URLParam(r, "previewID")
+	if err := core.DeleteModulePreview(r.Context(), moduleID, previewID); err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			http.Error(w, "preview not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("error deleting preview %s of %s: %v\n", previewID, moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetModulePreviewSettings returns the preview settings of a module.
+// @Summary      Get Module Preview Settings
+// @Tags         Modules,Git
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {object}  core.ModulePreviewSettings
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/previews/settings [get]
+func GetModulePreviewSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	settings, err := core.GetModulePreviewSettings(r.Context(), moduleID)
+	if err != nil {
+		log.Printf("error getting preview settings for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(settings)
+}
+
+// PutModulePreviewSettings sets the preview lifetime, maintainers and copied secrets of a module.
+// @Summary      Set Module Preview Settings
+// @Description  ttl_seconds is the default lifetime of new previews (60s to 90 days, 7 days when 0). maintainer_role_ids are the roles allowed on preview pages besides admins; the pages of existing previews are updated. preview_secrets lists the module secrets copied into new previews; previews build unreviewed branches, so none are copied by default.
+// @Tags         Modules,Git
+// @Accept       json
+// @Produce      json
+// @Param        moduleID  path      string                      true  "Module ID"
+// @Param        input     body      ModulePreviewSettingsInput  true  "Settings"
+// @Success      200       {object}  core.ModulePreviewSettings
+// @Failure      400       {string}  string  "Invalid input"
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/previews/settings [put]
+func PutModulePreviewSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	mod, err := core.GetModule(moduleID)
+	if err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	var input ModulePreviewSettingsInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	settings, err := core.SetModulePreviewSettings(r.Context(), mod, core.ModulePreviewSettings{
+		TTLSeconds:        input.TTLSeconds,
+		MaintainerRoleIDs: input.MaintainerRoleIDs,
+		PreviewSecrets:    input.PreviewSecrets,
+	}, requestActor(r))
+	if err != nil {
+		if errors.Is(err, core.ErrInvalidInput) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("error setting preview settings for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(settings)
+}
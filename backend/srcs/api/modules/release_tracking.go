@@ -0,0 +1,82 @@
+package modules
+
+import (
+	"backend/core"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetModuleReleaseTracking returns the release tracking of a module.
+// @Summary      Get Module Release Tracking
+// @Description  An empty constraint means the module follows its branch. version and latest_version on the module hold the tag at HEAD and the highest matching tag.
+// @Tags         Modules,Git
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {object}  core.ModuleReleaseTracking
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/release-tracking [get]
+func GetModuleReleaseTracking(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	tracking, err := core.GetModuleReleaseTracking(r.Context(), moduleID)
+	if err != nil {
+		log.Printf("error getting release tracking for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(tracking)
+}
+
+// PutModuleReleaseTracking makes a module follow git tags matching a semver constraint.
+// @Summary      Set Module Release Tracking
+// @Description  constraint is a semver range (^1.2, ~1.2.3, 1.x, >=1.2 <2, alternatives with ||); tags may start with v. Fetches compute the highest matching tag into latest_version, and pulls and deploys check it out, detached. Prerelease tags only match when prereleases is set. An empty constraint goes back to the branch; check the branch out again to leave the last release.
+// @Tags         Modules,Git
+// @Accept       json
+// @Produce      json
+// @Param        moduleID  path      string                      true  "Module ID"
+// @Param        input     body      ModuleReleaseTrackingInput  true  "Constraint"
+// @Success      200       {object}  core.ModuleReleaseTracking
+// @Failure      400       {string}  string  "Invalid input"
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/release-tracking [put]
+func PutModuleReleaseTracking(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	mod, err := core.GetModule(moduleID)
+	if err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	var input ModuleReleaseTrackingInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	tracking, err := core.SetModuleReleaseTracking(r.Context(), mod, core.ModuleReleaseTracking{
+		Constraint:  input.Constraint,
+		Prereleases: input.Prereleases,
+	}, requestActor(r))
+	if err != nil {
+		if errors.Is(err, core.ErrInvalidInput) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("error setting release tracking for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(tracking)
+}
@@ -0,0 +1,111 @@
+package modules
+
+import (
+	"backend/core"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetModuleResourceLimits returns the resource limits of a module.
+// @Summary      Get Module Resource Limits
+// @Description  Returns the CPU, memory, pids and disk limits applied to each container of the module. A null field is not limited.
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {object}  core.ModuleResourceLimits
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/resource-limits [get]
+func GetModuleResourceLimits(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	limits, err := core.GetModuleResourceLimits(r.Context(), moduleID)
+	if err != nil {
+		log.Printf("error getting resource limits for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(limits)
+}
+
+// PutModuleResourceLimits replaces the resource limits of a module.
+// @Summary      Set Module Resource Limits
+// @Description  Limits apply to each container of the module, not to the module as a whole, through a generated compose override, from the next deploy or rebuild on, and take precedence over limits set in the module compose file. cpus and memory_mb also cap image builds. Omitted or null fields remove the limit. disk_mb is refused unless MODULE_LIMITS_STORAGE_OPT is enabled.
+// @Tags         Modules
+// @Accept       json
+// @Produce      json
+// @Param        moduleID  path      string                     true  "Module ID"
+// @Param        input     body      ModuleResourceLimitsInput  true  "Limits"
+// @Success      200       {object}  core.ModuleResourceLimits
+// @Failure      400       {string}  string  "Invalid input"
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/resource-limits [put]
+func PutModuleResourceLimits(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	var input ModuleResourceLimitsInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	limits, err := core.SetModuleResourceLimits(r.Context(), moduleID, core.ModuleResourceLimits{
+		CPUs:     input.CPUs,
+		MemoryMB: input.MemoryMB,
+		Pids:     input.Pids,
+		DiskMB:   input.DiskMB,
+	})
+	if err != nil {
+		if errors.Is(err, core.ErrInvalidInput) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("error setting resource limits for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(limits)
+}
+
+// GetModuleResourceUsage reports the current resource usage of a module.
+// @Summary      Get Module Resource Usage
+// @Description  Reads CPU, memory, pids and writable layer size of each container of the module and reports them against its limits. Warnings list containers at 90% or more of a limit.
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {object}  core.ModuleResourceUsage
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/resources [get]
+func GetModuleResourceUsage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	module, err := core.GetModule(moduleID)
+	if err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	usage, err := core.GetModuleResourceUsage(r.Context(), module)
+	if err != nil {
+		log.Printf("error reading resource usage for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(usage)
+}
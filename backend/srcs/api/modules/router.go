@@ -7,23 +7,75 @@ import (
 func RegisterRoutes(r chi.Router) {
 	r.Get("/", GetModules)
 	r.Post("/", PostModule)
+	r.Get("/import/key", GetModuleBundleKey)
+	r.Post("/import", ImportModule)
+	r.Get("/templates", GetModuleTemplates)
+	r.Post("/templates", PostModuleTemplate)
+	r.Get("/templates/{templateID}", GetModuleTemplate)
+	r.Put("/templates/{templateID}", PutModuleTemplate)
+	r.Delete("/templates/{templateID}", DeleteModuleTemplate)
+	r.Post("/templates/{templateID}/modules", PostModuleFromTemplate)
 
 	r.Get("/{moduleID}", GetModule)
 	r.Delete("/{moduleID}", DeleteModule)
 
 	r.Get("/{moduleID}/logs", GetModuleLogs)
+	r.Get("/{moduleID}/health", GetModuleHealth)
 	r.Get("/{moduleID}/networks", GetModuleNetworks)
+	r.Get("/{moduleID}/export", ExportModule)
+	r.Get("/{moduleID}/manifest", GetModuleManifest)
+	r.Post("/{moduleID}/manifest/apply", ApplyModuleManifest)
+
+	r.Get("/{moduleID}/secrets", GetModuleSecrets)
+	r.Put("/{moduleID}/secrets/{name}", PutModuleSecret)
+	r.Delete("/{moduleID}/secrets/{name}", DeleteModuleSecret)
+
+	r.Get("/{moduleID}/update-policy", GetModuleUpdatePolicy)
+	r.Put("/{moduleID}/update-policy", PutModuleUpdatePolicy)
+	r.Post("/{moduleID}/update-policy/run", RunModuleUpdateCheck)
+	r.Post("/{moduleID}/update-policy/resume", ResumeModuleUpdates)
+	r.Get("/{moduleID}/release-tracking", GetModuleReleaseTracking)
+	r.Put("/{moduleID}/release-tracking", PutModuleReleaseTracking)
+	r.Get("/{moduleID}/previews", GetModulePreviews)
+	r.Post("/{moduleID}/previews", PostModulePreview)
+	r.Get("/{moduleID}/previews/settings", GetModulePreviewSettings)
+	r.Put("/{moduleID}/previews/settings", PutModulePreviewSettings)
+	r.Delete("/{moduleID}/previews/{previewID}", DeleteModulePreview)
+	r.Get("/{moduleID}/database", GetModuleDatabase)
+	r.Post("/{moduleID}/database", PostModuleDatabase)
+	r.Delete("/{moduleID}/database", DeleteModuleDatabase)
+
+	r.Get("/{moduleID}/deploy-strategy", GetModuleDeploySettings)
+	r.Put("/{moduleID}/deploy-strategy", PutModuleDeploySettings)
+
+	r.Get("/{moduleID}/resource-limits", GetModuleResourceLimits)
+	r.Put("/{moduleID}/resource-limits", PutModuleResourceLimits)
+	r.Get("/{moduleID}/resources", GetModuleResourceUsage)
+
+	r.Get("/{moduleID}/webhook", GetModuleWebhook)
+	r.Post("/{moduleID}/webhook", RotateModuleWebhook)
+	r.Patch("/{moduleID}/webhook", PatchModuleWebhook)
+	r.Delete("/{moduleID}/webhook", DeleteModuleWebhook)
+	r.Get("/{moduleID}/webhook/deliveries", GetModuleWebhookDeliveries)
 
 	r.Post("/{moduleID}/git/clone", GitClone)
 	r.Post("/{moduleID}/git/pull", GitPull)
 	r.Post("/{moduleID}/git/update-remote", GitUpdateRemote)
 	r.Post("/{moduleID}/git/ssh-key", GitSetSSHKey)
+	r.Get("/{moduleID}/git/credential", GetModuleGitCredential)
+	r.Put("/{moduleID}/git/credential", PutModuleGitCredential)
+	r.Get("/{moduleID}/git/options", GetModuleGitOptions)
+	r.Put("/{moduleID}/git/options", PutModuleGitOptions)
 	r.Get("/{moduleID}/git/status", GitStatus)
 	r.Post("/{moduleID}/git/fetch", GitFetch)
 	r.Post("/{moduleID}/git/add", GitAdd)
+	r.Post("/{moduleID}/git/commit", GitCommitHandler)
+	r.Post("/{moduleID}/git/push", GitPushHandler)
 	r.Post("/{moduleID}/git/merge/continue", GitMergeContinueHandler)
 	r.Post("/{moduleID}/git/merge/abort", GitMergeAbortHandler)
 	r.Get("/{moduleID}/git/commits", GitCommits)
+	r.Get("/{moduleID}/git/diff", GitDiff)
+	r.Get("/{moduleID}/git/file", GitFileAtRef)
 	r.Get("/{moduleID}/git/branches", GitBranches)
 	r.Get("/{moduleID}/git/behind", GitBehind)
 	r.Post("/{moduleID}/git/checkout", GitCheckout)
@@ -39,16 +91,32 @@ func RegisterRoutes(r chi.Router) {
 	r.Post("/{moduleID}/pages", PostModulePage)
 	r.Patch("/{moduleID}/pages/{pageID}", PatchModulePage)
 	r.Delete("/{moduleID}/pages/{pageID}", DeleteModulePage)
+	r.Get("/{moduleID}/pages/{pageID}/health-check", GetPageHealthCheck)
+	r.Put("/{moduleID}/pages/{pageID}/health-check", PutPageHealthCheck)
+	r.Delete("/{moduleID}/pages/{pageID}/health-check", DeletePageHealthCheck)
+	r.Get("/{moduleID}/pages/{pageID}/health-check/history", GetPageHealthHistory)
 	r.Post("/{moduleID}/pages/{pageID}/roles/{roleID}", PostModulePageRole)
 	r.Delete("/{moduleID}/pages/{pageID}/roles/{roleID}", DeleteModulePageRole)
 
 	r.Get("/{moduleID}/docker/config", GetModuleConfig)
 	r.Post("/{moduleID}/docker/deploy", DeployConfig)
+	r.Get("/{moduleID}/docker/config/lint", LintModuleConfig)
+	r.Post("/{moduleID}/docker/config/lint", LintModuleConfigDraft)
+	r.Get("/{moduleID}/docker/config/policy-overrides", GetModulePolicyOverrides)
+	r.Put("/{moduleID}/docker/config/policy-overrides/{rule}", PutModulePolicyOverride)
+	r.Delete("/{moduleID}/docker/config/policy-overrides/{rule}", DeleteModulePolicyOverride)
 
 	r.Get("/{moduleID}/docker/ls", GetModuleContainers)
 	r.Post("/{moduleID}/docker/compose/deploy", ComposeDeploy)
 	r.Post("/{moduleID}/docker/compose/rebuild", ComposeRebuild)
 	r.Post("/{moduleID}/docker/compose/down", ComposeDown)
+	r.Get("/{moduleID}/jobs", GetModuleJobs)
+	r.Get("/{moduleID}/jobs/{jobID}", GetModuleJob)
+	r.Post("/{moduleID}/jobs/{jobID}/cancel", CancelModuleJob)
+	r.Get("/{moduleID}/deployments", GetModuleDeployments)
+	r.Post("/{moduleID}/deployments/rollback", RollbackModuleDeployment)
+	r.Get("/{moduleID}/deployments/{deploymentID}", GetModuleDeployment)
+	r.Get("/{moduleID}/deployments/{deploymentID}/logs", GetModuleDeploymentLogs)
 	r.Get("/{moduleID}/docker/{containerName}/logs", GetContainerLogs)
 	r.Post("/{moduleID}/docker/{containerName}/start", StartModuleContainer)
 	r.Post("/{moduleID}/docker/{containerName}/stop", StopModuleContainer)
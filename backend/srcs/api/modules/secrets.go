@@ -0,0 +1,114 @@
+package modules
+
+import (
+	"backend/core"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetModuleSecrets lists the secrets of a module.
+// @Summary      List Module Secrets
+// @Description  Returns the names and timestamps of the module secrets. Values are never returned.
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {array}   core.ModuleSecret
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/secrets [get]
+func GetModuleSecrets(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	secrets, err := core.ListModuleSecrets(r.Context(), moduleID)
+	if err != nil {
+		log.Printf("error listing secrets for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(secrets)
+}
+
+// PutModuleSecret creates or replaces a module secret.
+// @Summary      Set Module Secret
+// @Description  Encrypts and stores a secret, injected as an environment variable into every compose service on the next deploy. The value is write-only.
+// @Tags         Modules
+// @Accept       json
+// @Produce      json
+// @Param        moduleID  path      string                  true  "Module ID"
+// @Param        name      path      string                  true  "Secret name (environment variable name)"
+// @Param        input     body      ModuleSecretInput       true  "Secret value"
+// @Success      200       {object}  core.ModuleSecret       "Secret updated"
+// @Success      201       {object}  core.ModuleSecret       "Secret created"
+// @Failure      400       {string}  string  "Invalid input"
+// @Failure      404       {string}  string  "module not found"
+// @Failure      503       {string}  string  "Secrets encryption key not configured"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/secrets/{name} [put]
+func PutModuleSecret(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	name := chi.URLParam(r, "name")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	var input ModuleSecretInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	secret, created, err := core.SetModuleSecret(r.Context(), moduleID, name, input.Value)
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, core.ErrSecretsKeyMissing):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		default:
+			log.Printf("error setting secret %s for %s: %v\n", name, moduleID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(secret)
+}
+
+// DeleteModuleSecret removes a module secret.
+// @Summary      Delete Module Secret
+// @Description  Deletes the secret. Running containers keep it until the next deploy.
+// @Tags         Modules
+// @Param        moduleID  path      string  true  "Module ID"
+// @Param        name      path      string  true  "Secret name"
+// @Success      204       {string}  string  "No Content"
+// @Failure      404       {string}  string  "Secret not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/secrets/{name} [delete]
+func DeleteModuleSecret(w http.ResponseWriter, r *http.Request) {
+	moduleID := chi.URLParam(r, "moduleID")
+	name := chi.URLParam(r, "name")
+
+	if err := core.DeleteModuleSecret(r.Context(), moduleID, name); err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			http.Error(w, "Secret not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("error deleting secret %s for %s: %v\n", name, moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,195 @@
+package modules
+
+import (
+	api "backend/api/dto"
+	"backend/core"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetModuleTemplates lists the template repositories.
+// @Summary      List Module Templates
+// @Tags         Modules
+// @Produce      json
+// @Success      200  {array}   core.ModuleTemplate
+// @Failure      500  {string}  string  "Internal server error"
+// @Router       /admin/modules/templates [get]
+func GetModuleTemplates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	templates, err := core.ListModuleTemplates(r.Context())
+	if err != nil {
+		log.Printf("error listing module templates: %v\n", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(templates)
+}
+
+// GetModuleTemplate returns a template repository.
+// @Summary      Get Module Template
+// @Tags         Modules
+// @Produce      json
+// @Param        templateID  path      string  true  "Template ID"
+// @Success      200         {object}  core.ModuleTemplate
+// @Failure      404         {string}  string  "Template not found"
+// @Failure      500         {string}  string  "Internal server error"
+// @Router       /admin/modules/templates/{templateID} [get]
+func GetModuleTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	templateID := chi.URLParam(r, "templateID")
+	tpl, err := core.GetModuleTemplate(r.Context(), templateID)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			http.Error(w, "Template not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("error getting module template %s: %v\n", templateID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(tpl)
+}
+
+// PostModuleTemplate registers a template repository.
+// @Summary      Register Module Template
+// @Description  Templates are cloned when a module is created from them. {{module_name}}, {{module_slug}} and {{module_port}} are replaced in file names and text files.
+// @Tags         Modules
+// @Accept       json
+// @Produce      json
+// @Param        input  body      ModuleTemplateInput  true  "Template repository"
+// @Success      201    {object}  core.ModuleTemplate
+// @Failure      400    {string}  string  "Invalid input"
+// @Failure      409    {string}  string  "Name already used"
+// @Failure      500    {string}  string  "Internal server error"
+// @Router       /admin/modules/templates [post]
+func PostModuleTemplate(w http.ResponseWriter, r *http.Request) {
+	saveModuleTemplate(w, r, "")
+}
+
+// PutModuleTemplate updates a template repository.
+// @Summary      Update Module Template
+// @Description  Modules already created from the template are not changed.
+// @Tags         Modules
+// @Accept       json
+// @Produce      json
+// @Param        templateID  path      string               true  "Template ID"
+// @Param        input       body      ModuleTemplateInput  true  "Template repository"
+// @Success      200         {object}  core.ModuleTemplate
+// @Failure      400         {string}  string  "Invalid input"
+// @Failure      404         {string}  string  "Template not found"
+// @Failure      409         {string}  string  "Name already used"
+// @Failure      500         {string}  string  "Internal server error"
+// @Router       /admin/modules/templates/{templateID} [put]
+func PutModuleTemplate(w http.ResponseWriter, r *http.Request) {
+	saveModuleTemplate(w, r, chi.URLParam(r, "templateID"))
+}
+
+func saveModuleTemplate(w http.ResponseWriter, r *http.Request, templateID string) {
+	w.Header().Set("Content-Type", "application/json")
+	var input ModuleTemplateInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	tpl, err := core.SaveModuleTemplate(r.Context(), core.ModuleTemplate{
+		ID:          templateID,
+		Name:        input.Name,
+		Description: input.Description,
+		GitURL:      input.GitURL,
+		GitBranch:   input.GitBranch,
+		SSHKeyID:    input.SSHKeyID,
+		DefaultPort: input.DefaultPort,
+	}, requestActor(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, core.ErrNotFound):
+			http.Error(w, "Template not found", http.StatusNotFound)
+		case errors.Is(err, core.ErrConflict):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Printf("error saving module template: %v\n", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	if templateID == "" {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(tpl)
+}
+
+// DeleteModuleTemplate removes a template repository.
+// @Summary      Delete Module Template
+// @Description  Modules already created from the template are kept.
+// @Tags         Modules
+// @Param        templateID  path      string  true  "Template ID"
+// @Success      204         "No Content"
+// @Failure      404         {string}  string  "Template not found"
+// @Failure      500         {string}  string  "Internal server error"
+// @Router       /admin/modules/templates/{templateID} [delete]
+func DeleteModuleTemplate(w http.ResponseWriter, r *http.Request) {
+	templateID := chi.URLParam(r, "templateID")
+	if err := core.DeleteModuleTemplate(r.Context(), templateID); err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			http.Error(w, "Template not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("error deleting module template %s: %v\n", templateID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PostModuleFromTemplate creates a module from a template.
+// @Summary      Create Module From Template
+// @Description  Registers the module, then queues a clone job that clones the template, substitutes its variables, commits the result to a fresh repository and, when push is set, pushes it to git_url with the module's SSH key. The remote must be empty and the module's deploy key needs write access; a failed push fails the job but keeps the module and its repository. A pan-bagnat.yml in the template is applied once the repository is created.
+// @Tags         Modules
+// @Accept       json
+// @Produce      json
+// @Param        templateID  path      string                   true  "Template ID"
+// @Param        input       body      ModuleFromTemplateInput  true  "New module"
+// @Success      202         {object}  ModuleFromTemplateResponse
+// @Failure      400         {string}  string  "Invalid input"
+// @Failure      404         {string}  string  "Template not found"
+// @Failure      500         {string}  string  "Internal server error"
+// @Router       /admin/modules/templates/{templateID}/modules [post]
+func PostModuleFromTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	templateID := chi.URLParam(r, "templateID")
+	var input ModuleFromTemplateInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	module, job, err := core.CreateModuleFromTemplate(r.Context(), templateID, core.ModuleFromTemplate{
+		Name:      input.Name,
+		Port:      input.Port,
+		GitURL:    input.GitURL,
+		GitBranch: input.GitBranch,
+		SSHKeyID:  input.SSHKeyID,
+		Push:      input.Push,
+	}, requestActor(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, core.ErrNotFound):
+			http.Error(w, "Template not found", http.StatusNotFound)
+		default:
+			log.Printf("error creating module from template %s: %v\n", templateID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(ModuleFromTemplateResponse{Module: api.ModuleToAPIModule(module), Job: job})
+}
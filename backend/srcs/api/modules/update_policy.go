@@ -0,0 +1,143 @@
+package modules
+
+import (
+	"backend/core"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetModuleUpdatePolicy returns the automatic update policy of a module.
+// @Summary      Get Module Update Policy
+// @Description  Returns the update policy and the outcome of its last check. Modules without a policy are manual.
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {object}  core.ModuleUpdatePolicy
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/update-policy [get]
+func GetModuleUpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	policy, err := core.GetModuleUpdatePolicy(r.Context(), moduleID)
+	if err != nil {
+		log.Printf("error getting update policy for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(policy)
+}
+
+// PutModuleUpdatePolicy sets the automatic update policy of a module.
+// @Summary      Set Module Update Policy
+// @Description  manual does nothing, notify announces new upstream commits, auto_pull pulls them and auto_pull_deploy also redeploys. Automatic pulls and deploys only run inside the maintenance windows and never on a dirty or conflicted working tree.
+// @Tags         Modules
+// @Accept       json
+// @Produce      json
+// @Param        moduleID  path      string                   true  "Module ID"
+// @Param        input     body      ModuleUpdatePolicyInput  true  "Policy"
+// @Success      200       {object}  core.ModuleUpdatePolicy
+// @Failure      400       {string}  string  "Invalid input"
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/update-policy [put]
+func PutModuleUpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	var input ModuleUpdatePolicyInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	policy, err := core.SetModuleUpdatePolicy(r.Context(), core.ModuleUpdatePolicy{
+		ModuleID:             moduleID,
+		Mode:                 input.Mode,
+		CheckIntervalSeconds: input.CheckIntervalSeconds,
+		Timezone:             input.Timezone,
+		MaintenanceWindows:   input.MaintenanceWindows,
+	})
+	if err != nil {
+		if errors.Is(err, core.ErrInvalidInput) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("error setting update policy for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(policy)
+}
+
+// RunModuleUpdateCheck runs the update policy of a module now.
+// @Summary      Run Module Update Check
+// @Description  Fetches the upstream and applies the policy immediately, as the scheduler would. The result is in last_result / last_error.
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {object}  core.ModuleUpdatePolicy
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/update-policy/run [post]
+func RunModuleUpdateCheck(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	policy, err := core.RunModuleUpdateCheck(r.Context(), moduleID)
+	if err != nil {
+		log.Printf("error running update check for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(policy)
+}
+
+// ResumeModuleUpdates clears the pause set by a rollback.
+// @Summary      Resume Module Updates
+// @Description  A rollback pauses automatic pulls and deploys so the update policy does not bring back the commit that was rolled back. This lets the policy pull and deploy again.
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {object}  core.ModuleUpdatePolicy
+// @Failure      404       {string}  string  "module not found"
+// @Failure      409       {string}  string  "automatic updates are not paused"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/update-policy/resume [post]
+func ResumeModuleUpdates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	policy, err := core.ResumeModuleUpdates(r.Context(), moduleID, requestActor(r))
+	if err != nil {
+		if errors.Is(err, core.ErrConflict) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Printf("error resuming updates for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(policy)
+}
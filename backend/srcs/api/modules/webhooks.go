@@ -0,0 +1,216 @@
+package modules
+
+import (
+	"backend/core"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxWebhookBody matches the largest payload GitHub delivers.
+const maxWebhookBody = 25 << 20
+
+// GetModuleWebhook returns the push webhook of a module.
+// @Summary      Get Module Webhook
+// @Description  Returns the URL to configure on GitHub, GitLab or Gitea and whether the webhook is enabled. The secret is only shown when generated.
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {object}  core.ModuleWebhook
+// @Failure      404       {string}  string  "Webhook not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/webhook [get]
+func GetModuleWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	hook, err := core.GetModuleWebhook(r.Context(), moduleID)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("error getting webhook for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(hook)
+}
+
+// RotateModuleWebhook creates the push webhook of a module or replaces its secret.
+// @Summary      Generate Module Webhook Secret
+// @Description  Creates the webhook (or replaces its secret) and enables it. The returned secret is not shown again: set it as the webhook secret (GitHub, Gitea) or secret token (GitLab).
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {object}  core.ModuleWebhook
+// @Failure      404       {string}  string  "module not found"
+// @Failure      503       {string}  string  "Master key is not configured"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/webhook [post]
+func RotateModuleWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	hook, err := core.RotateModuleWebhook(r.Context(), moduleID)
+	if err != nil {
+		if errors.Is(err, core.ErrSecretsKeyMissing) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		log.Printf("error generating webhook for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(hook)
+}
+
+// PatchModuleWebhook enables or disables the push webhook of a module.
+// @Summary      Enable/Disable Module Webhook
+// @Description  Deliveries to a disabled webhook are still verified and logged, but ignored.
+// @Tags         Modules
+// @Accept       json
+// @Produce      json
+// @Param        moduleID  path      string              true  "Module ID"
+// @Param        input     body      ModuleWebhookInput  true  "Webhook state"
+// @Success      200       {object}  core.ModuleWebhook
+// @Failure      400       {string}  string  "Invalid JSON input"
+// @Failure      404       {string}  string  "Webhook not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/webhook [patch]
+func PatchModuleWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	var input ModuleWebhookInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Enabled == nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	hook, err := core.SetModuleWebhookEnabled(r.Context(), moduleID, *input.Enabled)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("error updating webhook for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(hook)
+}
+
+// DeleteModuleWebhook removes the push webhook of a module.
+// @Summary      Delete Module Webhook
+// @Tags         Modules
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      204       "No Content"
+// @Failure      404       {string}  string  "Webhook not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/webhook [delete]
+func DeleteModuleWebhook(w http.ResponseWriter, r *http.Request) {
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	if err := core.DeleteModuleWebhook(r.Context(), moduleID); err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("error deleting webhook for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetModuleWebhookDeliveries lists the recent deliveries of a module webhook.
+// @Summary      List Module Webhook Deliveries
+// @Description  Returns the last deliveries, most recent first, with how each was handled: rejected (bad signature), ignored (not a push to the module branch or, for modules tracking releases, of a tag; or webhook disabled), accepted (update check running), succeeded or failed.
+// @Tags         Modules
+// @Produce      json
+// @Param        moduleID  path      string  true   "Module ID"
+// @Param        limit     query     int     false  "Max number of deliveries (default 50)"
+// @Success      200       {array}   core.ModuleWebhookDelivery
+// @Failure      404       {string}  string  "module not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /admin/modules/{moduleID}/webhook/deliveries [get]
+func GetModuleWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	moduleID := chi.URLParam(r, "moduleID")
+	if _, err := core.GetModule(moduleID); err != nil {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	deliveries, err := core.ListModuleWebhookDeliveries(r.Context(), moduleID, limit)
+	if err != nil {
+		log.Printf("error listing webhook deliveries for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// ReceiveModuleWebhook handles a delivery from GitHub, GitLab or Gitea.
+// @Summary      Receive Module Push Webhook
+// @Description  Public endpoint called by the forge. The delivery must be signed with the module webhook secret. A push to the module branch, or of a tag when the module tracks releases, fetches it and applies its update policy (notify, pull or pull and deploy).
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Param        moduleID  path      string  true  "Module ID"
+// @Success      200       {object}  core.ModuleWebhookDelivery  "Ignored"
+// @Success      202       {object}  core.ModuleWebhookDelivery  "Accepted"
+// @Failure      401       {string}  string  "unauthorized"
+// @Failure      404       {string}  string  "not found"
+// @Failure      500       {string}  string  "Internal server error"
+// @Router       /webhooks/modules/{moduleID} [post]
+func ReceiveModuleWebhook(w http.ResponseWriter, r *http.Request) {
+	moduleID := chi.URLParam(r, "moduleID")
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := core.HandleModuleWebhook(r.Context(), moduleID, r.Header, body)
+	switch {
+	case errors.Is(err, core.ErrNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	case errors.Is(err, core.ErrUnauthorized):
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	case err != nil:
+		log.Printf("error handling webhook for %s: %v\n", moduleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if delivery.Status == core.WebhookDeliveryAccepted {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(w).Encode(delivery)
+}
@@ -0,0 +1,251 @@
+package users
+
+import (
+	"backend/core"
+	"encoding/json"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetUserAttributeDefs lists the custom user attribute definitions.
+// @Summary      List User Attribute Definitions
+// @Description  Returns every admin-defined user attribute with its type and claim exposure.
+// @Tags         Users
+// @Produce      json
+// @Success      200  {array}   core.UserAttributeDef
+// @Failure      500  {string}  string  "Internal server error"
+// @Router       /admin/users/attributes [get]
+func GetUserAttributeDefs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defs, err := core.ListUserAttributeDefs()
+	if err != nil {
+		log.Printf("error listing user attributes: %v\n", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(defs)
+}
+
+// PostUserAttributeDef creates a custom user attribute.
+// @Summary      Create User Attribute Definition
+// @Description  Declares a typed custom attribute (string, number, bool or date). Values become available to role rules as custom.<key>.
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        input  body      UserAttributeDefInput  true  "Attribute definition"
+// @Success      201    {object}  core.UserAttributeDef
+// @Failure      400    {string}  string  "Invalid input"
+// @Failure      409    {string}  string  "Attribute already exists"
+// @Failure      500    {string}  string  "Internal server error"
+// @Router       /admin/users/attributes [post]
+func PostUserAttributeDef(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var input UserAttributeDefInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	def, err := core.CreateUserAttributeDef(core.UserAttributeDef{
+		Key:            input.Key,
+		Label:          input.Label,
+		Type:           input.Type,
+		Description:    input.Description,
+		ExposeInClaims: input.ExposeInClaims,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, core.ErrAlreadyExists):
+			http.Error(w, "Attribute already exists", http.StatusConflict)
+		default:
+			log.Printf("error creating user attribute: %v\n", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(def)
+}
+
+// PatchUserAttributeDef edits a custom user attribute.
+// @Summary      Update User Attribute Definition
+// @Description  Updates the label, description or claim exposure of an attribute. The type is immutable.
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        key    path      string                      true  "Attribute key"
+// @Param        input  body      UserAttributeDefPatchInput  true  "Fields to update"
+// @Success      200    {object}  core.UserAttributeDef
+// @Failure      400    {string}  string  "Invalid JSON input"
+// @Failure      404    {string}  string  "Attribute not found"
+// @Failure      500    {string}  string  "Internal server error"
+// @Router       /admin/users/attributes/{key} [patch]
+func PatchUserAttributeDef(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	key := chi.URLParam(r, "key")
+	var input UserAttributeDefPatchInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	def, err := core.UpdateUserAttributeDef(key, input.Label, input.Description, input.ExposeInClaims)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			http.Error(w, "Attribute not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("error updating user attribute %s: %v\n", key, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(def)
+}
+
+// DeleteUserAttributeDef removes a custom user attribute and all its values.
+// @Summary      Delete User Attribute Definition
+// @Description  Deletes the attribute definition along with every stored value.
+// @Tags         Users
+// @Param        key  path      string  true  "Attribute key"
+// @Success      204  {string}  string  "No Content"
+// @Failure      404  {string}  string  "Attribute not found"
+// @Failure      500  {string}  string  "Internal server error"
+// @Router       /admin/users/attributes/{key} [delete]
+func DeleteUserAttributeDef(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+	if err := core.DeleteUserAttributeDef(key); err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			http.Error(w, "Attribute not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("error deleting user attribute %s: %v\n", key, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PostUserAttributesImport bulk-sets attribute values.
+// @Summary      Import User Attributes
+// @Description  Sets attribute values for many users. Send text/csv with a header "login,<key>,..." (empty cells are skipped), or JSON as [{"user": "login", "values": {"key": value}}]. Invalid rows are reported and skipped. The rule roles of the updated users are re-evaluated in the background.
+// @Tags         Users
+// @Accept       json
+// @Accept       text/csv
+// @Produce      json
+// @Success      200  {object}  core.UserAttributeImportReport
+// @Failure      400  {string}  string  "Invalid input"
+// @Failure      500  {string}  string  "Internal server error"
+// @Router       /admin/users/attributes/import [post]
+func PostUserAttributesImport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var (
+		report core.UserAttributeImportReport
+		err    error
+	)
+	if mediaType == "text/csv" {
+		report, err = core.ImportUserAttributesCSV(r.Context(), r.Body)
+	} else {
+		var rows []core.UserAttributeImportRow
+		if err := json.NewDecoder(r.Body).Decode(&rows); err != nil {
+			http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+			return
+		}
+		report, err = core.ImportUserAttributes(r.Context(), rows)
+	}
+	if err != nil {
+		if errors.Is(err, core.ErrInvalidInput) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("error importing user attributes: %v\n", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetUserAttributes returns the custom attributes of a user.
+// @Summary      Get User Attributes
+// @Description  Returns the custom attribute values set on a user, keyed by attribute.
+// @Tags         Users
+// @Produce      json
+// @Param        identifier  path      string  true  "User identifier (ID or login)"
+// @Success      200         {object}  map[string]any
+// @Failure      404         {string}  string  "User not found"
+// @Failure      500         {string}  string  "Internal server error"
+// @Router       /admin/users/{identifier}/attributes [get]
+func GetUserAttributes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	identifier := chi.URLParam(r, "identifier")
+
+	values, err := core.GetUserAttributes(identifier)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("error getting attributes of %s: %v\n", identifier, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(values)
+}
+
+// PutUserAttributes sets custom attribute values on a user.
+// @Summary      Set User Attributes
+// @Description  Sets the given attribute values (null removes a value). Other attributes are left untouched. The user's rule-based roles are re-evaluated afterwards.
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        identifier  path      string          true  "User identifier (ID or login)"
+// @Param        input       body      map[string]any  true  "Attribute values"
+// @Success      200         {object}  map[string]any
+// @Failure      400         {string}  string  "Invalid input"
+// @Failure      404         {string}  string  "User not found"
+// @Failure      500         {string}  string  "Internal server error"
+// @Router       /admin/users/{identifier}/attributes [put]
+func PutUserAttributes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	identifier := chi.URLParam(r, "identifier")
+	if strings.TrimSpace(identifier) == "" {
+		http.Error(w, "Identifier is required", http.StatusBadRequest)
+		return
+	}
+
+	var values map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&values); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	if err := core.SetUserAttributes(r.Context(), identifier, values); err != nil {
+		switch {
+		case errors.Is(err, core.ErrNotFound):
+			http.Error(w, "User not found", http.StatusNotFound)
+		case errors.Is(err, core.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			log.Printf("error setting attributes of %s: %v\n", identifier, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	core.RefreshUserRulesAsync(identifier)
+
+	updated, err := core.GetUserAttributes(identifier)
+	if err != nil {
+		log.Printf("error getting attributes of %s: %v\n", identifier, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(updated)
+}
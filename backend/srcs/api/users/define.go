@@ -30,3 +30,35 @@ type UserPostInput struct {
 	// Roles lists the role IDs to assign to this user upon creation.
 	Roles []string `json:"roles,omitempty" example:"[\"role_01\",\"role_02\"]"`
 }
+
+// UserLifecycleExemptInput toggles the lifecycle exemption of a user.
+// swagger:model UserLifecycleExemptInput
+type UserLifecycleExemptInput struct {
+	// Exempt keeps the account out of dormancy and pruning when true.
+	Exempt bool `json:"exempt" example:"true"`
+	// Reason is an optional note explaining the exemption.
+	Reason string `json:"reason,omitempty" example:"Bocal staff alumni"`
+}
+
+// UserAttributeDefInput defines a new custom user attribute.
+// swagger:model UserAttributeDefInput
+type UserAttributeDefInput struct {
+	// Key is the attribute identifier, used as custom.<key> in rules and claims.
+	Key string `json:"key" example:"campus_badge"`
+	// Label is the human readable name shown in the UI.
+	Label string `json:"label" example:"Campus badge"`
+	// Type is one of string, number, bool or date. It cannot be changed later.
+	Type string `json:"type" example:"string"`
+	// Description is an optional explanation for other admins.
+	Description string `json:"description,omitempty" example:"Badge number printed on the access card"`
+	// ExposeInClaims adds the attribute to OIDC claims when the profile scope is granted.
+	ExposeInClaims bool `json:"expose_in_claims" example:"false"`
+}
+
+// UserAttributeDefPatchInput lists the editable fields of an attribute definition.
+// swagger:model UserAttributeDefPatchInput
+type UserAttributeDefPatchInput struct {
+	Label          *string `json:"label,omitempty" example:"Campus badge"`
+	Description    *string `json:"description,omitempty" example:"Badge number printed on the access card"`
+	ExposeInClaims *bool   `json:"expose_in_claims,omitempty" example:"true"`
+}
@@ -0,0 +1,89 @@
+package users
+
+import (
+	"backend/api/auth"
+	"backend/core"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetUserMeExport returns a copy of everything stored about the current user.
+// @Summary      Export Current User Data
+// @Description  Returns the GDPR export of the authenticated user: profile, roles with sources, sessions, preferences, OIDC grants and tokens, and audit entries. Use format=zip for a zip bundle with one JSON file per section.
+// @Tags         Users
+// @Produce      json
+// @Produce      application/zip
+// @Param        format  query     string  false  "Export format"  Enums(json,zip)  default(json)
+// @Success      200     {object}  core.UserExport
+// @Failure      401     {string}  string  "Unauthorized"
+// @Failure      500     {string}  string  "Internal server error"
+// @Router       /users/me/export [get]
+func GetUserMeExport(w http.ResponseWriter, r *http.Request) {
+	u, ok := r.Context().Value(auth.UserCtxKey).(*core.User)
+	if !ok || u == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	writeUserExport(w, r, u.ID)
+}
+
+// GetUserExport returns the GDPR export for any user.
+// @Summary      Export User Data
+// @Description  Returns the same export as /users/me/export for the user identified by ID or login.
+// @Tags         Users
+// @Produce      json
+// @Produce      application/zip
+// @Param        identifier  path      string  true   "User identifier (ID or login)"
+// @Param        format      query     string  false  "Export format"  Enums(json,zip)  default(json)
+// @Success      200         {object}  core.UserExport
+// @Failure      400         {string}  string  "Identifier is required"
+// @Failure      404         {string}  string  "User not found"
+// @Failure      500         {string}  string  "Internal server error"
+// @Router       /admin/users/{identifier}/export [get]
+func GetUserExport(w http.ResponseWriter, r *http.Request) {
+	identifier := chi.URLParam(r, "identifier")
+	if strings.TrimSpace(identifier) == "" {
+		http.Error(w, "Identifier is required", http.StatusBadRequest)
+		return
+	}
+	if actor, ok := r.Context().Value(auth.UserCtxKey).(*core.User); ok && actor != nil {
+		log.Printf("[export] %s exported data of user %s", actor.FtLogin, identifier)
+	}
+	writeUserExport(w, r, identifier)
+}
+
+func writeUserExport(w http.ResponseWriter, r *http.Request, identifier string) {
+	export, err := core.ExportUserData(r.Context(), identifier)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("error exporting user %s: %v\n", identifier, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("pan-bagnat-export-%s-%s", export.Profile.FtLogin, export.GeneratedAt.Format("20060102"))
+	if r.URL.Query().Get("format") == "zip" {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".zip"))
+		if err := core.WriteUserExportZip(w, export); err != nil {
+			log.Printf("error writing export zip for user %s: %v\n", identifier, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".json"))
+	if err := json.NewEncoder(w).Encode(export); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
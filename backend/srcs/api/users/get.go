@@ -23,11 +23,12 @@ import (
 // @Tags         Users
 // @Accept       json
 // @Produce      json
-// @Param        filter           query   string  false  "Filter expression (e.g. \"ft_login=heinz\")"
+// @Param        filter           query   string  false  "Search query (e.g. \"role:admin -role:student staff:true last_seen>90d login:~^ab\")"
 // @Param        next_page_token  query   string  false  "Pagination token for the next page"
 // @Param        order            query   string  false  "Sort order: asc or desc"            Enums(asc,desc)  default(desc)
 // @Param        limit            query   int     false  "Maximum number of items per page"   default(50)
 // @Success      200              {object} UserGetResponse
+// @Failure      400              {string} string  "Invalid search query"
 // @Failure      500              {string} string  "Internal server error"
 // @Router       /admin/users [get]
 func GetUsers(w http.ResponseWriter, r *http.Request) {
@@ -65,6 +66,10 @@ func GetUsers(w http.ResponseWriter, r *http.Request) {
 	}
 	users, nextToken, err = core.GetUsers(pagination)
 	if err != nil {
+		if errors.Is(err, core.ErrInvalidInput) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Failed in core.GetUsers()", http.StatusInternalServerError)
 		return
 	}
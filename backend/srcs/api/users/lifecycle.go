@@ -0,0 +1,95 @@
+package users
+
+import (
+	"backend/core"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// RunUserLifecycle applies the inactive account policy now.
+// @Summary      Run User Lifecycle Policy
+// @Description  Marks inactive accounts dormant, notifies them, and anonymises or deletes accounts past their grace period. With dry_run=true nothing is changed. Returns the run report.
+// @Tags         Users
+// @Produce      json
+// @Param        dry_run  query     bool  false  "Only report what would happen"
+// @Success      200      {object}  core.UserLifecycleReport
+// @Failure      500      {string}  string  "Internal server error"
+// @Router       /admin/users/lifecycle/run [post]
+func RunUserLifecycle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	report, err := core.RunUserLifecycle(r.Context(), dryRun)
+	if err != nil {
+		log.Printf("error running user lifecycle: %v\n", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetUserLifecycleRuns lists the reports of previous lifecycle runs.
+// @Summary      List User Lifecycle Reports
+// @Description  Returns the most recent lifecycle run reports, newest first.
+// @Tags         Users
+// @Produce      json
+// @Param        limit  query     int  false  "Maximum number of reports"  default(20)
+// @Success      200    {array}   core.UserLifecycleReport
+// @Failure      500    {string}  string  "Internal server error"
+// @Router       /admin/users/lifecycle/runs [get]
+func GetUserLifecycleRuns(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	reports, err := core.ListUserLifecycleReports(r.Context(), limit)
+	if err != nil {
+		log.Printf("error listing user lifecycle runs: %v\n", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(reports)
+}
+
+// PutUserLifecycleExempt exempts a user from the lifecycle policy.
+// @Summary      Set User Lifecycle Exemption
+// @Description  Exempts (or stops exempting) a user from dormancy and pruning.
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        identifier  path      string                    true  "User identifier (ID or login)"
+// @Param        input       body      UserLifecycleExemptInput  true  "Exemption"
+// @Success      204         {string}  string  "No Content"
+// @Failure      400         {string}  string  "Invalid JSON input"
+// @Failure      404         {string}  string  "User not found"
+// @Failure      500         {string}  string  "Internal server error"
+// @Router       /admin/users/{identifier}/lifecycle-exempt [put]
+func PutUserLifecycleExempt(w http.ResponseWriter, r *http.Request) {
+	identifier := chi.URLParam(r, "identifier")
+	if strings.TrimSpace(identifier) == "" {
+		http.Error(w, "Identifier is required", http.StatusBadRequest)
+		return
+	}
+
+	var input UserLifecycleExemptInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	if err := core.SetUserLifecycleExempt(r.Context(), identifier, input.Exempt, input.Reason); err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("error setting lifecycle exemption for %s: %v\n", identifier, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
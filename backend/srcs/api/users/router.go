@@ -5,8 +5,19 @@ import "github.com/go-chi/chi/v5"
 func RegisterRoutes(r chi.Router) {
 	r.Get("/", GetUsers)
 	r.Post("/", PostUser)
+	r.Post("/lifecycle/run", RunUserLifecycle)
+	r.Get("/lifecycle/runs", GetUserLifecycleRuns)
+	r.Get("/attributes", GetUserAttributeDefs)
+	r.Post("/attributes", PostUserAttributeDef)
+	r.Post("/attributes/import", PostUserAttributesImport)
+	r.Patch("/attributes/{key}", PatchUserAttributeDef)
+	r.Delete("/attributes/{key}", DeleteUserAttributeDef)
 	r.Get("/{identifier}", GetUser)
 	r.Get("/{identifier}/pages", GetUserPages)
+	r.Get("/{identifier}/export", GetUserExport)
+	r.Put("/{identifier}/lifecycle-exempt", PutUserLifecycleExempt)
+	r.Get("/{identifier}/attributes", GetUserAttributes)
+	r.Put("/{identifier}/attributes", PutUserAttributes)
 	r.Patch("/{identifier}", PatchUser)
 	r.Delete("/{identifier}", DeleteUser)
 	r.Post("/{identifier}/roles/{roleID}", PostUserRole)
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"backend/core"
+	"context"
+	"fmt"
+	"os"
+)
+
+// runCommand handles one-shot maintenance commands (`./main <command>`) and
+// returns the process exit code.
+func runCommand(args []string) int {
+	switch args[0] {
+	case "rotate-keys":
+		report, err := core.RewrapStoredSecrets(context.Background())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rotate-keys: %v\n", err)
+			return 1
+		}
+		fmt.Printf("active key version: %d\n", report.ActiveVersion)
+		fmt.Printf("ssh keys encrypted: %d\n", report.SSHKeysEncrypted)
+		fmt.Printf("ssh keys re-wrapped: %d\n", report.SSHKeysRewrapped)
+		fmt.Printf("module secrets re-wrapped: %d\n", report.ModuleSecretsRewrapped)
+		fmt.Printf("webhook secrets re-wrapped: %d\n", report.WebhooksRewrapped)
+		fmt.Printf("git credentials re-wrapped: %d\n", report.GitCredentialsRewrapped)
+		if report.Failed > 0 {
+			fmt.Fprintf(os.Stderr, "rotate-keys: %d secrets could not be re-wrapped, see logs above\n", report.Failed)
+			return 1
+		}
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q (available: rotate-keys)\n", args[0])
+		return 2
+	}
+}
@@ -0,0 +1,615 @@
+package core
+
+import (
+	"backend/database"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	dockercontainer "github.com/docker/docker/api/types/container"
+	dockerfilters "github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	DeployStrategyRecreate  = "recreate"
+	DeployStrategyBlueGreen = "blue_green"
+)
+
+// greenProjectSuffix names the second compose project of a blue/green
+// module. Module slugs never contain "--" (GenerateModuleSlug collapses
+// dashes and previewSlug joins with a single-dash separator), so it cannot
+// clash with another module's project.
+const greenProjectSuffix = "--green"
+
+// gatewayTargetLabel is set by the net-controller on each gateway container
+// to the upstream it proxies to.
+const gatewayTargetLabel = "com.panbagnat.gateway.target"
+
+// ModuleDeploySettings selects how a module is redeployed. recreate runs
+// compose up in place; blue_green starts the new stack next to the running
+// one and switches the pages over once it is healthy.
+type ModuleDeploySettings struct {
+	ModuleID             string     `json:"module_id"`
+	Strategy             string     `json:"strategy"`
+	HealthTimeoutSeconds int        `json:"health_timeout_seconds"`
+	ActiveProject        string     `json:"active_project"`
+	UpdatedAt            *time.Time `json:"updated_at,omitempty"`
+}
+
+// composeConfig is the part of `docker compose config --format json` the
+// blue/green deploy looks at.
+type composeConfig struct {
+	Services map[string]struct {
+		ContainerName string         `json:"container_name"`
+		NetworkMode   string         `json:"network_mode"`
+		Networks      map[string]any `json:"networks"`
+		Ports         []struct {
+			Published any `json:"published"`
+		} `json:"ports"`
+	} `json:"services"`
+	Volumes map[string]struct {
+		Name     string `json:"name"`
+		External bool   `json:"external"`
+	} `json:"volumes"`
+}
+
+type projectContainerState struct {
+	Service  string
+	State    string
+	Health   string
+	ExitCode int
+}
+
+// pageFlip is a page moved from one compose project to the other.
+type pageFlip struct {
+	PageID       string
+	PageSlug     string
+	Port         int
+	OldContainer string
+	OldNetwork   string
+	NewContainer string
+	NewNetwork   string
+}
+
+func defaultModuleDeploySettings(module Module) ModuleDeploySettings {
+	return ModuleDeploySettings{
+		ModuleID:             module.ID,
+		Strategy:             DeployStrategyRecreate,
+		HealthTimeoutSeconds: 120,
+		ActiveProject:        module.Slug,
+	}
+}
+
+// GetModuleDeploySettings returns the deploy settings of a module, or the
+// recreate default when none were set.
+func GetModuleDeploySettings(ctx context.Context, module Module) (ModuleDeploySettings, error) {
+	s, err := database.GetModuleDeploySettings(ctx, module.ID)
+	if errors.Is(err, database.ErrNotFound) {
+		return defaultModuleDeploySettings(module), nil
+	}
+	if err != nil {
+		return ModuleDeploySettings{}, err
+	}
+	out := ModuleDeploySettings{
+		ModuleID:             s.ModuleID,
+		Strategy:             s.Strategy,
+		HealthTimeoutSeconds: s.HealthTimeoutSeconds,
+		ActiveProject:        s.ActiveProject,
+	}
+	if out.ActiveProject == "" {
+		out.ActiveProject = module.Slug
+	}
+	if !s.UpdatedAt.IsZero() {
+		t := s.UpdatedAt
+		out.UpdatedAt = &t
+	}
+	return out, nil
+}
+
+// SetModuleDeploySettings validates and stores the deploy strategy of a
+// module. It applies from the next deploy on.
+func SetModuleDeploySettings(ctx context.Context, module Module, in ModuleDeploySettings) (ModuleDeploySettings, error) {
+	if err := validateModuleDeploySettings(&in); err != nil {
+		return ModuleDeploySettings{}, err
+	}
+	if _, err := database.UpsertModuleDeploySettings(ctx, database.ModuleDeploySettings{
+		ModuleID:             module.ID,
+		Strategy:             in.Strategy,
+		HealthTimeoutSeconds: in.HealthTimeoutSeconds,
+	}); err != nil {
+		return ModuleDeploySettings{}, err
+	}
+	return GetModuleDeploySettings(ctx, module)
+}
+
+func validateModuleDeploySettings(s *ModuleDeploySettings) error {
+	s.Strategy = strings.TrimSpace(s.Strategy)
+	if s.Strategy == "" {
+		s.Strategy = DeployStrategyRecreate
+	}
+	if s.Strategy != DeployStrategyRecreate && s.Strategy != DeployStrategyBlueGreen {
+		return fmt.Errorf("%w: strategy must be recreate or blue_green", ErrInvalidInput)
+	}
+	if s.HealthTimeoutSeconds == 0 {
+		s.HealthTimeoutSeconds = 120
+	}
+	if s.HealthTimeoutSeconds < 10 || s.HealthTimeoutSeconds > 3600 {
+		return fmt.Errorf("%w: health_timeout_seconds must be between 10 and 3600", ErrInvalidInput)
+	}
+	return nil
+}
+
+// moduleDeploySettings is GetModuleDeploySettings falling back to the
+// defaults, for the docker helpers that cannot fail on a settings lookup.
+func moduleDeploySettings(module Module) ModuleDeploySettings {
+	s, err := GetModuleDeploySettings(context.Background(), module)
+	if err != nil {
+		return defaultModuleDeploySettings(module)
+	}
+	return s
+}
+
+// composeProject is the compose project currently serving a module: its slug,
+// or the green project after a blue/green deploy switched to it.
+func composeProject(module Module) string {
+	return moduleDeploySettings(module).ActiveProject
+}
+
+// otherComposeProject is the project a blue/green deploy starts next to active.
+func otherComposeProject(slug, active string) string {
+	if active == slug {
+		return slug + greenProjectSuffix
+	}
+	return slug
+}
+
+// moduleSlugFromProject maps a compose project label back to its module slug.
+func moduleSlugFromProject(project string) string {
+	return strings.TrimSuffix(project, greenProjectSuffix)
+}
+
+// composeProjectArgs returns the compose file arguments to run project:
+// the module files and secrets override, plus a generated override with the
+// module resource limits and, for the green project, named volumes kept on
+// the module's volumes.
+func composeProjectArgs(module Module, dir, file, project string) ([]string, func(), error) {
+	fileArgs, cleanup, err := composeFileArgs(module, dir, file)
+	if err != nil {
+		return nil, cleanup, err
+	}
+	fail := func(err error) ([]string, func(), error) {
+		cleanup()
+		return nil, func() {}, err
+	}
+
+	limits, err := GetModuleResourceLimits(context.Background(), module.ID)
+	if err != nil {
+		return fail(fmt.Errorf("load resource limits: %w", err))
+	}
+	dbNetwork, err := moduleDatabaseNetwork(module)
+	if err != nil {
+		return fail(err)
+	}
+	if project == module.Slug && !limits.isSet() && dbNetwork == "" {
+		return append(fileArgs, "--project-name", project), cleanup, nil
+	}
+
+	cfg, err := loadComposeConfig(dir, file, project)
+	if err != nil {
+		return fail(err)
+	}
+	override := map[string]any{}
+	if project != module.Slug {
+		if volumes := pinnedVolumes(cfg, module.Slug, project); len(volumes) > 0 {
+			override["volumes"] = volumes
+		}
+	}
+	services := make([]string, 0, len(cfg.Services))
+	for name := range cfg.Services {
+		services = append(services, name)
+	}
+	limited := resourceLimitsOverride(limits, services, storageOptEnabled())
+	if dbNetwork != "" {
+		networked, networks := managedDatabaseNetworksOverride(cfg, dbNetwork)
+		if limited == nil {
+			limited = map[string]any{}
+		}
+		for name, spec := range networked {
+			merged := map[string]any{}
+			if base, ok := limited[name].(map[string]any); ok {
+				for k, v := range base {
+					merged[k] = v
+				}
+			}
+			for k, v := range spec.(map[string]any) {
+				merged[k] = v
+			}
+			limited[name] = merged
+		}
+		override["networks"] = networks
+	}
+	if len(limited) > 0 {
+		override["services"] = limited
+	}
+	if len(override) == 0 {
+		return append(fileArgs, "--project-name", project), cleanup, nil
+	}
+
+	data, err := yaml.Marshal(override)
+	if err != nil {
+		return fail(err)
+	}
+	f, err := os.CreateTemp("", module.Slug+"-*.override.yml")
+	if err != nil {
+		return fail(err)
+	}
+	_, werr := f.Write(data)
+	cerr := f.Close()
+	if werr != nil || cerr != nil {
+		_ = os.Remove(f.Name())
+		return fail(errors.Join(werr, cerr))
+	}
+	all := func() {
+		_ = os.Remove(f.Name())
+		cleanup()
+	}
+	return append(fileArgs, "-f", f.Name(), "--project-name", project), all, nil
+}
+
+func loadComposeConfig(dir, file, project string) (composeConfig, error) {
+	cmd := exec.Command("docker", "compose", "-f", file, "--project-name", project, "config", "--format", "json")
+	cmd.Dir = dir
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return composeConfig{}, fmt.Errorf("compose config: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var cfg composeConfig
+	if err := json.Unmarshal(out.Bytes(), &cfg); err != nil {
+		return composeConfig{}, fmt.Errorf("compose config: %w", err)
+	}
+	return cfg, nil
+}
+
+// pinnedVolumes renames the project-scoped named volumes of project to the
+// ones the module slug project uses, so the data follows the module when the
+// green project serves it. Both projects never run at once with named volumes:
+// blueGreenBlockers makes such modules recreate in place.
+func pinnedVolumes(cfg composeConfig, slug, project string) map[string]any {
+	out := map[string]any{}
+	for key, v := range cfg.Volumes {
+		if v.External || v.Name != project+"_"+key {
+			continue
+		}
+		out[key] = map[string]any{"name": slug + "_" + key}
+	}
+	return out
+}
+
+// blueGreenBlockers lists what prevents two copies of the stack from running
+// side by side: fixed container names, published host ports and named
+// volumes, which both copies would write to at the same time.
+func blueGreenBlockers(cfg composeConfig) []string {
+	var reasons []string
+	for key := range cfg.Volumes {
+		reasons = append(reasons, fmt.Sprintf("volume %s would be shared by both stacks", key))
+	}
+	for name, svc := range cfg.Services {
+		if svc.ContainerName != "" {
+			reasons = append(reasons, fmt.Sprintf("service %s sets container_name", name))
+		}
+		for _, p := range svc.Ports {
+			if published := fmt.Sprint(p.Published); p.Published != nil && published != "" && published != "0" {
+				reasons = append(reasons, fmt.Sprintf("service %s publishes host port %s", name, published))
+			}
+		}
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// projectReady reports whether every service of a project is up. Services
+// that exited 0 are done; any other exit or a failing healthcheck is an error.
+func projectReady(states []projectContainerState, services int) (bool, error) {
+	ready := 0
+	for _, s := range states {
+		switch {
+		case s.State == "exited" && s.ExitCode == 0:
+			ready++
+		case s.State == "exited" || s.State == "dead":
+			return false, fmt.Errorf("service %s stopped with exit code %d", s.Service, s.ExitCode)
+		case s.Health == "unhealthy":
+			return false, fmt.Errorf("service %s is unhealthy", s.Service)
+		case s.State == "running" && (s.Health == "" || s.Health == "healthy"):
+			ready++
+		}
+	}
+	return ready >= services, nil
+}
+
+// retargetPage moves a page target from one compose project to the other.
+// Only names scoped to the project change; shared networks and service
+// aliases stay as they are.
+func retargetPage(container, network, from, to string) (string, string) {
+	if strings.HasPrefix(container, from+"-") {
+		container = to + "-" + strings.TrimPrefix(container, from+"-")
+	}
+	if strings.HasPrefix(network, from+"_") {
+		network = to + "_" + strings.TrimPrefix(network, from+"_")
+	}
+	return container, network
+}
+
+func gatewayTargetURL(container string, port int) string {
+	return fmt.Sprintf("http://%s:%d/", container, port)
+}
+
+// composeBuildUp builds project and recreates its services in place. On
+// failure it returns a message naming the step that failed.
+func composeBuildUp(module Module, project, dir, file string) (string, error) {
+	cmdBuild, err := composeBuildCmd(module, dir, file, project)
+	if err != nil {
+		return "Failed to prepare the image build", err
+	}
+	if err := runAndLog(module.ID, cmdBuild); err != nil {
+		return "Failed to docker build", err
+	}
+
+	// module secrets are injected via an override
+	fileArgs, cleanup, err := composeProjectArgs(module, dir, file, project)
+	if err != nil {
+		return "Failed to prepare compose overrides", err
+	}
+	defer cleanup()
+	cmdUp := exec.Command("docker", append(append([]string{"compose"}, fileArgs...), "up", "-d")...)
+	cmdUp.Dir = dir
+	if err := runAndLog(module.ID, cmdUp); err != nil {
+		return "Failed to docker up", err
+	}
+	return "", nil
+}
+
+func composeProjectDown(module Module, dir, file, project string) error {
+	cmd := exec.Command("docker", "compose", "-f", file, "--project-name", project, "down", "--remove-orphans")
+	cmd.Dir = dir
+	return runAndLog(module.ID, cmd)
+}
+
+// blueGreenDeploy starts the new stack under the other compose project,
+// waits for its containers, points the module pages at it and waits for them
+// to answer through their gateways. The old stack is removed on success; on
+// failure the pages go back to it and the new stack is removed instead.
+func blueGreenDeploy(module Module, settings ModuleDeploySettings, dir, file string) (string, error) {
+	active := settings.ActiveProject
+	next := otherComposeProject(module.Slug, active)
+
+	cfg, err := loadComposeConfig(dir, file, next)
+	if err != nil {
+		return "Failed to read compose config", err
+	}
+	if reasons := blueGreenBlockers(cfg); len(reasons) > 0 {
+		LogModule(module.ID, "WARN", "Blue/green deploy not possible, recreating in place", map[string]any{"reasons": reasons}, nil)
+		return composeBuildUp(module, active, dir, file)
+	}
+	running, err := projectHasRunningContainers(active)
+	if err != nil {
+		return "Failed to list containers", err
+	}
+	if !running {
+		// nothing is served, so there is no downtime to avoid
+		return composeBuildUp(module, active, dir, file)
+	}
+
+	LogModule(module.ID, "INFO", fmt.Sprintf("Blue/green deploy: starting %s next to %s", next, active), nil, nil)
+	// leftovers of an interrupted deploy
+	_ = composeProjectDown(module, dir, file, next)
+	if step, err := composeBuildUp(module, next, dir, file); err != nil {
+		_ = composeProjectDown(module, dir, file, next)
+		return step, err
+	}
+
+	ctx, cancel := context.WithTimeout(moduleJobContext(module.ID), time.Duration(settings.HealthTimeoutSeconds)*time.Second)
+	defer cancel()
+	if err := waitProjectReady(ctx, next, len(cfg.Services)); err != nil {
+		_ = composeProjectDown(module, dir, file, next)
+		return fmt.Sprintf("Blue/green deploy: %s never became healthy", next), err
+	}
+
+	flips, err := retargetModulePages(module, active, next)
+	if err == nil {
+		err = waitPagesHealthy(ctx, module, flips)
+	}
+	if err != nil {
+		restoreModulePages(module, flips)
+		_ = composeProjectDown(module, dir, file, next)
+		return fmt.Sprintf("Blue/green deploy: pages never became healthy on %s, rolled back to %s", next, active), err
+	}
+
+	if err := database.SetModuleActiveProject(context.Background(), module.ID, next); err != nil {
+		restoreModulePages(module, flips)
+		_ = composeProjectDown(module, dir, file, next)
+		return "Failed to record the active project", err
+	}
+	LogModule(module.ID, "INFO", fmt.Sprintf("Blue/green deploy: switched to %s, removing %s", next, active), nil, nil)
+	if err := composeProjectDown(module, dir, file, active); err != nil {
+		LogModule(module.ID, "WARN", fmt.Sprintf("Failed to remove the previous stack %s", active), nil, err)
+	}
+	return "", nil
+}
+
+func projectHasRunningContainers(project string) (bool, error) {
+	cli, err := getDockerClient()
+	if err != nil {
+		return false, err
+	}
+	filter := dockerfilters.NewArgs()
+	filter.Add("label", fmt.Sprintf("com.docker.compose.project=%s", project))
+	filter.Add("status", "running")
+	items, err := cli.ContainerList(context.Background(), dockercontainer.ListOptions{Filters: filter})
+	if err != nil {
+		return false, err
+	}
+	return len(items) > 0, nil
+}
+
+func projectContainerStates(ctx context.Context, cli *client.Client, project string) ([]projectContainerState, error) {
+	filter := dockerfilters.NewArgs()
+	filter.Add("label", fmt.Sprintf("com.docker.compose.project=%s", project))
+	items, err := cli.ContainerList(ctx, dockercontainer.ListOptions{All: true, Filters: filter})
+	if err != nil {
+		return nil, err
+	}
+	states := make([]projectContainerState, 0, len(items))
+	for _, item := range items {
+		s := projectContainerState{
+			Service: item.Labels["com.docker.compose.service"],
+			State:   item.State,
+		}
+		if inspect, err := cli.ContainerInspect(ctx, item.ID); err == nil && inspect.State != nil {
+			s.ExitCode = inspect.State.ExitCode
+			if inspect.State.Health != nil {
+				s.Health = inspect.State.Health.Status
+			}
+		}
+		states = append(states, s)
+	}
+	return states, nil
+}
+
+func waitProjectReady(ctx context.Context, project string, services int) error {
+	cli, err := getDockerClient()
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		states, err := projectContainerStates(ctx, cli, project)
+		if err == nil {
+			ready, failed := projectReady(states, services)
+			if failed != nil {
+				return failed
+			}
+			if ready {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for the containers of %s", project)
+		case <-ticker.C:
+		}
+	}
+}
+
+// retargetModulePages points the pages of module from one project to the
+// other. The flips already applied are returned even on error so they can be
+// restored.
+func retargetModulePages(module Module, from, to string) ([]pageFlip, error) {
+	pages, err := database.GetModulePages(database.ModulePagesPagination{ModuleID: &module.ID})
+	if err != nil {
+		return nil, err
+	}
+	var flips []pageFlip
+	for _, p := range pages {
+		if !p.TargetContainer.Valid || !p.TargetPort.Valid {
+			continue
+		}
+		container, network := retargetPage(p.TargetContainer.String, p.NetworkName, from, to)
+		if container == p.TargetContainer.String && network == p.NetworkName {
+			continue
+		}
+		if err := database.SetModulePageTarget(context.Background(), p.ID, container, network); err != nil {
+			return flips, err
+		}
+		flips = append(flips, pageFlip{
+			PageID:       p.ID,
+			PageSlug:     p.Slug,
+			Port:         int(p.TargetPort.Int32),
+			OldContainer: p.TargetContainer.String,
+			OldNetwork:   p.NetworkName,
+			NewContainer: container,
+			NewNetwork:   network,
+		})
+	}
+	return flips, nil
+}
+
+func restoreModulePages(module Module, flips []pageFlip) {
+	for _, f := range flips {
+		if err := database.SetModulePageTarget(context.Background(), f.PageID, f.OldContainer, f.OldNetwork); err != nil {
+			LogModule(module.ID, "ERROR", fmt.Sprintf("Failed to restore the target of page %s", f.PageSlug), nil, err)
+		}
+	}
+}
+
+// waitPagesHealthy waits until the gateway of every flipped page proxies to
+// the new container and the page answers: with its health check when it has
+// one, otherwise with any status below 500.
+func waitPagesHealthy(ctx context.Context, module Module, flips []pageFlip) error {
+	if len(flips) == 0 {
+		return nil
+	}
+	cli, err := getDockerClient()
+	if err != nil {
+		return err
+	}
+	checks := map[string]PageHealthCheck{}
+	if stored, err := database.ListModulePageHealthChecks(ctx, module.ID); err == nil {
+		for _, h := range stored {
+			if h.Enabled {
+				checks[h.PageID] = dbPageHealthToPageHealth(h)
+			}
+		}
+	}
+
+	pending := append([]pageFlip(nil), flips...)
+	lastErr := map[string]string{}
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		var still []pageFlip
+		for _, f := range pending {
+			inspect, err := cli.ContainerInspect(ctx, "gateway-"+dnsSafeSlug(f.PageSlug))
+			if err != nil || inspect.State == nil || !inspect.State.Running ||
+				inspect.Config.Labels[gatewayTargetLabel] != gatewayTargetURL(f.NewContainer, f.Port) {
+				lastErr[f.PageSlug] = "gateway not switched yet"
+				still = append(still, f)
+				continue
+			}
+			check, configured := checks[f.PageID]
+			if !configured {
+				check = PageHealthCheck{PageSlug: f.PageSlug, Path: "/", TimeoutSeconds: 5}
+			}
+			res := probePage(ctx, check)
+			answered := res.OK || (!configured && res.StatusCode != nil && *res.StatusCode < 500)
+			if !answered {
+				if res.Error != "" {
+					lastErr[f.PageSlug] = res.Error
+				} else if res.StatusCode != nil {
+					lastErr[f.PageSlug] = fmt.Sprintf("got HTTP %d", *res.StatusCode)
+				}
+				still = append(still, f)
+			}
+		}
+		if len(still) == 0 {
+			return nil
+		}
+		pending = still
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("page %s: %s", pending[0].PageSlug, lastErr[pending[0].PageSlug])
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestComposeProjects(t *testing.T) {
+	if got := otherComposeProject("wiki", "wiki"); got != "wiki--green" {
+		t.Errorf("from blue: got %q", got)
+	}
+	if got := otherComposeProject("wiki", "wiki--green"); got != "wiki" {
+		t.Errorf("from green: got %q", got)
+	}
+	for _, project := range []string{"wiki", "wiki--green"} {
+		if got := moduleSlugFromProject(project); got != "wiki" {
+			t.Errorf("moduleSlugFromProject(%q) = %q", project, got)
+		}
+	}
+}
+
+func TestRetargetPage(t *testing.T) {
+	cases := []struct {
+		container, network, from, to string
+		wantC, wantN                 string
+	}{
+		{"wiki-web-1", "wiki_default", "wiki", "wiki--green", "wiki--green-web-1", "wiki--green_default"},
+		{"wiki--green-web-1", "wiki--green_front", "wiki--green", "wiki", "wiki-web-1", "wiki_front"},
+		// shared networks and service aliases are not project scoped
+		{"web", "pan-bagnat-net", "wiki", "wiki--green", "web", "pan-bagnat-net"},
+	}
+	for _, c := range cases {
+		gotC, gotN := retargetPage(c.container, c.network, c.from, c.to)
+		if gotC != c.wantC || gotN != c.wantN {
+			t.Errorf("retargetPage(%q, %q): got %q, %q; want %q, %q", c.container, c.network, gotC, gotN, c.wantC, c.wantN)
+		}
+	}
+}
+
+func TestBlueGreenComposeConfig(t *testing.T) {
+	var cfg composeConfig
+	err := json.Unmarshal([]byte(`{
+		"services": {
+			"web": {"ports": [{"target": 80}]},
+			"db": {"container_name": "wiki-db"},
+			"admin": {"ports": [{"target": 80, "published": "8081"}]}
+		},
+		"volumes": {
+			"data": {"name": "wiki--green_data"},
+			"shared": {"name": "shared", "external": true},
+			"named": {"name": "custom"}
+		}
+	}`), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"service admin publishes host port 8081",
+		"service db sets container_name",
+		"volume data would be shared by both stacks",
+		"volume named would be shared by both stacks",
+		"volume shared would be shared by both stacks",
+	}
+	if got := blueGreenBlockers(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("blockers: got %v, want %v", got, want)
+	}
+	stateless := composeConfig{Services: cfg.Services}
+	delete(stateless.Services, "db")
+	delete(stateless.Services, "admin")
+	if got := blueGreenBlockers(stateless); len(got) != 0 {
+		t.Errorf("stateless stack blocked: %v", got)
+	}
+	wantVolumes := map[string]any{"data": map[string]any{"name": "wiki_data"}}
+	if got := pinnedVolumes(cfg, "wiki", "wiki--green"); !reflect.DeepEqual(got, wantVolumes) {
+		t.Errorf("pinned volumes: got %v, want %v", got, wantVolumes)
+	}
+}
+
+func TestProjectReady(t *testing.T) {
+	running := projectContainerState{Service: "web", State: "running"}
+	cases := []struct {
+		name      string
+		states    []projectContainerState
+		services  int
+		wantReady bool
+		wantErr   bool
+	}{
+		{"not created yet", []projectContainerState{running}, 2, false, false},
+		{"all running", []projectContainerState{running, {Service: "db", State: "running", Health: "healthy"}}, 2, true, false},
+		{"health starting", []projectContainerState{running, {Service: "db", State: "running", Health: "starting"}}, 2, false, false},
+		{"one-shot done", []projectContainerState{running, {Service: "migrate", State: "exited"}}, 2, true, false},
+		{"crashed", []projectContainerState{running, {Service: "db", State: "exited", ExitCode: 1}}, 2, false, true},
+		{"unhealthy", []projectContainerState{{Service: "db", State: "running", Health: "unhealthy"}}, 1, false, true},
+	}
+	for _, c := range cases {
+		ready, err := projectReady(c.states, c.services)
+		if ready != c.wantReady || (err != nil) != c.wantErr {
+			t.Errorf("%s: got %v, %v", c.name, ready, err)
+		}
+	}
+}
+
+func TestValidateModuleDeploySettings(t *testing.T) {
+	s := ModuleDeploySettings{}
+	if err := validateModuleDeploySettings(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Strategy != DeployStrategyRecreate || s.HealthTimeoutSeconds != 120 {
+		t.Errorf("defaults: got %+v", s)
+	}
+	for _, bad := range []ModuleDeploySettings{
+		{Strategy: "rolling"},
+		{Strategy: DeployStrategyBlueGreen, HealthTimeoutSeconds: 5},
+	} {
+		if err := validateModuleDeploySettings(&bad); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%+v: got %v, want ErrInvalidInput", bad, err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -11,6 +12,15 @@ import (
 )
 
 func runAndLog(moduleID string, cmd *exec.Cmd) error {
+	// Commands run by a module job stop when the job is cancelled
+	var jobCtx context.Context
+	if v, ok := jobContexts.Load(moduleID); ok {
+		jobCtx = v.(context.Context)
+		if err := jobCtx.Err(); err != nil {
+			return err
+		}
+	}
+
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return fmt.Errorf("StdoutPipe: %w", err)
@@ -23,6 +33,9 @@ func runAndLog(moduleID string, cmd *exec.Cmd) error {
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("cmd.Start: %w", err)
 	}
+	if jobCtx != nil {
+		defer interruptOnCancel(jobCtx, cmd)()
+	}
 
 	var wg sync.WaitGroup
 	// used to wait that each pipe actually finished to read before exiting runAndLog
@@ -0,0 +1,416 @@
+package core
+
+import (
+	"backend/database"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ComposePolicyEnforce = "enforce"
+	ComposePolicyWarn    = "warn"
+	ComposePolicyOff     = "off"
+
+	PolicySeverityError   = "error"
+	PolicySeverityWarning = "warning"
+)
+
+// ComposePolicyRule is a construct the compose policy looks for. Violations
+// of an error rule block the deploy unless the rule is overridden for the
+// module.
+type ComposePolicyRule struct {
+	ID          string `json:"id"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+}
+
+var composePolicyRules = []ComposePolicyRule{
+	{"privileged", PolicySeverityError, "privileged: true gives the container full access to the host"},
+	{"host_network", PolicySeverityError, "network_mode: host shares the host network stack"},
+	{"host_pid", PolicySeverityError, "pid: host shares the host process namespace"},
+	{"host_ipc", PolicySeverityError, "ipc: host shares the host IPC namespace"},
+	{"host_userns", PolicySeverityError, "userns_mode: host disables user namespace remapping"},
+	{"docker_socket", PolicySeverityError, "mounting the Docker or containerd socket gives control over the host"},
+	{"host_root_mount", PolicySeverityError, "bind mount of the host root filesystem"},
+	{"sensitive_mount", PolicySeverityError, "bind mount of a host system directory (/etc, /proc, /sys, /dev, /boot, /root, /run, /var/run, /var/lib/docker)"},
+	{"dangerous_capability", PolicySeverityError, "cap_add of ALL or a capability that allows escaping the container"},
+	{"unconfined_security", PolicySeverityError, "security_opt disabling seccomp, AppArmor, SELinux labels or masked paths"},
+	{"host_bind_mount", PolicySeverityWarning, "bind mount of a host path outside the module repository"},
+	{"devices", PolicySeverityWarning, "host devices passed to the container"},
+	{"host_uts", PolicySeverityWarning, "uts: host shares the host hostname"},
+}
+
+var dangerousCapabilities = map[string]bool{
+	"ALL": true, "SYS_ADMIN": true, "SYS_MODULE": true, "SYS_PTRACE": true, "SYS_RAWIO": true,
+	"SYS_BOOT": true, "DAC_READ_SEARCH": true, "NET_ADMIN": true, "BPF": true, "MAC_ADMIN": true,
+}
+
+var sensitiveHostPaths = []string{"/etc", "/proc", "/sys", "/dev", "/boot", "/root", "/run", "/var/run", "/var/lib/docker"}
+
+// ComposePolicyViolation is one construct found in a module compose file.
+type ComposePolicyViolation struct {
+	Rule       string `json:"rule"`
+	Severity   string `json:"severity"`
+	Service    string `json:"service"`
+	Message    string `json:"message"`
+	Overridden bool   `json:"overridden"`
+}
+
+// ModulePolicyOverride allows a rule for one module.
+type ModulePolicyOverride struct {
+	ModuleID  string       `json:"module_id"`
+	Rule      string       `json:"rule"`
+	Reason    string       `json:"reason"`
+	Actor     *UserSummary `json:"actor,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// ComposePolicyReport is the result of linting a module compose file.
+// Allowed is false when a deploy of this file would be rejected.
+type ComposePolicyReport struct {
+	Mode       string                   `json:"mode"`
+	Allowed    bool                     `json:"allowed"`
+	Violations []ComposePolicyViolation `json:"violations"`
+	Overrides  []ModulePolicyOverride   `json:"overrides"`
+}
+
+// composePolicyConfig is the part of `docker compose config --format json`
+// the policy looks at. Relative paths and variables are already resolved.
+type composePolicyConfig struct {
+	Services map[string]map[string]any `json:"services"`
+	Volumes  map[string]struct {
+		DriverOpts map[string]string `json:"driver_opts"`
+	} `json:"volumes"`
+}
+
+// composePolicyMode reads COMPOSE_POLICY: enforce (default), warn or off.
+func composePolicyMode() string {
+	switch mode := strings.ToLower(strings.TrimSpace(os.Getenv("COMPOSE_POLICY"))); mode {
+	case ComposePolicyWarn, ComposePolicyOff:
+		return mode
+	default:
+		return ComposePolicyEnforce
+	}
+}
+
+// ComposePolicyRules lists the rules the policy checks.
+func ComposePolicyRules() []ComposePolicyRule {
+	return append([]ComposePolicyRule(nil), composePolicyRules...)
+}
+
+func composePolicyRule(id string) (ComposePolicyRule, bool) {
+	for _, r := range composePolicyRules {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return ComposePolicyRule{}, false
+}
+
+func ListModulePolicyOverrides(ctx context.Context, moduleID string) ([]ModulePolicyOverride, error) {
+	rows, err := database.ListModulePolicyOverrides(ctx, moduleID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ModulePolicyOverride, 0, len(rows))
+	for _, o := range rows {
+		item := ModulePolicyOverride{ModuleID: o.ModuleID, Rule: o.Rule, Reason: o.Reason, CreatedAt: o.CreatedAt}
+		if o.ActorUserID.Valid {
+			item.Actor = &UserSummary{ID: o.ActorUserID.String, Login: o.ActorLogin.String}
+		}
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+// SetModulePolicyOverride allows rule for a module. The reason is required
+// and, with the actor, recorded on the override and in the module logs.
+func SetModulePolicyOverride(ctx context.Context, moduleID, rule, reason string, actor *User) (ModulePolicyOverride, error) {
+	if _, ok := composePolicyRule(rule); !ok {
+		return ModulePolicyOverride{}, fmt.Errorf("%w: unknown policy rule %q", ErrInvalidInput, rule)
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return ModulePolicyOverride{}, fmt.Errorf("%w: reason is required", ErrInvalidInput)
+	}
+	row := database.ModulePolicyOverride{ModuleID: moduleID, Rule: rule, Reason: reason}
+	login := "unknown"
+	if actor != nil && actor.ID != "" {
+		row.ActorUserID = sql.NullString{String: actor.ID, Valid: true}
+		login = actor.FtLogin
+	}
+	if err := database.UpsertModulePolicyOverride(ctx, row); err != nil {
+		return ModulePolicyOverride{}, err
+	}
+	LogModule(moduleID, "WARN", fmt.Sprintf("Compose policy rule %s overridden by %s", rule, login), map[string]any{"rule": rule, "reason": reason}, nil)
+
+	overrides, err := ListModulePolicyOverrides(ctx, moduleID)
+	if err != nil {
+		return ModulePolicyOverride{}, err
+	}
+	for _, o := range overrides {
+		if o.Rule == rule {
+			return o, nil
+		}
+	}
+	return ModulePolicyOverride{}, ErrNotFound
+}
+
+func DeleteModulePolicyOverride(ctx context.Context, moduleID, rule string, actor *User) error {
+	if err := database.DeleteModulePolicyOverride(ctx, moduleID, rule); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	login := "unknown"
+	if actor != nil && actor.FtLogin != "" {
+		login = actor.FtLogin
+	}
+	LogModule(moduleID, "INFO", fmt.Sprintf("Compose policy override of %s removed by %s", rule, login), map[string]any{"rule": rule}, nil)
+	return nil
+}
+
+// LintModuleCompose checks a module compose file against the policy. An
+// empty content lints the file saved in the repository; otherwise content is
+// linted as if it replaced it.
+func LintModuleCompose(ctx context.Context, module Module, content string) (ComposePolicyReport, error) {
+	dir, err := ModuleRepoPath(module)
+	if err != nil {
+		return ComposePolicyReport{}, err
+	}
+	file := "docker-compose.yml"
+	if content != "" {
+		f, err := os.CreateTemp(dir, ".lint-*.yml")
+		if err != nil {
+			return ComposePolicyReport{}, err
+		}
+		defer os.Remove(f.Name())
+		_, werr := f.WriteString(content)
+		cerr := f.Close()
+		if werr != nil || cerr != nil {
+			return ComposePolicyReport{}, errors.Join(werr, cerr)
+		}
+		file = filepath.Base(f.Name())
+	}
+	return lintModuleComposeFile(ctx, module, dir, file)
+}
+
+func lintModuleComposeFile(ctx context.Context, module Module, dir, file string) (ComposePolicyReport, error) {
+	report := ComposePolicyReport{Mode: composePolicyMode(), Allowed: true, Violations: []ComposePolicyViolation{}}
+	overrides, err := ListModulePolicyOverrides(ctx, module.ID)
+	if err != nil {
+		return ComposePolicyReport{}, err
+	}
+	report.Overrides = overrides
+	if report.Mode == ComposePolicyOff {
+		return report, nil
+	}
+
+	cfg, err := loadComposePolicyConfig(ctx, dir, file)
+	if err != nil {
+		return ComposePolicyReport{}, err
+	}
+	allowed := make(map[string]bool, len(overrides))
+	for _, o := range overrides {
+		allowed[o.Rule] = true
+	}
+	for _, v := range lintComposeConfig(cfg, dir) {
+		v.Overridden = allowed[v.Rule]
+		if v.Severity == PolicySeverityError && !v.Overridden && report.Mode == ComposePolicyEnforce {
+			report.Allowed = false
+		}
+		report.Violations = append(report.Violations, v)
+	}
+	return report, nil
+}
+
+func loadComposePolicyConfig(ctx context.Context, dir, file string) (composePolicyConfig, error) {
+	cmd := exec.CommandContext(ctx, "docker", "compose", "-f", file, "config", "--format", "json")
+	cmd.Dir = dir
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return composePolicyConfig{}, fmt.Errorf("%w: compose config: %s", ErrInvalidInput, strings.TrimSpace(stderr.String()))
+	}
+	var cfg composePolicyConfig
+	if err := json.Unmarshal(out.Bytes(), &cfg); err != nil {
+		return composePolicyConfig{}, fmt.Errorf("compose config: %w", err)
+	}
+	return cfg, nil
+}
+
+// enforceComposePolicy lints the compose file before a build, writes each
+// violation to the module logs and fails when one is blocking.
+func enforceComposePolicy(module Module, dir, file string) (string, error) {
+	report, err := lintModuleComposeFile(context.Background(), module, dir, file)
+	if err != nil {
+		return "Compose policy check failed", err
+	}
+	var blocking []string
+	for _, v := range report.Violations {
+		meta := map[string]any{"rule": v.Rule, "service": v.Service, "severity": v.Severity}
+		switch {
+		case v.Overridden:
+			LogModule(module.ID, "WARN", "Compose policy (overridden): "+v.Message, meta, nil)
+		case v.Severity == PolicySeverityError && report.Mode == ComposePolicyEnforce:
+			LogModule(module.ID, "ERROR", "Compose policy: "+v.Message, meta, nil)
+			blocking = append(blocking, v.Rule)
+		default:
+			LogModule(module.ID, "WARN", "Compose policy: "+v.Message, meta, nil)
+		}
+	}
+	if len(blocking) > 0 {
+		return "Deploy rejected by compose policy", fmt.Errorf("%w: %s", ErrComposePolicy, strings.Join(uniqueStrings(blocking), ", "))
+	}
+	return "", nil
+}
+
+// lintComposeConfig returns the policy violations of a resolved compose
+// config, sorted by service then rule. repoDir is the module repository,
+// where bind mounts are expected.
+func lintComposeConfig(cfg composePolicyConfig, repoDir string) []ComposePolicyViolation {
+	var out []ComposePolicyViolation
+	add := func(rule, service, format string, args ...any) {
+		r, _ := composePolicyRule(rule)
+		out = append(out, ComposePolicyViolation{
+			Rule:     rule,
+			Severity: r.Severity,
+			Service:  service,
+			Message:  fmt.Sprintf("service %s: ", service) + fmt.Sprintf(format, args...),
+		})
+	}
+
+	for name, svc := range cfg.Services {
+		if b, _ := svc["privileged"].(bool); b {
+			add("privileged", name, "privileged: true")
+		}
+		for key, rule := range map[string]string{
+			"network_mode": "host_network",
+			"pid":          "host_pid",
+			"ipc":          "host_ipc",
+			"userns_mode":  "host_userns",
+			"uts":          "host_uts",
+		} {
+			if s, _ := svc[key].(string); s == "host" {
+				add(rule, name, "%s: host", key)
+			}
+		}
+		for _, c := range stringList(svc["cap_add"]) {
+			cap := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(c)), "CAP_")
+			if dangerousCapabilities[cap] {
+				add("dangerous_capability", name, "cap_add %s", cap)
+			}
+		}
+		for _, opt := range stringList(svc["security_opt"]) {
+			o := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(opt), ":", "="))
+			switch o {
+			case "seccomp=unconfined", "apparmor=unconfined", "label=disable", "systempaths=unconfined":
+				add("unconfined_security", name, "security_opt %s", opt)
+			}
+		}
+		if devices, _ := svc["devices"].([]any); len(devices) > 0 {
+			add("devices", name, "%d host device(s)", len(devices))
+		}
+
+		volumes, _ := svc["volumes"].([]any)
+		for _, raw := range volumes {
+			v, _ := raw.(map[string]any)
+			typ, _ := v["type"].(string)
+			source, _ := v["source"].(string)
+			switch typ {
+			case "bind":
+			case "volume":
+				// A local volume with o=bind mounts its device like a bind mount.
+				opts := cfg.Volumes[source].DriverOpts
+				if !strings.Contains(opts["o"], "bind") || opts["device"] == "" {
+					continue
+				}
+				source = opts["device"]
+			default:
+				continue
+			}
+			if source == "" {
+				continue
+			}
+			lintBindSource(name, resolveHostPath(source), repoDir, add)
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Service != out[j].Service {
+			return out[i].Service < out[j].Service
+		}
+		return out[i].Rule < out[j].Rule
+	})
+	return out
+}
+
+func lintBindSource(service, source, repoDir string, add func(rule, service, format string, args ...any)) {
+	source = filepath.Clean(source)
+	switch base := filepath.Base(source); {
+	case source == "/":
+		add("host_root_mount", service, "bind mount of /")
+		return
+	case base == "docker.sock" || base == "containerd.sock" || base == "podman.sock":
+		add("docker_socket", service, "bind mount of %s", source)
+		return
+	}
+	for _, p := range sensitiveHostPaths {
+		if pathWithin(source, p) {
+			add("sensitive_mount", service, "bind mount of %s", source)
+			return
+		}
+	}
+	if repoDir != "" && pathWithin(source, resolveHostPath(filepath.Clean(repoDir))) {
+		return
+	}
+	add("host_bind_mount", service, "bind mount of %s", source)
+}
+
+// resolveHostPath follows symlinks so a link in the repository cannot hide
+// the real mount source. Paths that do not exist are kept as is.
+func resolveHostPath(p string) string {
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		return resolved
+	}
+	return p
+}
+
+func pathWithin(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+}
+
+func stringList(v any) []string {
+	items, _ := v.([]any)
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func uniqueStrings(in []string) []string {
+	seen := make(map[string]bool, len(in))
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLintComposeConfig(t *testing.T) {
+	raw := `{
+	  "services": {
+	    "web": {
+	      "privileged": true,
+	      "network_mode": "host",
+	      "cap_add": ["NET_BIND_SERVICE", "CAP_SYS_ADMIN"],
+	      "security_opt": ["seccomp:unconfined", "no-new-privileges:true"],
+	      "volumes": [
+	        {"type": "bind", "source": "/var/run/docker.sock", "target": "/var/run/docker.sock"},
+	        {"type": "bind", "source": "/srv/modules/demo/data", "target": "/data"},
+	        {"type": "bind", "source": "/srv/shared", "target": "/shared"}
+	      ]
+	    },
+	    "db": {
+	      "pid": "host",
+	      "devices": [{"source": "/dev/fuse", "target": "/dev/fuse", "permissions": "rwm"}],
+	      "volumes": [
+	        {"type": "volume", "source": "hostroot", "target": "/host"},
+	        {"type": "volume", "source": "pgdata", "target": "/var/lib/postgresql/data"},
+	        {"type": "bind", "source": "/etc/passwd", "target": "/etc/passwd"}
+	      ]
+	    }
+	  },
+	  "volumes": {
+	    "hostroot": {"driver_opts": {"type": "none", "o": "bind", "device": "/"}},
+	    "pgdata": {}
+	  }
+	}`
+	var cfg composePolicyConfig
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]string{}
+	for _, v := range lintComposeConfig(cfg, "/srv/modules/demo") {
+		got[v.Service+"/"+v.Rule] = v.Severity
+	}
+	want := map[string]string{
+		"web/privileged":           PolicySeverityError,
+		"web/host_network":         PolicySeverityError,
+		"web/dangerous_capability": PolicySeverityError,
+		"web/unconfined_security":  PolicySeverityError,
+		"web/docker_socket":        PolicySeverityError,
+		"web/host_bind_mount":      PolicySeverityWarning,
+		"db/host_pid":              PolicySeverityError,
+		"db/devices":               PolicySeverityWarning,
+		"db/host_root_mount":       PolicySeverityError,
+		"db/sensitive_mount":       PolicySeverityError,
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for k, sev := range want {
+		if got[k] != sev {
+			t.Errorf("%s: got %q, want %q", k, got[k], sev)
+		}
+	}
+}
+
+func TestLintComposeConfigClean(t *testing.T) {
+	cfg := composePolicyConfig{Services: map[string]map[string]any{
+		"web": {
+			"network_mode": "bridge",
+			"volumes":      []any{map[string]any{"type": "bind", "source": "/srv/modules/demo/static", "target": "/static"}},
+		},
+	}}
+	if got := lintComposeConfig(cfg, "/srv/modules/demo"); len(got) != 0 {
+		t.Errorf("got %v, want no violations", got)
+	}
+}
+
+func TestPathWithin(t *testing.T) {
+	if !pathWithin("/etc", "/etc") || !pathWithin("/etc/ssl", "/etc") {
+		t.Error("paths under dir not matched")
+	}
+	if pathWithin("/etcetera", "/etc") {
+		t.Error("sibling prefix matched")
+	}
+}
@@ -223,9 +223,10 @@ func waitForComposeContainer(ctx context.Context, cli *dockerclient.Client, modu
 
 func findComposeContainer(ctx context.Context, cli *dockerclient.Client, module Module, service string) (composeContainerRef, bool) {
 	filter := dockerfilters.NewArgs()
-	slug := strings.TrimSpace(module.Slug)
-	if slug != "" {
-		filter.Add("label", fmt.Sprintf("com.docker.compose.project=%s", slug))
+	project := ""
+	if strings.TrimSpace(module.Slug) != "" {
+		project = composeProject(module)
+		filter.Add("label", fmt.Sprintf("com.docker.compose.project=%s", project))
 	}
 	service = strings.TrimSpace(service)
 	containers, err := cli.ContainerList(ctx, dockercontainer.ListOptions{All: true, Filters: filter})
@@ -240,8 +241,8 @@ func findComposeContainer(ctx context.Context, cli *dockerclient.Client, module
 		name := firstDockerName(c.Names)
 		labelSvc := strings.TrimSpace(c.Labels["com.docker.compose.service"])
 		canonical := ""
-		if slug != "" && labelSvc != "" {
-			canonical = fmt.Sprintf("%s-%s-1", slug, labelSvc)
+		if project != "" && labelSvc != "" {
+			canonical = fmt.Sprintf("%s-%s-1", project, labelSvc)
 		}
 		return strings.EqualFold(service, name) ||
 			(labelSvc != "" && strings.EqualFold(service, labelSvc)) ||
@@ -0,0 +1,263 @@
+package core
+
+import (
+	"backend/database"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	DeploySourceManual     = "manual"
+	DeploySourceRebuild    = "rebuild"
+	DeploySourceAutoUpdate = "auto_update"
+	DeploySourceRollback   = "rollback"
+	DeploySourcePreview    = "preview"
+)
+
+// DeployOptions describes who or what started a deployment.
+type DeployOptions struct {
+	Actor      *User
+	Source     string
+	RollbackOf string
+}
+
+type ModuleDeployment struct {
+	ID           string       `json:"id"`
+	ModuleID     string       `json:"module_id"`
+	Actor        *UserSummary `json:"actor,omitempty"`
+	Source       string       `json:"source"`
+	RollbackOf   string       `json:"rollback_of,omitempty"`
+	Status       string       `json:"status"`
+	CommitBefore string       `json:"commit_before"`
+	CommitAfter  string       `json:"commit_after"`
+	ComposeHash  string       `json:"compose_hash"`
+	Error        string       `json:"error,omitempty"`
+	StartedAt    time.Time    `json:"started_at"`
+	FinishedAt   *time.Time   `json:"finished_at,omitempty"`
+	DurationMs   *int64       `json:"duration_ms,omitempty"`
+}
+
+// activeDeployments maps a module ID to the deployment currently running, so
+// LogModule can attach log lines to it.
+var activeDeployments sync.Map
+
+func activeDeploymentID(moduleID string) string {
+	if v, ok := activeDeployments.Load(moduleID); ok {
+		return v.(string)
+	}
+	return ""
+}
+
+func dbDeploymentToDeployment(d database.ModuleDeployment) ModuleDeployment {
+	out := ModuleDeployment{
+		ID:           d.ID,
+		ModuleID:     d.ModuleID,
+		Source:       d.Source,
+		RollbackOf:   d.RollbackOf.String,
+		Status:       d.Status,
+		CommitBefore: d.CommitBefore,
+		CommitAfter:  d.CommitAfter,
+		ComposeHash:  d.ComposeHash,
+		Error:        d.Error,
+		StartedAt:    d.StartedAt,
+	}
+	if d.ActorUserID.Valid {
+		out.Actor = &UserSummary{ID: d.ActorUserID.String, Login: d.ActorLogin.String, PhotoURL: d.ActorPhoto.String}
+	}
+	if d.FinishedAt.Valid {
+		t := d.FinishedAt.Time
+		out.FinishedAt = &t
+	}
+	if d.DurationMs.Valid {
+		ms := d.DurationMs.Int64
+		out.DurationMs = &ms
+	}
+	return out
+}
+
+// recordDeployment runs deploy as a tracked deployment: a module_deployments
+// row is written before and completed after, and every module log line in
+// between is linked to it. A failure to record never blocks the deploy.
+func recordDeployment(module Module, opts DeployOptions, deploy func() error) error {
+	ctx := context.Background()
+	if opts.Source == "" {
+		opts.Source = DeploySourceManual
+	}
+	row := database.ModuleDeployment{
+		ModuleID:    module.ID,
+		Source:      opts.Source,
+		CommitAfter: headCommit(module),
+		ComposeHash: composeFileHash(module),
+	}
+	if opts.Actor != nil && opts.Actor.ID != "" {
+		row.ActorUserID = sql.NullString{String: opts.Actor.ID, Valid: true}
+	}
+	if opts.RollbackOf != "" {
+		row.RollbackOf = sql.NullString{String: opts.RollbackOf, Valid: true}
+	}
+	if prev, err := database.ListSuccessfulModuleDeployments(ctx, module.ID); err == nil && len(prev) > 0 {
+		row.CommitBefore = prev[0].CommitAfter
+	}
+
+	dep, err := database.InsertModuleDeployment(ctx, row)
+	if err != nil {
+		log.Printf("[deployments] failed to record deployment of %s: %v", module.ID, err)
+		return deploy()
+	}
+	activeDeployments.Store(module.ID, dep.ID)
+	deployErr := deploy()
+	activeDeployments.Delete(module.ID)
+
+	status, msg := "success", ""
+	if deployErr != nil {
+		status, msg = "failed", deployErr.Error()
+	}
+	if err := database.FinishModuleDeployment(ctx, dep.ID, status, msg, time.Now().UTC()); err != nil {
+		log.Printf("[deployments] failed to finish deployment %s: %v", dep.ID, err)
+	}
+	return deployErr
+}
+
+func headCommit(module Module) string {
+	out, err := exec.Command("git", "-C", repoDirFor(module), "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return string(bytesTrimSpace(out))
+}
+
+func composeFileHash(module Module) string {
+	dir, err := ModuleRepoPath(module)
+	if err != nil {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "docker-compose.yml"))
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func ListModuleDeployments(ctx context.Context, moduleID string, limit int) ([]ModuleDeployment, error) {
+	rows, err := database.ListModuleDeployments(ctx, moduleID, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ModuleDeployment, 0, len(rows))
+	for _, d := range rows {
+		out = append(out, dbDeploymentToDeployment(d))
+	}
+	return out, nil
+}
+
+func GetModuleDeployment(ctx context.Context, moduleID, deploymentID string) (ModuleDeployment, error) {
+	d, err := database.GetModuleDeployment(ctx, moduleID, deploymentID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ModuleDeployment{}, ErrNotFound
+		}
+		return ModuleDeployment{}, err
+	}
+	return dbDeploymentToDeployment(d), nil
+}
+
+func GetDeploymentLogs(ctx context.Context, deploymentID string) ([]ModuleLog, error) {
+	rows, err := database.ListDeploymentLogs(ctx, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	logs := DatabaseModuleLogsToModuleLogs(rows)
+	if logs == nil {
+		logs = []ModuleLog{}
+	}
+	return logs, nil
+}
+
+// rollbackTarget picks the deployment to roll back to: the given one, or the
+// most recent successful deployment of a commit other than the one currently
+// deployed.
+func rollbackTarget(successes []database.ModuleDeployment, targetID string) (database.ModuleDeployment, error) {
+	if targetID != "" {
+		for _, d := range successes {
+			if d.ID == targetID {
+				if d.CommitAfter == "" {
+					return database.ModuleDeployment{}, fmt.Errorf("%w: deployment %s has no recorded commit", ErrInvalidInput, targetID)
+				}
+				return d, nil
+			}
+		}
+		return database.ModuleDeployment{}, fmt.Errorf("%w: no successful deployment %s", ErrNotFound, targetID)
+	}
+	if len(successes) == 0 {
+		return database.ModuleDeployment{}, fmt.Errorf("%w: no successful deployment to roll back to", ErrNotFound)
+	}
+	current := successes[0].CommitAfter
+	for _, d := range successes[1:] {
+		if d.CommitAfter != "" && d.CommitAfter != current {
+			return d, nil
+		}
+	}
+	return database.ModuleDeployment{}, fmt.Errorf("%w: no previous successful deployment of another commit", ErrNotFound)
+}
+
+// RollbackModule queues a rollback job that checks out the commit of a
+// previous successful deployment and redeploys it. It returns the deployment
+// rolled back to and the queued job.
+func RollbackModule(ctx context.Context, module Module, targetID string, actor *User) (ModuleDeployment, ModuleJob, error) {
+	successes, err := database.ListSuccessfulModuleDeployments(ctx, module.ID)
+	if err != nil {
+		return ModuleDeployment{}, ModuleJob{}, err
+	}
+	target, err := rollbackTarget(successes, targetID)
+	if err != nil {
+		return ModuleDeployment{}, ModuleJob{}, err
+	}
+	if reason := gitTreeNotClean(module); reason != "" {
+		return ModuleDeployment{}, ModuleJob{}, fmt.Errorf("%w: cannot roll back, %s", ErrConflict, reason)
+	}
+	job, err := EnqueueModuleJob(ctx, module, ModuleJobRollback, ModuleJobParams{DeploymentID: target.ID}, actor)
+	if err != nil {
+		return ModuleDeployment{}, ModuleJob{}, err
+	}
+	return dbDeploymentToDeployment(target), job, nil
+}
+
+// runRollback is the body of a rollback job. The tree is checked again since
+// other jobs may have run since it was queued.
+func runRollback(ctx context.Context, module Module, deploymentID string, actor *User) error {
+	target, err := database.GetModuleDeployment(ctx, module.ID, deploymentID)
+	if err != nil {
+		return fmt.Errorf("load deployment %s: %w", deploymentID, err)
+	}
+	if reason := gitTreeNotClean(module); reason != "" {
+		return LogModule(module.ID, "ERROR", "Rollback aborted", nil, fmt.Errorf("%w: %s", ErrConflict, reason))
+	}
+	LogModule(module.ID, "INFO", fmt.Sprintf("Rolling back to %s (deployment %s)", shortHash(target.CommitAfter), target.ID), nil, nil)
+	// Otherwise the update policy would pull and redeploy the commit just rolled back
+	reason := fmt.Sprintf("rolled back to %s", shortHash(target.CommitAfter))
+	if err := PauseModuleUpdates(ctx, module.ID, reason); err != nil {
+		return LogModule(module.ID, "ERROR", "Rollback aborted: cannot pause automatic updates", nil, err)
+	}
+	if err := GitCheckout(module, target.CommitAfter); err != nil {
+		return LogModule(module.ID, "ERROR", "Rollback checkout failed", nil, err)
+	}
+	return DeployModule(module, DeployOptions{Actor: actor, Source: DeploySourceRollback, RollbackOf: target.ID})
+}
+
+func shortHash(h string) string {
+	if len(h) > 7 {
+		return h[:7]
+	}
+	return h
+}
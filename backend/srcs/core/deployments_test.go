@@ -0,0 +1,36 @@
+package core
+
+import (
+	"backend/database"
+	"errors"
+	"testing"
+)
+
+func TestRollbackTarget(t *testing.T) {
+	successes := []database.ModuleDeployment{
+		{ID: "d4", CommitAfter: "ccc"},
+		{ID: "d3", CommitAfter: "ccc"},
+		{ID: "d2", CommitAfter: "bbb"},
+		{ID: "d1", CommitAfter: ""},
+	}
+
+	got, err := rollbackTarget(successes, "")
+	if err != nil || got.ID != "d2" {
+		t.Fatalf("default target = %q, %v; want d2", got.ID, err)
+	}
+	if got, err := rollbackTarget(successes, "d3"); err != nil || got.ID != "d3" {
+		t.Errorf("explicit target = %q, %v; want d3", got.ID, err)
+	}
+	if _, err := rollbackTarget(successes, "d1"); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("target without commit: got %v, want ErrInvalidInput", err)
+	}
+	if _, err := rollbackTarget(successes, "nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown target: got %v, want ErrNotFound", err)
+	}
+	if _, err := rollbackTarget(successes[:2], ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("single commit history: got %v, want ErrNotFound", err)
+	}
+	if _, err := rollbackTarget(nil, ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("empty history: got %v, want ErrNotFound", err)
+	}
+}
@@ -76,7 +76,19 @@ func SaveModuleConfig(module Module, content string) error {
 	return nil
 }
 
-func DeployModule(module Module) error {
+// DeployModule builds and starts the module compose project, recorded as a
+// deployment in the module history. Modules tracking releases first check out
+// their latest matching tag, except for rollbacks.
+func DeployModule(module Module, opts DeployOptions) error {
+	if opts.RollbackOf == "" {
+		if err := checkoutTrackedRelease(module); err != nil {
+			return err
+		}
+	}
+	return recordDeployment(module, opts, func() error { return composeDeploy(module) })
+}
+
+func composeDeploy(module Module) error {
 	dir, err := ModuleRepoPath(module)
 	if err != nil {
 		return LogModule(module.ID, "ERROR", "invalid module slug", nil, err)
@@ -90,24 +102,19 @@ func DeployModule(module Module) error {
 	// Emit WS: deployment starting
 	websocket.SendModuleDeployStatus(module.ID, true, "pending", "")
 
-	// Step 1: docker compose build
-	cmdBuild := exec.Command("docker", "compose", "-f", file, "build")
-	cmdBuild.Dir = dir
-	err = runAndLog(module.ID, cmdBuild)
-	if err != nil {
-		_, _ = database.PatchModule(database.ModulePatch{ID: module.ID, IsDeploying: ptrBool(false), LastDeployStatus: strPtr("failed")})
-		websocket.SendModuleDeployStatus(module.ID, false, "failed", "")
-		return LogModule(module.ID, "ERROR", "Failed to docker build", nil, err)
+	step, err := enforceComposePolicy(module, dir, file)
+	if err == nil {
+		settings := moduleDeploySettings(module)
+		if settings.Strategy == DeployStrategyBlueGreen {
+			step, err = blueGreenDeploy(module, settings, dir, file)
+		} else {
+			step, err = composeBuildUp(module, settings.ActiveProject, dir, file)
+		}
 	}
-
-	// Step 2: docker compose up -d
-	cmdUp := exec.Command("docker", "compose", "-f", file, "up", "-d")
-	cmdUp.Dir = dir
-	err = runAndLog(module.ID, cmdUp)
 	if err != nil {
 		_, _ = database.PatchModule(database.ModulePatch{ID: module.ID, IsDeploying: ptrBool(false), LastDeployStatus: strPtr("failed")})
 		websocket.SendModuleDeployStatus(module.ID, false, "failed", "")
-		return LogModule(module.ID, "ERROR", "Failed to docker up", nil, err)
+		return LogModule(module.ID, "ERROR", step, nil, err)
 	}
 
 	// Mark success: set last_deploy to now, clear in-progress, and set success status
@@ -120,21 +127,40 @@ func DeployModule(module Module) error {
 	return nil
 }
 
-// ComposeRebuild rebuilds images without cache and restarts containers.
-func ComposeRebuild(module Module) error {
+// ComposeRebuild rebuilds images without cache and restarts containers. It is
+// recorded as a deployment too.
+func ComposeRebuild(module Module, opts DeployOptions) error {
+	if opts.Source == "" {
+		opts.Source = DeploySourceRebuild
+	}
+	return recordDeployment(module, opts, func() error { return composeRebuild(module) })
+}
+
+func composeRebuild(module Module) error {
 	dir, err := ModuleRepoPath(module)
 	if err != nil {
 		return err
 	}
 	file := "docker-compose.yml"
+	if step, err := enforceComposePolicy(module, dir, file); err != nil {
+		return LogModule(module.ID, "ERROR", step, nil, err)
+	}
+	project := composeProject(module)
 	LogModule(module.ID, "INFO", "docker compose build --no-cache", nil, nil)
-	cmdBuild := exec.Command("docker", "compose", "-f", file, "--project-name", module.Slug, "build", "--no-cache")
-	cmdBuild.Dir = dir
+	cmdBuild, err := composeBuildCmd(module, dir, file, project, "--no-cache")
+	if err != nil {
+		return LogModule(module.ID, "ERROR", "Failed to prepare the image build", nil, err)
+	}
 	if err := runAndLog(module.ID, cmdBuild); err != nil {
 		return err
 	}
 	LogModule(module.ID, "INFO", "docker compose up -d", nil, nil)
-	cmdUp := exec.Command("docker", "compose", "-f", file, "--project-name", module.Slug, "up", "-d")
+	fileArgs, cleanup, err := composeProjectArgs(module, dir, file, project)
+	if err != nil {
+		return LogModule(module.ID, "ERROR", "Failed to prepare compose overrides", nil, err)
+	}
+	defer cleanup()
+	cmdUp := exec.Command("docker", append(append([]string{"compose"}, fileArgs...), "up", "-d")...)
 	cmdUp.Dir = dir
 	if err := runAndLog(module.ID, cmdUp); err != nil {
 		return err
@@ -151,9 +177,7 @@ func ComposeDown(module Module) error {
 	}
 	file := "docker-compose.yml"
 	LogModule(module.ID, "INFO", "docker compose down --remove-orphans", nil, nil)
-	cmd := exec.Command("docker", "compose", "-f", file, "--project-name", module.Slug, "down", "--remove-orphans")
-	cmd.Dir = dir
-	if err := runAndLog(module.ID, cmd); err != nil {
+	if err := composeProjectDown(module, dir, file, composeProject(module)); err != nil {
 		return err
 	}
 	notifyContainersChanged(module)
@@ -178,8 +202,7 @@ func ptrBool(b bool) *bool    { return &b }
 func strPtr(s string) *string { return &s }
 
 func GetModuleContainers(module Module) ([]ModuleContainer, error) {
-	project := strings.TrimSpace(module.Slug)
-	if project == "" {
+	if strings.TrimSpace(module.Slug) == "" {
 		return nil, fmt.Errorf("missing module slug")
 	}
 	cli, err := getDockerClient()
@@ -188,7 +211,7 @@ func GetModuleContainers(module Module) ([]ModuleContainer, error) {
 	}
 	ctx := context.Background()
 	filter := dockerfilters.NewArgs()
-	filter.Add("label", fmt.Sprintf("com.docker.compose.project=%s", project))
+	filter.Add("label", fmt.Sprintf("com.docker.compose.project=%s", composeProject(module)))
 	items, err := cli.ContainerList(ctx, dockercontainer.ListOptions{All: true, Filters: filter})
 	if err != nil {
 		return nil, fmt.Errorf("list containers failed: %w", err)
@@ -218,10 +241,10 @@ func findModuleContainer(ctx context.Context, cli *client.Client, module Module,
 	if cli == nil || containerName == "" {
 		return dockercontainer.Summary{}, false
 	}
-	project := strings.TrimSpace(module.Slug)
-	if project == "" {
+	if strings.TrimSpace(module.Slug) == "" {
 		return dockercontainer.Summary{}, false
 	}
+	project := composeProject(module)
 	filter := dockerfilters.NewArgs()
 	filter.Add("label", fmt.Sprintf("com.docker.compose.project=%s", project))
 	items, err := cli.ContainerList(ctx, dockercontainer.ListOptions{All: true, Filters: filter})
@@ -408,7 +431,7 @@ func CollectModuleNetworkAliases(module Module) (map[string][]string, []string,
 	}
 	ctx := context.Background()
 	filter := dockerfilters.NewArgs()
-	filter.Add("label", fmt.Sprintf("com.docker.compose.project=%s", slug))
+	filter.Add("label", fmt.Sprintf("com.docker.compose.project=%s", composeProject(module)))
 	list, err := cli.ContainerList(ctx, dockercontainer.ListOptions{All: true, Filters: filter})
 	if err != nil {
 		return nil, nil, fmt.Errorf("list containers failed: %w", err)
@@ -503,7 +526,8 @@ func GetAllContainers() ([]AllContainer, error) {
 			}
 			continue
 		}
-		cfgCmd := exec.Command("docker", "compose", "-f", "docker-compose.yml", "--project-name", m.Slug, "config", "--format", "json")
+		project := composeProject(m)
+		cfgCmd := exec.Command("docker", "compose", "-f", "docker-compose.yml", "--project-name", project, "config", "--format", "json")
 		cfgCmd.Dir = dir
 		var out bytes.Buffer
 		cfgCmd.Stdout = &out
@@ -527,7 +551,7 @@ func GetAllContainers() ([]AllContainer, error) {
 			}
 		}
 		filter := dockerfilters.NewArgs()
-		filter.Add("label", fmt.Sprintf("com.docker.compose.project=%s", m.Slug))
+		filter.Add("label", fmt.Sprintf("com.docker.compose.project=%s", project))
 		if current, err := cli.ContainerList(ctx, dockercontainer.ListOptions{All: true, Filters: filter}); err == nil {
 			for _, c := range current {
 				for name := range c.NetworkSettings.Networks {
@@ -541,7 +565,7 @@ func GetAllContainers() ([]AllContainer, error) {
 		for n := range netsSet {
 			networks = append(networks, n)
 		}
-		expects[m.Slug] = composeInfo{Services: services, Networks: networks}
+		expects[project] = composeInfo{Services: services, Networks: networks}
 	}
 
 	type containerInfo struct {
@@ -593,7 +617,7 @@ func GetAllContainers() ([]AllContainer, error) {
 		for _, net := range info.Networks {
 			containersByNetwork[net] = append(containersByNetwork[net], info)
 		}
-		if m, ok := modBySlug[info.Project]; ok {
+		if m, ok := modBySlug[moduleSlugFromProject(info.Project)]; ok {
 			status, reason, since := parseContainerStatus(info.Status)
 			results[info.Name] = AllContainer{
 				Name:       info.Name,
@@ -621,7 +645,7 @@ func GetAllContainers() ([]AllContainer, error) {
 			}
 			processed[info.ID] = true
 			status, reason, since := parseContainerStatus(info.Status)
-			if m, ok := modBySlug[info.Project]; ok {
+			if m, ok := modBySlug[moduleSlugFromProject(info.Project)]; ok {
 				results[info.Name] = AllContainer{
 					Name:       info.Name,
 					Status:     status,
@@ -659,18 +683,18 @@ func GetAllContainers() ([]AllContainer, error) {
 		}
 	}
 
-	for slug, ci := range expects {
+	for project, ci := range expects {
 		for _, svc := range ci.Services {
-			expectedName := fmt.Sprintf("%s-%s-1", slug, svc)
+			expectedName := fmt.Sprintf("%s-%s-1", project, svc)
 			if _, ok := results[expectedName]; ok {
 				continue
 			}
-			m := modBySlug[slug]
+			m := modBySlug[moduleSlugFromProject(project)]
 			results[expectedName] = AllContainer{
 				Name:       expectedName,
 				Status:     ContainerUnknown,
 				Reason:     "Not created",
-				Project:    slug,
+				Project:    project,
 				Networks:   ci.Networks,
 				ModuleID:   m.ID,
 				ModuleName: m.Name,
@@ -699,7 +723,7 @@ func GetContainerLogs(module Module, containerName string, since string) ([]stri
 		target = ref.ID
 	}
 	if target == "" {
-		target = fmt.Sprintf("%s-%s-1", module.Slug, containerName)
+		target = fmt.Sprintf("%s-%s-1", composeProject(module), containerName)
 	}
 	opts := dockercontainer.LogsOptions{
 		ShowStdout: true,
@@ -735,10 +759,13 @@ func CleanupModuleDockerResources(module Module) error {
 	}
 	file := "docker-compose.yml"
 
-	cmdDown := exec.Command("docker", "compose", "-f", file, "down", "--volumes", "--remove-orphans", "--rmi", "all")
-	cmdDown.Dir = dir
-	if err := runAndLog(module.ID, cmdDown); err != nil {
-		return LogModule(module.ID, "ERROR", "Failed to docker compose down", nil, err)
+	// a blue/green module may have a stack under either project
+	for _, project := range []string{module.Slug + greenProjectSuffix, module.Slug} {
+		cmdDown := exec.Command("docker", "compose", "-f", file, "--project-name", project, "down", "--volumes", "--remove-orphans", "--rmi", "all")
+		cmdDown.Dir = dir
+		if err := runAndLog(module.ID, cmdDown); err != nil {
+			return LogModule(module.ID, "ERROR", "Failed to docker compose down", nil, err)
+		}
 	}
 
 	cli, err := getDockerClient()
@@ -752,6 +779,10 @@ func CleanupModuleDockerResources(module Module) error {
 		return LogModule(module.ID, "ERROR", "Failed to prune images", nil, err)
 	}
 
+	if _, err := pruneModuleBuilders(module, ""); err != nil {
+		LogModule(module.ID, "WARN", "Failed to remove the image builders", nil, err)
+	}
+
 	LogModule(module.ID, "INFO", "docker cleanup completed", nil, nil)
 	SetModuleStatus(module.ID, Disabled, false)
 	notifyContainersChanged(module)
@@ -801,7 +832,7 @@ func runDockerCommand(module Module, containerName, action string) error {
 		target = ref.ID
 	}
 	if target == "" {
-		target = fmt.Sprintf("%s-%s-1", module.Slug, containerName)
+		target = fmt.Sprintf("%s-%s-1", composeProject(module), containerName)
 	}
 	fmt.Printf("[Docker] docker %s %s\n", action, target)
 	switch action {
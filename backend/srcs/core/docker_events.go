@@ -89,7 +89,7 @@ func handleDockerEventMessage(msg eventtypes.Message) {
 	if !ok {
 		return
 	}
-	module, ok := moduleFromSlug(moduleSlug)
+	module, ok := moduleFromSlug(moduleSlugFromProject(moduleSlug))
 	if !ok {
 		return
 	}
@@ -240,7 +240,7 @@ func preloadComposeContainers() {
 		if !ok {
 			continue
 		}
-		mod, ok := moduleFromSlug(module)
+		mod, ok := moduleFromSlug(moduleSlugFromProject(module))
 		if !ok {
 			continue
 		}
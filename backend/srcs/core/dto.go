@@ -45,7 +45,6 @@ func DatabaseModuleToModule(dbModule database.Module) Module {
 	return Module{
 		ID:            dbModule.ID,
 		SSHPublicKey:  dbModule.SSHPublicKey,
-		SSHPrivateKey: dbModule.SSHPrivateKey,
 		SSHKeyID:      dbModule.SSHKeyID,
 		Name:          dbModule.Name,
 		Slug:          dbModule.Slug,
@@ -102,12 +101,13 @@ func DatabaseModulesToModules(dbModules []database.Module) (dest []Module) {
 
 func DatabaseModuleLogToModuleLog(dbLog database.ModuleLog) ModuleLog {
 	return ModuleLog{
-		ID:        dbLog.ID,
-		ModuleID:  dbLog.ModuleID,
-		Level:     dbLog.Level,
-		Message:   dbLog.Message,
-		Meta:      dbLog.Meta,
-		CreatedAt: dbLog.CreatedAt,
+		ID:           dbLog.ID,
+		ModuleID:     dbLog.ModuleID,
+		Level:        dbLog.Level,
+		Message:      dbLog.Message,
+		Meta:         dbLog.Meta,
+		CreatedAt:    dbLog.CreatedAt,
+		DeploymentID: dbLog.DeploymentID,
 	}
 }
 
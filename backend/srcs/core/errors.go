@@ -15,6 +15,18 @@ var (
 	// ErrConflict is returned when an operation conflicts with the current state.
 	ErrConflict = errors.New("conflict")
 
+	// ErrUnauthorized is returned when a request signature or token does not verify.
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrComposePolicy is returned when a compose file breaks a blocking policy rule.
+	ErrComposePolicy = errors.New("compose policy violation")
+
+	// ErrPushRejected is returned when the remote refuses a git push.
+	ErrPushRejected = errors.New("push rejected")
+
+	// ErrManagedDatabaseDisabled is returned when no managed Postgres is configured.
+	ErrManagedDatabaseDisabled = errors.New("managed databases are disabled")
+
 	// ErrPaginationTokenInvalid is returned when a pagination token is malformed or expired.
 	ErrPaginationTokenInvalid = errors.New("invalid pagination token")
 
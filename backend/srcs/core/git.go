@@ -31,10 +31,10 @@ func CloneModuleRepo(module Module) error {
 		return LogModule(module.ID, "ERROR", "invalid module slug", nil, err)
 	}
 
-	// Prepare a temporary SSH key for this clone
-	sshCommand, cleanup, err := tempSSHForModule(module)
+	// Prepare temporary git credentials (SSH key or HTTPS token) for this clone
+	gitEnv, cleanup, err := gitAuthForModule(module)
 	if err != nil {
-		return LogModule(module.ID, "ERROR", "failed to prepare temp ssh key", nil, err)
+		return LogModule(module.ID, "ERROR", "failed to prepare git credentials", nil, err)
 	}
 	defer cleanup()
 	var cmd *exec.Cmd
@@ -52,7 +52,11 @@ func CloneModuleRepo(module Module) error {
 			targetDir,
 		)
 	}
-	cmd.Env = append(os.Environ(), "GIT_SSH_COMMAND="+sshCommand)
+	cmd.Env = append(os.Environ(), gitEnv...)
+	if moduleGitOptions(module).LFS {
+		// LFS objects are downloaded afterwards by syncModuleRepoExtras
+		cmd.Env = append(cmd.Env, "GIT_LFS_SKIP_SMUDGE=1")
+	}
 
 	err = runAndLog(module.ID, cmd)
 	if err != nil {
@@ -64,9 +68,18 @@ func CloneModuleRepo(module Module) error {
 			err,
 		)
 	}
+	if err := syncModuleRepoExtras(module, targetDir, gitEnv); err != nil {
+		_ = finishModuleClone(module, targetDir)
+		return err
+	}
+	return finishModuleClone(module, targetDir)
+}
 
+// finishModuleClone marks a freshly created repository as cloned: the module
+// is disabled until deployed and its git metadata is computed.
+func finishModuleClone(module Module, targetDir string) error {
 	newStatus := "disabled"
-	_, err = database.PatchModule(database.ModulePatch{
+	_, err := database.PatchModule(database.ModulePatch{
 		ID:     module.ID,
 		Status: &newStatus,
 	})
@@ -84,6 +97,7 @@ func CloneModuleRepo(module Module) error {
 	// Initialize git metadata in DB (timestamps, commits, behind count)
 	now := time.Now().UTC()
 	_ = updateGitComputed(module, &now, &now)
+	checkModuleManifest(module)
 	return nil
 }
 
@@ -95,10 +109,22 @@ func PullModuleRepo(module Module) error {
 	// Ensure safe.directory before issuing git commands
 	_ = ensureSafeDirectory(module, targetDir)
 
-	// Prepare a temporary SSH key for this pull session
-	sshCommand, cleanup, err := tempSSHForModule(module)
+	// Modules tracking releases move to the latest matching tag instead
+	if c, _ := moduleReleaseConstraint(module); c != nil {
+		if err := GitFetchModule(module); err != nil {
+			return err
+		}
+		return checkoutTrackedRelease(module)
+	}
+	if currentBranch(targetDir) == "" {
+		return LogModule(module.ID, "ERROR", "HEAD is detached, check out a branch before pulling", nil,
+			fmt.Errorf("%w: HEAD is detached, check out a branch before pulling", ErrConflict))
+	}
+
+	// Prepare temporary git credentials for this pull session
+	gitEnv, cleanup, err := gitAuthForModule(module)
 	if err != nil {
-		return LogModule(module.ID, "ERROR", "failed to prepare temp ssh key", nil, err)
+		return LogModule(module.ID, "ERROR", "failed to prepare git credentials", nil, err)
 	}
 	defer cleanup()
 
@@ -126,7 +152,7 @@ func PullModuleRepo(module Module) error {
 
 	// Always fetch all remotes and prune before pulling
 	cmdFetchAll := exec.Command("git", "-C", targetDir, "fetch", "--all", "--prune")
-	cmdFetchAll.Env = append(os.Environ(), "GIT_SSH_COMMAND="+sshCommand)
+	cmdFetchAll.Env = append(os.Environ(), gitEnv...)
 	if e := runAndLog(module.ID, cmdFetchAll); e != nil {
 		LogModule(module.ID, "WARN", "git fetch --all failed before pull", nil, e)
 	}
@@ -143,7 +169,7 @@ func PullModuleRepo(module Module) error {
 		if upOut, upErr := cmdUp.CombinedOutput(); upErr != nil || len(bytesTrimSpace(upOut)) == 0 {
 			// Check if origin/<branch> exists
 			cmdLs := exec.Command("git", "-C", targetDir, "ls-remote", "--heads", "origin", curBranch)
-			cmdLs.Env = append(os.Environ(), "GIT_SSH_COMMAND="+sshCommand)
+			cmdLs.Env = append(os.Environ(), gitEnv...)
 			lsOut, _ := cmdLs.CombinedOutput()
 			if len(bytesTrimSpace(lsOut)) > 0 {
 				setUp := exec.Command("git", "-C", targetDir, "branch", "--set-upstream-to=origin/"+curBranch)
@@ -166,7 +192,7 @@ func PullModuleRepo(module Module) error {
 
 	// Pull in merge mode (no rebase)
 	cmd := exec.Command("git", "-C", targetDir, "-c", "pull.rebase=false", "pull")
-	cmd.Env = append(os.Environ(), "GIT_SSH_COMMAND="+sshCommand)
+	cmd.Env = append(os.Environ(), gitEnv...)
 	err = runAndLog(module.ID, cmd)
 	if err != nil {
 		return LogModule(module.ID, "ERROR", "git pull failed", nil, err)
@@ -187,6 +213,10 @@ func PullModuleRepo(module Module) error {
 	now := time.Now().UTC()
 	_ = updateGitComputed(module, &now, &now)
 	broadcastGitStatus(module)
+	checkModuleManifest(module)
+	if err := syncModuleRepoExtras(module, targetDir, gitEnv); err != nil {
+		return err
+	}
 	return LogModule(module.ID, "INFO", fmt.Sprintf("Pulled module from URL %s", module.GitURL), nil, nil)
 }
 
@@ -229,6 +259,8 @@ type GitStatus struct {
 	LatestHash    string   `json:"latest_hash"`
 	LatestSubject string   `json:"latest_subject"`
 	Behind        int      `json:"behind"`
+	// Submodules is empty when the repository has none.
+	Submodules []GitSubmoduleStatus `json:"submodules"`
 }
 
 func repoDirFor(module Module) string {
@@ -242,14 +274,14 @@ func repoDirFor(module Module) string {
 func GitFetchModule(module Module) error {
 	repoDir := repoDirFor(module)
 	_ = ensureSafeDirectory(module, repoDir)
-	// Use a temporary SSH key for this fetch
-	sshCommand, cleanup, err := tempSSHForModule(module)
+	// Use temporary git credentials for this fetch
+	gitEnv, cleanup, err := gitAuthForModule(module)
 	if err != nil {
-		return LogModule(module.ID, "ERROR", "failed to prepare temp ssh key", nil, err)
+		return LogModule(module.ID, "ERROR", "failed to prepare git credentials", nil, err)
 	}
 	defer cleanup()
 	cmd := exec.Command("git", "-C", repoDir, "fetch", "--all")
-	cmd.Env = append(os.Environ(), "GIT_SSH_COMMAND="+sshCommand)
+	cmd.Env = append(os.Environ(), gitEnv...)
 	if err := runAndLog(module.ID, cmd); err != nil {
 		return LogModule(module.ID, "ERROR", "git fetch failed", nil, err)
 	}
@@ -263,7 +295,7 @@ func GitStatusModule(module Module) (GitStatus, error) {
 	repoDir := repoDirFor(module)
 	_ = ensureSafeDirectory(module, repoDir)
 	// Prepare temp SSH for any network fetch during status computation
-	sshCommand, cleanup, _ := tempSSHForModule(module)
+	gitEnv, cleanup, _ := gitAuthForModule(module)
 	if cleanup != nil {
 		defer cleanup()
 	}
@@ -349,8 +381,8 @@ func GitStatusModule(module Module) (GitStatus, error) {
 	}
 	if up != "" {
 		cmdFetch := exec.Command("git", "-C", repoDir, "fetch", "--all", "--prune")
-		if sshCommand != "" {
-			cmdFetch.Env = append(os.Environ(), "GIT_SSH_COMMAND="+sshCommand)
+		if len(gitEnv) > 0 {
+			cmdFetch.Env = append(os.Environ(), gitEnv...)
 		}
 		_ = cmdFetch.Run()
 		latestLine, _ := exec.Command("git", "-C", repoDir, "log", "-1", up, "--pretty=%H%x1f%s").CombinedOutput()
@@ -377,6 +409,7 @@ func GitStatusModule(module Module) (GitStatus, error) {
 		fmt.Sscanf(string(bytesTrimSpace(cntB)), "%d %d", &left, &right)
 		st.Behind = right
 	}
+	st.Submodules = gitSubmoduleStatus(repoDir)
 	if !module.GitLastPull.IsZero() {
 		st.LastPull = module.GitLastPull.UTC().Format(time.RFC3339)
 	}
@@ -481,7 +514,7 @@ func GitListCommitsRef(module Module, ref string, limit int) ([]GitCommit, error
 	repoDir := repoDirFor(module)
 	_ = ensureSafeDirectory(module, repoDir)
 	// Temp SSH for any fetches here
-	sshCommand, cleanup, _ := tempSSHForModule(module)
+	gitEnv, cleanup, _ := gitAuthForModule(module)
 	if cleanup != nil {
 		defer cleanup()
 	}
@@ -514,21 +547,21 @@ func GitListCommitsRef(module Module, ref string, limit int) ([]GitCommit, error
 		parts := strings.SplitN(remoteRef, "/", 2)
 		if len(parts) == 2 {
 			c := exec.Command("git", "-C", repoDir, "fetch", parts[0], parts[1])
-			if sshCommand != "" {
-				c.Env = append(os.Environ(), "GIT_SSH_COMMAND="+sshCommand)
+			if len(gitEnv) > 0 {
+				c.Env = append(os.Environ(), gitEnv...)
 			}
 			_ = runAndLog(module.ID, c)
 		} else {
 			c := exec.Command("git", "-C", repoDir, "fetch", "--all", "--prune")
-			if sshCommand != "" {
-				c.Env = append(os.Environ(), "GIT_SSH_COMMAND="+sshCommand)
+			if len(gitEnv) > 0 {
+				c.Env = append(os.Environ(), gitEnv...)
 			}
 			_ = runAndLog(module.ID, c)
 		}
 	} else {
 		c := exec.Command("git", "-C", repoDir, "fetch", "--all", "--prune")
-		if sshCommand != "" {
-			c.Env = append(os.Environ(), "GIT_SSH_COMMAND="+sshCommand)
+		if len(gitEnv) > 0 {
+			c.Env = append(os.Environ(), gitEnv...)
 		}
 		_ = runAndLog(module.ID, c)
 	}
@@ -569,7 +602,7 @@ func GitListCommitsRef(module Module, ref string, limit int) ([]GitCommit, error
 func GitListBranches(module Module) ([]GitBranch, error) {
 	repoDir := repoDirFor(module)
 	_ = ensureSafeDirectory(module, repoDir)
-	sshCommand, cleanup, _ := tempSSHForModule(module)
+	gitEnv, cleanup, _ := gitAuthForModule(module)
 	if cleanup != nil {
 		defer cleanup()
 	}
@@ -583,8 +616,8 @@ func GitListBranches(module Module) ([]GitBranch, error) {
 	}
 	// Query remote branches from origin
 	cmd := exec.Command("git", "-C", repoDir, "ls-remote", "--heads", "origin")
-	if sshCommand != "" {
-		cmd.Env = append(os.Environ(), "GIT_SSH_COMMAND="+sshCommand)
+	if len(gitEnv) > 0 {
+		cmd.Env = append(os.Environ(), gitEnv...)
 	}
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -625,7 +658,7 @@ func GitCheckout(module Module, ref string) error {
 	repoDir := repoDirFor(module)
 	_ = ensureSafeDirectory(module, repoDir)
 	// Temp SSH for network fetch
-	sshCommand, cleanup, _ := tempSSHForModule(module)
+	gitEnv, cleanup, _ := gitAuthForModule(module)
 	if cleanup != nil {
 		defer cleanup()
 	}
@@ -633,8 +666,8 @@ func GitCheckout(module Module, ref string) error {
 	// Fetch all remotes and prune deleted branches
 	{
 		c := exec.Command("git", "-C", repoDir, "fetch", "--all", "--prune")
-		if sshCommand != "" {
-			c.Env = append(os.Environ(), "GIT_SSH_COMMAND="+sshCommand)
+		if len(gitEnv) > 0 {
+			c.Env = append(os.Environ(), gitEnv...)
 		}
 		if e := runAndLog(module.ID, c); e != nil {
 			return e
@@ -713,7 +746,7 @@ func GitCheckout(module Module, ref string) error {
 	now := time.Now().UTC()
 	_ = updateGitComputed(module, &now, nil)
 	broadcastGitStatus(module)
-	return nil
+	return syncModuleRepoExtras(module, repoDir, gitEnv)
 }
 
 // updateGitComputed recomputes commit metadata and behind counts and patches DB.
@@ -721,10 +754,14 @@ func updateGitComputed(module Module, updatedAt *time.Time, pulledAt *time.Time)
 	repoDir := repoDirFor(module)
 	_ = ensureSafeDirectory(module, repoDir)
 	// Temp SSH for any fetch during computation
-	sshCommand, cleanup, _ := tempSSHForModule(module)
+	gitEnv, cleanup, _ := gitAuthForModule(module)
 	if cleanup != nil {
 		defer cleanup()
 	}
+	releases, prereleases := moduleReleaseConstraint(module)
+	if releases != nil {
+		_ = fetchModuleTags(module, repoDir, gitEnv)
+	}
 	up := ""
 	if out, err := exec.Command("git", "-C", repoDir, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{u}").CombinedOutput(); err == nil {
 		up = string(bytesTrimSpace(out))
@@ -736,8 +773,8 @@ func updateGitComputed(module Module, updatedAt *time.Time, pulledAt *time.Time)
 		parts := strings.SplitN(up, "/", 2)
 		if len(parts) == 2 {
 			c := exec.Command("git", "-C", repoDir, "fetch", parts[0], parts[1])
-			if sshCommand != "" {
-				c.Env = append(os.Environ(), "GIT_SSH_COMMAND="+sshCommand)
+			if len(gitEnv) > 0 {
+				c.Env = append(os.Environ(), gitEnv...)
 			}
 			_ = runAndLog(module.ID, c)
 		}
@@ -798,6 +835,9 @@ func updateGitComputed(module Module, updatedAt *time.Time, pulledAt *time.Time)
 	if latestSubj != "" {
 		patch.LatestCommitSubject = &latestSubj
 	}
+	if releases != nil {
+		applyReleaseSnapshot(repoDir, headHash, releases, prereleases, &patch)
+	}
 	_, err := database.PatchModule(patch)
 	return err
 }
@@ -822,7 +862,7 @@ func GitBehind(module Module) (int, error) {
 	repoDir := repoDirFor(module)
 	if err := ensureSafeDirectory(module, repoDir); err != nil { /* ignore */
 	}
-	sshCommand, cleanup, _ := tempSSHForModule(module)
+	gitEnv, cleanup, _ := gitAuthForModule(module)
 	if cleanup != nil {
 		defer cleanup()
 	}
@@ -838,8 +878,8 @@ func GitBehind(module Module) (int, error) {
 	}
 	// Make sure upstream is fetched
 	c := exec.Command("git", "-C", repoDir, "fetch", "--all", "--prune")
-	if sshCommand != "" {
-		c.Env = append(os.Environ(), "GIT_SSH_COMMAND="+sshCommand)
+	if len(gitEnv) > 0 {
+		c.Env = append(os.Environ(), gitEnv...)
 	}
 	_ = c.Run()
 	out, err := exec.Command("git", "-C", repoDir, "rev-list", "--left-right", "--count", "HEAD..."+up).CombinedOutput()
@@ -990,16 +1030,31 @@ func ensureSafeDirectory(module Module, repoDir string) error {
 	return nil
 }
 
-// tempSSHForModule creates a temporary private key file from the module's
-// SSHPrivateKey and returns an ssh command string and a cleanup function.
+// tempSSHForModule decrypts the module's SSH key into a temporary private key
+// file and returns an ssh command string and a cleanup function. cleanup is
+// never nil.
 func tempSSHForModule(module Module) (string, func(), error) {
+	noop := func() {}
+	keyID := module.SSHKeyID
+	if keyID == "" {
+		m, err := database.GetModule(module.ID)
+		if err != nil {
+			return "", noop, err
+		}
+		keyID = m.SSHKeyID
+	}
+	privateKey, err := sshPrivateKey(keyID)
+	if err != nil {
+		return "", noop, err
+	}
 	tmpKey, err := os.CreateTemp("", "id_rsa_")
 	if err != nil {
-		return "", nil, err
+		return "", noop, err
 	}
-	if err := os.WriteFile(tmpKey.Name(), []byte(module.SSHPrivateKey), 0600); err != nil {
+	tmpKey.Close()
+	if err := os.WriteFile(tmpKey.Name(), []byte(privateKey), 0600); err != nil {
 		_ = os.Remove(tmpKey.Name())
-		return "", nil, err
+		return "", noop, err
 	}
 	// Build a safer SSH command by default: enforce host key checking and allow a custom known_hosts
 	sshStrict := strings.ToLower(strings.TrimSpace(os.Getenv("GIT_SSH_STRICT")))
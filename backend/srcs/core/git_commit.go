@@ -0,0 +1,324 @@
+package core
+
+import (
+	"backend/database"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Commits made from Pan Bagnat are committed by the backend on behalf of the
+// acting user, who is recorded as the author.
+const (
+	gitCommitterName  = "Pan Bagnat"
+	gitCommitterEmail = "pan-bagnat@localhost"
+)
+
+// Push outcomes reported by GitPushModule.
+const (
+	GitPushPushed   = "pushed"
+	GitPushUpToDate = "up_to_date"
+	GitPushRejected = "rejected"
+	GitPushFailed   = "failed"
+)
+
+// GitCommitInput commits the staged changes of a module working tree.
+type GitCommitInput struct {
+	Message string
+	// All stages every change, untracked files included, before committing.
+	All bool
+	// Amend replaces HEAD. It keeps the HEAD message when Message is empty,
+	// and is refused once HEAD has been pushed.
+	Amend bool
+}
+
+// GitPushResult is the outcome of pushing the current branch to origin.
+type GitPushResult struct {
+	Branch string `json:"branch"`
+	Status string `json:"status"`
+	// Reason is git's explanation of a rejection, e.g. "non-fast-forward".
+	Reason string `json:"reason,omitempty"`
+	Hint   string `json:"hint,omitempty"`
+	// Remote holds the messages sent back by the remote (hooks, protected
+	// branch rules), without their "remote:" prefix.
+	Remote []string `json:"remote,omitempty"`
+}
+
+// gitPushRef is one ref line of `git push --porcelain`.
+type gitPushRef struct {
+	Flag    byte
+	From    string
+	To      string
+	Summary string
+	Reason  string
+}
+
+// gitAuthorFor returns the author recorded for commits made by actor.
+func gitAuthorFor(actor *User) string {
+	if actor == nil || actor.FtLogin == "" {
+		return fmt.Sprintf("%s <%s>", gitCommitterName, gitCommitterEmail)
+	}
+	domain := strings.TrimSpace(os.Getenv("GIT_AUTHOR_EMAIL_DOMAIN"))
+	if domain == "" {
+		domain = "localhost"
+	}
+	return fmt.Sprintf("%s <%s@%s>", actor.FtLogin, actor.FtLogin, domain)
+}
+
+func isMerging(repoDir string) bool {
+	_, err := os.Stat(filepath.Join(repoDir, ".git", "MERGE_HEAD"))
+	return err == nil
+}
+
+// currentBranch returns the checked out branch, or "" on a detached HEAD.
+func currentBranch(repoDir string) string {
+	out, err := exec.Command("git", "-C", repoDir, "symbolic-ref", "--short", "-q", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return string(bytesTrimSpace(out))
+}
+
+// gitHeadCommit describes the HEAD commit of a repository.
+func gitHeadCommit(repoDir string) (GitCommit, error) {
+	out, err := exec.Command("git", "-C", repoDir, "log", "-1", "--date=iso-strict", "--pretty=%H%x1f%an%x1f%ae%x1f%ad%x1f%s").Output()
+	if err != nil {
+		return GitCommit{}, err
+	}
+	f := strings.SplitN(string(bytesTrimSpace(out)), "\x1f", 5)
+	if len(f) < 5 {
+		return GitCommit{}, fmt.Errorf("unexpected git log output %q", out)
+	}
+	return GitCommit{Hash: f[0], Author: f[1], Email: f[2], Date: f[3], Subject: f[4]}, nil
+}
+
+// ensureNoActiveJob refuses with ErrConflict while module has a queued or
+// running job: commits and pushes run outside the job queue, and a pull or
+// deploy could change the working tree under them.
+func ensureNoActiveJob(module Module) error {
+	busy, err := database.ModuleHasActiveJobs(context.Background(), module.ID)
+	if err != nil {
+		return fmt.Errorf("check module jobs: %w", err)
+	}
+	if busy {
+		return fmt.Errorf("%w: a job is queued or running for this module, retry once it is done", ErrConflict)
+	}
+	return nil
+}
+
+// GitCommitModule commits the staged changes of a module working tree with
+// actor as author. Nothing is pushed.
+func GitCommitModule(module Module, in GitCommitInput, actor *User) (GitCommit, error) {
+	if err := ensureNoActiveJob(module); err != nil {
+		return GitCommit{}, err
+	}
+	repoDir := repoDirFor(module)
+	_ = ensureSafeDirectory(module, repoDir)
+	msg := strings.TrimSpace(in.Message)
+	if msg == "" && !in.Amend {
+		return GitCommit{}, fmt.Errorf("%w: a commit message is required", ErrInvalidInput)
+	}
+	if isMerging(repoDir) {
+		return GitCommit{}, fmt.Errorf("%w: a merge is in progress, resolve it and use merge continue", ErrConflict)
+	}
+
+	if in.All {
+		if err := runAndLog(module.ID, exec.Command("git", "-C", repoDir, "add", "-A")); err != nil {
+			return GitCommit{}, LogModule(module.ID, "ERROR", "git add failed", nil, err)
+		}
+	}
+	if in.Amend {
+		if exec.Command("git", "-C", repoDir, "rev-parse", "--verify", "-q", "HEAD").Run() != nil {
+			return GitCommit{}, fmt.Errorf("%w: there is no commit to amend", ErrConflict)
+		}
+		if up := upstreamRef(module, repoDir); up != "" &&
+			exec.Command("git", "-C", repoDir, "merge-base", "--is-ancestor", "HEAD", up).Run() == nil {
+			return GitCommit{}, fmt.Errorf("%w: HEAD is already on %s, amending it would need a force push", ErrConflict, up)
+		}
+	} else if exec.Command("git", "-C", repoDir, "diff", "--cached", "--quiet").Run() == nil {
+		return GitCommit{}, fmt.Errorf("%w: nothing is staged", ErrConflict)
+	}
+
+	args := []string{"-C", repoDir,
+		"-c", "user.name=" + gitCommitterName, "-c", "user.email=" + gitCommitterEmail,
+		"commit", "-q"}
+	if in.Amend {
+		// The amended commit keeps its author
+		args = append(args, "--amend")
+	} else {
+		args = append(args, "--author="+gitAuthorFor(actor))
+	}
+	if msg != "" {
+		args = append(args, "-m", msg)
+	} else {
+		args = append(args, "--no-edit")
+	}
+	if err := runAndLog(module.ID, exec.Command("git", args...)); err != nil {
+		return GitCommit{}, LogModule(module.ID, "ERROR", "git commit failed", nil, err)
+	}
+
+	commit, err := gitHeadCommit(repoDir)
+	if err != nil {
+		return GitCommit{}, err
+	}
+	meta := map[string]any{"commit": commit.Hash}
+	if actor != nil {
+		meta["actor"] = actor.FtLogin
+	}
+	action := "Committed"
+	if in.Amend {
+		action = "Amended HEAD"
+	}
+	LogModule(module.ID, "INFO", fmt.Sprintf("%s: %s", action, commit.Subject), meta, nil)
+	_ = persistHeadCommit(module)
+	_ = updateGitComputed(module, nil, nil)
+	broadcastGitStatus(module)
+	return commit, nil
+}
+
+// upstreamRef returns the upstream of the current branch, falling back to
+// origin/<module branch>, or "" when neither exists.
+func upstreamRef(module Module, repoDir string) string {
+	if b, err := exec.Command("git", "-C", repoDir, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{u}").Output(); err == nil {
+		return string(bytesTrimSpace(b))
+	}
+	if module.GitBranch != "" && isCommitRef(repoDir, "origin/"+module.GitBranch) {
+		return "origin/" + module.GitBranch
+	}
+	return ""
+}
+
+// GitPushModule pushes the current branch to origin with the module's
+// credentials. A refused push returns the result with ErrPushRejected; the
+// local commits are kept.
+func GitPushModule(module Module, actor *User) (GitPushResult, error) {
+	if err := ensureNoActiveJob(module); err != nil {
+		return GitPushResult{}, err
+	}
+	repoDir := repoDirFor(module)
+	_ = ensureSafeDirectory(module, repoDir)
+	branch := currentBranch(repoDir)
+	if branch == "" {
+		return GitPushResult{}, fmt.Errorf("%w: HEAD is detached, check out a branch first", ErrConflict)
+	}
+	if isMerging(repoDir) {
+		return GitPushResult{}, fmt.Errorf("%w: a merge is in progress, resolve it and use merge continue", ErrConflict)
+	}
+	gitEnv, cleanup, err := gitAuthForModule(module)
+	if err != nil {
+		return GitPushResult{}, LogModule(module.ID, "ERROR", "failed to prepare git credentials", nil, err)
+	}
+	defer cleanup()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", "-C", repoDir, "push", "--porcelain", "-u", "origin", "refs/heads/"+branch+":refs/heads/"+branch)
+	cmd.Env = append(os.Environ(), gitEnv...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	runErr := cmd.Run()
+	for _, line := range splitLines(stderr.String()) {
+		if line != "" {
+			LogModule(module.ID, "WARN", line, nil, nil)
+		}
+	}
+
+	res := GitPushResult{Branch: branch, Remote: remoteMessages(stderr.String())}
+	meta := map[string]any{"branch": branch}
+	if actor != nil {
+		meta["actor"] = actor.FtLogin
+	}
+	var ref *gitPushRef
+	refs := parsePushPorcelain(stdout.String())
+	for i := range refs {
+		if refs[i].To == "refs/heads/"+branch {
+			ref = &refs[i]
+		}
+	}
+
+	switch {
+	case ref != nil && ref.Flag == '!':
+		res.Status = GitPushRejected
+		res.Reason = ref.Reason
+		if res.Reason == "" {
+			res.Reason = strings.Trim(ref.Summary, "[]")
+		}
+		res.Hint = pushRejectionHint(res.Reason)
+		meta["reason"] = res.Reason
+		return res, LogModule(module.ID, "ERROR", fmt.Sprintf("Push of %s rejected: %s", branch, res.Reason), meta,
+			fmt.Errorf("%w: %s", ErrPushRejected, res.Reason))
+	case runErr != nil:
+		res.Status = GitPushFailed
+		res.Reason = gitErrorSummary(stderr.String())
+		return res, LogModule(module.ID, "ERROR", "git push failed", meta, fmt.Errorf("git push failed: %s", res.Reason))
+	case ref != nil && ref.Flag == '=':
+		res.Status = GitPushUpToDate
+	default:
+		res.Status = GitPushPushed
+	}
+
+	LogModule(module.ID, "INFO", fmt.Sprintf("Pushed %s to origin", branch), meta, nil)
+	_ = updateGitComputed(module, nil, nil)
+	broadcastGitStatus(module)
+	return res, nil
+}
+
+// parsePushPorcelain parses the ref lines of `git push --porcelain`:
+// <flag>\t<from>:<to>\t<summary>[ (<reason>)].
+func parsePushPorcelain(out string) []gitPushRef {
+	refs := []gitPushRef{}
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.SplitN(strings.TrimRight(line, "\r"), "\t", 3)
+		if len(parts) != 3 || len(parts[0]) != 1 {
+			continue
+		}
+		from, to, _ := strings.Cut(parts[1], ":")
+		r := gitPushRef{Flag: parts[0][0], From: from, To: to, Summary: parts[2]}
+		if i := strings.Index(parts[2], " ("); i >= 0 && strings.HasSuffix(parts[2], ")") {
+			r.Summary = parts[2][:i]
+			r.Reason = parts[2][i+2 : len(parts[2])-1]
+		}
+		refs = append(refs, r)
+	}
+	return refs
+}
+
+// remoteMessages returns the lines the remote sent back during a push.
+func remoteMessages(stderr string) []string {
+	var out []string
+	for _, line := range strings.Split(stderr, "\n") {
+		if msg, ok := strings.CutPrefix(strings.TrimRight(line, "\r"), "remote:"); ok {
+			if msg = strings.TrimSpace(msg); msg != "" {
+				out = append(out, msg)
+			}
+		}
+	}
+	return out
+}
+
+// gitErrorSummary condenses the stderr of a failed git command to its
+// error lines, without progress, hints and remote messages.
+func gitErrorSummary(stderr string) string {
+	lines := []string{}
+	for _, line := range splitLines(stderr) {
+		if line == "" || strings.HasPrefix(line, "remote:") || strings.HasPrefix(line, "hint:") || strings.HasPrefix(line, "To ") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "; ")
+}
+
+func pushRejectionHint(reason string) string {
+	switch {
+	case strings.Contains(reason, "fetch first"), strings.Contains(reason, "non-fast-forward"):
+		return "origin has commits that are not here: pull, then push again"
+	case strings.Contains(reason, "hook declined"), strings.Contains(reason, "protected"):
+		return "the remote refused the push, see its messages"
+	case strings.Contains(reason, "stale info"):
+		return "fetch, then push again"
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestParsePushPorcelain(t *testing.T) {
+	out := "To git@github.com:org/repo.git\n" +
+		"!\trefs/heads/main:refs/heads/main\t[rejected] (fetch first)\n" +
+		"=\trefs/heads/dev:refs/heads/dev\t[up to date]\n" +
+		" \trefs/heads/fix:refs/heads/fix\t1a2b3c4..5d6e7f8\n" +
+		"Done\n"
+	refs := parsePushPorcelain(out)
+	if len(refs) != 3 {
+		t.Fatalf("got %d refs: %+v", len(refs), refs)
+	}
+	if r := refs[0]; r.Flag != '!' || r.To != "refs/heads/main" || r.Summary != "[rejected]" || r.Reason != "fetch first" {
+		t.Errorf("rejected ref: %+v", r)
+	}
+	if r := refs[1]; r.Flag != '=' || r.Reason != "" {
+		t.Errorf("up to date ref: %+v", r)
+	}
+	if r := refs[2]; r.Flag != ' ' || r.Summary != "1a2b3c4..5d6e7f8" {
+		t.Errorf("fast-forward ref: %+v", r)
+	}
+}
+
+func TestPushPorcelainRejection(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	remote, a, b := filepath.Join(dir, "remote.git"), filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@localhost"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return string(out)
+	}
+	git("init", "-q", "--bare", "-b", "main", remote)
+	git("clone", "-q", remote, a)
+	git("-C", a, "commit", "-q", "--allow-empty", "-m", "one")
+	git("-C", a, "push", "-q", "origin", "main")
+	git("clone", "-q", remote, b)
+	git("-C", a, "commit", "-q", "--allow-empty", "-m", "two")
+	git("-C", a, "push", "-q", "origin", "main")
+	git("-C", b, "commit", "-q", "--allow-empty", "-m", "diverged")
+
+	out, err := exec.Command("git", "-C", b, "push", "--porcelain", "origin", "refs/heads/main:refs/heads/main").Output()
+	if err == nil {
+		t.Fatal("diverged push accepted")
+	}
+	refs := parsePushPorcelain(string(out))
+	if len(refs) != 1 || refs[0].Flag != '!' || refs[0].Reason != "fetch first" {
+		t.Fatalf("got %+v from %q", refs, out)
+	}
+	if hint := pushRejectionHint(refs[0].Reason); hint == "" {
+		t.Error("no hint for a diverged branch")
+	}
+}
+
+func TestGitErrorSummary(t *testing.T) {
+	stderr := "remote: GitLab: You are not allowed to push code to protected branches on this project.\n" +
+		"To gitlab.example.com:org/repo.git\n" +
+		"ERROR: Permission to org/repo.git denied to deploy key\n" +
+		"fatal: Could not read from remote repository.\n" +
+		"hint: Updates were rejected\n"
+	want := "ERROR: Permission to org/repo.git denied to deploy key; fatal: Could not read from remote repository."
+	if got := gitErrorSummary(stderr); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := remoteMessages(stderr); len(got) != 1 || got[0] != "GitLab: You are not allowed to push code to protected branches on this project." {
+		t.Errorf("remote messages: %q", got)
+	}
+}
@@ -0,0 +1,339 @@
+package core
+
+import (
+	"backend/database"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// GitCredential is an HTTPS username/token pair used instead of the SSH key
+// for modules whose remote is an https:// URL. The token is write-only.
+type GitCredential struct {
+	ID              string     `json:"id"`
+	Name            string     `json:"name"`
+	Username        string     `json:"username"`
+	CreatedByUserID string     `json:"created_by_user_id,omitempty"`
+	CreatedByLogin  string     `json:"created_by_login,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty"`
+	UsageCount      int        `json:"usage_count"`
+}
+
+// GitCredentialInput creates or updates a credential. An empty Token keeps
+// the stored one on update.
+type GitCredentialInput struct {
+	Name     string
+	Username string
+	Token    string
+}
+
+func gitCredentialAAD(id string) []byte {
+	return []byte("git-credential:" + id)
+}
+
+func dbGitCredentialToGitCredential(c database.GitCredential) GitCredential {
+	out := GitCredential{
+		ID:              c.ID,
+		Name:            c.Name,
+		Username:        c.Username,
+		CreatedByUserID: c.CreatedByUserID.String,
+		CreatedByLogin:  c.CreatedByLogin.String,
+		CreatedAt:       c.CreatedAt,
+		UpdatedAt:       c.UpdatedAt,
+		UsageCount:      c.UsageCount,
+	}
+	if c.LastUsedAt.Valid {
+		t := c.LastUsedAt.Time
+		out.LastUsedAt = &t
+	}
+	return out
+}
+
+func sealedFromGitCredential(c database.GitCredential) sealedSecret {
+	return sealedSecret{KeyVersion: c.KeyVersion, DataKey: c.DataKey, Nonce: c.Nonce, Ciphertext: c.Ciphertext}
+}
+
+func ListGitCredentials(ctx context.Context) ([]GitCredential, error) {
+	rows, err := database.ListGitCredentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]GitCredential, 0, len(rows))
+	for _, c := range rows {
+		out = append(out, dbGitCredentialToGitCredential(c))
+	}
+	return out, nil
+}
+
+func GetGitCredential(ctx context.Context, id string) (GitCredential, error) {
+	c, err := database.GetGitCredential(ctx, id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return GitCredential{}, ErrNotFound
+		}
+		return GitCredential{}, err
+	}
+	return dbGitCredentialToGitCredential(c), nil
+}
+
+func validateGitCredentialInput(in *GitCredentialInput, requireToken bool) error {
+	in.Name = strings.TrimSpace(in.Name)
+	in.Username = strings.TrimSpace(in.Username)
+	in.Token = strings.TrimSpace(in.Token)
+	if in.Name == "" || in.Username == "" {
+		return fmt.Errorf("%w: name and username are required", ErrInvalidInput)
+	}
+	if requireToken && in.Token == "" {
+		return fmt.Errorf("%w: token is required", ErrInvalidInput)
+	}
+	// The credential helper protocol is line based
+	if strings.ContainsAny(in.Username+in.Token, "\r\n\x00") {
+		return fmt.Errorf("%w: username and token cannot contain line breaks", ErrInvalidInput)
+	}
+	return nil
+}
+
+// CreateGitCredential seals the token under the active master key and stores
+// the credential.
+func CreateGitCredential(ctx context.Context, in GitCredentialInput, actor *User) (GitCredential, error) {
+	if err := validateGitCredentialInput(&in, true); err != nil {
+		return GitCredential{}, err
+	}
+	id, err := GenerateULID(GitCredentialKind)
+	if err != nil {
+		return GitCredential{}, err
+	}
+	ring, err := loadMasterKeyRing()
+	if err != nil {
+		return GitCredential{}, err
+	}
+	sealed, err := ring.sealEnvelope([]byte(in.Token), gitCredentialAAD(id))
+	if err != nil {
+		return GitCredential{}, fmt.Errorf("encrypt git token: %w", err)
+	}
+	row := database.GitCredential{
+		ID:         id,
+		Name:       in.Name,
+		Username:   in.Username,
+		Nonce:      sealed.Nonce,
+		Ciphertext: sealed.Ciphertext,
+		DataKey:    sealed.DataKey,
+		KeyVersion: sealed.KeyVersion,
+	}
+	if actor != nil && actor.ID != "" {
+		row.CreatedByUserID = sql.NullString{String: actor.ID, Valid: true}
+	}
+	stored, err := database.InsertGitCredential(ctx, row)
+	if errors.Is(err, database.ErrAlreadyExists) {
+		return GitCredential{}, fmt.Errorf("%w: a credential named %q already exists", ErrConflict, in.Name)
+	}
+	if err != nil {
+		return GitCredential{}, err
+	}
+	return dbGitCredentialToGitCredential(stored), nil
+}
+
+// UpdateGitCredential renames a credential and replaces its username, and
+// its token when one is given.
+func UpdateGitCredential(ctx context.Context, id string, in GitCredentialInput) (GitCredential, error) {
+	if err := validateGitCredentialInput(&in, false); err != nil {
+		return GitCredential{}, err
+	}
+	current, err := database.GetGitCredential(ctx, id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return GitCredential{}, ErrNotFound
+		}
+		return GitCredential{}, err
+	}
+	next := current
+	next.Name, next.Username = in.Name, in.Username
+	if in.Token != "" {
+		ring, err := loadMasterKeyRing()
+		if err != nil {
+			return GitCredential{}, err
+		}
+		sealed, err := ring.sealEnvelope([]byte(in.Token), gitCredentialAAD(id))
+		if err != nil {
+			return GitCredential{}, fmt.Errorf("encrypt git token: %w", err)
+		}
+		next.Nonce, next.Ciphertext, next.DataKey, next.KeyVersion = sealed.Nonce, sealed.Ciphertext, sealed.DataKey, sealed.KeyVersion
+	}
+	stored, err := database.UpdateGitCredential(ctx, next)
+	switch {
+	case errors.Is(err, database.ErrAlreadyExists):
+		return GitCredential{}, fmt.Errorf("%w: a credential named %q already exists", ErrConflict, in.Name)
+	case errors.Is(err, database.ErrNotFound):
+		return GitCredential{}, ErrNotFound
+	case err != nil:
+		return GitCredential{}, err
+	}
+	return dbGitCredentialToGitCredential(stored), nil
+}
+
+// DeleteGitCredential removes a credential no module uses anymore.
+func DeleteGitCredential(ctx context.Context, id string) error {
+	c, err := GetGitCredential(ctx, id)
+	if err != nil {
+		return err
+	}
+	if c.UsageCount > 0 {
+		return fmt.Errorf("%w: credential is used by %d module(s)", ErrConflict, c.UsageCount)
+	}
+	if err := database.DeleteGitCredential(ctx, id); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// GetModuleGitCredential returns the credential selected for a module, or
+// nil when the module uses its SSH key.
+func GetModuleGitCredential(ctx context.Context, moduleID string) (*GitCredential, error) {
+	id, err := database.GetModuleGitCredentialID(ctx, moduleID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if id == "" {
+		return nil, nil
+	}
+	c, err := GetGitCredential(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// SetModuleGitCredential selects the credential a module uses for its
+// https:// remote. An empty credentialID goes back to the SSH key.
+func SetModuleGitCredential(ctx context.Context, moduleID, credentialID string, actor *User) (*GitCredential, error) {
+	credentialID = strings.TrimSpace(credentialID)
+	var cred *GitCredential
+	if credentialID != "" {
+		c, err := GetGitCredential(ctx, credentialID)
+		if errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("%w: unknown git credential %q", ErrInvalidInput, credentialID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		cred = &c
+	}
+	if err := database.SetModuleGitCredentialID(ctx, moduleID, sql.NullString{String: credentialID, Valid: credentialID != ""}); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	meta := map[string]any{}
+	if actor != nil {
+		meta["actor"] = actor.FtLogin
+	}
+	if cred == nil {
+		LogModule(moduleID, "INFO", "Git remote now uses the SSH key", meta, nil)
+	} else {
+		LogModule(moduleID, "INFO", fmt.Sprintf("Git remote now uses the HTTPS credential %s", cred.Name), meta, nil)
+	}
+	return cred, nil
+}
+
+// httpsRemoteHost returns the scheme and host of an https:// remote, or false
+// for any other remote. Tokens are never sent over plain HTTP.
+func httpsRemoteHost(remote string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(remote))
+	if err != nil || !strings.EqualFold(u.Scheme, "https") || u.Host == "" {
+		return "", false
+	}
+	return "https://" + strings.ToLower(u.Host), true
+}
+
+// gitCredentialHelperEnv configures git, through the environment, to answer
+// credential requests for host from file. Helpers from the system and user
+// configuration are reset first so they never see or store the token.
+func gitCredentialHelperEnv(host, file string) []string {
+	helper := fmt.Sprintf(`!f() { test "$1" = get && cat '%s'; }; f`, strings.ReplaceAll(file, "'", `'\''`))
+	return []string{
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_CONFIG_COUNT=2",
+		"GIT_CONFIG_KEY_0=credential.helper",
+		"GIT_CONFIG_VALUE_0=",
+		"GIT_CONFIG_KEY_1=credential." + host + ".helper",
+		"GIT_CONFIG_VALUE_1=" + helper,
+	}
+}
+
+// gitAuthForModule returns the environment git needs to reach the module's
+// remote: its HTTPS credential through a credential helper when one is
+// selected and the remote is an https:// URL, its SSH key otherwise. cleanup
+// is never nil.
+func gitAuthForModule(module Module) ([]string, func(), error) {
+	noop := func() {}
+	if host, ok := httpsRemoteHost(module.GitURL); ok && module.ID != "" {
+		env, cleanup, used, err := tempGitCredentialForModule(module.ID, host)
+		if err != nil {
+			return nil, noop, err
+		}
+		if used {
+			return env, cleanup, nil
+		}
+	}
+	sshCommand, cleanup, err := tempSSHForModule(module)
+	if err != nil {
+		return nil, noop, err
+	}
+	return []string{"GIT_SSH_COMMAND=" + sshCommand}, cleanup, nil
+}
+
+// tempGitCredentialForModule decrypts the module's HTTPS credential, if it
+// has one, into a temporary file read by the credential helper.
+func tempGitCredentialForModule(moduleID, host string) ([]string, func(), bool, error) {
+	noop := func() {}
+	ctx := moduleJobContext(moduleID)
+	id, err := database.GetModuleGitCredentialID(ctx, moduleID)
+	if err != nil || id == "" {
+		return nil, noop, false, err
+	}
+	c, err := database.GetGitCredential(ctx, id)
+	if err != nil {
+		return nil, noop, false, err
+	}
+	ring, err := loadMasterKeyRing()
+	if err != nil {
+		return nil, noop, false, err
+	}
+	token, err := ring.openEnvelope(sealedFromGitCredential(c), gitCredentialAAD(c.ID))
+	if err != nil {
+		return nil, noop, false, fmt.Errorf("decrypt git token: %w", err)
+	}
+
+	f, err := os.CreateTemp("", "git_credential_")
+	if err != nil {
+		return nil, noop, false, err
+	}
+	cleanup := func() { _ = os.Remove(f.Name()) }
+	_, err = fmt.Fprintf(f, "username=%s\npassword=%s\n", c.Username, token)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		cleanup()
+		return nil, noop, false, err
+	}
+	if err := database.TouchGitCredential(ctx, c.ID); err != nil {
+		log.Printf("failed to update last use of git credential %s: %v", c.ID, err)
+	}
+	return gitCredentialHelperEnv(host, f.Name()), cleanup, true, nil
+}
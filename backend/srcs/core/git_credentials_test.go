@@ -0,0 +1,88 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHTTPSRemoteHost(t *testing.T) {
+	cases := []struct {
+		remote string
+		want   string
+		ok     bool
+	}{
+		{"https://gitlab.example.com/group/repo.git", "https://gitlab.example.com", true},
+		{"https://Git.Example.com:8443/repo.git", "https://git.example.com:8443", true},
+		{"http://gitlab.example.com/group/repo.git", "", false},
+		{"git@github.com:org/repo.git", "", false},
+		{"ssh://git@github.com/org/repo.git", "", false},
+		{"", "", false},
+	}
+	for _, c := range cases {
+		got, ok := httpsRemoteHost(c.remote)
+		if got != c.want || ok != c.ok {
+			t.Errorf("%q: got %q, %v; want %q, %v", c.remote, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestGitCredentialHelperEnv(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	file := filepath.Join(t.TempDir(), "it's creds")
+	if err := os.WriteFile(file, []byte("username=bot\npassword=s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env := append(os.Environ(), "HOME="+t.TempDir(), "GIT_CONFIG_NOSYSTEM=1")
+	env = append(env, gitCredentialHelperEnv("https://git.example.com", file)...)
+
+	fill := func(host string) (string, error) {
+		cmd := exec.Command("git", "credential", "fill")
+		cmd.Env = env
+		cmd.Stdin = strings.NewReader("protocol=https\nhost=" + host + "\n\n")
+		out, err := cmd.Output()
+		return string(out), err
+	}
+	out, err := fill("git.example.com")
+	if err != nil {
+		t.Fatalf("credential fill: %v", err)
+	}
+	if !strings.Contains(out, "username=bot\n") || !strings.Contains(out, "password=s3cret\n") {
+		t.Errorf("credential not returned: %q", out)
+	}
+	if out, err := fill("other.example.com"); err == nil && strings.Contains(out, "s3cret") {
+		t.Errorf("credential sent to another host: %q", out)
+	}
+}
+
+func TestValidateGitCredentialInput(t *testing.T) {
+	in := GitCredentialInput{Name: " ro ", Username: " oauth2 ", Token: " glpat-x "}
+	if err := validateGitCredentialInput(&in, true); err != nil {
+		t.Fatal(err)
+	}
+	if in.Name != "ro" || in.Username != "oauth2" || in.Token != "glpat-x" {
+		t.Errorf("input not trimmed: %+v", in)
+	}
+	if err := validateGitCredentialInput(&GitCredentialInput{Name: "ro", Username: "u"}, false); err != nil {
+		t.Errorf("update without token rejected: %v", err)
+	}
+
+	bad := []struct {
+		in           GitCredentialInput
+		requireToken bool
+	}{
+		{GitCredentialInput{Name: "ro", Username: "u"}, true},
+		{GitCredentialInput{Username: "u", Token: "t"}, true},
+		{GitCredentialInput{Name: "ro", Username: "u", Token: "t\npassword=x"}, true},
+	}
+	for _, b := range bad {
+		if err := validateGitCredentialInput(&b.in, b.requireToken); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%+v: want ErrInvalidInput, got %v", b.in, err)
+		}
+	}
+}
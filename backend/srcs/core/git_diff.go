@@ -0,0 +1,567 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Diff kinds accepted by GitDiffModule.
+const (
+	GitDiffWorking  = "working"
+	GitDiffUpstream = "upstream"
+	GitDiffCommits  = "commits"
+)
+
+// File statuses in a GitDiff.
+const (
+	GitDiffAdded    = "added"
+	GitDiffModified = "modified"
+	GitDiffDeleted  = "deleted"
+	GitDiffRenamed  = "renamed"
+	GitDiffCopied   = "copied"
+)
+
+// Line kinds in a GitDiffHunk.
+const (
+	GitDiffLineContext = "context"
+	GitDiffLineAdd     = "add"
+	GitDiffLineDelete  = "delete"
+)
+
+const (
+	// gitDiffMaxBytes caps the raw diff read from git; past it the diff is
+	// cut at a file boundary and marked truncated.
+	gitDiffMaxBytes = 4 << 20
+	// gitDiffMaxContext caps the context lines around each change.
+	gitDiffMaxContext = 50
+	// gitFileMaxBytes caps the size of a file read at a revision.
+	gitFileMaxBytes = 5 << 20
+)
+
+// GitDiffRequest selects what GitDiffModule compares.
+type GitDiffRequest struct {
+	// Kind is working (working tree and index vs HEAD, untracked files
+	// included), upstream (HEAD vs its upstream branch) or commits (From vs To).
+	Kind string
+	From string
+	// To defaults to HEAD for the commits kind.
+	To string
+	// Path limits the diff to a file or directory of the repository.
+	Path string
+	// Context is the number of unchanged lines around each change, 3 when
+	// negative.
+	Context int
+}
+
+// GitDiff is a parsed unified diff.
+type GitDiff struct {
+	Kind      string        `json:"kind"`
+	From      string        `json:"from"`
+	To        string        `json:"to"`
+	Files     []GitDiffFile `json:"files"`
+	Truncated bool          `json:"truncated"`
+}
+
+type GitDiffFile struct {
+	Path string `json:"path"`
+	// OldPath is the source of a rename or copy.
+	OldPath   string        `json:"old_path,omitempty"`
+	Status    string        `json:"status"`
+	Binary    bool          `json:"binary"`
+	OldMode   string        `json:"old_mode,omitempty"`
+	NewMode   string        `json:"new_mode,omitempty"`
+	Additions int           `json:"additions"`
+	Deletions int           `json:"deletions"`
+	Hunks     []GitDiffHunk `json:"hunks"`
+}
+
+type GitDiffHunk struct {
+	Header   string `json:"header"`
+	OldStart int    `json:"old_start"`
+	OldLines int    `json:"old_lines"`
+	NewStart int    `json:"new_start"`
+	NewLines int    `json:"new_lines"`
+	// Section is the enclosing function or heading git found for the hunk.
+	Section string        `json:"section,omitempty"`
+	Lines   []GitDiffLine `json:"lines"`
+}
+
+// GitDiffLine is one line of a hunk. OldLine and NewLine are 0 on the side
+// the line does not exist on.
+type GitDiffLine struct {
+	Kind    string `json:"kind"`
+	Content string `json:"content"`
+	OldLine int    `json:"old_line,omitempty"`
+	NewLine int    `json:"new_line,omitempty"`
+	// NoNewline marks the last line of a file without a trailing newline.
+	NoNewline bool `json:"no_newline,omitempty"`
+}
+
+// GitFileAtRef is the content of a file at a revision. Binary content is
+// base64 encoded.
+type GitFileAtRef struct {
+	Path     string `json:"path"`
+	Ref      string `json:"ref"`
+	Commit   string `json:"commit"`
+	Size     int64  `json:"size"`
+	Binary   bool   `json:"binary"`
+	Encoding string `json:"encoding"`
+	Content  string `json:"content"`
+}
+
+// validGitRef rejects refs that are empty, look like options or do not name
+// a commit of the repository.
+func validGitRef(repoDir, ref string) error {
+	if ref == "" || strings.HasPrefix(ref, "-") || strings.ContainsAny(ref, " \t\r\n\x00") {
+		return fmt.Errorf("%w: invalid ref %q", ErrInvalidInput, ref)
+	}
+	if !isCommitRef(repoDir, ref) {
+		return fmt.Errorf("%w: unknown ref %q", ErrInvalidInput, ref)
+	}
+	return nil
+}
+
+// gitRepoPath validates a repository relative path for git pathspecs and
+// object names.
+func gitRepoPath(p string) (string, error) {
+	rel, err := sanitizeRelPath(strings.TrimPrefix(strings.TrimSpace(p), "./"))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// GitDiffModule returns the structured diff selected by req.
+func GitDiffModule(module Module, req GitDiffRequest) (GitDiff, error) {
+	repoDir := repoDirFor(module)
+	_ = ensureSafeDirectory(module, repoDir)
+	if req.Context < 0 {
+		req.Context = 3
+	}
+	if req.Context > gitDiffMaxContext {
+		req.Context = gitDiffMaxContext
+	}
+	pathspec := ""
+	if strings.TrimSpace(req.Path) != "" {
+		p, err := gitRepoPath(req.Path)
+		if err != nil {
+			return GitDiff{}, err
+		}
+		pathspec = p
+	}
+
+	d := GitDiff{Kind: req.Kind, Files: []GitDiffFile{}}
+	switch req.Kind {
+	case GitDiffWorking:
+		d.From, d.To = "HEAD", "working tree"
+		if !isCommitRef(repoDir, "HEAD") {
+			return GitDiff{}, fmt.Errorf("%w: the repository has no commit yet", ErrConflict)
+		}
+	case GitDiffUpstream:
+		up := upstreamRef(module, repoDir)
+		if up == "" {
+			return GitDiff{}, fmt.Errorf("%w: the current branch has no upstream", ErrConflict)
+		}
+		d.From, d.To = "HEAD", up
+	case GitDiffCommits:
+		if req.To == "" {
+			req.To = "HEAD"
+		}
+		for _, ref := range []string{req.From, req.To} {
+			if err := validGitRef(repoDir, ref); err != nil {
+				return GitDiff{}, err
+			}
+		}
+		d.From, d.To = req.From, req.To
+	default:
+		return GitDiff{}, fmt.Errorf("%w: kind must be %s, %s or %s", ErrInvalidInput, GitDiffWorking, GitDiffUpstream, GitDiffCommits)
+	}
+
+	args := []string{"-C", repoDir, "-c", "core.quotepath=false", "diff",
+		"--no-color", "--no-ext-diff", "--no-textconv", "--find-renames",
+		"--src-prefix=a/", "--dst-prefix=b/", fmt.Sprintf("-U%d", req.Context), d.From}
+	if req.Kind != GitDiffWorking {
+		args = append(args, d.To)
+	}
+	args = append(args, "--")
+	if pathspec != "" {
+		args = append(args, pathspec)
+	}
+	out, truncated, err := gitOutputLimited(exec.Command("git", args...), gitDiffMaxBytes)
+	if err != nil {
+		return GitDiff{}, err
+	}
+	d.Files = append(d.Files, parseUnifiedDiff(out)...)
+	d.Truncated = truncated
+
+	if req.Kind == GitDiffWorking && !truncated {
+		untracked, err := untrackedDiffs(repoDir, pathspec, req.Context, gitDiffMaxBytes-len(out))
+		if err != nil {
+			return GitDiff{}, err
+		}
+		d.Files = append(d.Files, untracked.Files...)
+		d.Truncated = untracked.Truncated
+	}
+	return d, nil
+}
+
+// untrackedDiffs diffs the untracked, not ignored, files of the working tree
+// against nothing, so new files show up like in a commit.
+func untrackedDiffs(repoDir, pathspec string, context, budget int) (GitDiff, error) {
+	args := []string{"-C", repoDir, "ls-files", "-z", "--others", "--exclude-standard", "--"}
+	if pathspec != "" {
+		args = append(args, pathspec)
+	}
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return GitDiff{}, err
+	}
+	d := GitDiff{Files: []GitDiffFile{}}
+	for _, p := range strings.Split(string(out), "\x00") {
+		if p == "" {
+			continue
+		}
+		if budget <= 0 {
+			d.Truncated = true
+			break
+		}
+		// --no-index exits with 1 when the files differ, which they always do
+		cmd := exec.Command("git", "-c", "core.quotepath=false", "diff", "--no-index",
+			"--no-color", "--no-ext-diff", "--no-textconv", "--src-prefix=a/", "--dst-prefix=b/",
+			fmt.Sprintf("-U%d", context), "--", os.DevNull, p)
+		cmd.Dir = repoDir
+		raw, truncated, err := gitOutputLimited(cmd, budget)
+		if err != nil && !isExitCode(err, 1) {
+			return GitDiff{}, err
+		}
+		d.Files = append(d.Files, parseUnifiedDiff(raw)...)
+		if truncated {
+			d.Truncated = true
+			break
+		}
+		budget -= len(raw)
+	}
+	return d, nil
+}
+
+func isExitCode(err error, code int) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitCode() == code
+}
+
+// gitOutputLimited runs cmd and returns at most max bytes of its output, cut
+// before the last file that did not fit. Git is stopped once max is reached.
+func gitOutputLimited(cmd *exec.Cmd, max int) (string, bool, error) {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", false, err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", false, err
+	}
+	buf, readErr := io.ReadAll(io.LimitReader(stdout, int64(max)+1))
+	truncated := len(buf) > max
+	if truncated {
+		_ = cmd.Process.Kill()
+	}
+	waitErr := cmd.Wait()
+	if readErr != nil {
+		return "", false, readErr
+	}
+	if truncated {
+		buf = buf[:max]
+		if i := bytes.LastIndex(buf, []byte("\ndiff --git ")); i >= 0 {
+			buf = buf[:i+1]
+		} else {
+			buf = nil
+		}
+		return string(buf), true, nil
+	}
+	if waitErr != nil {
+		if msg := gitErrorSummary(stderr.String()); msg != "" {
+			return string(buf), false, fmt.Errorf("%w: %s", waitErr, msg)
+		}
+		return string(buf), false, waitErr
+	}
+	return string(buf), false, nil
+}
+
+// parseUnifiedDiff parses the output of git diff into files and hunks.
+// Hunk bodies are consumed by their line counts, so content lines that look
+// like headers are not mistaken for them.
+func parseUnifiedDiff(out string) []GitDiffFile {
+	files := []GitDiffFile{}
+	var file *GitDiffFile
+	var hunk *GitDiffHunk
+	oldLeft, newLeft, oldNo, newNo := 0, 0, 0, 0
+
+	flush := func() {
+		if file == nil {
+			return
+		}
+		if file.Status == "" {
+			file.Status = GitDiffModified
+		}
+		files = append(files, *file)
+		file, hunk = nil, nil
+	}
+
+	lines := strings.Split(out, "\n")
+	if n := len(lines); n > 0 && lines[n-1] == "" {
+		lines = lines[:n-1]
+	}
+	for _, line := range lines {
+		if hunk != nil && (oldLeft > 0 || newLeft > 0) && isHunkLine(line) {
+			if line == "" {
+				// Some tools strip the space of empty context lines
+				line = " "
+			}
+			l := GitDiffLine{Content: line[1:]}
+			switch line[0] {
+			case ' ':
+				oldNo++
+				newNo++
+				oldLeft--
+				newLeft--
+				l.Kind, l.OldLine, l.NewLine = GitDiffLineContext, oldNo, newNo
+			case '-':
+				oldNo++
+				oldLeft--
+				l.Kind, l.OldLine = GitDiffLineDelete, oldNo
+				file.Deletions++
+			case '+':
+				newNo++
+				newLeft--
+				l.Kind, l.NewLine = GitDiffLineAdd, newNo
+				file.Additions++
+			default:
+				markNoNewline(hunk)
+				continue
+			}
+			hunk.Lines = append(hunk.Lines, l)
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			oldPath, newPath := splitDiffGitPaths(strings.TrimPrefix(line, "diff --git "))
+			file = &GitDiffFile{Path: newPath, OldPath: oldPath, Hunks: []GitDiffHunk{}}
+		case file == nil:
+			continue
+		case strings.HasPrefix(line, `\`) && hunk != nil:
+			markNoNewline(hunk)
+		case strings.HasPrefix(line, "@@ "):
+			h, ok := parseHunkHeader(line)
+			if !ok {
+				continue
+			}
+			file.Hunks = append(file.Hunks, h)
+			hunk = &file.Hunks[len(file.Hunks)-1]
+			oldLeft, newLeft = h.OldLines, h.NewLines
+			oldNo, newNo = h.OldStart-1, h.NewStart-1
+		case strings.HasPrefix(line, "--- "):
+			if p := diffHeaderPath(strings.TrimPrefix(line, "--- "), "a/"); p != "" {
+				file.OldPath = p
+			}
+		case strings.HasPrefix(line, "+++ "):
+			if p := diffHeaderPath(strings.TrimPrefix(line, "+++ "), "b/"); p != "" {
+				file.Path = p
+			}
+		case strings.HasPrefix(line, "new file mode "):
+			file.Status, file.NewMode = GitDiffAdded, strings.TrimPrefix(line, "new file mode ")
+		case strings.HasPrefix(line, "deleted file mode "):
+			file.Status, file.OldMode = GitDiffDeleted, strings.TrimPrefix(line, "deleted file mode ")
+		case strings.HasPrefix(line, "old mode "):
+			file.OldMode = strings.TrimPrefix(line, "old mode ")
+		case strings.HasPrefix(line, "new mode "):
+			file.NewMode = strings.TrimPrefix(line, "new mode ")
+		case strings.HasPrefix(line, "rename from "):
+			file.Status, file.OldPath = GitDiffRenamed, unquoteGitPath(strings.TrimPrefix(line, "rename from "))
+		case strings.HasPrefix(line, "rename to "):
+			file.Status, file.Path = GitDiffRenamed, unquoteGitPath(strings.TrimPrefix(line, "rename to "))
+		case strings.HasPrefix(line, "copy from "):
+			file.Status, file.OldPath = GitDiffCopied, unquoteGitPath(strings.TrimPrefix(line, "copy from "))
+		case strings.HasPrefix(line, "copy to "):
+			file.Status, file.Path = GitDiffCopied, unquoteGitPath(strings.TrimPrefix(line, "copy to "))
+		case strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch":
+			file.Binary = true
+		}
+	}
+	flush()
+
+	for i := range files {
+		if files[i].Status != GitDiffRenamed && files[i].Status != GitDiffCopied {
+			files[i].OldPath = ""
+		}
+	}
+	return files
+}
+
+// isHunkLine reports whether line can be part of a hunk body.
+func isHunkLine(line string) bool {
+	return line == "" || strings.ContainsRune(" +-\\", rune(line[0]))
+}
+
+func markNoNewline(h *GitDiffHunk) {
+	if n := len(h.Lines); n > 0 {
+		h.Lines[n-1].NoNewline = true
+	}
+}
+
+// parseHunkHeader parses "@@ -a[,b] +c[,d] @@[ section]".
+func parseHunkHeader(line string) (GitDiffHunk, bool) {
+	rest := strings.TrimPrefix(line, "@@ ")
+	end := strings.Index(rest, " @@")
+	if end < 0 {
+		return GitDiffHunk{}, false
+	}
+	h := GitDiffHunk{Header: line, Lines: []GitDiffLine{}}
+	h.Section = strings.TrimSpace(rest[end+3:])
+	ranges := strings.Fields(rest[:end])
+	if len(ranges) != 2 || !strings.HasPrefix(ranges[0], "-") || !strings.HasPrefix(ranges[1], "+") {
+		return GitDiffHunk{}, false
+	}
+	var ok1, ok2 bool
+	h.OldStart, h.OldLines, ok1 = parseHunkRange(ranges[0][1:])
+	h.NewStart, h.NewLines, ok2 = parseHunkRange(ranges[1][1:])
+	return h, ok1 && ok2
+}
+
+func parseHunkRange(s string) (int, int, bool) {
+	start, count, hasCount := strings.Cut(s, ",")
+	a, err := strconv.Atoi(start)
+	if err != nil {
+		return 0, 0, false
+	}
+	if !hasCount {
+		return a, 1, true
+	}
+	b, err := strconv.Atoi(count)
+	if err != nil {
+		return 0, 0, false
+	}
+	return a, b, true
+}
+
+// diffHeaderPath returns the path of a ---/+++ line, or "" for /dev/null.
+func diffHeaderPath(s, prefix string) string {
+	s = unquoteGitPath(strings.TrimSuffix(s, "\t"))
+	if s == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(s, prefix)
+}
+
+// splitDiffGitPaths splits the "a/<old> b/<new>" part of a diff --git line.
+// Unquoted paths with spaces are ambiguous; when old and new are the same the
+// split is exact, otherwise the ---/+++ or rename lines correct it.
+func splitDiffGitPaths(s string) (string, string) {
+	if strings.HasPrefix(s, `"`) {
+		if end := quotedEnd(s); end > 0 {
+			a := unquoteGitPath(s[:end])
+			b := unquoteGitPath(strings.TrimSpace(s[end:]))
+			return strings.TrimPrefix(a, "a/"), strings.TrimPrefix(b, "b/")
+		}
+	}
+	if n := len(s); n%2 == 1 {
+		a, b := s[:n/2], s[n/2+1:]
+		if strings.HasPrefix(a, "a/") && strings.HasPrefix(b, "b/") && a[2:] == b[2:] {
+			return a[2:], b[2:]
+		}
+	}
+	if i := strings.LastIndex(s, " b/"); i >= 0 {
+		b := unquoteGitPath(s[i+1:])
+		return strings.TrimPrefix(s[:i], "a/"), strings.TrimPrefix(b, "b/")
+	}
+	return s, s
+}
+
+// quotedEnd returns the index just past the closing quote of a C-quoted
+// string starting at s[0].
+func quotedEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// unquoteGitPath undoes git's C-style quoting of unusual paths.
+func unquoteGitPath(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	if u, err := strconv.Unquote(s); err == nil {
+		return u
+	}
+	return s
+}
+
+// GitReadFileAtRef returns the content of path at ref, HEAD when empty.
+func GitReadFileAtRef(module Module, ref, path string) (GitFileAtRef, error) {
+	repoDir := repoDirFor(module)
+	_ = ensureSafeDirectory(module, repoDir)
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if err := validGitRef(repoDir, ref); err != nil {
+		return GitFileAtRef{}, err
+	}
+	rel, err := gitRepoPath(path)
+	if err != nil {
+		return GitFileAtRef{}, err
+	}
+	if rel == "." {
+		return GitFileAtRef{}, fmt.Errorf("%w: path is required", ErrInvalidInput)
+	}
+	commitB, err := exec.Command("git", "-C", repoDir, "rev-parse", "--verify", ref+"^{commit}").Output()
+	if err != nil {
+		return GitFileAtRef{}, fmt.Errorf("%w: unknown ref %q", ErrInvalidInput, ref)
+	}
+	commit := string(bytesTrimSpace(commitB))
+	object := commit + ":" + rel
+
+	typeB, err := exec.Command("git", "-C", repoDir, "cat-file", "-t", object).Output()
+	if err != nil {
+		return GitFileAtRef{}, ErrNotFound
+	}
+	if t := string(bytesTrimSpace(typeB)); t != "blob" {
+		return GitFileAtRef{}, fmt.Errorf("%w: %s is a %s, not a file", ErrInvalidInput, rel, t)
+	}
+	sizeB, err := exec.Command("git", "-C", repoDir, "cat-file", "-s", object).Output()
+	if err != nil {
+		return GitFileAtRef{}, err
+	}
+	size, _ := strconv.ParseInt(string(bytesTrimSpace(sizeB)), 10, 64)
+	if size > gitFileMaxBytes {
+		return GitFileAtRef{}, fmt.Errorf("%w: %s is %d bytes, over the %d bytes limit", ErrInvalidInput, rel, size, gitFileMaxBytes)
+	}
+	data, err := exec.Command("git", "-C", repoDir, "cat-file", "blob", object).Output()
+	if err != nil {
+		return GitFileAtRef{}, err
+	}
+
+	f := GitFileAtRef{Path: rel, Ref: ref, Commit: commit, Size: size, Encoding: "utf-8"}
+	if bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+		f.Binary, f.Encoding = true, "base64"
+		f.Content = base64.StdEncoding.EncodeToString(data)
+	} else {
+		f.Content = string(data)
+	}
+	return f, nil
+}
@@ -0,0 +1,162 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseUnifiedDiff(t *testing.T) {
+	out := "diff --git a/app.py b/app.py\n" +
+		"index 1111111..2222222 100644\n" +
+		"--- a/app.py\n" +
+		"+++ b/app.py\n" +
+		"@@ -1,3 +1,3 @@ def main():\n" +
+		" a\n" +
+		"--- not a header\n" +
+		"+++ not a header either\n" +
+		" c\n" +
+		"\\ No newline at end of file\n" +
+		"diff --git a/old name.txt b/new name.txt\n" +
+		"similarity index 90%\n" +
+		"rename from old name.txt\n" +
+		"rename to new name.txt\n" +
+		"diff --git a/logo.png b/logo.png\n" +
+		"new file mode 100644\n" +
+		"index 0000000..3333333\n" +
+		"Binary files /dev/null and b/logo.png differ\n" +
+		"diff --git a/gone.txt b/gone.txt\n" +
+		"deleted file mode 100644\n" +
+		"--- a/gone.txt\n" +
+		"+++ /dev/null\n" +
+		"@@ -1 +0,0 @@\n" +
+		"-bye\n"
+	files := parseUnifiedDiff(out)
+	if len(files) != 4 {
+		t.Fatalf("got %d files: %+v", len(files), files)
+	}
+
+	f := files[0]
+	if f.Path != "app.py" || f.Status != GitDiffModified || f.Additions != 1 || f.Deletions != 1 || len(f.Hunks) != 1 {
+		t.Fatalf("modified file: %+v", f)
+	}
+	h := f.Hunks[0]
+	if h.OldStart != 1 || h.NewLines != 3 || h.Section != "def main():" || len(h.Lines) != 4 {
+		t.Fatalf("hunk: %+v", h)
+	}
+	want := []GitDiffLine{
+		{Kind: GitDiffLineContext, Content: "a", OldLine: 1, NewLine: 1},
+		{Kind: GitDiffLineDelete, Content: "-- not a header", OldLine: 2},
+		{Kind: GitDiffLineAdd, Content: "++ not a header either", NewLine: 2},
+		{Kind: GitDiffLineContext, Content: "c", OldLine: 3, NewLine: 3, NoNewline: true},
+	}
+	for i, l := range want {
+		if h.Lines[i] != l {
+			t.Errorf("line %d: got %+v, want %+v", i, h.Lines[i], l)
+		}
+	}
+
+	if f := files[1]; f.Status != GitDiffRenamed || f.OldPath != "old name.txt" || f.Path != "new name.txt" {
+		t.Errorf("renamed file: %+v", f)
+	}
+	if f := files[2]; f.Status != GitDiffAdded || !f.Binary || f.Path != "logo.png" || f.OldPath != "" {
+		t.Errorf("binary file: %+v", f)
+	}
+	if f := files[3]; f.Status != GitDiffDeleted || f.Path != "gone.txt" || f.Deletions != 1 || f.Hunks[0].Lines[0].OldLine != 1 {
+		t.Errorf("deleted file: %+v", f)
+	}
+}
+
+func TestSplitDiffGitPaths(t *testing.T) {
+	cases := []struct{ in, old, new string }{
+		{"a/x.go b/x.go", "x.go", "x.go"},
+		{"a/my file b/my file", "my file", "my file"},
+		{"a/a b/b", "a", "b"},
+		{`"a/t\tab" "b/t\tab"`, "t\tab", "t\tab"},
+	}
+	for _, c := range cases {
+		if o, n := splitDiffGitPaths(c.in); o != c.old || n != c.new {
+			t.Errorf("%q: got %q, %q", c.in, o, n)
+		}
+	}
+}
+
+func TestGitDiffModule(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	base := t.TempDir()
+	t.Setenv("REPO_BASE_PATH", base)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	module := Module{ID: "m", Slug: "diff-test"}
+	repo := filepath.Join(base, module.Slug)
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=t", "-c", "user.email=t@localhost"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(repo, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	git("init", "-q", "-b", "main")
+	write("compose.yml", "services:\n  app:\n    image: a\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "one")
+	write("compose.yml", "services:\n  app:\n    image: b\n")
+	write("new.txt", "hello\n")
+
+	d, err := GitDiffModule(module, GitDiffRequest{Kind: GitDiffWorking, Context: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Files) != 2 || d.Truncated {
+		t.Fatalf("working diff: %+v", d)
+	}
+	if f := d.Files[0]; f.Path != "compose.yml" || f.Additions != 1 || f.Deletions != 1 {
+		t.Errorf("modified file: %+v", f)
+	}
+	if f := d.Files[1]; f.Path != "new.txt" || f.Status != GitDiffAdded || f.Additions != 1 {
+		t.Errorf("untracked file: %+v", f)
+	}
+
+	git("add", "-A")
+	git("commit", "-q", "-m", "two")
+	d, err = GitDiffModule(module, GitDiffRequest{Kind: GitDiffCommits, From: "HEAD~1", Path: "compose.yml", Context: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Files) != 1 || len(d.Files[0].Hunks) != 1 || len(d.Files[0].Hunks[0].Lines) != 2 {
+		t.Fatalf("commits diff: %+v", d)
+	}
+	if _, err := GitDiffModule(module, GitDiffRequest{Kind: GitDiffCommits, From: "--output=/tmp/x"}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("option as ref: %v", err)
+	}
+	if _, err := GitDiffModule(module, GitDiffRequest{Kind: GitDiffUpstream}); !errors.Is(err, ErrConflict) {
+		t.Errorf("no upstream: %v", err)
+	}
+
+	f, err := GitReadFileAtRef(module, "HEAD~1", "compose.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Content != "services:\n  app:\n    image: a\n" || f.Binary || f.Encoding != "utf-8" {
+		t.Errorf("file at ref: %+v", f)
+	}
+	if _, err := GitReadFileAtRef(module, "HEAD~1", "new.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing file: %v", err)
+	}
+	if _, err := GitReadFileAtRef(module, "HEAD", "../etc/passwd"); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("escaping path: %v", err)
+	}
+}
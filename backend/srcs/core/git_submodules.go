@@ -0,0 +1,197 @@
+package core
+
+import (
+	"backend/database"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Submodule states reported by GitStatusModule.
+const (
+	SubmoduleUpToDate       = "up_to_date"
+	SubmoduleNotInitialized = "not_initialized"
+	SubmoduleOutOfDate      = "out_of_date"
+	SubmoduleConflict       = "conflict"
+)
+
+// ModuleGitOptions are the opt-in repository features of a module. They
+// apply on clone, pull and checkout.
+type ModuleGitOptions struct {
+	ModuleID string `json:"module_id"`
+	// Submodules initializes and updates submodules recursively.
+	Submodules bool `json:"submodules"`
+	// LFS downloads Git LFS objects, in submodules too when enabled.
+	LFS       bool       `json:"lfs"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// GitSubmoduleStatus is one line of `git submodule status --recursive`.
+type GitSubmoduleStatus struct {
+	Path     string `json:"path"`
+	Commit   string `json:"commit"`
+	State    string `json:"state"`
+	Describe string `json:"describe,omitempty"`
+}
+
+// GetModuleGitOptions returns the repository options of a module, all off
+// when none were set.
+func GetModuleGitOptions(ctx context.Context, moduleID string) (ModuleGitOptions, error) {
+	o, err := database.GetModuleGitOptions(ctx, moduleID)
+	if errors.Is(err, database.ErrNotFound) {
+		return ModuleGitOptions{ModuleID: moduleID}, nil
+	}
+	if err != nil {
+		return ModuleGitOptions{}, err
+	}
+	t := o.UpdatedAt
+	return ModuleGitOptions{ModuleID: o.ModuleID, Submodules: o.Submodules, LFS: o.LFS, UpdatedAt: &t}, nil
+}
+
+// SetModuleGitOptions stores the repository options of a module. They apply
+// from the next clone, pull or checkout.
+func SetModuleGitOptions(ctx context.Context, moduleID string, in ModuleGitOptions) (ModuleGitOptions, error) {
+	if _, err := database.UpsertModuleGitOptions(ctx, database.ModuleGitOptions{
+		ModuleID:   moduleID,
+		Submodules: in.Submodules,
+		LFS:        in.LFS,
+	}); err != nil {
+		return ModuleGitOptions{}, err
+	}
+	LogModule(moduleID, "INFO", "Git options updated", map[string]any{
+		"submodules": in.Submodules,
+		"lfs":        in.LFS,
+	}, nil)
+	return GetModuleGitOptions(ctx, moduleID)
+}
+
+// moduleGitOptions is GetModuleGitOptions falling back to everything off,
+// for the git helpers that cannot fail on a settings lookup.
+func moduleGitOptions(module Module) ModuleGitOptions {
+	o, err := GetModuleGitOptions(moduleJobContext(module.ID), module.ID)
+	if err != nil {
+		return ModuleGitOptions{ModuleID: module.ID}
+	}
+	return o
+}
+
+// syncModuleRepoExtras brings submodules and LFS objects in line with the
+// checked out commit, when the module opted in. Submodules are fetched with
+// the same credentials as the module remote.
+func syncModuleRepoExtras(module Module, repoDir string, gitEnv []string) error {
+	opts := moduleGitOptions(module)
+	env := append(os.Environ(), gitEnv...)
+
+	if opts.Submodules {
+		if _, err := os.Stat(filepath.Join(repoDir, ".gitmodules")); err == nil {
+			sub := append(env[:len(env):len(env)], "GIT_LFS_SKIP_SMUDGE=1")
+			for _, args := range [][]string{
+				{"submodule", "sync", "--recursive"},
+				{"submodule", "update", "--init", "--recursive"},
+			} {
+				cmd := exec.Command("git", append([]string{"-C", repoDir}, args...)...)
+				cmd.Env = sub
+				if err := runAndLog(module.ID, cmd); err != nil {
+					return LogModule(module.ID, "ERROR", "git submodule update failed", nil, err)
+				}
+			}
+			if list := gitSubmoduleStatus(repoDir); len(list) > 0 {
+				LogModule(module.ID, "INFO", "Submodules: "+submoduleSummary(list), nil, nil)
+			}
+		}
+	}
+
+	if opts.LFS {
+		if err := exec.Command("git", "lfs", "version").Run(); err != nil {
+			return LogModule(module.ID, "ERROR", "Git LFS is enabled but git-lfs is not installed", nil, err)
+		}
+		// Checkouts leave pointer files; objects are only downloaded by lfs pull
+		steps := [][]string{
+			{"lfs", "install", "--local", "--skip-smudge"},
+			{"lfs", "pull"},
+		}
+		if opts.Submodules {
+			steps = append(steps, []string{"submodule", "foreach", "--recursive", "git lfs install --local --skip-smudge && git lfs pull"})
+		}
+		for _, args := range steps {
+			cmd := exec.Command("git", append([]string{"-C", repoDir}, args...)...)
+			cmd.Env = env
+			if err := runAndLog(module.ID, cmd); err != nil {
+				return LogModule(module.ID, "ERROR", "git lfs pull failed", nil, err)
+			}
+		}
+	}
+	return nil
+}
+
+// gitSubmoduleStatus lists the submodules of a repository, recursively.
+func gitSubmoduleStatus(repoDir string) []GitSubmoduleStatus {
+	if _, err := os.Stat(filepath.Join(repoDir, ".gitmodules")); err != nil {
+		return []GitSubmoduleStatus{}
+	}
+	out, err := exec.Command("git", "-C", repoDir, "submodule", "status", "--recursive").Output()
+	if err != nil {
+		return []GitSubmoduleStatus{}
+	}
+	return parseSubmoduleStatus(string(out))
+}
+
+// parseSubmoduleStatus parses `git submodule status` lines:
+// <state char><sha1> <path>[ (<describe>)].
+func parseSubmoduleStatus(out string) []GitSubmoduleStatus {
+	list := []GitSubmoduleStatus{}
+	// Not splitLines: the leading space is the up to date state
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if len(line) < 2 {
+			continue
+		}
+		var s GitSubmoduleStatus
+		switch line[0] {
+		case ' ':
+			s.State = SubmoduleUpToDate
+		case '-':
+			s.State = SubmoduleNotInitialized
+		case '+':
+			s.State = SubmoduleOutOfDate
+		case 'U':
+			s.State = SubmoduleConflict
+		default:
+			continue
+		}
+		commit, rest, ok := strings.Cut(line[1:], " ")
+		if !ok || rest == "" {
+			continue
+		}
+		s.Commit = commit
+		if strings.HasSuffix(rest, ")") {
+			if i := strings.LastIndex(rest, " ("); i > 0 {
+				s.Describe = rest[i+2 : len(rest)-1]
+				rest = rest[:i]
+			}
+		}
+		s.Path = rest
+		list = append(list, s)
+	}
+	return list
+}
+
+// submoduleSummary is a one-line count of submodules per state, for logs.
+func submoduleSummary(list []GitSubmoduleStatus) string {
+	counts := map[string]int{}
+	for _, s := range list {
+		counts[s.State]++
+	}
+	parts := []string{}
+	for _, st := range []string{SubmoduleUpToDate, SubmoduleOutOfDate, SubmoduleNotInitialized, SubmoduleConflict} {
+		if counts[st] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[st], st))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
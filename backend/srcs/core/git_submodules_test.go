@@ -0,0 +1,30 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSubmoduleStatus(t *testing.T) {
+	out := " 1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b ui-kit (v1.2.0)\n" +
+		"-0123456789abcdef0123456789abcdef01234567 vendor/icons\n" +
+		"+89abcdef0123456789abcdef0123456789abcdef libs/shared lib (heads/main)\n" +
+		"U0000000000000000000000000000000000000000 conflicted\n" +
+		"\n"
+	want := []GitSubmoduleStatus{
+		{Path: "ui-kit", Commit: "1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b", State: SubmoduleUpToDate, Describe: "v1.2.0"},
+		{Path: "vendor/icons", Commit: "0123456789abcdef0123456789abcdef01234567", State: SubmoduleNotInitialized},
+		{Path: "libs/shared lib", Commit: "89abcdef0123456789abcdef0123456789abcdef", State: SubmoduleOutOfDate, Describe: "heads/main"},
+		{Path: "conflicted", Commit: "0000000000000000000000000000000000000000", State: SubmoduleConflict},
+	}
+	got := parseSubmoduleStatus(out)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+	if s := submoduleSummary(got); s != "1 up_to_date, 1 out_of_date, 1 not_initialized, 1 conflict" {
+		t.Errorf("summary: %q", s)
+	}
+	if got := parseSubmoduleStatus(""); got == nil || len(got) != 0 {
+		t.Errorf("empty output: %#v", got)
+	}
+}
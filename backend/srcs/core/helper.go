@@ -1,6 +1,11 @@
 package core
 
-import "strings"
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 func splitLines(s string) []string {
 	lines := []string{}
@@ -11,3 +16,40 @@ func splitLines(s string) []string {
 	}
 	return lines
 }
+
+// envDuration reads a Go duration (e.g. "90m", "24h") from the environment.
+func envDuration(key string, fallback time.Duration) time.Duration {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return fallback
+	}
+	return d
+}
+
+func envInt(key string, fallback int) int {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return fallback
+	}
+	return n
+}
+
+func envBool(key string, fallback bool) bool {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		return fallback
+	}
+	return b
+}
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"backend/database"
+	"context"
+	"fmt"
+	"log"
+)
+
+// KeyRotationReport counts what RewrapStoredSecrets changed.
+type KeyRotationReport struct {
+	ActiveVersion           int `json:"active_version"`
+	SSHKeysEncrypted        int `json:"ssh_keys_encrypted"`
+	SSHKeysRewrapped        int `json:"ssh_keys_rewrapped"`
+	ModuleSecretsRewrapped  int `json:"module_secrets_rewrapped"`
+	WebhooksRewrapped       int `json:"webhooks_rewrapped"`
+	GitCredentialsRewrapped int `json:"git_credentials_rewrapped"`
+	Failed                  int `json:"failed"`
+}
+
+// RewrapStoredSecrets brings every stored secret to the active master key:
+// plaintext SSH keys are encrypted, and data keys wrapped with an older
+// version are re-wrapped. Payloads are not re-encrypted. Rows that fail are
+// logged and counted, the others are still processed.
+func RewrapStoredSecrets(ctx context.Context) (KeyRotationReport, error) {
+	ring, err := loadMasterKeyRing()
+	if err != nil {
+		return KeyRotationReport{}, err
+	}
+	report := KeyRotationReport{ActiveVersion: ring.active}
+
+	keys, err := database.ListSSHKeysToRewrap(ctx, ring.active)
+	if err != nil {
+		return report, fmt.Errorf("list ssh keys: %w", err)
+	}
+	for _, k := range keys {
+		var sealed sealedSecret
+		if !k.KeyVersion.Valid {
+			sealed, err = ring.sealEnvelope([]byte(k.PrivateKey), sshKeyAAD(k.ID))
+		} else {
+			sealed, err = ring.rewrapEnvelope(sealedFromSSHKeyCipher(k.SSHKeyCipher), sshKeyAAD(k.ID))
+		}
+		if err != nil {
+			log.Printf("[secrets] ssh key %s: %v", k.ID, err)
+			report.Failed++
+			continue
+		}
+		ok, err := database.ReplaceSSHKeyCipher(ctx, k.ID, k.KeyVersion, sshKeyCipherFromSealed(sealed))
+		if err != nil {
+			log.Printf("[secrets] ssh key %s: %v", k.ID, err)
+			report.Failed++
+			continue
+		}
+		if !ok {
+			// regenerated meanwhile, already sealed with the active key
+			continue
+		}
+		if k.KeyVersion.Valid {
+			report.SSHKeysRewrapped++
+		} else {
+			report.SSHKeysEncrypted++
+		}
+	}
+
+	secrets, err := database.ListModuleSecretsToRewrap(ctx, ring.active)
+	if err != nil {
+		return report, fmt.Errorf("list module secrets: %w", err)
+	}
+	for _, s := range secrets {
+		next, err := rewrapModuleSecret(ring, s)
+		if err != nil {
+			log.Printf("[secrets] module %s secret %s: %v", s.ModuleID, s.Name, err)
+			report.Failed++
+			continue
+		}
+		ok, err := database.ReplaceModuleSecretCipher(ctx, next, s.KeyVersion)
+		if err != nil {
+			log.Printf("[secrets] module %s secret %s: %v", s.ModuleID, s.Name, err)
+			report.Failed++
+			continue
+		}
+		if ok {
+			report.ModuleSecretsRewrapped++
+		}
+	}
+
+	hooks, err := database.ListModuleWebhooksToRewrap(ctx, ring.active)
+	if err != nil {
+		return report, fmt.Errorf("list module webhooks: %w", err)
+	}
+	for _, w := range hooks {
+		sealed, err := ring.rewrapEnvelope(sealedFromModuleWebhook(w), moduleWebhookAAD(w.ModuleID))
+		if err != nil {
+			log.Printf("[secrets] module %s webhook: %v", w.ModuleID, err)
+			report.Failed++
+			continue
+		}
+		next := w
+		next.Nonce, next.Ciphertext, next.DataKey, next.KeyVersion = sealed.Nonce, sealed.Ciphertext, sealed.DataKey, sealed.KeyVersion
+		ok, err := database.ReplaceModuleWebhookCipher(ctx, next, w.KeyVersion)
+		if err != nil {
+			log.Printf("[secrets] module %s webhook: %v", w.ModuleID, err)
+			report.Failed++
+			continue
+		}
+		if ok {
+			report.WebhooksRewrapped++
+		}
+	}
+
+	creds, err := database.ListGitCredentialsToRewrap(ctx, ring.active)
+	if err != nil {
+		return report, fmt.Errorf("list git credentials: %w", err)
+	}
+	for _, c := range creds {
+		sealed, err := ring.rewrapEnvelope(sealedFromGitCredential(c), gitCredentialAAD(c.ID))
+		if err != nil {
+			log.Printf("[secrets] git credential %s: %v", c.ID, err)
+			report.Failed++
+			continue
+		}
+		next := c
+		next.Nonce, next.Ciphertext, next.DataKey, next.KeyVersion = sealed.Nonce, sealed.Ciphertext, sealed.DataKey, sealed.KeyVersion
+		ok, err := database.ReplaceGitCredentialCipher(ctx, next, c.KeyVersion)
+		if err != nil {
+			log.Printf("[secrets] git credential %s: %v", c.ID, err)
+			report.Failed++
+			continue
+		}
+		if ok {
+			report.GitCredentialsRewrapped++
+		}
+	}
+	return report, nil
+}
+
+func rewrapModuleSecret(ring *masterKeyRing, s database.ModuleSecret) (database.ModuleSecret, error) {
+	aad := moduleSecretAAD(s.ModuleID, s.Name)
+	var sealed sealedSecret
+	if !s.KeyVersion.Valid {
+		plain, err := openModuleSecret(ring, s)
+		if err != nil {
+			return database.ModuleSecret{}, err
+		}
+		if sealed, err = ring.sealEnvelope(plain, aad); err != nil {
+			return database.ModuleSecret{}, err
+		}
+	} else {
+		var err error
+		if sealed, err = ring.rewrapEnvelope(sealedFromModuleSecret(s), aad); err != nil {
+			return database.ModuleSecret{}, err
+		}
+	}
+	return moduleSecretFromSealed(s.ModuleID, s.Name, sealed), nil
+}
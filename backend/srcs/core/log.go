@@ -18,10 +18,11 @@ func LogModule(moduleID, level, message string, meta map[string]any, err error)
 	}
 
 	log, _ := database.InsertModuleLog(database.ModuleLog{
-		ModuleID: moduleID,
-		Level:    level,
-		Message:  message,
-		Meta:     meta,
+		ModuleID:     moduleID,
+		Level:        level,
+		Message:      message,
+		Meta:         meta,
+		DeploymentID: activeDeploymentID(moduleID),
 	})
 
 	ts := time.Now().Format(time.RFC3339)
@@ -0,0 +1,756 @@
+package core
+
+import (
+	"backend/database"
+	"context"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	ModuleBundleFormat  = "pan-bagnat-module-bundle"
+	ModuleBundleVersion = 1
+
+	// maxBundleIconSize bounds each icon embedded in a bundle.
+	maxBundleIconSize = 2 << 20
+
+	bundleKeyInfo     = "pan-bagnat module bundle key"
+	bundleSecretsInfo = "pan-bagnat module bundle secrets v1"
+)
+
+// ModuleBundle is a portable copy of a module configuration, used to move a
+// module between Pan Bagnat instances. The repository itself is cloned again
+// on the target.
+type ModuleBundle struct {
+	Format     string               `json:"format"`
+	Version    int                  `json:"version"`
+	ExportedAt time.Time            `json:"exported_at"`
+	Source     ModuleBundleSource   `json:"source"`
+	Module     ModuleBundleModule   `json:"module"`
+	Pages      []ModuleBundlePage   `json:"pages"`
+	OIDC       *ModuleBundleOIDC    `json:"oidc,omitempty"`
+	Settings   ModuleBundleSettings `json:"settings"`
+	// SecretNames is always filled; values are only in Secrets, sealed for
+	// one target instance.
+	SecretNames []string             `json:"secret_names"`
+	Secrets     *ModuleBundleSecrets `json:"secrets,omitempty"`
+}
+
+type ModuleBundleSource struct {
+	Host     string `json:"host,omitempty"`
+	ModuleID string `json:"module_id"`
+}
+
+type ModuleBundleModule struct {
+	Name      string            `json:"name"`
+	Slug      string            `json:"slug"`
+	GitURL    string            `json:"git_url"`
+	GitBranch string            `json:"git_branch"`
+	Icon      *ModuleBundleIcon `json:"icon,omitempty"`
+}
+
+// ModuleBundleIcon carries an uploaded icon, or the URL of an external one.
+type ModuleBundleIcon struct {
+	Name string `json:"name,omitempty"`
+	Data []byte `json:"data,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+type ModuleBundlePage struct {
+	ID          string                   `json:"id"`
+	Name        string                   `json:"name"`
+	Slug        string                   `json:"slug"`
+	Container   string                   `json:"container,omitempty"`
+	Port        int                      `json:"port,omitempty"`
+	Network     string                   `json:"network,omitempty"`
+	IframeOnly  bool                     `json:"iframe_only"`
+	PageOnly    bool                     `json:"page_only"`
+	NeedAuth    bool                     `json:"need_auth"`
+	IsVisible   bool                     `json:"is_visible"`
+	Roles       []string                 `json:"roles"`
+	Icon        *ModuleBundleIcon        `json:"icon,omitempty"`
+	HealthCheck *ModuleBundleHealthCheck `json:"health_check,omitempty"`
+}
+
+type ModuleBundleHealthCheck struct {
+	Enabled         bool   `json:"enabled"`
+	Path            string `json:"path"`
+	ExpectedStatus  int    `json:"expected_status"`
+	IntervalSeconds int    `json:"interval_seconds"`
+	TimeoutSeconds  int    `json:"timeout_seconds"`
+}
+
+type ModuleBundleOIDC struct {
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+}
+
+type ModuleBundleSettings struct {
+	UpdatePolicy    *ModuleBundleUpdatePolicy   `json:"update_policy,omitempty"`
+	DeployStrategy  *ModuleBundleDeployStrategy `json:"deploy_strategy,omitempty"`
+	ResourceLimits  *ModuleResourceLimits       `json:"resource_limits,omitempty"`
+	GitOptions      *ModuleGitOptions           `json:"git_options,omitempty"`
+	ReleaseTracking *ModuleReleaseTracking      `json:"release_tracking,omitempty"`
+}
+
+type ModuleBundleUpdatePolicy struct {
+	Mode                 string              `json:"mode"`
+	CheckIntervalSeconds int                 `json:"check_interval_seconds"`
+	Timezone             string              `json:"timezone"`
+	MaintenanceWindows   []MaintenanceWindow `json:"maintenance_windows"`
+}
+
+type ModuleBundleDeployStrategy struct {
+	Strategy             string `json:"strategy"`
+	HealthTimeoutSeconds int    `json:"health_timeout_seconds"`
+}
+
+// ModuleBundleSecrets holds the module secrets as a JSON object sealed with
+// AES-256-GCM under a key agreed (X25519) between an ephemeral key and the
+// bundle key of the target instance.
+type ModuleBundleSecrets struct {
+	RecipientKeyID string `json:"recipient_key_id"`
+	EphemeralKey   []byte `json:"ephemeral_key"`
+	Nonce          []byte `json:"nonce"`
+	Ciphertext     []byte `json:"ciphertext"`
+}
+
+// ModuleBundleKey is the public key other instances seal secrets for.
+type ModuleBundleKey struct {
+	KeyID     string `json:"key_id"`
+	PublicKey string `json:"public_key"`
+}
+
+// ModuleImportOptions tunes how a bundle is imported. PageSlugs renames
+// pages (bundle slug to new slug) to get around slugs taken on this instance.
+type ModuleImportOptions struct {
+	Name      string
+	SSHKeyID  string
+	PageSlugs map[string]string
+	DryRun    bool
+}
+
+type ModuleImportPage struct {
+	SourceID   string `json:"source_id"`
+	SourceSlug string `json:"source_slug"`
+	Slug       string `json:"slug"`
+	PageID     string `json:"page_id,omitempty"`
+}
+
+// ModuleImportReport describes an import, or what it would do on a dry run.
+// Conflicts block the import; warnings do not.
+type ModuleImportReport struct {
+	DryRun         bool               `json:"dry_run"`
+	ModuleID       string             `json:"module_id,omitempty"`
+	Slug           string             `json:"slug,omitempty"`
+	IDMap          map[string]string  `json:"id_map"`
+	Pages          []ModuleImportPage `json:"pages"`
+	Secrets        []string           `json:"secrets"`
+	MissingSecrets []string           `json:"missing_secrets"`
+	Conflicts      []string           `json:"conflicts"`
+	Warnings       []string           `json:"warnings"`
+}
+
+// bundlePrivateKey derives the bundle key of this instance from master key
+// version, so it needs no storage and follows master key rotation.
+func bundlePrivateKey(ring *masterKeyRing, version int) (*ecdh.PrivateKey, error) {
+	master, err := ring.key(version)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := hkdf.Key(sha256.New, master, nil, bundleKeyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	return ecdh.X25519().NewPrivateKey(seed)
+}
+
+func bundleKeyID(pub []byte) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// ModuleBundleRecipientKey returns the key to pass to an export on another
+// instance so the bundle secrets can be imported here.
+func ModuleBundleRecipientKey() (ModuleBundleKey, error) {
+	ring, err := loadMasterKeyRing()
+	if err != nil {
+		return ModuleBundleKey{}, err
+	}
+	priv, err := bundlePrivateKey(ring, ring.active)
+	if err != nil {
+		return ModuleBundleKey{}, err
+	}
+	pub := priv.PublicKey().Bytes()
+	return ModuleBundleKey{KeyID: bundleKeyID(pub), PublicKey: base64.RawURLEncoding.EncodeToString(pub)}, nil
+}
+
+func bundleSecretsKey(shared, ephemeral, recipient []byte) ([]byte, error) {
+	salt := append(append([]byte{}, ephemeral...), recipient...)
+	return hkdf.Key(sha256.New, shared, salt, bundleSecretsInfo, 32)
+}
+
+// sealBundleSecrets encrypts secrets for the instance owning recipientKey
+// (base64url X25519 public key).
+func sealBundleSecrets(secrets map[string]string, recipientKey string) (*ModuleBundleSecrets, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(recipientKey))
+	if err != nil {
+		return nil, fmt.Errorf("%w: recipient_key must be the base64url public key of the target instance", ErrInvalidInput)
+	}
+	recipient, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: recipient_key: %s", ErrInvalidInput, err.Error())
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, err
+	}
+	key, err := bundleSecretsKey(shared, ephemeral.PublicKey().Bytes(), raw)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return nil, err
+	}
+	keyID := bundleKeyID(raw)
+	nonce, ciphertext, err := sealSecret(key, plain, []byte("module-bundle:"+keyID))
+	if err != nil {
+		return nil, err
+	}
+	return &ModuleBundleSecrets{
+		RecipientKeyID: keyID,
+		EphemeralKey:   ephemeral.PublicKey().Bytes(),
+		Nonce:          nonce,
+		Ciphertext:     ciphertext,
+	}, nil
+}
+
+// openBundleSecrets decrypts bundle secrets with the bundle key of whichever
+// loaded master key version they were sealed for.
+func openBundleSecrets(ring *masterKeyRing, s ModuleBundleSecrets) (map[string]string, error) {
+	for version := range ring.keys {
+		priv, err := bundlePrivateKey(ring, version)
+		if err != nil {
+			return nil, err
+		}
+		pub := priv.PublicKey().Bytes()
+		if bundleKeyID(pub) != s.RecipientKeyID {
+			continue
+		}
+		ephemeral, err := ecdh.X25519().NewPublicKey(s.EphemeralKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ephemeral key: %w", err)
+		}
+		shared, err := priv.ECDH(ephemeral)
+		if err != nil {
+			return nil, err
+		}
+		key, err := bundleSecretsKey(shared, s.EphemeralKey, pub)
+		if err != nil {
+			return nil, err
+		}
+		plain, err := openSecret(key, s.Nonce, s.Ciphertext, []byte("module-bundle:"+s.RecipientKeyID))
+		if err != nil {
+			return nil, errors.New("secrets do not decrypt with this instance key")
+		}
+		var out map[string]string
+		if err := json.Unmarshal(plain, &out); err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("secrets were sealed for key %s, which is not a key of this instance", s.RecipientKeyID)
+}
+
+// ExportModuleBundle builds the bundle of a module. With a recipientKey the
+// secret values are sealed for that instance; otherwise only their names are
+// exported.
+func ExportModuleBundle(ctx context.Context, module Module, recipientKey string) (ModuleBundle, error) {
+	b := ModuleBundle{
+		Format:      ModuleBundleFormat,
+		Version:     ModuleBundleVersion,
+		ExportedAt:  time.Now().UTC(),
+		Source:      ModuleBundleSource{Host: strings.TrimSpace(os.Getenv("HOST_NAME")), ModuleID: module.ID},
+		Pages:       []ModuleBundlePage{},
+		SecretNames: []string{},
+		Module: ModuleBundleModule{
+			Name:      module.Name,
+			Slug:      module.Slug,
+			GitURL:    module.GitURL,
+			GitBranch: module.GitBranch,
+			Icon:      exportIcon(module.IconURL),
+		},
+	}
+
+	pages, err := database.GetModulePages(database.ModulePagesPagination{ModuleID: &module.ID})
+	if err != nil {
+		return ModuleBundle{}, fmt.Errorf("list module pages: %w", err)
+	}
+	checks, err := database.ListModulePageHealthChecks(ctx, module.ID)
+	if err != nil {
+		return ModuleBundle{}, fmt.Errorf("list page health checks: %w", err)
+	}
+	checkByPage := make(map[string]database.ModulePageHealthCheck, len(checks))
+	for _, c := range checks {
+		checkByPage[c.PageID] = c
+	}
+	for _, dbPage := range pages {
+		p := DatabaseModulePageToModulePage(dbPage)
+		bp := ModuleBundlePage{
+			ID:         p.ID,
+			Name:       p.Name,
+			Slug:       p.Slug,
+			Container:  ptrValue(p.TargetContainer),
+			Network:    p.NetworkName,
+			IframeOnly: p.IframeOnly,
+			PageOnly:   p.PageOnly,
+			NeedAuth:   p.NeedAuth,
+			IsVisible:  p.IsVisible,
+			Roles:      []string{},
+			Icon:       exportIcon(p.IconURL),
+		}
+		if p.TargetPort != nil {
+			bp.Port = *p.TargetPort
+		}
+		roles, err := database.GetPageRoles(p.ID)
+		if err != nil {
+			return ModuleBundle{}, fmt.Errorf("list roles of page %s: %w", p.Slug, err)
+		}
+		for _, r := range roles {
+			bp.Roles = append(bp.Roles, r.Name)
+		}
+		if c, ok := checkByPage[p.ID]; ok {
+			bp.HealthCheck = &ModuleBundleHealthCheck{
+				Enabled:         c.Enabled,
+				Path:            c.Path,
+				ExpectedStatus:  c.ExpectedStatus,
+				IntervalSeconds: c.IntervalSeconds,
+				TimeoutSeconds:  c.TimeoutSeconds,
+			}
+		}
+		b.Pages = append(b.Pages, bp)
+	}
+
+	client, err := database.GetOIDCClientByModuleID(module.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return ModuleBundle{}, fmt.Errorf("load oidc client: %w", err)
+	}
+	if err == nil && client != nil {
+		b.OIDC = &ModuleBundleOIDC{RedirectURIs: client.AllowedRedirectURIs, Scopes: client.AllowedScopes}
+	}
+
+	if p, err := database.GetModuleUpdatePolicy(ctx, module.ID); err == nil {
+		policy := dbUpdatePolicyToUpdatePolicy(p)
+		b.Settings.UpdatePolicy = &ModuleBundleUpdatePolicy{
+			Mode:                 policy.Mode,
+			CheckIntervalSeconds: policy.CheckIntervalSeconds,
+			Timezone:             policy.Timezone,
+			MaintenanceWindows:   policy.MaintenanceWindows,
+		}
+	} else if !errors.Is(err, database.ErrNotFound) {
+		return ModuleBundle{}, fmt.Errorf("load update policy: %w", err)
+	}
+	if s, err := database.GetModuleDeploySettings(ctx, module.ID); err == nil {
+		b.Settings.DeployStrategy = &ModuleBundleDeployStrategy{Strategy: s.Strategy, HealthTimeoutSeconds: s.HealthTimeoutSeconds}
+	} else if !errors.Is(err, database.ErrNotFound) {
+		return ModuleBundle{}, fmt.Errorf("load deploy settings: %w", err)
+	}
+	if limits, err := GetModuleResourceLimits(ctx, module.ID); err != nil {
+		return ModuleBundle{}, fmt.Errorf("load resource limits: %w", err)
+	} else if limits.isSet() {
+		limits.ModuleID, limits.UpdatedAt = "", nil
+		b.Settings.ResourceLimits = &limits
+	}
+	if opts, err := GetModuleGitOptions(ctx, module.ID); err != nil {
+		return ModuleBundle{}, fmt.Errorf("load git options: %w", err)
+	} else if opts.Submodules || opts.LFS {
+		opts.ModuleID, opts.UpdatedAt = "", nil
+		b.Settings.GitOptions = &opts
+	}
+	if t, err := GetModuleReleaseTracking(ctx, module.ID); err != nil {
+		return ModuleBundle{}, fmt.Errorf("load release tracking: %w", err)
+	} else if t.Constraint != "" {
+		t.ModuleID, t.UpdatedAt = "", nil
+		b.Settings.ReleaseTracking = &t
+	}
+
+	secrets, err := database.ListModuleSecrets(ctx, module.ID)
+	if err != nil {
+		return ModuleBundle{}, fmt.Errorf("list module secrets: %w", err)
+	}
+	for _, s := range secrets {
+		b.SecretNames = append(b.SecretNames, s.Name)
+	}
+	if strings.TrimSpace(recipientKey) != "" && len(secrets) > 0 {
+		values, err := moduleSecretEnv(ctx, module.ID)
+		if err != nil {
+			return ModuleBundle{}, err
+		}
+		if b.Secrets, err = sealBundleSecrets(values, recipientKey); err != nil {
+			return ModuleBundle{}, err
+		}
+	}
+	return b, nil
+}
+
+// exportIcon embeds icons uploaded to this instance and keeps external URLs.
+// Stored icons that are not accepted images (older SVG uploads) are left out,
+// since the import would refuse them.
+func exportIcon(iconURL string) *ModuleBundleIcon {
+	iconURL = strings.TrimSpace(iconURL)
+	if iconURL == "" {
+		return nil
+	}
+	for prefix, dir := range map[string]string{"/assets/module-icons/": moduleIconDir, "/assets/page-icons/": pageIconDir} {
+		if !strings.HasPrefix(iconURL, prefix) {
+			continue
+		}
+		name := filepath.Base(iconURL)
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || len(data) > maxBundleIconSize {
+			return nil
+		}
+		if _, err := iconExt(data); err != nil {
+			return nil
+		}
+		return &ModuleBundleIcon{Name: name, Data: data}
+	}
+	return &ModuleBundleIcon{URL: iconURL}
+}
+
+func validateModuleBundle(b *ModuleBundle) error {
+	if b.Format != ModuleBundleFormat {
+		return fmt.Errorf("%w: not a module bundle", ErrInvalidInput)
+	}
+	if b.Version != ModuleBundleVersion {
+		return fmt.Errorf("%w: unsupported bundle version %d", ErrInvalidInput, b.Version)
+	}
+	b.Module.Name = strings.TrimSpace(b.Module.Name)
+	if b.Module.Name == "" || strings.TrimSpace(b.Module.GitURL) == "" {
+		return fmt.Errorf("%w: module name and git_url are required", ErrInvalidInput)
+	}
+	if err := validateBundleIcon(b.Module.Icon); err != nil {
+		return fmt.Errorf("%w: module icon: %s", ErrInvalidInput, err.Error())
+	}
+	for i := range b.Pages {
+		p := &b.Pages[i]
+		p.Name = strings.TrimSpace(p.Name)
+		if p.Name == "" || normalizePageSlug(p.Slug) == "" {
+			return fmt.Errorf("%w: pages[%d]: name and slug are required", ErrInvalidInput, i)
+		}
+		if (p.Container == "") != (p.Port == 0) || p.Port < 0 || p.Port > 65535 {
+			return fmt.Errorf("%w: pages[%d]: invalid container or port", ErrInvalidInput, i)
+		}
+		if err := validatePageMode(p.IframeOnly, p.PageOnly); err != nil {
+			return fmt.Errorf("%w: pages[%d]: %s", ErrInvalidInput, i, err.Error())
+		}
+		if err := validateBundleIcon(p.Icon); err != nil {
+			return fmt.Errorf("%w: pages[%d] icon: %s", ErrInvalidInput, i, err.Error())
+		}
+	}
+	return nil
+}
+
+func validateBundleIcon(icon *ModuleBundleIcon) error {
+	if icon == nil {
+		return nil
+	}
+	if len(icon.Data) > maxBundleIconSize {
+		return errors.New("too large")
+	}
+	if len(icon.Data) > 0 {
+		if _, err := iconExt(icon.Data); err != nil {
+			return errors.New("not a PNG, JPEG, WebP or GIF image")
+		}
+	}
+	return nil
+}
+
+// remapProjectName rewrites a container or network name generated by compose
+// for the source module (`<slug>-web-1`, `<slug>_default`, or the green
+// project) to the slug of the imported module. Other names are kept.
+func remapProjectName(name, oldSlug, newSlug string) string {
+	if name == "" || oldSlug == "" || oldSlug == newSlug {
+		return name
+	}
+	for _, project := range []string{oldSlug + greenProjectSuffix, oldSlug} {
+		for _, sep := range []string{"-", "_"} {
+			if strings.HasPrefix(name, project+sep) {
+				return newSlug + sep + strings.TrimPrefix(name, project+sep)
+			}
+		}
+	}
+	return name
+}
+
+// ImportModuleBundle creates a module from a bundle. Page IDs are new; the
+// report maps the bundle IDs to them. Nothing is created when the report has
+// conflicts (ErrConflict) or on a dry run.
+func ImportModuleBundle(ctx context.Context, b ModuleBundle, opts ModuleImportOptions, actor *User) (ModuleImportReport, error) {
+	report := ModuleImportReport{
+		DryRun:         opts.DryRun,
+		IDMap:          map[string]string{},
+		Pages:          []ModuleImportPage{},
+		Secrets:        []string{},
+		MissingSecrets: []string{},
+		Conflicts:      []string{},
+		Warnings:       []string{},
+	}
+	if err := validateModuleBundle(&b); err != nil {
+		return report, err
+	}
+	name := strings.TrimSpace(opts.Name)
+	if name == "" {
+		name = b.Module.Name
+	}
+
+	// Pages: slugs are global, so they may already be used here.
+	seen := map[string]bool{}
+	for _, p := range b.Pages {
+		slug := normalizePageSlug(p.Slug)
+		if renamed, ok := opts.PageSlugs[p.Slug]; ok {
+			slug = normalizePageSlug(renamed)
+		}
+		report.Pages = append(report.Pages, ModuleImportPage{SourceID: p.ID, SourceSlug: p.Slug, Slug: slug})
+		switch taken, err := database.IsPageSlugTaken(slug); {
+		case err != nil:
+			return report, fmt.Errorf("check page slug: %w", err)
+		case slug == "":
+			report.Conflicts = append(report.Conflicts, fmt.Sprintf("page %q: invalid slug", p.Slug))
+		case taken:
+			report.Conflicts = append(report.Conflicts, fmt.Sprintf("page slug %q is already used on this instance", slug))
+		case seen[slug]:
+			report.Conflicts = append(report.Conflicts, fmt.Sprintf("page slug %q is used twice", slug))
+		}
+		seen[slug] = true
+	}
+
+	// Roles are matched by name.
+	roles, err := database.GetAllRoles(nil, "", nil, 0)
+	if err != nil {
+		return report, fmt.Errorf("list roles: %w", err)
+	}
+	roleIDs := make(map[string]string, len(roles))
+	for _, r := range roles {
+		roleIDs[r.Name] = r.ID
+	}
+	for _, p := range b.Pages {
+		for _, r := range p.Roles {
+			if _, ok := roleIDs[r]; !ok {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("page %q: role %q does not exist here, link skipped", p.Slug, r))
+			}
+		}
+	}
+
+	// Secrets
+	secrets := map[string]string{}
+	if b.Secrets != nil {
+		ring, err := loadMasterKeyRing()
+		if err != nil {
+			report.Conflicts = append(report.Conflicts, "secrets: "+err.Error())
+		} else if secrets, err = openBundleSecrets(ring, *b.Secrets); err != nil {
+			report.Conflicts = append(report.Conflicts, "secrets: "+err.Error())
+		}
+	}
+	for _, n := range b.SecretNames {
+		if _, ok := secrets[n]; ok {
+			report.Secrets = append(report.Secrets, n)
+		} else {
+			report.MissingSecrets = append(report.MissingSecrets, n)
+		}
+	}
+
+	existing, err := database.GetAllModules(nil, "", nil, 0)
+	if err != nil {
+		return report, fmt.Errorf("list modules: %w", err)
+	}
+	for _, m := range existing {
+		if m.GitURL == b.Module.GitURL && m.GitBranch == b.Module.GitBranch {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("module %s already uses this repository and branch", m.Name))
+		}
+	}
+
+	if len(report.Conflicts) > 0 {
+		if opts.DryRun {
+			return report, nil
+		}
+		return report, fmt.Errorf("%w: %s", ErrConflict, strings.Join(report.Conflicts, "; "))
+	}
+	if opts.DryRun {
+		return report, nil
+	}
+
+	module, err := ImportModule(actor, name, b.Module.GitURL, b.Module.GitBranch, opts.SSHKeyID)
+	if err != nil {
+		return report, err
+	}
+	report.ModuleID, report.Slug = module.ID, module.Slug
+	report.IDMap[b.Source.ModuleID] = module.ID
+	warn := func(format string, args ...any) {
+		report.Warnings = append(report.Warnings, fmt.Sprintf(format, args...))
+	}
+
+	if icon := b.Module.Icon; icon != nil {
+		iconURL := icon.URL
+		if len(icon.Data) > 0 {
+			if iconURL, err = SaveModuleIcon(module.ID, icon.Data); err != nil {
+				warn("module icon: %v", err)
+			}
+		}
+		if iconURL != "" {
+			if _, err := PatchModule(ModulePatch{ID: module.ID, IconURL: &iconURL}); err != nil {
+				warn("module icon: %v", err)
+			}
+		}
+	}
+
+	for i, p := range b.Pages {
+		slug := report.Pages[i].Slug
+		var container *string
+		var port *int
+		if p.Container != "" {
+			c, n := remapProjectName(p.Container, b.Module.Slug, module.Slug), p.Port
+			container, port = &c, &n
+		}
+		page, err := ImportModulePage(module.ID, p.Name, &slug, container, port, p.IframeOnly, p.PageOnly, p.NeedAuth, p.IsVisible, remapProjectName(p.Network, b.Module.Slug, module.Slug))
+		if err != nil {
+			warn("page %q: %v", slug, err)
+			continue
+		}
+		report.Pages[i].PageID = page.ID
+		if p.ID != "" {
+			report.IDMap[p.ID] = page.ID
+		}
+		importPageRoles(page.ID, p.Roles, roleIDs, warn)
+		if icon := p.Icon; icon != nil {
+			iconURL := icon.URL
+			if len(icon.Data) > 0 {
+				if iconURL, err = SavePageIcon(page.ID, icon.Data); err != nil {
+					warn("page %q icon: %v", slug, err)
+				}
+			}
+			if iconURL != "" {
+				if err := database.SetPageIconURL(page.ID, &iconURL); err != nil {
+					warn("page %q icon: %v", slug, err)
+				}
+			}
+		}
+		if h := p.HealthCheck; h != nil {
+			if _, err := SetPageHealthCheck(ctx, module.ID, page.ID, PageHealthCheck{
+				Enabled:         h.Enabled,
+				Path:            h.Path,
+				ExpectedStatus:  h.ExpectedStatus,
+				IntervalSeconds: h.IntervalSeconds,
+				TimeoutSeconds:  h.TimeoutSeconds,
+			}); err != nil {
+				warn("page %q health check: %v", slug, err)
+			}
+		}
+	}
+
+	if o := b.OIDC; o != nil {
+		if client, err := ensureOIDCClientForModule(module); err != nil {
+			warn("oidc: %v", err)
+		} else {
+			uris, scopes := normalizeStringList(o.RedirectURIs), normalizeScopeValues(o.Scopes)
+			patch := database.OIDCClientPatch{ID: client.ID, AllowedRedirectURIs: &uris}
+			if len(scopes) > 0 {
+				patch.AllowedScopes = &scopes
+			}
+			if _, err := database.UpdateOIDCClient(patch); err != nil {
+				warn("oidc: %v", err)
+			}
+		}
+	}
+
+	if s := b.Settings.UpdatePolicy; s != nil {
+		if _, err := SetModuleUpdatePolicy(ctx, ModuleUpdatePolicy{
+			ModuleID:             module.ID,
+			Mode:                 s.Mode,
+			CheckIntervalSeconds: s.CheckIntervalSeconds,
+			Timezone:             s.Timezone,
+			MaintenanceWindows:   s.MaintenanceWindows,
+		}); err != nil {
+			warn("update policy: %v", err)
+		}
+	}
+	if s := b.Settings.DeployStrategy; s != nil {
+		if _, err := SetModuleDeploySettings(ctx, module, ModuleDeploySettings{Strategy: s.Strategy, HealthTimeoutSeconds: s.HealthTimeoutSeconds}); err != nil {
+			warn("deploy strategy: %v", err)
+		}
+	}
+	if l := b.Settings.ResourceLimits; l != nil {
+		limits, dropped := l.enforceable(storageOptEnabled())
+		if dropped {
+			warn("resource limits: disk_mb dropped, MODULE_LIMITS_STORAGE_OPT is off")
+		}
+		if _, err := SetModuleResourceLimits(ctx, module.ID, limits); err != nil {
+			warn("resource limits: %v", err)
+		}
+	}
+	if o := b.Settings.GitOptions; o != nil {
+		if _, err := SetModuleGitOptions(ctx, module.ID, *o); err != nil {
+			warn("git options: %v", err)
+		}
+	}
+	if t := b.Settings.ReleaseTracking; t != nil {
+		if _, err := SetModuleReleaseTracking(ctx, module, *t, actor); err != nil {
+			warn("release tracking: %v", err)
+		}
+	}
+
+	for _, n := range report.Secrets {
+		if _, _, err := SetModuleSecret(ctx, module.ID, n, secrets[n]); err != nil {
+			warn("secret %s: %v", n, err)
+		}
+	}
+
+	LogModule(module.ID, "INFO", "Module imported from bundle", map[string]any{
+		"source_host":     b.Source.Host,
+		"source_module":   b.Source.ModuleID,
+		"pages":           len(b.Pages),
+		"secrets":         len(report.Secrets),
+		"missing_secrets": report.MissingSecrets,
+		"warnings":        report.Warnings,
+	}, nil)
+	return report, nil
+}
+
+// importPageRoles links a new page to the bundle roles found here, dropping
+// the admin role ImportModulePage assigns when the bundle page did not have it.
+func importPageRoles(pageID string, names []string, roleIDs map[string]string, warn func(string, ...any)) {
+	want := make([]string, 0, len(names))
+	for _, n := range names {
+		if id, ok := roleIDs[n]; ok {
+			want = append(want, id)
+		}
+	}
+	for _, id := range want {
+		if err := database.AssignRoleToPage(id, pageID); err != nil {
+			warn("page role %s: %v", id, err)
+		}
+	}
+	if !slices.Contains(want, RoleIDAdmin) {
+		if err := database.RemoveRoleFromPage(RoleIDAdmin, pageID); err != nil {
+			warn("page role %s: %v", RoleIDAdmin, err)
+		}
+	}
+}
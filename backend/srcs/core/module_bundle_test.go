@@ -0,0 +1,120 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func testKeyRing(t *testing.T, versions ...int) *masterKeyRing {
+	t.Helper()
+	ring := &masterKeyRing{keys: map[int][]byte{}}
+	for _, v := range versions {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			t.Fatal(err)
+		}
+		ring.keys[v] = key
+		if v > ring.active {
+			ring.active = v
+		}
+	}
+	return ring
+}
+
+func recipientKey(t *testing.T, ring *masterKeyRing, version int) string {
+	t.Helper()
+	priv, err := bundlePrivateKey(ring, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(priv.PublicKey().Bytes())
+}
+
+func TestBundleSecretsRoundTrip(t *testing.T) {
+	target := testKeyRing(t, 1, 2)
+	secrets := map[string]string{"DB_PASSWORD": "hunter2", "API_TOKEN": "abc"}
+
+	// Sealed for the previous key version: still opens after a rotation.
+	sealed, err := sealBundleSecrets(secrets, recipientKey(t, target, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := openBundleSecrets(target, *sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["DB_PASSWORD"] != "hunter2" || got["API_TOKEN"] != "abc" {
+		t.Errorf("got %v", got)
+	}
+
+	if _, err := openBundleSecrets(testKeyRing(t, 1), *sealed); err == nil {
+		t.Error("opened with another instance key")
+	}
+	sealed.Ciphertext[0] ^= 1
+	if _, err := openBundleSecrets(target, *sealed); err == nil {
+		t.Error("opened tampered ciphertext")
+	}
+	if _, err := sealBundleSecrets(secrets, "not a key"); err == nil {
+		t.Error("sealed for an invalid key")
+	}
+}
+
+func TestRemapProjectName(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{"wiki-web-1", "wiki-2-web-1"},
+		{"wiki_default", "wiki-2_default"},
+		{"wiki--green-web-1", "wiki-2-web-1"},
+		{"wiki--green_default", "wiki-2_default"},
+		{"shared-proxy", "shared-proxy"},
+		{"wikipedia-web-1", "wikipedia-web-1"},
+		{"", ""},
+	}
+	for _, c := range cases {
+		if got := remapProjectName(c.in, "wiki", "wiki-2"); got != c.want {
+			t.Errorf("remapProjectName(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestValidateModuleBundle(t *testing.T) {
+	valid := func() ModuleBundle {
+		return ModuleBundle{
+			Format:  ModuleBundleFormat,
+			Version: ModuleBundleVersion,
+			Module:  ModuleBundleModule{Name: "Wiki", GitURL: "git@example.com:wiki.git"},
+			Pages:   []ModuleBundlePage{{Name: "Wiki", Slug: "wiki", Container: "wiki-web-1", Port: 80}},
+		}
+	}
+	b := valid()
+	if err := validateModuleBundle(&b); err != nil {
+		t.Fatalf("valid bundle: %v", err)
+	}
+
+	b = valid()
+	b.Version = 2
+	if err := validateModuleBundle(&b); err == nil {
+		t.Error("unsupported version accepted")
+	}
+	b = valid()
+	b.Pages[0].Port = 0
+	if err := validateModuleBundle(&b); err == nil {
+		t.Error("container without port accepted")
+	}
+	b = valid()
+	b.Module.Icon = &ModuleBundleIcon{Name: "icon.png", Data: []byte("#!/bin/sh\n")}
+	if err := validateModuleBundle(&b); err == nil {
+		t.Error("non-image icon accepted")
+	}
+	b = valid()
+	b.Pages[0].Icon = &ModuleBundleIcon{Name: "icon.svg", Data: []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`)}
+	if err := validateModuleBundle(&b); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("svg page icon: error = %v, want ErrInvalidInput", err)
+	}
+	b = valid()
+	b.Pages[0].Icon = &ModuleBundleIcon{Name: "icon.svg", Data: testPNG}
+	if err := validateModuleBundle(&b); err != nil {
+		t.Errorf("png named .svg: %v", err)
+	}
+}
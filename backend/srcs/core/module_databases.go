@@ -0,0 +1,599 @@
+package core
+
+import (
+	"backend/database"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	networktypes "github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/lib/pq"
+)
+
+// Managed database states.
+const (
+	ManagedDatabaseActive  = "active"
+	ManagedDatabaseRetired = "retired"
+	ManagedDatabaseDropped = "dropped"
+)
+
+// managedDatabaseLabel marks the docker networks created for managed databases.
+const managedDatabaseLabel = "com.panbagnat.managed-db.module"
+
+// managedDatabaseSecrets are the module secrets written on provisioning.
+var managedDatabaseSecrets = []string{"DATABASE_URL", "PGHOST", "PGPORT", "PGDATABASE", "PGUSER", "PGPASSWORD"}
+
+var (
+	managedDBOnce sync.Once
+	managedDBConn *sql.DB
+	managedDBErr  error
+	// managedDBMu serializes the dumps of retired databases.
+	managedDBMu          sync.Mutex
+	managedDBSweeperOnce sync.Once
+	// managedDBHardened is set once managedDatabaseHardeningStmts succeeded.
+	managedDBHardenMu sync.Mutex
+	managedDBHardened bool
+
+	managedDBIdentRe = regexp.MustCompile(`[^a-z0-9_]+`)
+)
+
+// ModuleDatabase is the managed database of a module. The password is only
+// in the module secrets.
+type ModuleDatabase struct {
+	ModuleID  string     `json:"module_id"`
+	Status    string     `json:"status"`
+	Host      string     `json:"host"`
+	Port      int        `json:"port"`
+	Database  string     `json:"database"`
+	Role      string     `json:"role"`
+	Network   string     `json:"network"`
+	Secrets   []string   `json:"secrets"`
+	CreatedAt time.Time  `json:"created_at"`
+	RetiredAt *time.Time `json:"retired_at,omitempty"`
+}
+
+func dbModuleDatabaseToModuleDatabase(d database.ModuleDatabase) ModuleDatabase {
+	out := ModuleDatabase{
+		ModuleID:  d.ModuleID.String,
+		Status:    d.Status,
+		Host:      managedDBHost(),
+		Port:      managedDBPort(),
+		Database:  d.DBName,
+		Role:      d.RoleName,
+		Network:   d.Network,
+		Secrets:   managedDatabaseSecrets,
+		CreatedAt: d.CreatedAt,
+	}
+	if d.RetiredAt.Valid {
+		t := d.RetiredAt.Time
+		out.RetiredAt = &t
+	}
+	return out
+}
+
+// managedDBContainer is the platform Postgres container modules are given
+// access to.
+func managedDBContainer() string {
+	if c := strings.TrimSpace(os.Getenv("MANAGED_DB_CONTAINER")); c != "" {
+		return c
+	}
+	return "pan-bagnat-modules-db"
+}
+
+// managedDBHost is the hostname modules reach the managed Postgres at, on
+// their database network.
+func managedDBHost() string {
+	if h := strings.TrimSpace(os.Getenv("MANAGED_DB_HOST")); h != "" {
+		return h
+	}
+	return managedDBContainer()
+}
+
+func managedDBPort() int {
+	return envInt("MANAGED_DB_PORT", 5432)
+}
+
+func managedDBDumpDir() string {
+	if dir := strings.TrimSpace(os.Getenv("MANAGED_DB_DUMP_DIR")); dir != "" {
+		return dir
+	}
+	return "/data/db-dumps"
+}
+
+// managedDBAdmin opens the connection used to create databases and roles,
+// from MANAGED_DB_ADMIN_URL.
+func managedDBAdmin() (*sql.DB, error) {
+	adminURL := strings.TrimSpace(os.Getenv("MANAGED_DB_ADMIN_URL"))
+	if adminURL == "" {
+		return nil, ErrManagedDatabaseDisabled
+	}
+	managedDBOnce.Do(func() {
+		managedDBConn, managedDBErr = sql.Open("postgres", adminURL)
+	})
+	return managedDBConn, managedDBErr
+}
+
+// managedDBAdminUser is the user pg_dump runs as inside the Postgres container.
+func managedDBAdminUser() string {
+	u, err := url.Parse(strings.TrimSpace(os.Getenv("MANAGED_DB_ADMIN_URL")))
+	if err == nil && u.User != nil && u.User.Username() != "" {
+		return u.User.Username()
+	}
+	return "postgres"
+}
+
+// managedDatabaseSystemDBs are the databases every Postgres cluster has.
+// PUBLIC may connect to them by default, module roles included.
+var managedDatabaseSystemDBs = []string{"postgres", "template1"}
+
+// managedDatabaseHardeningStmts keep module roles out of the system databases.
+func managedDatabaseHardeningStmts() []string {
+	stmts := make([]string, 0, len(managedDatabaseSystemDBs))
+	for _, name := range managedDatabaseSystemDBs {
+		stmts = append(stmts, fmt.Sprintf("REVOKE CONNECT, TEMPORARY ON DATABASE %s FROM PUBLIC", pq.QuoteIdentifier(name)))
+	}
+	return stmts
+}
+
+// hardenManagedDB runs managedDatabaseHardeningStmts the first time a database
+// is provisioned in this process, and again after a failure.
+func hardenManagedDB(ctx context.Context, admin *sql.DB) error {
+	managedDBHardenMu.Lock()
+	defer managedDBHardenMu.Unlock()
+	if managedDBHardened {
+		return nil
+	}
+	for _, stmt := range managedDatabaseHardeningStmts() {
+		if _, err := admin.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%s: %w", stmt, err)
+		}
+	}
+	managedDBHardened = true
+	return nil
+}
+
+// managedDatabaseCreateStmts create the database name owned by the role of the
+// same name, which only that role may connect to.
+func managedDatabaseCreateStmts(name string) []string {
+	ident := pq.QuoteIdentifier(name)
+	return []string{
+		fmt.Sprintf("CREATE DATABASE %s OWNER %s", ident, ident),
+		fmt.Sprintf("REVOKE ALL ON DATABASE %s FROM PUBLIC", ident),
+	}
+}
+
+// managedDatabaseName derives a database and role name from a module slug,
+// with a random suffix so a name is never reused while an older database
+// of the same module waits for its dump.
+func managedDatabaseName(slug string) (string, error) {
+	base := strings.Trim(managedDBIdentRe.ReplaceAllString(strings.ReplaceAll(strings.ToLower(slug), "-", "_"), "_"), "_")
+	if len(base) > 48 {
+		base = strings.TrimRight(base[:48], "_")
+	}
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return "m_" + base + "_" + hex.EncodeToString(suffix), nil
+}
+
+func managedDatabasePassword() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// GetModuleDatabase returns the active managed database of a module.
+func GetModuleDatabase(ctx context.Context, moduleID string) (ModuleDatabase, error) {
+	d, err := database.GetActiveModuleDatabase(ctx, moduleID)
+	if errors.Is(err, database.ErrNotFound) {
+		return ModuleDatabase{}, ErrNotFound
+	}
+	if err != nil {
+		return ModuleDatabase{}, err
+	}
+	return dbModuleDatabaseToModuleDatabase(d), nil
+}
+
+// ProvisionModuleDatabase creates a database and its owner role in the
+// managed Postgres, a docker network joining that Postgres to the module,
+// and the module secrets holding the credentials. The module containers
+// join the network from the next deploy. On a module that already has a
+// database, the password is rotated and the network and secrets repaired.
+func ProvisionModuleDatabase(ctx context.Context, module Module, actor *User) (ModuleDatabase, bool, error) {
+	admin, err := managedDBAdmin()
+	if err != nil {
+		return ModuleDatabase{}, false, err
+	}
+	meta := map[string]any{}
+	if actor != nil {
+		meta["actor"] = actor.FtLogin
+	}
+	password, err := managedDatabasePassword()
+	if err != nil {
+		return ModuleDatabase{}, false, err
+	}
+	if err := hardenManagedDB(ctx, admin); err != nil {
+		return ModuleDatabase{}, false, LogModule(module.ID, "ERROR", "Failed to revoke public access to the managed Postgres system databases", nil, err)
+	}
+
+	if existing, err := database.GetActiveModuleDatabase(ctx, module.ID); err == nil {
+		if _, err := admin.ExecContext(ctx, fmt.Sprintf("ALTER ROLE %s WITH LOGIN PASSWORD %s",
+			pq.QuoteIdentifier(existing.RoleName), pq.QuoteLiteral(password))); err != nil {
+			return ModuleDatabase{}, false, LogModule(module.ID, "ERROR", "Failed to rotate the managed database password", nil, err)
+		}
+		if err := ensureManagedDatabaseNetwork(ctx, existing.Network, module.ID); err != nil {
+			return ModuleDatabase{}, false, LogModule(module.ID, "ERROR", "Failed to attach the managed database network", nil, err)
+		}
+		if err := writeManagedDatabaseSecrets(ctx, module.ID, existing, password); err != nil {
+			return ModuleDatabase{}, false, err
+		}
+		meta["database"] = existing.DBName
+		LogModule(module.ID, "INFO", "Managed database password rotated, deploy to apply it", meta, nil)
+		return dbModuleDatabaseToModuleDatabase(existing), false, nil
+	} else if !errors.Is(err, database.ErrNotFound) {
+		return ModuleDatabase{}, false, err
+	}
+
+	name, err := managedDatabaseName(module.Slug)
+	if err != nil {
+		return ModuleDatabase{}, false, err
+	}
+	row := database.ModuleDatabase{
+		ModuleID:   sql.NullString{String: module.ID, Valid: true},
+		ModuleSlug: module.Slug,
+		DBName:     name,
+		RoleName:   name,
+		Network:    "pan-bagnat-db-" + strings.ReplaceAll(name, "_", "-"),
+	}
+	ident := pq.QuoteIdentifier(name)
+
+	// CREATE DATABASE cannot run in a transaction, so undo by hand
+	if _, err := admin.ExecContext(ctx, fmt.Sprintf("CREATE ROLE %s WITH LOGIN PASSWORD %s", ident, pq.QuoteLiteral(password))); err != nil {
+		return ModuleDatabase{}, false, LogModule(module.ID, "ERROR", "Failed to create the managed database role", nil, err)
+	}
+	undo := func() {
+		_, _ = admin.ExecContext(context.Background(), fmt.Sprintf("DROP DATABASE IF EXISTS %s WITH (FORCE)", ident))
+		_, _ = admin.ExecContext(context.Background(), fmt.Sprintf("DROP ROLE IF EXISTS %s", ident))
+		_ = removeManagedDatabaseNetwork(context.Background(), row.Network)
+	}
+	for _, stmt := range managedDatabaseCreateStmts(name) {
+		if _, err := admin.ExecContext(ctx, stmt); err != nil {
+			undo()
+			return ModuleDatabase{}, false, LogModule(module.ID, "ERROR", "Failed to create the managed database", nil, err)
+		}
+	}
+	if err := ensureManagedDatabaseNetwork(ctx, row.Network, module.ID); err != nil {
+		undo()
+		return ModuleDatabase{}, false, LogModule(module.ID, "ERROR", "Failed to create the managed database network", nil, err)
+	}
+	inserted, err := database.InsertModuleDatabase(ctx, row)
+	if err != nil {
+		undo()
+		if errors.Is(err, database.ErrAlreadyExists) {
+			return ModuleDatabase{}, false, fmt.Errorf("%w: a managed database is already being provisioned", ErrConflict)
+		}
+		return ModuleDatabase{}, false, err
+	}
+	if err := writeManagedDatabaseSecrets(ctx, module.ID, inserted, password); err != nil {
+		return dbModuleDatabaseToModuleDatabase(inserted), true, err
+	}
+	meta["database"] = name
+	meta["network"] = row.Network
+	LogModule(module.ID, "INFO", "Managed database provisioned, deploy to use it", meta, nil)
+	return dbModuleDatabaseToModuleDatabase(inserted), true, nil
+}
+
+func writeManagedDatabaseSecrets(ctx context.Context, moduleID string, d database.ModuleDatabase, password string) error {
+	host, port := managedDBHost(), fmt.Sprint(managedDBPort())
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(d.RoleName, password),
+		Host:     host + ":" + port,
+		Path:     "/" + d.DBName,
+		RawQuery: "sslmode=disable",
+	}
+	values := map[string]string{
+		"DATABASE_URL": dsn.String(),
+		"PGHOST":       host,
+		"PGPORT":       port,
+		"PGDATABASE":   d.DBName,
+		"PGUSER":       d.RoleName,
+		"PGPASSWORD":   password,
+	}
+	for _, name := range managedDatabaseSecrets {
+		if _, _, err := SetModuleSecret(ctx, moduleID, name, values[name]); err != nil {
+			return LogModule(moduleID, "ERROR", "Failed to store the managed database credentials", map[string]any{"secret": name}, err)
+		}
+	}
+	return nil
+}
+
+// ensureManagedDatabaseNetwork creates the internal network of a managed
+// database if needed and connects the managed Postgres to it under
+// managedDBHost.
+func ensureManagedDatabaseNetwork(ctx context.Context, name, moduleID string) error {
+	cli, err := getDockerClient()
+	if err != nil {
+		return err
+	}
+	if _, err := cli.NetworkInspect(ctx, name, networktypes.InspectOptions{}); client.IsErrNotFound(err) {
+		if _, err := cli.NetworkCreate(ctx, name, networktypes.CreateOptions{
+			Driver:   "bridge",
+			Internal: true,
+			Labels:   map[string]string{managedDatabaseLabel: moduleID},
+		}); err != nil {
+			return fmt.Errorf("create network %s: %w", name, err)
+		}
+	} else if err != nil {
+		return err
+	}
+	pg, err := cli.ContainerInspect(ctx, managedDBContainer())
+	if err != nil {
+		return fmt.Errorf("inspect %s: %w", managedDBContainer(), err)
+	}
+	if _, ok := pg.NetworkSettings.Networks[name]; ok {
+		return nil
+	}
+	if err := cli.NetworkConnect(ctx, name, pg.ID, &networktypes.EndpointSettings{Aliases: []string{managedDBHost()}}); err != nil {
+		return fmt.Errorf("connect %s to %s: %w", managedDBContainer(), name, err)
+	}
+	return nil
+}
+
+// removeManagedDatabaseNetwork detaches the managed Postgres from a network
+// and removes it. It fails while module containers are still attached.
+func removeManagedDatabaseNetwork(ctx context.Context, name string) error {
+	cli, err := getDockerClient()
+	if err != nil {
+		return err
+	}
+	if err := cli.NetworkDisconnect(ctx, name, managedDBContainer(), true); err != nil && !client.IsErrNotFound(err) {
+		log.Printf("[managed-db] disconnect %s from %s: %v", managedDBContainer(), name, err)
+	}
+	if err := cli.NetworkRemove(ctx, name); err != nil && !client.IsErrNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// moduleDatabaseNetwork returns the network the module containers join to
+// reach their managed database, or "" when the module has none. The network
+// is recreated if it went missing.
+func moduleDatabaseNetwork(module Module) (string, error) {
+	d, err := database.GetActiveModuleDatabase(context.Background(), module.ID)
+	if errors.Is(err, database.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := ensureManagedDatabaseNetwork(context.Background(), d.Network, module.ID); err != nil {
+		return "", fmt.Errorf("managed database network: %w", err)
+	}
+	return d.Network, nil
+}
+
+// managedDatabaseNetworksOverride joins every service of cfg to network.
+// Compose merges the networks of an override with those of the service, but
+// a service without any would lose its implicit default network, so it is
+// listed. Services sharing the network stack of another container or of the
+// host are left alone.
+func managedDatabaseNetworksOverride(cfg composeConfig, network string) (map[string]any, map[string]any) {
+	services := map[string]any{}
+	for name, svc := range cfg.Services {
+		if svc.NetworkMode != "" {
+			continue
+		}
+		nets := map[string]any{network: nil}
+		if len(svc.Networks) == 0 {
+			nets["default"] = nil
+		}
+		services[name] = map[string]any{"networks": nets}
+	}
+	networks := map[string]any{network: map[string]any{"name": network, "external": true}}
+	return services, networks
+}
+
+// DeprovisionModuleDatabase takes a module off its managed database and
+// removes the credentials from its secrets. The database is dumped and
+// dropped in the background; the dump is kept for MANAGED_DB_DUMP_RETENTION.
+func DeprovisionModuleDatabase(ctx context.Context, module Module, actor *User) error {
+	d, err := database.GetActiveModuleDatabase(ctx, module.ID)
+	if errors.Is(err, database.ErrNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := retireModuleDatabase(ctx, d); err != nil {
+		return err
+	}
+	for _, name := range managedDatabaseSecrets {
+		if err := DeleteModuleSecret(ctx, module.ID, name); err != nil && !errors.Is(err, ErrNotFound) {
+			LogModule(module.ID, "WARN", fmt.Sprintf("Failed to delete secret %s", name), nil, err)
+		}
+	}
+	meta := map[string]any{"database": d.DBName}
+	if actor != nil {
+		meta["actor"] = actor.FtLogin
+	}
+	LogModule(module.ID, "INFO", "Managed database deprovisioned, it is dumped before being dropped", meta, nil)
+	return nil
+}
+
+// deprovisionDeletedModuleDatabase retires the database of a module that is
+// being deleted.
+func deprovisionDeletedModuleDatabase(module Module) {
+	d, err := database.GetActiveModuleDatabase(context.Background(), module.ID)
+	if errors.Is(err, database.ErrNotFound) {
+		return
+	}
+	if err == nil {
+		err = retireModuleDatabase(context.Background(), d)
+	}
+	if err != nil {
+		LogModule(module.ID, "WARN", "Failed to deprovision the managed database", nil, err)
+	}
+}
+
+// retireModuleDatabase locks the role out, then dumps and drops the database
+// in the background. The sweeper retries what fails.
+func retireModuleDatabase(ctx context.Context, d database.ModuleDatabase) error {
+	admin, err := managedDBAdmin()
+	if err != nil {
+		return err
+	}
+	retired, err := database.RetireModuleDatabase(ctx, d.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := admin.ExecContext(ctx, fmt.Sprintf("ALTER ROLE %s WITH NOLOGIN", pq.QuoteIdentifier(d.RoleName))); err != nil {
+		log.Printf("[managed-db] lock role %s: %v", d.RoleName, err)
+	}
+	if _, err := admin.ExecContext(ctx, `
+		SELECT pg_terminate_backend(pid)
+		  FROM pg_stat_activity
+		 WHERE datname = $1 AND pid <> pg_backend_pid()
+	`, d.DBName); err != nil {
+		log.Printf("[managed-db] close connections to %s: %v", d.DBName, err)
+	}
+	go func() {
+		managedDBMu.Lock()
+		defer managedDBMu.Unlock()
+		if err := dropRetiredModuleDatabase(context.Background(), retired); err != nil {
+			log.Printf("[managed-db] drop %s: %v", retired.DBName, err)
+		}
+	}()
+	return nil
+}
+
+// dropRetiredModuleDatabase dumps a retired database next to the other dumps,
+// then drops it with its role and network.
+func dropRetiredModuleDatabase(ctx context.Context, d database.ModuleDatabase) error {
+	admin, err := managedDBAdmin()
+	if err != nil {
+		return err
+	}
+	fail := func(err error) error {
+		_ = database.SetModuleDatabaseError(ctx, d.ID, err.Error())
+		return err
+	}
+
+	dir := managedDBDumpDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fail(err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.dump", d.DBName, time.Now().UTC().Format("20060102T150405Z")))
+	if err := dumpManagedDatabase(ctx, d.DBName, path); err != nil {
+		_ = os.Remove(path)
+		return fail(fmt.Errorf("pg_dump: %w", err))
+	}
+
+	ident := pq.QuoteIdentifier(d.DBName)
+	for _, stmt := range []string{
+		fmt.Sprintf("DROP DATABASE IF EXISTS %s WITH (FORCE)", ident),
+		fmt.Sprintf("DROP ROLE IF EXISTS %s", pq.QuoteIdentifier(d.RoleName)),
+	} {
+		if _, err := admin.ExecContext(ctx, stmt); err != nil {
+			return fail(err)
+		}
+	}
+	if err := removeManagedDatabaseNetwork(ctx, d.Network); err != nil {
+		log.Printf("[managed-db] remove network %s: %v", d.Network, err)
+	}
+	retention := envDuration("MANAGED_DB_DUMP_RETENTION", 30*24*time.Hour)
+	if err := database.MarkModuleDatabaseDropped(ctx, d.ID, path, time.Now().UTC().Add(retention)); err != nil {
+		return err
+	}
+	log.Printf("[managed-db] %s of %s dumped to %s and dropped", d.DBName, d.ModuleSlug, path)
+	return nil
+}
+
+// dumpManagedDatabase runs pg_dump inside the managed Postgres container, so
+// its version always matches the server.
+func dumpManagedDatabase(ctx context.Context, dbName, path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ctx, cancel := context.WithTimeout(ctx, envDuration("MANAGED_DB_DUMP_TIMEOUT", time.Hour))
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", "exec", managedDBContainer(),
+		"pg_dump", "--format=custom", "--no-owner", "-U", managedDBAdminUser(), "--dbname", dbName)
+	cmd.Stdout = f
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return f.Sync()
+}
+
+// StartManagedDatabaseSweeper retries the dumps of retired databases and
+// removes dumps past their retention every MANAGED_DB_SWEEP_TICK (default
+// 1h; 0 disables it). It does nothing while managed databases are off.
+func StartManagedDatabaseSweeper() {
+	tick := envDuration("MANAGED_DB_SWEEP_TICK", time.Hour)
+	if tick <= 0 || strings.TrimSpace(os.Getenv("MANAGED_DB_ADMIN_URL")) == "" {
+		return
+	}
+	managedDBSweeperOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(tick)
+			defer ticker.Stop()
+			for range ticker.C {
+				sweepModuleDatabases(context.Background(), time.Now())
+			}
+		}()
+	})
+}
+
+func sweepModuleDatabases(ctx context.Context, now time.Time) {
+	managedDBMu.Lock()
+	defer managedDBMu.Unlock()
+	rows, err := database.ListRetiredModuleDatabases(ctx, now)
+	if err != nil {
+		log.Printf("[managed-db] list retired databases: %v", err)
+		return
+	}
+	for _, d := range rows {
+		switch d.Status {
+		case ManagedDatabaseRetired:
+			if err := dropRetiredModuleDatabase(ctx, d); err != nil {
+				log.Printf("[managed-db] drop %s: %v", d.DBName, err)
+			}
+		case ManagedDatabaseDropped:
+			if d.DumpPath != "" {
+				if err := os.Remove(d.DumpPath); err != nil && !os.IsNotExist(err) {
+					log.Printf("[managed-db] remove dump %s: %v", d.DumpPath, err)
+					continue
+				}
+			}
+			if err := database.DeleteModuleDatabase(ctx, d.ID); err != nil {
+				log.Printf("[managed-db] forget %s: %v", d.DBName, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestManagedDatabaseName(t *testing.T) {
+	valid := regexp.MustCompile(`^m_[a-z0-9_]+_[0-9a-f]{6}$`)
+	cases := []struct{ slug, prefix string }{
+		{"wiki-home", "m_wiki_home_"},
+		{"Mod.Name", "m_mod_name_"},
+		{strings.Repeat("abc-", 20), "m_" + strings.Repeat("abc_", 12)[:47] + "_"},
+	}
+	for _, c := range cases {
+		got, err := managedDatabaseName(c.slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !valid.MatchString(got) || !strings.HasPrefix(got, c.prefix) || len(got) > 63 {
+			t.Errorf("managedDatabaseName(%q) = %q, want prefix %q", c.slug, got, c.prefix)
+		}
+	}
+	a, _ := managedDatabaseName("mod")
+	b, _ := managedDatabaseName("mod")
+	if a == b {
+		t.Errorf("two databases of the same slug share name %q", a)
+	}
+}
+
+func TestManagedDatabaseSQL(t *testing.T) {
+	want := []string{
+		`CREATE DATABASE "m_wiki_0a1b2c" OWNER "m_wiki_0a1b2c"`,
+		`REVOKE ALL ON DATABASE "m_wiki_0a1b2c" FROM PUBLIC`,
+	}
+	if got := managedDatabaseCreateStmts("m_wiki_0a1b2c"); !reflect.DeepEqual(got, want) {
+		t.Errorf("create statements = %q, want %q", got, want)
+	}
+
+	want = []string{
+		`REVOKE CONNECT, TEMPORARY ON DATABASE "postgres" FROM PUBLIC`,
+		`REVOKE CONNECT, TEMPORARY ON DATABASE "template1" FROM PUBLIC`,
+	}
+	if got := managedDatabaseHardeningStmts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("hardening statements = %q, want %q", got, want)
+	}
+}
+
+func TestManagedDatabaseNetworksOverride(t *testing.T) {
+	var cfg composeConfig
+	cfg.Services = map[string]struct {
+		ContainerName string         `json:"container_name"`
+		NetworkMode   string         `json:"network_mode"`
+		Networks      map[string]any `json:"networks"`
+		Ports         []struct {
+			Published any `json:"published"`
+		} `json:"ports"`
+	}{
+		"app":     {},
+		"worker":  {Networks: map[string]any{"backend": nil}},
+		"sidecar": {NetworkMode: "service:app"},
+	}
+	services, networks := managedDatabaseNetworksOverride(cfg, "pan-bagnat-db-x")
+
+	nets := func(svc string) map[string]any {
+		spec, ok := services[svc].(map[string]any)
+		if !ok {
+			return nil
+		}
+		return spec["networks"].(map[string]any)
+	}
+	if n := nets("app"); len(n) != 2 || !hasKey(n, "default") || !hasKey(n, "pan-bagnat-db-x") {
+		t.Errorf("app networks = %v", n)
+	}
+	if n := nets("worker"); len(n) != 1 || !hasKey(n, "pan-bagnat-db-x") {
+		t.Errorf("worker networks = %v", n)
+	}
+	if _, ok := services["sidecar"]; ok {
+		t.Error("service with network_mode was given networks")
+	}
+	net, _ := networks["pan-bagnat-db-x"].(map[string]any)
+	if net["external"] != true || net["name"] != "pan-bagnat-db-x" {
+		t.Errorf("networks = %v", networks)
+	}
+}
+
+func hasKey(m map[string]any, key string) bool {
+	_, ok := m[key]
+	return ok
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+const (
+	moduleIconDir = "./assets/module-icons"
+	pageIconDir   = "./assets/page-icons"
+)
+
+// iconExts are the extensions an icon may have been stored with, including
+// ones no longer accepted.
+var iconExts = []string{".png", ".jpg", ".jpeg", ".webp", ".gif", ".svg"}
+
+// iconExt returns the extension matching the content of a raster image. The
+// file name is never trusted, and SVG is refused: icons are served from the
+// main origin, where an SVG could run script.
+func iconExt(data []byte) (string, error) {
+	switch http.DetectContentType(data) {
+	case "image/png":
+		return ".png", nil
+	case "image/jpeg":
+		return ".jpg", nil
+	case "image/webp":
+		return ".webp", nil
+	case "image/gif":
+		return ".gif", nil
+	}
+	return "", fmt.Errorf("%w: icon must be a PNG, JPEG, WebP or GIF image", ErrInvalidInput)
+}
+
+// saveIcon writes data as the icon of id in dir and returns its public URL
+// under urlPrefix. Icons of id stored with another extension are removed.
+func saveIcon(dir, urlPrefix, id string, data []byte) (string, error) {
+	ext, err := iconExt(data)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	dst := filepath.Join(dir, id+ext)
+	if err := os.WriteFile(dst, data, 0o644); err != nil {
+		return "", err
+	}
+	for _, other := range iconExts {
+		if other != ext {
+			_ = os.Remove(filepath.Join(dir, id+other))
+		}
+	}
+	return urlPrefix + filepath.Base(dst), nil
+}
+
+// SaveModuleIcon stores the icon of a module and returns its public URL.
+func SaveModuleIcon(moduleID string, data []byte) (string, error) {
+	return saveIcon(moduleIconDir, "/assets/module-icons/", moduleID, data)
+}
+
+// SavePageIcon stores the icon of a page and returns its public URL.
+func SavePageIcon(pageID string, data []byte) (string, error) {
+	return saveIcon(pageIconDir, "/assets/page-icons/", pageID, data)
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89")
+
+func TestIconExt(t *testing.T) {
+	cases := map[string][]byte{
+		".png":  testPNG,
+		".jpg":  []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"),
+		".gif":  []byte("GIF89a\x01\x00\x01\x00"),
+		".webp": []byte("RIFF\x00\x00\x00\x00WEBPVP8 "),
+	}
+	for want, data := range cases {
+		if got, err := iconExt(data); err != nil || got != want {
+			t.Errorf("iconExt(%s) = %q, %v", want, got, err)
+		}
+	}
+
+	rejected := [][]byte{
+		[]byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`),
+		[]byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"/>`),
+		[]byte(`<html><body>hi</body></html>`),
+		[]byte("plain text"),
+		nil,
+	}
+	for _, data := range rejected {
+		if _, err := iconExt(data); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("iconExt(%q) error = %v, want ErrInvalidInput", data, err)
+		}
+	}
+}
+
+func TestSaveIcon(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "module_1.svg")
+	if err := os.WriteFile(stale, []byte("<svg/>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	url, err := saveIcon(dir, "/assets/module-icons/", "module_1", testPNG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "/assets/module-icons/module_1.png" {
+		t.Errorf("url = %q", url)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale svg icon kept: %v", err)
+	}
+
+	if _, err := saveIcon(dir, "/assets/module-icons/", "module_2", []byte("<svg/>")); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("saveIcon(svg) error = %v, want ErrInvalidInput", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "module_2.svg")); !os.IsNotExist(err) {
+		t.Error("svg icon written")
+	}
+}
@@ -0,0 +1,420 @@
+package core
+
+import (
+	"backend/database"
+	"backend/websocket"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+const (
+	ModuleJobDeploy   = "deploy"
+	ModuleJobRebuild  = "rebuild"
+	ModuleJobDown     = "down"
+	ModuleJobPull     = "pull"
+	ModuleJobClone    = "clone"
+	ModuleJobRollback = "rollback"
+
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// ErrJobCancelled is returned when waiting on a job that was cancelled.
+var ErrJobCancelled = errors.New("job cancelled")
+
+// moduleJobMaxAttempts bounds how many times a job interrupted by a restart is retried.
+const moduleJobMaxAttempts = 3
+
+// jobKillGrace is how long a cancelled command gets to exit after SIGINT.
+var jobKillGrace = 10 * time.Second
+
+// ModuleJobParams carries the kind-specific options of a job.
+type ModuleJobParams struct {
+	// Source of the deployment for deploy, rebuild and pull+deploy jobs.
+	Source string `json:"source,omitempty"`
+	// Deploy makes a pull job deploy afterwards when the tree is still clean.
+	Deploy bool `json:"deploy,omitempty"`
+	// DeploymentID is the deployment a rollback job returns to.
+	DeploymentID string `json:"deployment_id,omitempty"`
+	// TemplateID makes a clone job build the repository from a template,
+	// with TemplatePort as its port, and push it to the module's remote
+	// when Push is set.
+	TemplateID   string `json:"template_id,omitempty"`
+	TemplatePort int    `json:"template_port,omitempty"`
+	Push         bool   `json:"push,omitempty"`
+}
+
+type ModuleJob struct {
+	ID              string          `json:"id"`
+	ModuleID        string          `json:"module_id"`
+	Kind            string          `json:"kind"`
+	Params          ModuleJobParams `json:"params"`
+	Status          string          `json:"status"`
+	ActorUserID     string          `json:"actor_user_id,omitempty"`
+	Error           string          `json:"error,omitempty"`
+	CancelRequested bool            `json:"cancel_requested"`
+	Attempts        int             `json:"attempts"`
+	CreatedAt       time.Time       `json:"created_at"`
+	StartedAt       *time.Time      `json:"started_at,omitempty"`
+	FinishedAt      *time.Time      `json:"finished_at,omitempty"`
+}
+
+var (
+	moduleJobsOnce sync.Once
+	moduleJobWake  = make(chan struct{}, 1)
+	// runningJobs maps a job ID to the cancel func of its context.
+	runningJobs sync.Map
+	// jobContexts maps a module ID to the context of its running job, so
+	// runAndLog can interrupt the commands it starts.
+	jobContexts sync.Map
+)
+
+// moduleJobContext returns the context of the job running for a module, or
+// the background context outside of jobs.
+func moduleJobContext(moduleID string) context.Context {
+	if v, ok := jobContexts.Load(moduleID); ok {
+		return v.(context.Context)
+	}
+	return context.Background()
+}
+
+func dbJobToJob(j database.ModuleJob) ModuleJob {
+	out := ModuleJob{
+		ID:              j.ID,
+		ModuleID:        j.ModuleID,
+		Kind:            j.Kind,
+		Status:          j.Status,
+		ActorUserID:     j.ActorUserID.String,
+		Error:           j.Error,
+		CancelRequested: j.CancelRequested,
+		Attempts:        j.Attempts,
+		CreatedAt:       j.CreatedAt,
+	}
+	_ = json.Unmarshal(j.Params, &out.Params)
+	if j.StartedAt.Valid {
+		t := j.StartedAt.Time
+		out.StartedAt = &t
+	}
+	if j.FinishedAt.Valid {
+		t := j.FinishedAt.Time
+		out.FinishedAt = &t
+	}
+	return out
+}
+
+func validModuleJobKind(kind string) bool {
+	switch kind {
+	case ModuleJobDeploy, ModuleJobRebuild, ModuleJobDown, ModuleJobPull, ModuleJobClone, ModuleJobRollback:
+		return true
+	}
+	return false
+}
+
+// EnqueueModuleJob queues an operation on a module. It runs once every job
+// queued before it for the same module is finished.
+func EnqueueModuleJob(ctx context.Context, module Module, kind string, params ModuleJobParams, actor *User) (ModuleJob, error) {
+	if !validModuleJobKind(kind) {
+		return ModuleJob{}, fmt.Errorf("%w: unknown job kind %q", ErrInvalidInput, kind)
+	}
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return ModuleJob{}, err
+	}
+	row := database.ModuleJob{ModuleID: module.ID, Kind: kind, Params: raw}
+	if actor != nil && actor.ID != "" {
+		row.ActorUserID = sql.NullString{String: actor.ID, Valid: true}
+	}
+	inserted, err := database.InsertModuleJob(ctx, row)
+	if err != nil {
+		return ModuleJob{}, err
+	}
+	job := dbJobToJob(inserted)
+	broadcastModuleJob(job)
+	select {
+	case moduleJobWake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// RunModuleJob queues a job and waits for it to finish. The job keeps going
+// if ctx ends first.
+func RunModuleJob(ctx context.Context, module Module, kind string, params ModuleJobParams, actor *User) (ModuleJob, error) {
+	job, err := EnqueueModuleJob(ctx, module, kind, params, actor)
+	if err != nil {
+		return ModuleJob{}, err
+	}
+	return WaitModuleJob(ctx, module.ID, job.ID)
+}
+
+// WaitModuleJob polls a job until it is finished. A failed job is returned
+// with its error, a cancelled one with ErrJobCancelled.
+func WaitModuleJob(ctx context.Context, moduleID, jobID string) (ModuleJob, error) {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		job, err := GetModuleJob(ctx, moduleID, jobID)
+		if err != nil {
+			return ModuleJob{}, err
+		}
+		switch job.Status {
+		case JobSucceeded:
+			return job, nil
+		case JobFailed:
+			return job, errors.New(job.Error)
+		case JobCancelled:
+			return job, ErrJobCancelled
+		}
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func GetModuleJob(ctx context.Context, moduleID, jobID string) (ModuleJob, error) {
+	j, err := database.GetModuleJob(ctx, moduleID, jobID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ModuleJob{}, ErrNotFound
+		}
+		return ModuleJob{}, err
+	}
+	return dbJobToJob(j), nil
+}
+
+func ListModuleJobs(ctx context.Context, moduleID string, statuses []string, limit int) ([]ModuleJob, error) {
+	rows, err := database.ListModuleJobs(ctx, moduleID, statuses, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ModuleJob, 0, len(rows))
+	for _, j := range rows {
+		out = append(out, dbJobToJob(j))
+	}
+	return out, nil
+}
+
+// CancelModuleJob drops a queued job, or interrupts a running one: its current
+// command gets SIGINT, then SIGKILL after a grace period.
+func CancelModuleJob(ctx context.Context, moduleID, jobID string) (ModuleJob, error) {
+	row, ok, err := database.CancelModuleJob(ctx, moduleID, jobID)
+	if err != nil {
+		return ModuleJob{}, err
+	}
+	if !ok {
+		job, err := GetModuleJob(ctx, moduleID, jobID)
+		if err != nil {
+			return ModuleJob{}, err
+		}
+		return job, fmt.Errorf("%w: job is already %s", ErrConflict, job.Status)
+	}
+	job := dbJobToJob(row)
+	if cancel, ok := runningJobs.Load(jobID); ok {
+		cancel.(context.CancelFunc)()
+	}
+	if job.Status == JobCancelled {
+		broadcastModuleJob(job)
+	}
+	LogModule(moduleID, "WARN", fmt.Sprintf("Cancellation requested for %s job %s", job.Kind, job.ID), nil, nil)
+	return job, nil
+}
+
+func broadcastModuleJob(job ModuleJob) {
+	websocket.SendGenericModuleEvent(job.ModuleID, "module_job", map[string]any{
+		"id":         job.ID,
+		"module_id":  job.ModuleID,
+		"kind":       job.Kind,
+		"status":     job.Status,
+		"error":      job.Error,
+		"created_at": job.CreatedAt,
+	})
+}
+
+// StartModuleJobWorkers recovers the jobs interrupted by the previous run and
+// starts MODULE_JOB_WORKERS workers (default 4) polling the queue every
+// MODULE_JOB_POLL (default 2s).
+func StartModuleJobWorkers() {
+	workers := envInt("MODULE_JOB_WORKERS", 4)
+	if workers < 1 {
+		workers = 1
+	}
+	poll := envDuration("MODULE_JOB_POLL", 2*time.Second)
+	if poll <= 0 {
+		poll = 2 * time.Second
+	}
+	moduleJobsOnce.Do(func() {
+		recoverModuleJobs(context.Background())
+		host, _ := os.Hostname()
+		for i := 0; i < workers; i++ {
+			go moduleJobWorker(fmt.Sprintf("%s:%d:%d", host, os.Getpid(), i), poll)
+		}
+		go watchCancelledJobs(poll)
+	})
+}
+
+// recoverModuleJobs assumes a single backend: whatever was running belongs to
+// a process that is gone.
+func recoverModuleJobs(ctx context.Context) {
+	requeued, failed, err := database.RecoverModuleJobs(ctx, moduleJobMaxAttempts)
+	if err != nil {
+		log.Printf("[jobs] recover interrupted jobs: %v", err)
+	} else if requeued+failed > 0 {
+		log.Printf("[jobs] recovered interrupted jobs: %d requeued, %d failed", requeued, failed)
+	}
+	if n, err := database.FailRunningModuleDeployments(ctx); err != nil {
+		log.Printf("[jobs] close interrupted deployments: %v", err)
+	} else if n > 0 {
+		log.Printf("[jobs] marked %d interrupted deployment(s) as failed", n)
+	}
+	if n, err := database.ResetStaleDeployingFlags(ctx); err != nil {
+		log.Printf("[jobs] reset deploying flags: %v", err)
+	} else if n > 0 {
+		log.Printf("[jobs] reset is_deploying on %d module(s)", n)
+	}
+}
+
+func moduleJobWorker(workerID string, poll time.Duration) {
+	for {
+		row, ok, err := database.ClaimNextModuleJob(context.Background(), workerID)
+		if err != nil {
+			log.Printf("[jobs] %s: claim: %v", workerID, err)
+		}
+		if err != nil || !ok {
+			select {
+			case <-moduleJobWake:
+			case <-time.After(poll):
+			}
+			continue
+		}
+		runClaimedModuleJob(dbJobToJob(row))
+	}
+}
+
+// watchCancelledJobs picks up cancellations recorded by another process.
+func watchCancelledJobs(poll time.Duration) {
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for range ticker.C {
+		ids, err := database.ListCancelRequestedModuleJobs(context.Background())
+		if err != nil {
+			continue
+		}
+		for _, id := range ids {
+			if cancel, ok := runningJobs.Load(id); ok {
+				cancel.(context.CancelFunc)()
+			}
+		}
+	}
+}
+
+func runClaimedModuleJob(job ModuleJob) {
+	ctx, cancel := context.WithCancel(context.Background())
+	runningJobs.Store(job.ID, cancel)
+	jobContexts.Store(job.ModuleID, ctx)
+	broadcastModuleJob(job)
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("job panicked: %v", r)
+			}
+		}()
+		return executeModuleJob(ctx, job)
+	}()
+
+	jobContexts.Delete(job.ModuleID)
+	runningJobs.Delete(job.ID)
+	cancelled := ctx.Err() != nil
+	cancel()
+
+	status, msg := JobSucceeded, ""
+	switch {
+	case cancelled:
+		status = JobCancelled
+		LogModule(job.ModuleID, "WARN", fmt.Sprintf("%s job %s cancelled", job.Kind, job.ID), nil, nil)
+	case err != nil:
+		status, msg = JobFailed, err.Error()
+	}
+	row, ferr := database.FinishModuleJob(context.Background(), job.ID, status, msg)
+	if ferr != nil {
+		log.Printf("[jobs] finish %s: %v", job.ID, ferr)
+		return
+	}
+	broadcastModuleJob(dbJobToJob(row))
+}
+
+func executeModuleJob(ctx context.Context, job ModuleJob) error {
+	module, err := GetModule(job.ModuleID)
+	if err != nil {
+		return err
+	}
+	opts := DeployOptions{Source: job.Params.Source}
+	if job.ActorUserID != "" {
+		opts.Actor = &User{ID: job.ActorUserID}
+	}
+
+	switch job.Kind {
+	case ModuleJobDeploy:
+		return DeployModule(module, opts)
+	case ModuleJobRebuild:
+		return ComposeRebuild(module, opts)
+	case ModuleJobDown:
+		return ComposeDown(module)
+	case ModuleJobClone:
+		if job.Params.TemplateID != "" {
+			return InitModuleRepoFromTemplate(module, job.Params.TemplateID, job.Params.TemplatePort, job.Params.Push)
+		}
+		return CloneModuleRepo(module)
+	case ModuleJobPull:
+		if err := PullModuleRepo(module); err != nil {
+			return err
+		}
+		if !job.Params.Deploy || ctx.Err() != nil {
+			return nil
+		}
+		// A merge with local commits can still leave conflicts behind
+		if reason := gitTreeNotClean(module); reason != "" {
+			LogModule(module.ID, "WARN", "Deploy skipped after pull: "+reason, nil, nil)
+			return fmt.Errorf("%w: deploy skipped after pull, %s", ErrConflict, reason)
+		}
+		LogModule(module.ID, "INFO", "Deploying after pull", nil, nil)
+		return DeployModule(module, opts)
+	case ModuleJobRollback:
+		return runRollback(ctx, module, job.Params.DeploymentID, opts.Actor)
+	}
+	return fmt.Errorf("unknown job kind %q", job.Kind)
+}
+
+// interruptOnCancel sends SIGINT to cmd when ctx is cancelled, then kills it
+// if it is still running after jobKillGrace. The returned func must be called
+// once the command has exited.
+func interruptOnCancel(ctx context.Context, cmd *exec.Cmd) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+		}
+		_ = cmd.Process.Signal(os.Interrupt)
+		select {
+		case <-done:
+		case <-time.After(jobKillGrace):
+			_ = cmd.Process.Kill()
+		}
+	}()
+	return func() { close(done) }
+}
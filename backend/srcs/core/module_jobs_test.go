@@ -0,0 +1,48 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestRunAndLogStopsOnJobCancel(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep not available")
+	}
+	const moduleID = "module_jobs_test"
+	ctx, cancel := context.WithCancel(context.Background())
+	jobContexts.Store(moduleID, ctx)
+	defer jobContexts.Delete(moduleID)
+
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	if err := runAndLog(moduleID, exec.Command("sleep", "30")); err == nil {
+		t.Fatal("expected the interrupted command to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("command ran %v after cancel", elapsed)
+	}
+
+	// once cancelled, later steps of the job do not start
+	cmd := exec.Command("sleep", "30")
+	if err := runAndLog(moduleID, cmd); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if cmd.Process != nil {
+		t.Error("command started after cancel")
+	}
+}
+
+func TestValidModuleJobKind(t *testing.T) {
+	for _, kind := range []string{ModuleJobDeploy, ModuleJobRebuild, ModuleJobDown, ModuleJobPull, ModuleJobClone, ModuleJobRollback} {
+		if !validModuleJobKind(kind) {
+			t.Errorf("%q should be valid", kind)
+		}
+	}
+	if validModuleJobKind("restart") {
+		t.Error("unknown kind accepted")
+	}
+}
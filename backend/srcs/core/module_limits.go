@@ -0,0 +1,470 @@
+package core
+
+import (
+	"backend/database"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dockercontainer "github.com/docker/docker/api/types/container"
+	dockerfilters "github.com/docker/docker/api/types/filters"
+)
+
+// limitWarnRatio is the share of a limit above which usage is reported as a
+// warning.
+const limitWarnRatio = 0.9
+
+// ModuleResourceLimits caps each container of a module, so a module with N
+// services can use up to N times each limit. A nil field is not limited.
+// Limits are applied at the next deploy or rebuild, cpus and memory to its
+// image builds as well.
+type ModuleResourceLimits struct {
+	ModuleID  string     `json:"module_id"`
+	CPUs      *float64   `json:"cpus"`
+	MemoryMB  *int       `json:"memory_mb"`
+	Pids      *int       `json:"pids"`
+	DiskMB    *int       `json:"disk_mb"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// ContainerResourceUsage is a point-in-time reading of one container.
+type ContainerResourceUsage struct {
+	Name          string  `json:"name"`
+	Service       string  `json:"service"`
+	State         string  `json:"state"`
+	CPUPercent    float64 `json:"cpu_percent"`
+	MemoryBytes   uint64  `json:"memory_bytes"`
+	MemoryLimit   uint64  `json:"memory_limit_bytes"`
+	MemoryPercent float64 `json:"memory_percent"`
+	Pids          uint64  `json:"pids"`
+	DiskBytes     int64   `json:"disk_bytes"`
+	StatsError    string  `json:"stats_error,omitempty"`
+}
+
+// ModuleResourceUsage reports the usage of the running containers of a
+// module against its limits. CPU is in percent of one core.
+type ModuleResourceUsage struct {
+	ModuleID         string                   `json:"module_id"`
+	Project          string                   `json:"project"`
+	Limits           ModuleResourceLimits     `json:"limits"`
+	Containers       []ContainerResourceUsage `json:"containers"`
+	TotalCPUPercent  float64                  `json:"total_cpu_percent"`
+	TotalMemoryBytes uint64                   `json:"total_memory_bytes"`
+	TotalPids        uint64                   `json:"total_pids"`
+	TotalDiskBytes   int64                    `json:"total_disk_bytes"`
+	Warnings         []string                 `json:"warnings"`
+	CollectedAt      time.Time                `json:"collected_at"`
+}
+
+func (l ModuleResourceLimits) isSet() bool {
+	return l.CPUs != nil || l.MemoryMB != nil || l.Pids != nil || l.DiskMB != nil
+}
+
+// GetModuleResourceLimits returns the limits of a module, all unset when
+// none were configured.
+func GetModuleResourceLimits(ctx context.Context, moduleID string) (ModuleResourceLimits, error) {
+	l, err := database.GetModuleResourceLimits(ctx, moduleID)
+	if errors.Is(err, database.ErrNotFound) {
+		return ModuleResourceLimits{ModuleID: moduleID}, nil
+	}
+	if err != nil {
+		return ModuleResourceLimits{}, err
+	}
+	return dbLimitsToLimits(l), nil
+}
+
+// SetModuleResourceLimits validates and stores the limits of a module. Unset
+// fields remove the corresponding limit.
+func SetModuleResourceLimits(ctx context.Context, moduleID string, in ModuleResourceLimits) (ModuleResourceLimits, error) {
+	if err := validateModuleResourceLimits(in, storageOptEnabled()); err != nil {
+		return ModuleResourceLimits{}, err
+	}
+	row := database.ModuleResourceLimits{ModuleID: moduleID}
+	if in.CPUs != nil {
+		row.CPUs = sql.NullFloat64{Float64: *in.CPUs, Valid: true}
+	}
+	if in.MemoryMB != nil {
+		row.MemoryMB = sql.NullInt64{Int64: int64(*in.MemoryMB), Valid: true}
+	}
+	if in.Pids != nil {
+		row.Pids = sql.NullInt64{Int64: int64(*in.Pids), Valid: true}
+	}
+	if in.DiskMB != nil {
+		row.DiskMB = sql.NullInt64{Int64: int64(*in.DiskMB), Valid: true}
+	}
+	saved, err := database.UpsertModuleResourceLimits(ctx, row)
+	if err != nil {
+		return ModuleResourceLimits{}, err
+	}
+	out := dbLimitsToLimits(saved)
+	LogModule(moduleID, "INFO", "Resource limits per container updated, applied at next deploy", map[string]any{"limits": describeLimits(out)}, nil)
+	return out, nil
+}
+
+// storageOptEnabled reports whether disk limits can be enforced: storage_opt
+// needs a storage driver with quota support.
+func storageOptEnabled() bool {
+	return envBool("MODULE_LIMITS_STORAGE_OPT", false)
+}
+
+func validateModuleResourceLimits(l ModuleResourceLimits, storageOpt bool) error {
+	if l.CPUs != nil && (*l.CPUs <= 0 || *l.CPUs > 1024) {
+		return fmt.Errorf("%w: cpus must be between 0 and 1024", ErrInvalidInput)
+	}
+	// Docker refuses memory limits below 6MB.
+	if l.MemoryMB != nil && *l.MemoryMB < 6 {
+		return fmt.Errorf("%w: memory_mb must be at least 6", ErrInvalidInput)
+	}
+	if l.Pids != nil && *l.Pids <= 0 {
+		return fmt.Errorf("%w: pids must be positive", ErrInvalidInput)
+	}
+	if l.DiskMB != nil && *l.DiskMB <= 0 {
+		return fmt.Errorf("%w: disk_mb must be positive", ErrInvalidInput)
+	}
+	if l.DiskMB != nil && !storageOpt {
+		return fmt.Errorf("%w: disk_mb cannot be enforced, MODULE_LIMITS_STORAGE_OPT is off", ErrInvalidInput)
+	}
+	return nil
+}
+
+// enforceable drops the disk limit when it cannot be enforced, for limits
+// copied from elsewhere. It reports whether one was dropped.
+func (l ModuleResourceLimits) enforceable(storageOpt bool) (ModuleResourceLimits, bool) {
+	if l.DiskMB == nil || storageOpt {
+		return l, false
+	}
+	l.DiskMB = nil
+	return l, true
+}
+
+func dbLimitsToLimits(l database.ModuleResourceLimits) ModuleResourceLimits {
+	out := ModuleResourceLimits{ModuleID: l.ModuleID}
+	if l.CPUs.Valid {
+		v := l.CPUs.Float64
+		out.CPUs = &v
+	}
+	if l.MemoryMB.Valid {
+		v := int(l.MemoryMB.Int64)
+		out.MemoryMB = &v
+	}
+	if l.Pids.Valid {
+		v := int(l.Pids.Int64)
+		out.Pids = &v
+	}
+	if l.DiskMB.Valid {
+		v := int(l.DiskMB.Int64)
+		out.DiskMB = &v
+	}
+	if !l.UpdatedAt.IsZero() {
+		t := l.UpdatedAt
+		out.UpdatedAt = &t
+	}
+	return out
+}
+
+func describeLimits(l ModuleResourceLimits) string {
+	var parts []string
+	if l.CPUs != nil {
+		parts = append(parts, "cpus="+strconv.FormatFloat(*l.CPUs, 'f', -1, 64))
+	}
+	if l.MemoryMB != nil {
+		parts = append(parts, fmt.Sprintf("memory=%dMB", *l.MemoryMB))
+	}
+	if l.Pids != nil {
+		parts = append(parts, fmt.Sprintf("pids=%d", *l.Pids))
+	}
+	if l.DiskMB != nil {
+		parts = append(parts, fmt.Sprintf("disk=%dMB", *l.DiskMB))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, " ")
+}
+
+// resourceLimitsOverride returns the compose services override applying
+// limits to every service. Both the legacy keys and deploy.resources.limits
+// are set so they cannot disagree with what the module file declares. The
+// disk limit needs a storage driver supporting storage_opt size (overlay2 on
+// xfs with pquota, btrfs, zfs), so it is only emitted when storageOpt is set.
+func resourceLimitsOverride(l ModuleResourceLimits, services []string, storageOpt bool) map[string]any {
+	spec := map[string]any{}
+	resources := map[string]any{}
+	if l.CPUs != nil {
+		cpus := strconv.FormatFloat(*l.CPUs, 'f', -1, 64)
+		spec["cpus"] = *l.CPUs
+		resources["cpus"] = cpus
+	}
+	if l.MemoryMB != nil {
+		mem := fmt.Sprintf("%dm", *l.MemoryMB)
+		spec["mem_limit"] = mem
+		spec["memswap_limit"] = mem
+		resources["memory"] = mem
+	}
+	if l.Pids != nil {
+		spec["pids_limit"] = *l.Pids
+		resources["pids"] = *l.Pids
+	}
+	if l.DiskMB != nil && storageOpt {
+		spec["storage_opt"] = map[string]any{"size": fmt.Sprintf("%dm", *l.DiskMB)}
+	}
+	if len(spec) == 0 {
+		return nil
+	}
+	if len(resources) > 0 {
+		spec["deploy"] = map[string]any{"resources": map[string]any{"limits": resources}}
+	}
+	out := make(map[string]any, len(services))
+	for _, name := range services {
+		out[name] = spec
+	}
+	return out
+}
+
+// moduleBuilderPrefix starts the name of the buildx builders running the
+// image builds of a module under its limits.
+func moduleBuilderPrefix(slug string) string {
+	return "pan-bagnat-build-" + slug + "-"
+}
+
+// isModuleBuilder tells the builders of slug apart from those of a module
+// whose slug only starts with it: the name ends with an 8 digit hash.
+func isModuleBuilder(name, slug string) bool {
+	rest, ok := strings.CutPrefix(name, moduleBuilderPrefix(slug))
+	if !ok || len(rest) != 8 {
+		return false
+	}
+	_, err := hex.DecodeString(rest)
+	return err == nil
+}
+
+// builderDriverOpts maps the cpus and memory limits to options of the
+// docker-container buildx driver. pids and disk have no builder equivalent.
+func builderDriverOpts(l ModuleResourceLimits) []string {
+	var opts []string
+	if l.CPUs != nil {
+		opts = append(opts, "cpu-period=100000", fmt.Sprintf("cpu-quota=%d", int64(*l.CPUs*100000)))
+	}
+	if l.MemoryMB != nil {
+		mem := fmt.Sprintf("%dm", *l.MemoryMB)
+		opts = append(opts, "memory="+mem, "memory-swap="+mem)
+	}
+	return opts
+}
+
+// moduleBuilder returns the buildx builder capping the image builds of a
+// module to its cpus and memory limits, creating it when the limits changed.
+// Builders made for other limits are removed. "" means the default builder.
+func moduleBuilder(module Module) (string, error) {
+	limits, err := GetModuleResourceLimits(context.Background(), module.ID)
+	if err != nil {
+		return "", fmt.Errorf("load resource limits: %w", err)
+	}
+	opts := builderDriverOpts(limits)
+	name := ""
+	if len(opts) > 0 {
+		sum := sha1.Sum([]byte(strings.Join(opts, ",")))
+		name = moduleBuilderPrefix(module.Slug) + hex.EncodeToString(sum[:])[:8]
+	}
+	found, err := pruneModuleBuilders(module, name)
+	if err != nil {
+		return "", err
+	}
+	if name == "" || found {
+		return name, nil
+	}
+	args := []string{"buildx", "create", "--name", name, "--driver", "docker-container"}
+	for _, o := range opts {
+		args = append(args, "--driver-opt", o)
+	}
+	if err := runAndLog(module.ID, exec.Command("docker", args...)); err != nil {
+		return "", fmt.Errorf("create builder %s: %w", name, err)
+	}
+	return name, nil
+}
+
+// pruneModuleBuilders removes the builders of module other than keep and
+// reports whether keep exists.
+func pruneModuleBuilders(module Module, keep string) (bool, error) {
+	var out bytes.Buffer
+	cmd := exec.Command("docker", "buildx", "ls", "--format", "{{.Name}}")
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return false, fmt.Errorf("list builders: %w", err)
+	}
+	found := false
+	for _, name := range splitLines(out.String()) {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == keep && keep != "":
+			found = true
+		case isModuleBuilder(name, module.Slug):
+			_ = runAndLog(module.ID, exec.Command("docker", "buildx", "rm", name))
+		}
+	}
+	return found, nil
+}
+
+// composeBuildCmd builds the images of project, under the module limits.
+func composeBuildCmd(module Module, dir, file, project string, extra ...string) (*exec.Cmd, error) {
+	builder, err := moduleBuilder(module)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"compose", "-f", file, "--project-name", project, "build"}
+	if builder != "" {
+		args = append(args, "--builder", builder)
+	}
+	cmd := exec.Command("docker", append(args, extra...)...)
+	cmd.Dir = dir
+	return cmd, nil
+}
+
+// GetModuleResourceUsage reads the current usage of the module containers.
+// Stopped containers are listed with their disk usage only.
+func GetModuleResourceUsage(ctx context.Context, module Module) (ModuleResourceUsage, error) {
+	limits, err := GetModuleResourceLimits(ctx, module.ID)
+	if err != nil {
+		return ModuleResourceUsage{}, err
+	}
+	cli, err := getDockerClient()
+	if err != nil {
+		return ModuleResourceUsage{}, err
+	}
+	project := composeProject(module)
+	filter := dockerfilters.NewArgs()
+	filter.Add("label", "com.docker.compose.project="+project)
+	items, err := cli.ContainerList(ctx, dockercontainer.ListOptions{All: true, Size: true, Filters: filter})
+	if err != nil {
+		return ModuleResourceUsage{}, fmt.Errorf("list containers failed: %w", err)
+	}
+
+	containers := make([]ContainerResourceUsage, len(items))
+	var wg sync.WaitGroup
+	for i, item := range items {
+		c := &containers[i]
+		c.Name = firstDockerName(item.Names)
+		if c.Name == "" {
+			c.Name = item.ID
+		}
+		c.Service = item.Labels["com.docker.compose.service"]
+		c.State = item.State
+		c.DiskBytes = item.SizeRw
+		if item.State != "running" {
+			continue
+		}
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			stats, err := containerStats(ctx, id)
+			if err != nil {
+				c.StatsError = err.Error()
+				return
+			}
+			c.CPUPercent = cpuPercent(stats)
+			c.MemoryBytes = memoryUsage(stats)
+			c.MemoryLimit = stats.MemoryStats.Limit
+			if c.MemoryLimit > 0 {
+				c.MemoryPercent = float64(c.MemoryBytes) / float64(c.MemoryLimit) * 100
+			}
+			c.Pids = stats.PidsStats.Current
+		}(item.ID)
+	}
+	wg.Wait()
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Name < containers[j].Name })
+
+	out := ModuleResourceUsage{
+		ModuleID:    module.ID,
+		Project:     project,
+		Limits:      limits,
+		Containers:  containers,
+		Warnings:    []string{},
+		CollectedAt: time.Now().UTC(),
+	}
+	for _, c := range containers {
+		out.TotalCPUPercent += c.CPUPercent
+		out.TotalMemoryBytes += c.MemoryBytes
+		out.TotalPids += c.Pids
+		out.TotalDiskBytes += c.DiskBytes
+		out.Warnings = append(out.Warnings, limitWarnings(c, limits)...)
+	}
+	return out, nil
+}
+
+func containerStats(ctx context.Context, id string) (dockercontainer.StatsResponse, error) {
+	cli, err := getDockerClient()
+	if err != nil {
+		return dockercontainer.StatsResponse{}, err
+	}
+	resp, err := cli.ContainerStats(ctx, id, false)
+	if err != nil {
+		return dockercontainer.StatsResponse{}, err
+	}
+	defer resp.Body.Close()
+	var stats dockercontainer.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return dockercontainer.StatsResponse{}, err
+	}
+	return stats, nil
+}
+
+// cpuPercent computes the CPU usage the way `docker stats` does, in percent
+// of one core.
+func cpuPercent(s dockercontainer.StatsResponse) float64 {
+	cpuDelta := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(s.CPUStats.SystemUsage) - float64(s.PreCPUStats.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+	cpus := float64(s.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(s.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpus == 0 {
+		cpus = 1
+	}
+	return cpuDelta / systemDelta * cpus * 100
+}
+
+// memoryUsage excludes the page cache, as `docker stats` does: inactive_file
+// on cgroup v2, total_inactive_file on v1.
+func memoryUsage(s dockercontainer.StatsResponse) uint64 {
+	usage := s.MemoryStats.Usage
+	cache, ok := s.MemoryStats.Stats["inactive_file"]
+	if !ok {
+		cache = s.MemoryStats.Stats["total_inactive_file"]
+	}
+	if cache < usage {
+		return usage - cache
+	}
+	return usage
+}
+
+// limitWarnings flags a container using at least limitWarnRatio of a limit.
+func limitWarnings(c ContainerResourceUsage, l ModuleResourceLimits) []string {
+	var out []string
+	if l.CPUs != nil && c.CPUPercent >= *l.CPUs*100*limitWarnRatio {
+		out = append(out, fmt.Sprintf("%s: CPU at %.0f%% of a core, limit is %s", c.Name, c.CPUPercent, strconv.FormatFloat(*l.CPUs, 'f', -1, 64)))
+	}
+	if l.MemoryMB != nil && float64(c.MemoryBytes) >= float64(*l.MemoryMB)*1024*1024*limitWarnRatio {
+		out = append(out, fmt.Sprintf("%s: memory at %dMB, limit is %dMB", c.Name, c.MemoryBytes/(1024*1024), *l.MemoryMB))
+	}
+	if l.Pids != nil && float64(c.Pids) >= float64(*l.Pids)*limitWarnRatio {
+		out = append(out, fmt.Sprintf("%s: %d processes, limit is %d", c.Name, c.Pids, *l.Pids))
+	}
+	if l.DiskMB != nil && float64(c.DiskBytes) >= float64(*l.DiskMB)*1024*1024*limitWarnRatio {
+		out = append(out, fmt.Sprintf("%s: writable layer at %dMB, limit is %dMB", c.Name, c.DiskBytes/(1024*1024), *l.DiskMB))
+	}
+	return out
+}
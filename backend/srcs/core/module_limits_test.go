@@ -0,0 +1,141 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	dockercontainer "github.com/docker/docker/api/types/container"
+)
+
+func TestResourceLimitsOverride(t *testing.T) {
+	cpus, mem, pids, disk := 0.5, 256, 100, 1024
+	limits := ModuleResourceLimits{CPUs: &cpus, MemoryMB: &mem, Pids: &pids, DiskMB: &disk}
+
+	out := resourceLimitsOverride(limits, []string{"web", "db"}, false)
+	if len(out) != 2 {
+		t.Fatalf("got %d services, want 2", len(out))
+	}
+	spec := out["web"].(map[string]any)
+	if spec["cpus"] != 0.5 || spec["mem_limit"] != "256m" || spec["memswap_limit"] != "256m" || spec["pids_limit"] != 100 {
+		t.Errorf("unexpected spec: %v", spec)
+	}
+	if _, ok := spec["storage_opt"]; ok {
+		t.Error("storage_opt emitted without storage driver support")
+	}
+	res := spec["deploy"].(map[string]any)["resources"].(map[string]any)["limits"].(map[string]any)
+	if res["cpus"] != "0.5" || res["memory"] != "256m" || res["pids"] != 100 {
+		t.Errorf("unexpected deploy limits: %v", res)
+	}
+
+	spec = resourceLimitsOverride(limits, []string{"web"}, true)["web"].(map[string]any)
+	if opt, _ := spec["storage_opt"].(map[string]any); opt["size"] != "1024m" {
+		t.Errorf("storage_opt = %v, want size 1024m", spec["storage_opt"])
+	}
+
+	if out := resourceLimitsOverride(ModuleResourceLimits{}, []string{"web"}, true); out != nil {
+		t.Errorf("no limits: got %v, want nil", out)
+	}
+	if out := resourceLimitsOverride(ModuleResourceLimits{DiskMB: &disk}, []string{"web"}, false); out != nil {
+		t.Errorf("disk only without storage_opt: got %v, want nil", out)
+	}
+}
+
+func TestValidateModuleResourceLimits(t *testing.T) {
+	zero, small, ok := 0.0, 4, 64
+	if err := validateModuleResourceLimits(ModuleResourceLimits{}, false); err != nil {
+		t.Errorf("empty limits: %v", err)
+	}
+	if err := validateModuleResourceLimits(ModuleResourceLimits{MemoryMB: &ok}, false); err != nil {
+		t.Errorf("valid memory: %v", err)
+	}
+	if err := validateModuleResourceLimits(ModuleResourceLimits{CPUs: &zero}, false); err == nil {
+		t.Error("zero cpus accepted")
+	}
+	if err := validateModuleResourceLimits(ModuleResourceLimits{MemoryMB: &small}, false); err == nil {
+		t.Error("memory below docker minimum accepted")
+	}
+	disk := 1024
+	if err := validateModuleResourceLimits(ModuleResourceLimits{DiskMB: &disk}, false); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("disk without storage_opt: error = %v, want ErrInvalidInput", err)
+	}
+	if err := validateModuleResourceLimits(ModuleResourceLimits{DiskMB: &disk}, true); err != nil {
+		t.Errorf("disk with storage_opt: %v", err)
+	}
+}
+
+func TestResourceLimitsEnforceable(t *testing.T) {
+	mem, disk := 256, 1024
+	l := ModuleResourceLimits{MemoryMB: &mem, DiskMB: &disk}
+	if got, dropped := l.enforceable(false); !dropped || got.DiskMB != nil || got.MemoryMB != &mem {
+		t.Errorf("without storage_opt: %+v, dropped %v", got, dropped)
+	}
+	if got, dropped := l.enforceable(true); dropped || got.DiskMB != &disk {
+		t.Errorf("with storage_opt: %+v, dropped %v", got, dropped)
+	}
+}
+
+func TestBuilderDriverOpts(t *testing.T) {
+	cpus, mem, pids := 1.5, 512, 100
+	got := builderDriverOpts(ModuleResourceLimits{CPUs: &cpus, MemoryMB: &mem, Pids: &pids})
+	want := []string{"cpu-period=100000", "cpu-quota=150000", "memory=512m", "memory-swap=512m"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := builderDriverOpts(ModuleResourceLimits{Pids: &pids}); len(got) != 0 {
+		t.Errorf("pids only: got %v, want the default builder", got)
+	}
+}
+
+func TestIsModuleBuilder(t *testing.T) {
+	cases := []struct {
+		name string
+		want bool
+	}{
+		{"pan-bagnat-build-wiki-0a1b2c3d", true},
+		{"pan-bagnat-build-wiki-home-0a1b2c3d", false},
+		{"pan-bagnat-build-wiki-0a1b2c3d0", false},
+		{"pan-bagnat-build-wiki-notahash", false},
+		{"default", false},
+	}
+	for _, c := range cases {
+		if got := isModuleBuilder(c.name, "wiki"); got != c.want {
+			t.Errorf("isModuleBuilder(%q) = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCPUPercentAndMemoryUsage(t *testing.T) {
+	var s dockercontainer.StatsResponse
+	s.PreCPUStats.CPUUsage.TotalUsage = 1000
+	s.CPUStats.CPUUsage.TotalUsage = 1500
+	s.PreCPUStats.SystemUsage = 10000
+	s.CPUStats.SystemUsage = 12000
+	s.CPUStats.OnlineCPUs = 4
+	if got := cpuPercent(s); got != 100 {
+		t.Errorf("cpuPercent = %v, want 100", got)
+	}
+
+	s.MemoryStats.Usage = 300
+	s.MemoryStats.Stats = map[string]uint64{"inactive_file": 100}
+	if got := memoryUsage(s); got != 200 {
+		t.Errorf("memoryUsage v2 = %d, want 200", got)
+	}
+	s.MemoryStats.Stats = map[string]uint64{"total_inactive_file": 50}
+	if got := memoryUsage(s); got != 250 {
+		t.Errorf("memoryUsage v1 = %d, want 250", got)
+	}
+}
+
+func TestLimitWarnings(t *testing.T) {
+	mem, pids := 100, 10
+	limits := ModuleResourceLimits{MemoryMB: &mem, Pids: &pids}
+	c := ContainerResourceUsage{Name: "web", MemoryBytes: 95 * 1024 * 1024, Pids: 3}
+	if got := limitWarnings(c, limits); len(got) != 1 {
+		t.Errorf("got %v, want one memory warning", got)
+	}
+	c.MemoryBytes = 10 * 1024 * 1024
+	if got := limitWarnings(c, limits); len(got) != 0 {
+		t.Errorf("got %v, want none", got)
+	}
+}
@@ -0,0 +1,499 @@
+package core
+
+import (
+	"backend/database"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"go.yaml.in/yaml/v3"
+)
+
+// ModuleManifestFile is read from the root of a module repository.
+const ModuleManifestFile = "pan-bagnat.yml"
+
+var manifestEnvNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ModuleManifest describes how Pan Bagnat should expose a module. Modules ship
+// it in their repo so pages, icon and OIDC settings don't have to be set by hand.
+type ModuleManifest struct {
+	Version int                    `yaml:"version" json:"version"`
+	Icon    string                 `yaml:"icon" json:"icon,omitempty"`
+	Pages   []ModuleManifestPage   `yaml:"pages" json:"pages"`
+	OIDC    *ModuleManifestOIDC    `yaml:"oidc" json:"oidc,omitempty"`
+	Env     []ModuleManifestEnvVar `yaml:"env" json:"env"`
+}
+
+type ModuleManifestPage struct {
+	Name       string `yaml:"name" json:"name"`
+	Slug       string `yaml:"slug" json:"slug"`
+	Container  string `yaml:"container" json:"container,omitempty"`
+	Port       int    `yaml:"port" json:"port,omitempty"`
+	Network    string `yaml:"network" json:"network,omitempty"`
+	IframeOnly bool   `yaml:"iframe_only" json:"iframe_only"`
+	PageOnly   bool   `yaml:"page_only" json:"page_only"`
+	NeedAuth   *bool  `yaml:"need_auth" json:"need_auth"`
+	Visible    *bool  `yaml:"visible" json:"visible"`
+}
+
+type ModuleManifestOIDC struct {
+	RedirectURIs []string `yaml:"redirect_uris" json:"redirect_uris"`
+	Scopes       []string `yaml:"scopes" json:"scopes"`
+}
+
+type ModuleManifestEnvVar struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description,omitempty"`
+	Required    bool   `yaml:"required" json:"required"`
+}
+
+type ModuleManifestFieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+type ModuleManifestPageUpdate struct {
+	PageID  string                      `json:"page_id"`
+	Slug    string                      `json:"slug"`
+	Changes []ModuleManifestFieldChange `json:"changes"`
+}
+
+// ModuleManifestDiff lists what applying the manifest would change. Pages that
+// exist in DB but not in the manifest are reported as unmanaged and left alone.
+type ModuleManifestDiff struct {
+	ManifestHash   string                      `json:"manifest_hash"`
+	Manifest       ModuleManifest              `json:"manifest"`
+	CreatePages    []ModuleManifestPage        `json:"create_pages"`
+	UpdatePages    []ModuleManifestPageUpdate  `json:"update_pages"`
+	UnmanagedPages []string                    `json:"unmanaged_pages"`
+	Icon           *ModuleManifestFieldChange  `json:"icon,omitempty"`
+	OIDC           []ModuleManifestFieldChange `json:"oidc"`
+	MissingEnv     []string                    `json:"missing_env"`
+	Conflicts      []string                    `json:"conflicts"`
+	HasChanges     bool                        `json:"has_changes"`
+}
+
+// ParseModuleManifest decodes and validates a manifest, filling defaults.
+func ParseModuleManifest(data []byte) (ModuleManifest, error) {
+	var m ModuleManifest
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&m); err != nil {
+		return ModuleManifest{}, fmt.Errorf("%w: %s: %s", ErrInvalidInput, ModuleManifestFile, err.Error())
+	}
+	if err := validateModuleManifest(&m); err != nil {
+		return ModuleManifest{}, fmt.Errorf("%w: %s: %s", ErrInvalidInput, ModuleManifestFile, err.Error())
+	}
+	return m, nil
+}
+
+func validateModuleManifest(m *ModuleManifest) error {
+	if m.Version == 0 {
+		m.Version = 1
+	}
+	if m.Version != 1 {
+		return fmt.Errorf("unsupported version %d", m.Version)
+	}
+
+	if m.Icon != "" {
+		clean, err := sanitizeRelPath(m.Icon)
+		if err != nil || clean == "." {
+			return fmt.Errorf("icon: invalid path %q", m.Icon)
+		}
+		m.Icon = clean
+	}
+
+	seen := map[string]bool{}
+	for i := range m.Pages {
+		p := &m.Pages[i]
+		p.Name = strings.TrimSpace(p.Name)
+		if p.Name == "" {
+			return fmt.Errorf("pages[%d]: name is required", i)
+		}
+		if p.Slug == "" {
+			p.Slug = p.Name
+		}
+		p.Slug = normalizePageSlug(p.Slug)
+		if p.Slug == "" {
+			return fmt.Errorf("pages[%d]: invalid slug", i)
+		}
+		if seen[p.Slug] {
+			return fmt.Errorf("pages[%d]: duplicate slug %q", i, p.Slug)
+		}
+		seen[p.Slug] = true
+
+		p.Container = strings.TrimSpace(p.Container)
+		p.Network = strings.TrimSpace(p.Network)
+		if (p.Container == "") != (p.Port == 0) {
+			return fmt.Errorf("pages[%d]: container and port must be defined together", i)
+		}
+		if p.Port < 0 || p.Port > 65535 {
+			return fmt.Errorf("pages[%d]: port must be between 1 and 65535", i)
+		}
+		if err := validatePageMode(p.IframeOnly, p.PageOnly); err != nil {
+			return fmt.Errorf("pages[%d]: %w", i, err)
+		}
+		if p.NeedAuth == nil {
+			p.NeedAuth = ptrBool(true)
+		}
+		if p.Visible == nil {
+			p.Visible = ptrBool(true)
+		}
+	}
+
+	if m.OIDC != nil {
+		m.OIDC.RedirectURIs = normalizeStringList(m.OIDC.RedirectURIs)
+		for _, raw := range m.OIDC.RedirectURIs {
+			u, err := url.Parse(raw)
+			if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.Fragment != "" {
+				return fmt.Errorf("oidc: invalid redirect URI %q", raw)
+			}
+		}
+		m.OIDC.Scopes = normalizeScopeValues(m.OIDC.Scopes)
+		supported := strings.Fields(defaultOIDCScopes)
+		for _, s := range m.OIDC.Scopes {
+			if !slices.Contains(supported, s) {
+				return fmt.Errorf("oidc: unsupported scope %q", s)
+			}
+		}
+	}
+
+	envSeen := map[string]bool{}
+	for i := range m.Env {
+		e := &m.Env[i]
+		e.Name = strings.TrimSpace(e.Name)
+		if !manifestEnvNameRe.MatchString(e.Name) {
+			return fmt.Errorf("env[%d]: invalid name %q", i, e.Name)
+		}
+		if envSeen[e.Name] {
+			return fmt.Errorf("env[%d]: duplicate name %q", i, e.Name)
+		}
+		envSeen[e.Name] = true
+	}
+	return nil
+}
+
+// LoadModuleManifest reads the manifest from the module checkout. It returns
+// (nil, "", nil) when the repo has no manifest.
+func LoadModuleManifest(module Module) (*ModuleManifest, string, error) {
+	data, err := ReadModuleFile(module, ModuleManifestFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+	m, err := ParseModuleManifest(data)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(data)
+	return &m, hex.EncodeToString(sum[:]), nil
+}
+
+// DiffModuleManifest compares the module manifest with the current DB state.
+// It returns ErrNotFound when the repo has no manifest.
+func DiffModuleManifest(module Module) (ModuleManifestDiff, error) {
+	m, hash, err := LoadModuleManifest(module)
+	if err != nil {
+		return ModuleManifestDiff{}, err
+	}
+	if m == nil {
+		return ModuleManifestDiff{}, ErrNotFound
+	}
+	return diffModuleManifest(module, *m, hash)
+}
+
+func diffModuleManifest(module Module, m ModuleManifest, hash string) (ModuleManifestDiff, error) {
+	diff := ModuleManifestDiff{
+		ManifestHash:   hash,
+		Manifest:       m,
+		CreatePages:    []ModuleManifestPage{},
+		UpdatePages:    []ModuleManifestPageUpdate{},
+		UnmanagedPages: []string{},
+		OIDC:           []ModuleManifestFieldChange{},
+		MissingEnv:     []string{},
+		Conflicts:      []string{},
+	}
+
+	// Pages
+	current, err := database.GetModulePages(database.ModulePagesPagination{ModuleID: &module.ID})
+	if err != nil {
+		return diff, fmt.Errorf("list module pages: %w", err)
+	}
+	bySlug := make(map[string]ModulePage, len(current))
+	for _, p := range current {
+		bySlug[p.Slug] = DatabaseModulePageToModulePage(p)
+	}
+	for _, want := range m.Pages {
+		have, ok := bySlug[want.Slug]
+		if !ok {
+			owner, err := database.GetPage(want.Slug)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return diff, fmt.Errorf("check page slug: %w", err)
+			}
+			if err == nil && owner != nil {
+				diff.Conflicts = append(diff.Conflicts, fmt.Sprintf("page slug %q is already used by another module", want.Slug))
+				continue
+			}
+			diff.CreatePages = append(diff.CreatePages, want)
+			continue
+		}
+		delete(bySlug, want.Slug)
+		if changes := diffManifestPage(have, want); len(changes) > 0 {
+			diff.UpdatePages = append(diff.UpdatePages, ModuleManifestPageUpdate{PageID: have.ID, Slug: have.Slug, Changes: changes})
+		}
+	}
+	for slug := range bySlug {
+		diff.UnmanagedPages = append(diff.UnmanagedPages, slug)
+	}
+	slices.Sort(diff.UnmanagedPages)
+
+	// Icon
+	if m.Icon != "" {
+		data, err := ReadModuleFile(module, m.Icon)
+		if err != nil {
+			diff.Conflicts = append(diff.Conflicts, fmt.Sprintf("icon %q cannot be read from the repository", m.Icon))
+		} else if !moduleIconMatches(module, data) {
+			diff.Icon = &ModuleManifestFieldChange{Field: "icon", From: module.IconURL, To: m.Icon}
+		}
+	}
+
+	// OIDC
+	if m.OIDC != nil {
+		client, err := database.GetOIDCClientByModuleID(module.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return diff, fmt.Errorf("load oidc client: %w", err)
+		}
+		var haveURIs, haveScopes []string
+		if client != nil {
+			haveURIs, haveScopes = client.AllowedRedirectURIs, client.AllowedScopes
+		}
+		if !sameStringSet(haveURIs, m.OIDC.RedirectURIs) {
+			diff.OIDC = append(diff.OIDC, ModuleManifestFieldChange{Field: "redirect_uris", From: haveURIs, To: m.OIDC.RedirectURIs})
+		}
+		if len(m.OIDC.Scopes) > 0 && !sameStringSet(haveScopes, m.OIDC.Scopes) {
+			diff.OIDC = append(diff.OIDC, ModuleManifestFieldChange{Field: "scopes", From: haveScopes, To: m.OIDC.Scopes})
+		}
+	}
+
+	// Env: required vars must come from the repo .env or the module secrets
+	if len(m.Env) > 0 {
+		env := map[string]string{}
+		if root, err := moduleRepoPath(module); err == nil {
+			if values, err := godotenv.Read(filepath.Join(root, ".env")); err == nil {
+				env = values
+			}
+		}
+		secrets, err := database.ListModuleSecrets(context.Background(), module.ID)
+		if err != nil {
+			return diff, fmt.Errorf("list module secrets: %w", err)
+		}
+		for _, s := range secrets {
+			env[s.Name] = "set"
+		}
+		for _, e := range m.Env {
+			if e.Required && strings.TrimSpace(env[e.Name]) == "" {
+				diff.MissingEnv = append(diff.MissingEnv, e.Name)
+			}
+		}
+	}
+
+	diff.HasChanges = len(diff.CreatePages) > 0 || len(diff.UpdatePages) > 0 || diff.Icon != nil || len(diff.OIDC) > 0
+	return diff, nil
+}
+
+func diffManifestPage(have ModulePage, want ModuleManifestPage) []ModuleManifestFieldChange {
+	var changes []ModuleManifestFieldChange
+	add := func(field string, from, to any) {
+		changes = append(changes, ModuleManifestFieldChange{Field: field, From: from, To: to})
+	}
+	if have.Name != want.Name {
+		add("name", have.Name, want.Name)
+	}
+	haveContainer, havePort := ptrValue(have.TargetContainer), 0
+	if have.TargetPort != nil {
+		havePort = *have.TargetPort
+	}
+	if haveContainer != want.Container {
+		add("container", haveContainer, want.Container)
+	}
+	if havePort != want.Port {
+		add("port", havePort, want.Port)
+	}
+	if have.NetworkName != want.Network {
+		add("network", have.NetworkName, want.Network)
+	}
+	if have.IframeOnly != want.IframeOnly {
+		add("iframe_only", have.IframeOnly, want.IframeOnly)
+	}
+	if have.PageOnly != want.PageOnly {
+		add("page_only", have.PageOnly, want.PageOnly)
+	}
+	if have.NeedAuth != *want.NeedAuth {
+		add("need_auth", have.NeedAuth, *want.NeedAuth)
+	}
+	if have.IsVisible != *want.Visible {
+		add("visible", have.IsVisible, *want.Visible)
+	}
+	return changes
+}
+
+func sameStringSet(a, b []string) bool {
+	a, b = normalizeStringList(a), normalizeStringList(b)
+	if len(a) != len(b) {
+		return false
+	}
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+// moduleIconMatches reports whether the stored module icon has the given content.
+func moduleIconMatches(module Module, data []byte) bool {
+	if !strings.HasPrefix(module.IconURL, "/assets/module-icons/") {
+		return false
+	}
+	current, err := os.ReadFile(filepath.Join(moduleIconDir, filepath.Base(module.IconURL)))
+	return err == nil && bytes.Equal(current, data)
+}
+
+// ApplyModuleManifest applies the manifest currently checked out. expectedHash
+// must match the hash returned by DiffModuleManifest, so admins only confirm
+// what they reviewed.
+func ApplyModuleManifest(module Module, expectedHash string) (ModuleManifestDiff, error) {
+	m, hash, err := LoadModuleManifest(module)
+	if err != nil {
+		return ModuleManifestDiff{}, err
+	}
+	if m == nil {
+		return ModuleManifestDiff{}, ErrNotFound
+	}
+	if expectedHash != "" && expectedHash != hash {
+		return ModuleManifestDiff{}, fmt.Errorf("%w: manifest changed since it was reviewed", ErrConflict)
+	}
+	diff, err := diffModuleManifest(module, *m, hash)
+	if err != nil {
+		return diff, err
+	}
+	if len(diff.Conflicts) > 0 {
+		return diff, fmt.Errorf("%w: %s", ErrConflict, strings.Join(diff.Conflicts, "; "))
+	}
+
+	for _, p := range diff.CreatePages {
+		slug := p.Slug
+		container, port := optionalTarget(p)
+		if _, err := ImportModulePage(module.ID, p.Name, &slug, container, port, p.IframeOnly, p.PageOnly, *p.NeedAuth, *p.Visible, p.Network); err != nil {
+			return diff, LogModule(module.ID, "ERROR", fmt.Sprintf("manifest: failed to create page %s", p.Slug), nil, err)
+		}
+	}
+	for _, u := range diff.UpdatePages {
+		p := manifestPageBySlug(*m, u.Slug)
+		container, port := optionalTarget(p)
+		network := p.Network
+		if _, err := UpdateModulePage(u.PageID, &p.Name,
+			nil, false,
+			container, true,
+			port, true,
+			&p.IframeOnly,
+			&p.PageOnly,
+			p.NeedAuth,
+			p.Visible,
+			&network, true,
+		); err != nil {
+			return diff, LogModule(module.ID, "ERROR", fmt.Sprintf("manifest: failed to update page %s", u.Slug), nil, err)
+		}
+	}
+
+	if diff.Icon != nil {
+		data, err := ReadModuleFile(module, m.Icon)
+		if err != nil {
+			return diff, LogModule(module.ID, "ERROR", "manifest: failed to read icon", nil, err)
+		}
+		iconURL, err := SaveModuleIcon(module.ID, data)
+		if err != nil {
+			return diff, LogModule(module.ID, "ERROR", "manifest: failed to save icon", nil, err)
+		}
+		if _, err := PatchModule(ModulePatch{ID: module.ID, IconURL: &iconURL}); err != nil {
+			return diff, LogModule(module.ID, "ERROR", "manifest: failed to update icon", nil, err)
+		}
+	}
+
+	if len(diff.OIDC) > 0 {
+		client, err := ensureOIDCClientForModule(module)
+		if err != nil {
+			return diff, LogModule(module.ID, "ERROR", "manifest: failed to load OIDC client", nil, err)
+		}
+		patch := database.OIDCClientPatch{ID: client.ID, AllowedRedirectURIs: &m.OIDC.RedirectURIs}
+		if len(m.OIDC.Scopes) > 0 {
+			patch.AllowedScopes = &m.OIDC.Scopes
+		}
+		if _, err := database.UpdateOIDCClient(patch); err != nil {
+			return diff, LogModule(module.ID, "ERROR", "manifest: failed to update OIDC client", nil, err)
+		}
+	}
+
+	LogModule(module.ID, "INFO", "Applied "+ModuleManifestFile, manifestDiffSummary(diff), nil)
+	return diff, nil
+}
+
+func optionalTarget(p ModuleManifestPage) (*string, *int) {
+	if p.Container == "" {
+		return nil, nil
+	}
+	container, port := p.Container, p.Port
+	return &container, &port
+}
+
+func manifestPageBySlug(m ModuleManifest, slug string) ModuleManifestPage {
+	for _, p := range m.Pages {
+		if p.Slug == slug {
+			return p
+		}
+	}
+	return ModuleManifestPage{}
+}
+
+func manifestDiffSummary(diff ModuleManifestDiff) map[string]any {
+	return map[string]any{
+		"manifest_hash":   diff.ManifestHash,
+		"create_pages":    len(diff.CreatePages),
+		"update_pages":    len(diff.UpdatePages),
+		"unmanaged_pages": diff.UnmanagedPages,
+		"icon":            diff.Icon != nil,
+		"oidc":            len(diff.OIDC),
+		"missing_env":     diff.MissingEnv,
+		"conflicts":       diff.Conflicts,
+	}
+}
+
+// checkModuleManifest validates the manifest after a clone or pull and logs the
+// pending diff. Nothing is applied until an admin confirms it.
+func checkModuleManifest(module Module) {
+	diff, err := DiffModuleManifest(module)
+	if errors.Is(err, ErrNotFound) {
+		return
+	}
+	if err != nil {
+		LogModule(module.ID, "WARN", ModuleManifestFile+" is invalid", nil, err)
+		return
+	}
+	if len(diff.MissingEnv) > 0 {
+		LogModule(module.ID, "WARN", "Missing required env vars: "+strings.Join(diff.MissingEnv, ", "), nil, nil)
+	}
+	if diff.HasChanges || len(diff.Conflicts) > 0 {
+		LogModule(module.ID, "INFO", ModuleManifestFile+" has changes waiting to be applied", manifestDiffSummary(diff), nil)
+	}
+}
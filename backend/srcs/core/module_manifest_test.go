@@ -0,0 +1,83 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseModuleManifestDefaults(t *testing.T) {
+	m, err := ParseModuleManifest([]byte(`
+icon: assets/icon.png
+pages:
+  - name: Main Dashboard
+    container: web
+    port: 8080
+  - name: Admin
+    slug: my-admin
+    iframe_only: true
+    need_auth: false
+    visible: false
+oidc:
+  redirect_uris: ["https://app.example.com/callback", "https://app.example.com/callback"]
+  scopes: [openid, profile]
+env:
+  - name: API_KEY
+    required: true
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.Version != 1 {
+		t.Errorf("version should default to 1, got %d", m.Version)
+	}
+	if m.Pages[0].Slug != "main-dashboard" {
+		t.Errorf("slug should derive from name, got %q", m.Pages[0].Slug)
+	}
+	if !*m.Pages[0].NeedAuth || !*m.Pages[0].Visible {
+		t.Errorf("need_auth and visible should default to true: %+v", m.Pages[0])
+	}
+	if *m.Pages[1].NeedAuth || *m.Pages[1].Visible {
+		t.Errorf("explicit false should be kept: %+v", m.Pages[1])
+	}
+	if len(m.OIDC.RedirectURIs) != 1 {
+		t.Errorf("redirect URIs should be deduplicated: %v", m.OIDC.RedirectURIs)
+	}
+}
+
+func TestParseModuleManifestInvalid(t *testing.T) {
+	cases := map[string]string{
+		"unknown field":  "pagez: []",
+		"bad version":    "version: 2",
+		"escaping icon":  "icon: ../secret.png",
+		"missing name":   "pages: [{slug: x}]",
+		"duplicate slug": "pages: [{name: A, slug: a}, {name: B, slug: a}]",
+		"port alone":     "pages: [{name: A, port: 80}]",
+		"port range":     "pages: [{name: A, container: web, port: 70000}]",
+		"both modes":     "pages: [{name: A, iframe_only: true, page_only: true}]",
+		"bad redirect":   "oidc: {redirect_uris: [\"javascript:alert(1)\"]}",
+		"unknown scope":  "oidc: {scopes: [openid, admin]}",
+		"bad env name":   "env: [{name: \"1ABC\"}]",
+		"duplicate env":  "env: [{name: A}, {name: A}]",
+	}
+	for name, doc := range cases {
+		if _, err := ParseModuleManifest([]byte(doc)); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: expected ErrInvalidInput, got %v", name, err)
+		}
+	}
+}
+
+func TestDiffManifestPage(t *testing.T) {
+	container, port := "web", 8080
+	have := ModulePage{Name: "Main", Slug: "main", TargetContainer: &container, TargetPort: &port, NeedAuth: true, IsVisible: true}
+	yes := true
+	want := ModuleManifestPage{Name: "Main", Slug: "main", Container: "web", Port: 8080, NeedAuth: &yes, Visible: &yes}
+	if changes := diffManifestPage(have, want); len(changes) != 0 {
+		t.Errorf("expected no changes, got %+v", changes)
+	}
+	want.Port = 3000
+	want.Network = "backend"
+	changes := diffManifestPage(have, want)
+	if len(changes) != 2 || changes[0].Field != "port" || changes[1].Field != "network" {
+		t.Errorf("unexpected changes: %+v", changes)
+	}
+}
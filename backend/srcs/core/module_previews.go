@@ -0,0 +1,606 @@
+package core
+
+import (
+	"backend/database"
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultPreviewTTL is how long previews live when their parent has no
+	// preview settings.
+	defaultPreviewTTL = 7 * 24 * time.Hour
+	maxPreviewTTL     = 90 * 24 * time.Hour
+	// previewSlugSep separates the parent slug from the branch in derived
+	// module and page slugs. It holds no "--", which is reserved for the
+	// green compose project of blue/green modules.
+	previewSlugSep = "-preview-"
+	// maxPreviewSlugLen keeps derived slugs usable as a DNS label.
+	maxPreviewSlugLen = 63
+)
+
+var modulePreviewGCOnce sync.Once
+
+// ModulePreviewSettings are the preview settings of a module.
+type ModulePreviewSettings struct {
+	ModuleID   string `json:"module_id"`
+	TTLSeconds int    `json:"ttl_seconds"`
+	// MaintainerRoleIDs are the only roles, with admins, that can open the
+	// pages of the module's previews.
+	MaintainerRoleIDs []string `json:"maintainer_role_ids"`
+	// PreviewSecrets are the parent secrets copied into new previews. Previews
+	// build branches nobody reviewed, so none are copied by default.
+	PreviewSecrets []string   `json:"preview_secrets"`
+	UpdatedAt      *time.Time `json:"updated_at,omitempty"`
+}
+
+// ModulePreview is a module cloned from a branch of its parent.
+type ModulePreview struct {
+	ModuleID    string    `json:"module_id"`
+	ParentID    string    `json:"parent_id"`
+	Branch      string    `json:"branch"`
+	Slug        string    `json:"slug"`
+	Name        string    `json:"name"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	ActorUserID string    `json:"actor_user_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ModulePreviewInput asks for a preview of Branch. TTLSeconds overrides the
+// lifetime from the preview settings.
+type ModulePreviewInput struct {
+	Branch     string `json:"branch"`
+	TTLSeconds int    `json:"ttl_seconds"`
+}
+
+func dbPreviewToPreview(p database.ModulePreview, module Module) ModulePreview {
+	return ModulePreview{
+		ModuleID:    p.ModuleID,
+		ParentID:    p.ParentID,
+		Branch:      p.Branch,
+		Slug:        module.Slug,
+		Name:        module.Name,
+		ExpiresAt:   p.ExpiresAt,
+		ActorUserID: p.ActorUserID.String,
+		CreatedAt:   p.CreatedAt,
+	}
+}
+
+// GetModulePreviewSettings returns the preview settings of a module, with the
+// default lifetime and no maintainer role when none were set.
+func GetModulePreviewSettings(ctx context.Context, moduleID string) (ModulePreviewSettings, error) {
+	roles, err := database.ListModuleMaintainerRoleIDs(ctx, moduleID)
+	if err != nil {
+		return ModulePreviewSettings{}, err
+	}
+	s, err := database.GetModulePreviewSettings(ctx, moduleID)
+	if errors.Is(err, database.ErrNotFound) {
+		return ModulePreviewSettings{ModuleID: moduleID, TTLSeconds: int(defaultPreviewTTL / time.Second), MaintainerRoleIDs: roles, PreviewSecrets: []string{}}, nil
+	}
+	if err != nil {
+		return ModulePreviewSettings{}, err
+	}
+	secrets := []string(s.PreviewSecrets)
+	if secrets == nil {
+		secrets = []string{}
+	}
+	t := s.UpdatedAt
+	return ModulePreviewSettings{ModuleID: s.ModuleID, TTLSeconds: s.TTLSeconds, MaintainerRoleIDs: roles, PreviewSecrets: secrets, UpdatedAt: &t}, nil
+}
+
+// SetModulePreviewSettings stores the preview settings of a module. The pages
+// of its existing previews are restricted to the new maintainer roles.
+func SetModulePreviewSettings(ctx context.Context, module Module, in ModulePreviewSettings, actor *User) (ModulePreviewSettings, error) {
+	if in.TTLSeconds == 0 {
+		in.TTLSeconds = int(defaultPreviewTTL / time.Second)
+	}
+	if err := validatePreviewTTL(in.TTLSeconds); err != nil {
+		return ModulePreviewSettings{}, err
+	}
+	roles := []string{}
+	for _, id := range in.MaintainerRoleIDs {
+		id = strings.TrimSpace(id)
+		if id == "" || slices.Contains(roles, id) {
+			continue
+		}
+		if id == RoleIDBlacklist {
+			return ModulePreviewSettings{}, fmt.Errorf("%w: the blacklist role cannot maintain a module", ErrInvalidInput)
+		}
+		if _, err := database.GetRole(id); err != nil {
+			return ModulePreviewSettings{}, fmt.Errorf("%w: unknown role %q", ErrInvalidInput, id)
+		}
+		roles = append(roles, id)
+	}
+	secrets := []string{}
+	for _, name := range in.PreviewSecrets {
+		name = strings.TrimSpace(name)
+		if name == "" || slices.Contains(secrets, name) {
+			continue
+		}
+		if !manifestEnvNameRe.MatchString(name) {
+			return ModulePreviewSettings{}, fmt.Errorf("%w: %q is not a valid secret name", ErrInvalidInput, name)
+		}
+		secrets = append(secrets, name)
+	}
+	if _, err := database.UpsertModulePreviewSettings(ctx, database.ModulePreviewSettings{
+		ModuleID:       module.ID,
+		TTLSeconds:     in.TTLSeconds,
+		PreviewSecrets: secrets,
+	}, roles); err != nil {
+		return ModulePreviewSettings{}, err
+	}
+	meta := map[string]any{"ttl_seconds": in.TTLSeconds, "maintainer_role_ids": roles, "preview_secrets": secrets}
+	if actor != nil {
+		meta["actor"] = actor.FtLogin
+	}
+	LogModule(module.ID, "INFO", "Preview settings updated", meta, nil)
+
+	previews, err := database.ListModulePreviews(ctx, module.ID)
+	if err != nil {
+		return ModulePreviewSettings{}, err
+	}
+	for _, p := range previews {
+		if err := restrictPreviewPages(p.ModuleID, roles); err != nil {
+			LogModule(p.ModuleID, "WARN", "Failed to update the roles of the preview pages", nil, err)
+		}
+	}
+	return GetModulePreviewSettings(ctx, module.ID)
+}
+
+func validatePreviewTTL(seconds int) error {
+	if seconds < 60 || time.Duration(seconds)*time.Second > maxPreviewTTL {
+		return fmt.Errorf("%w: ttl_seconds must be between 60 and %d", ErrInvalidInput, int(maxPreviewTTL/time.Second))
+	}
+	return nil
+}
+
+// ListModulePreviews returns the previews of a module, oldest first.
+func ListModulePreviews(ctx context.Context, parentID string) ([]ModulePreview, error) {
+	rows, err := database.ListModulePreviews(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ModulePreview, 0, len(rows))
+	for _, p := range rows {
+		module, err := GetModule(p.ModuleID)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, dbPreviewToPreview(p, module))
+	}
+	return out, nil
+}
+
+// CreateModulePreview registers a module cloned from branch of parent, with
+// the parent's pages under derived slugs, then queues its clone and deploy.
+// Asking again for the same branch extends the preview and redeploys it.
+func CreateModulePreview(ctx context.Context, parent Module, in ModulePreviewInput, actor *User) (ModulePreview, []ModuleJob, error) {
+	branch := strings.TrimSpace(in.Branch)
+	if err := validPreviewBranch(branch); err != nil {
+		return ModulePreview{}, nil, err
+	}
+	if branch == parent.GitBranch {
+		return ModulePreview{}, nil, fmt.Errorf("%w: %s is already the branch of the module", ErrInvalidInput, branch)
+	}
+	if _, err := database.GetModulePreview(ctx, parent.ID); err == nil {
+		return ModulePreview{}, nil, fmt.Errorf("%w: a preview cannot be previewed", ErrInvalidInput)
+	} else if !errors.Is(err, database.ErrNotFound) {
+		return ModulePreview{}, nil, err
+	}
+	settings, err := GetModulePreviewSettings(ctx, parent.ID)
+	if err != nil {
+		return ModulePreview{}, nil, err
+	}
+	ttl := settings.TTLSeconds
+	if in.TTLSeconds != 0 {
+		if err := validatePreviewTTL(in.TTLSeconds); err != nil {
+			return ModulePreview{}, nil, err
+		}
+		ttl = in.TTLSeconds
+	}
+	expiresAt := time.Now().UTC().Add(time.Duration(ttl) * time.Second)
+
+	if existing, err := database.GetModulePreviewByBranch(ctx, parent.ID, branch); err == nil {
+		return refreshModulePreview(ctx, existing, expiresAt, actor)
+	} else if !errors.Is(err, database.ErrNotFound) {
+		return ModulePreview{}, nil, err
+	}
+
+	if found, err := remoteHasBranch(parent, branch); err != nil {
+		return ModulePreview{}, nil, fmt.Errorf("%w: cannot list the branches of %s: %v", ErrConflict, parent.Name, err)
+	} else if !found {
+		return ModulePreview{}, nil, fmt.Errorf("%w: branch %q not found on the remote", ErrInvalidInput, branch)
+	}
+
+	slug := previewSlug(parent.Slug, branch)
+	if taken, err := database.IsModuleSlugTaken(slug); err != nil {
+		return ModulePreview{}, nil, err
+	} else if taken {
+		return ModulePreview{}, nil, fmt.Errorf("%w: module slug %q is already used", ErrConflict, slug)
+	}
+	pages, err := database.GetModulePages(database.ModulePagesPagination{ModuleID: &parent.ID})
+	if err != nil {
+		return ModulePreview{}, nil, fmt.Errorf("list module pages: %w", err)
+	}
+	for _, p := range pages {
+		pageSlug := previewSlug(p.Slug, branch)
+		if taken, err := database.IsPageSlugTaken(pageSlug); err != nil {
+			return ModulePreview{}, nil, err
+		} else if taken {
+			return ModulePreview{}, nil, fmt.Errorf("%w: page slug %q is already used", ErrConflict, pageSlug)
+		}
+	}
+
+	module, err := importModule(actor, fmt.Sprintf("%s (%s)", parent.Name, branch), slug, parent.GitURL, branch, parent.SSHKeyID)
+	if err != nil {
+		return ModulePreview{}, nil, err
+	}
+	row := database.ModulePreview{ModuleID: module.ID, ParentID: parent.ID, Branch: branch, ExpiresAt: expiresAt}
+	if actor != nil && actor.ID != "" {
+		row.ActorUserID = sql.NullString{String: actor.ID, Valid: true}
+	}
+	inserted, err := database.InsertModulePreview(ctx, row)
+	if err != nil {
+		if derr := DeleteModule(module.ID); derr != nil {
+			log.Printf("[previews] drop %s after a failed insert: %v", module.ID, derr)
+		}
+		if errors.Is(err, database.ErrAlreadyExists) {
+			return ModulePreview{}, nil, fmt.Errorf("%w: a preview of %s is already being created", ErrConflict, branch)
+		}
+		return ModulePreview{}, nil, err
+	}
+
+	copyPreviewSettings(ctx, parent, module, settings.PreviewSecrets, actor)
+	if err := copyPreviewPages(ctx, parent, module, pages, branch, settings.MaintainerRoleIDs); err != nil {
+		LogModule(module.ID, "WARN", "Failed to copy the pages of "+parent.Name, nil, err)
+	}
+
+	meta := map[string]any{"parent_id": parent.ID, "branch": branch, "expires_at": expiresAt}
+	if actor != nil {
+		meta["actor"] = actor.FtLogin
+	}
+	LogModule(module.ID, "INFO", fmt.Sprintf("Preview of %s on branch %s created", parent.Name, branch), meta, nil)
+	LogModule(parent.ID, "INFO", fmt.Sprintf("Preview %s created for branch %s", module.Slug, branch), meta, nil)
+
+	jobs := []ModuleJob{}
+	for _, kind := range []string{ModuleJobClone, ModuleJobDeploy} {
+		job, err := EnqueueModuleJob(ctx, module, kind, ModuleJobParams{Source: DeploySourcePreview}, actor)
+		if err != nil {
+			return dbPreviewToPreview(inserted, module), jobs, err
+		}
+		jobs = append(jobs, job)
+	}
+	return dbPreviewToPreview(inserted, module), jobs, nil
+}
+
+// refreshModulePreview extends an existing preview and queues a pull and
+// deploy of its branch.
+func refreshModulePreview(ctx context.Context, p database.ModulePreview, expiresAt time.Time, actor *User) (ModulePreview, []ModuleJob, error) {
+	module, err := GetModule(p.ModuleID)
+	if err != nil {
+		return ModulePreview{}, nil, err
+	}
+	if expiresAt.After(p.ExpiresAt) {
+		if p, err = database.ExtendModulePreview(ctx, p.ModuleID, expiresAt); err != nil {
+			return ModulePreview{}, nil, err
+		}
+	}
+	LogModule(module.ID, "INFO", "Preview refreshed", map[string]any{"expires_at": p.ExpiresAt}, nil)
+	job, err := EnqueueModuleJob(ctx, module, ModuleJobPull, ModuleJobParams{Source: DeploySourcePreview, Deploy: true}, actor)
+	if err != nil {
+		return dbPreviewToPreview(p, module), nil, err
+	}
+	return dbPreviewToPreview(p, module), []ModuleJob{job}, nil
+}
+
+// DeleteModulePreview deletes a preview of parentID and everything it runs.
+func DeleteModulePreview(ctx context.Context, parentID, previewID string) error {
+	p, err := database.GetModulePreview(ctx, previewID)
+	if errors.Is(err, database.ErrNotFound) || (err == nil && p.ParentID != parentID) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return deleteModulePreview(p, "deleted")
+}
+
+func deleteModulePreview(p database.ModulePreview, reason string) error {
+	module, err := GetModule(p.ModuleID)
+	if err != nil {
+		return err
+	}
+	if err := DeleteModule(p.ModuleID); err != nil {
+		return err
+	}
+	LogModule(p.ParentID, "INFO", fmt.Sprintf("Preview %s of branch %s %s", module.Slug, p.Branch, reason), nil, nil)
+	return nil
+}
+
+// validPreviewBranch rejects names git would not accept as a branch.
+func validPreviewBranch(branch string) error {
+	if branch == "" {
+		return fmt.Errorf("%w: branch is required", ErrInvalidInput)
+	}
+	if strings.HasPrefix(branch, "-") || exec.Command("git", "check-ref-format", "refs/heads/"+branch).Run() != nil {
+		return fmt.Errorf("%w: invalid branch %q", ErrInvalidInput, branch)
+	}
+	return nil
+}
+
+// remoteHasBranch asks the remote of a cloned module whether branch exists.
+func remoteHasBranch(module Module, branch string) (bool, error) {
+	branches, err := GitListBranches(module)
+	if err != nil {
+		return false, err
+	}
+	for _, b := range branches {
+		if b.Name == branch {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// previewSlug derives the slug of a preview from the slug of its parent
+// module or page: "mymod" and "feature/x" give "mymod-preview-feature-x".
+// Branches too long for a DNS label are cut and suffixed with a hash of their
+// name; a base too long to leave room for that hash is cut too, and then
+// hashed with the branch. Like any module slug, the result never contains "--".
+func previewSlug(base, branch string) string {
+	const hashLen = 8
+	hashed := branch
+	if maxBase := maxPreviewSlugLen - len(previewSlugSep) - hashLen; len(base) > maxBase {
+		hashed = base + "/" + branch
+		base = strings.TrimRight(base[:maxBase], "-")
+	}
+	suffix := normalizePageSlug(branch)
+	room := maxPreviewSlugLen - len(base) - len(previewSlugSep)
+	if suffix == "" || len(suffix) > room || hashed != branch {
+		sum := sha1.Sum([]byte(hashed))
+		hash := hex.EncodeToString(sum[:])[:hashLen]
+		keep := room - len(hash) - 1
+		if keep < 0 {
+			keep = 0
+		}
+		if len(suffix) > keep {
+			suffix = strings.Trim(suffix[:keep], "-")
+		}
+		if suffix == "" {
+			suffix = hash
+		} else {
+			suffix += "-" + hash
+		}
+	}
+	return base + previewSlugSep + suffix
+}
+
+// copyPreviewSettings gives a preview the repository access, git options and
+// resource limits of its parent, and the parent secrets listed in
+// secretNames. Failures are logged on the preview, which can be fixed by hand.
+func copyPreviewSettings(ctx context.Context, parent, module Module, secretNames []string, actor *User) {
+	warn := func(what string, err error) {
+		LogModule(module.ID, "WARN", fmt.Sprintf("Failed to copy the %s of %s", what, parent.Name), nil, err)
+	}
+	if parent.IconURL != "" {
+		icon := parent.IconURL
+		if _, err := PatchModule(ModulePatch{ID: module.ID, IconURL: &icon}); err != nil {
+			warn("icon", err)
+		}
+	}
+	if cred, err := GetModuleGitCredential(ctx, parent.ID); err != nil {
+		warn("git credential", err)
+	} else if cred != nil {
+		if _, err := SetModuleGitCredential(ctx, module.ID, cred.ID, actor); err != nil {
+			warn("git credential", err)
+		}
+	}
+	if opts, err := GetModuleGitOptions(ctx, parent.ID); err != nil {
+		warn("git options", err)
+	} else if opts.Submodules || opts.LFS {
+		if _, err := SetModuleGitOptions(ctx, module.ID, opts); err != nil {
+			warn("git options", err)
+		}
+	}
+	if limits, err := GetModuleResourceLimits(ctx, parent.ID); err != nil {
+		warn("resource limits", err)
+	} else if limits, _ = limits.enforceable(storageOptEnabled()); limits.isSet() {
+		if _, err := SetModuleResourceLimits(ctx, module.ID, limits); err != nil {
+			warn("resource limits", err)
+		}
+	}
+	if len(secretNames) == 0 {
+		return
+	}
+	secrets, err := moduleSecretEnv(ctx, parent.ID)
+	if err != nil {
+		warn("secrets", err)
+		return
+	}
+	// The managed database of the parent is not reachable from the preview
+	_, err = database.GetActiveModuleDatabase(ctx, parent.ID)
+	secrets = previewSecrets(secrets, secretNames, err == nil)
+	for name, value := range secrets {
+		if _, _, err := SetModuleSecret(ctx, module.ID, name, value); err != nil {
+			warn("secret "+name, err)
+		}
+	}
+}
+
+// previewSecrets keeps the secrets named in allowed, minus the managed
+// database credentials when the parent has a managed database.
+func previewSecrets(secrets map[string]string, allowed []string, managedDB bool) map[string]string {
+	out := map[string]string{}
+	for _, name := range allowed {
+		if managedDB && slices.Contains(managedDatabaseSecrets, name) {
+			continue
+		}
+		if value, ok := secrets[name]; ok {
+			out[name] = value
+		}
+	}
+	return out
+}
+
+// copyPreviewPages recreates the pages of the parent on the preview under
+// derived slugs, pointed at the preview's containers and restricted to the
+// maintainer roles.
+func copyPreviewPages(ctx context.Context, parent, module Module, pages []database.ModulePage, branch string, roleIDs []string) error {
+	checks, err := database.ListModulePageHealthChecks(ctx, parent.ID)
+	if err != nil {
+		return fmt.Errorf("list page health checks: %w", err)
+	}
+	checkByPage := make(map[string]database.ModulePageHealthCheck, len(checks))
+	for _, c := range checks {
+		checkByPage[c.PageID] = c
+	}
+	for _, dbPage := range pages {
+		p := DatabaseModulePageToModulePage(dbPage)
+		if p.TargetContainer != nil {
+			c := remapProjectName(*p.TargetContainer, parent.Slug, module.Slug)
+			p.TargetContainer = &c
+		}
+		page, err := insertModulePage(ModulePage{
+			ModuleID:        module.ID,
+			Name:            p.Name,
+			Slug:            previewSlug(p.Slug, branch),
+			TargetContainer: p.TargetContainer,
+			TargetPort:      p.TargetPort,
+			IframeOnly:      p.IframeOnly,
+			PageOnly:        p.PageOnly,
+			NeedAuth:        true,
+			IsVisible:       p.IsVisible,
+			NetworkName:     remapProjectName(p.NetworkName, parent.Slug, module.Slug),
+		})
+		if err != nil {
+			return fmt.Errorf("page %s: %w", p.Slug, err)
+		}
+		if p.IconURL != "" {
+			icon := p.IconURL
+			if err := database.SetPageIconURL(page.ID, &icon); err != nil {
+				return fmt.Errorf("page %s icon: %w", p.Slug, err)
+			}
+		}
+		if c, ok := checkByPage[p.ID]; ok {
+			if _, err := SetPageHealthCheck(ctx, module.ID, page.ID, PageHealthCheck{
+				Enabled:         c.Enabled,
+				Path:            c.Path,
+				ExpectedStatus:  c.ExpectedStatus,
+				IntervalSeconds: c.IntervalSeconds,
+				TimeoutSeconds:  c.TimeoutSeconds,
+			}); err != nil {
+				return fmt.Errorf("page %s health check: %w", p.Slug, err)
+			}
+		}
+	}
+	return restrictPreviewPages(module.ID, roleIDs)
+}
+
+// restrictPreviewPages makes the maintainer roles and the admins the only
+// ones allowed on the pages of a preview.
+func restrictPreviewPages(moduleID string, roleIDs []string) error {
+	pages, err := database.GetModulePages(database.ModulePagesPagination{ModuleID: &moduleID})
+	if err != nil {
+		return err
+	}
+	want := append([]string{RoleIDAdmin}, roleIDs...)
+	for _, p := range pages {
+		current, err := database.GetPageRoles(p.ID)
+		if err != nil {
+			return err
+		}
+		have := make([]string, 0, len(current))
+		for _, r := range current {
+			have = append(have, r.ID)
+			if !slices.Contains(want, r.ID) {
+				if err := database.RemoveRoleFromPage(r.ID, p.ID); err != nil {
+					return err
+				}
+			}
+		}
+		for _, id := range want {
+			if !slices.Contains(have, id) {
+				if err := database.AssignRoleToPage(id, p.ID); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// deleteModulePreviews deletes the previews of a module that is going away.
+func deleteModulePreviews(parentID string) {
+	previews, err := database.ListModulePreviews(context.Background(), parentID)
+	if err != nil {
+		LogModule(parentID, "WARN", "Failed to list previews", nil, err)
+		return
+	}
+	for _, p := range previews {
+		if err := DeleteModule(p.ModuleID); err != nil {
+			LogModule(parentID, "WARN", fmt.Sprintf("Failed to delete preview of branch %s", p.Branch), nil, err)
+		}
+	}
+}
+
+// StartModulePreviewGC deletes expired previews, and those whose branch is
+// gone from the remote, every MODULE_PREVIEW_GC_TICK (default 10m; 0
+// disables it).
+func StartModulePreviewGC() {
+	tick := envDuration("MODULE_PREVIEW_GC_TICK", 10*time.Minute)
+	if tick <= 0 {
+		return
+	}
+	modulePreviewGCOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(tick)
+			defer ticker.Stop()
+			for range ticker.C {
+				collectModulePreviews(context.Background(), time.Now())
+			}
+		}()
+	})
+}
+
+func collectModulePreviews(ctx context.Context, now time.Time) {
+	previews, err := database.ListModulePreviews(ctx, "")
+	if err != nil {
+		log.Printf("[previews] list previews: %v", err)
+		return
+	}
+	for _, p := range previews {
+		// leave previews alone while a job works on them
+		if jobs, err := database.ListModuleJobs(ctx, p.ModuleID, []string{JobQueued, JobRunning}, 1); err != nil || len(jobs) > 0 {
+			continue
+		}
+		reason := ""
+		if now.After(p.ExpiresAt) {
+			reason = "expired"
+		} else if module, err := GetModule(p.ModuleID); err == nil {
+			if _, err := os.Stat(repoDirFor(module)); err != nil {
+				continue
+			}
+			// a remote that cannot be reached is not a deleted branch
+			if found, err := remoteHasBranch(module, p.Branch); err == nil && !found {
+				reason = "deleted, the branch is gone"
+			}
+		}
+		if reason == "" {
+			continue
+		}
+		if err := deleteModulePreview(p, reason); err != nil {
+			log.Printf("[previews] delete %s: %v", p.ModuleID, err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestPreviewSlug(t *testing.T) {
+	cases := []struct{ base, branch, want string }{
+		{"mymod", "feature-x", "mymod-preview-feature-x"},
+		{"mymod", "Feature/X_2", "mymod-preview-feature-x-2"},
+		{"wiki-home", "fix/login", "wiki-home-preview-fix-login"},
+	}
+	for _, c := range cases {
+		if got := previewSlug(c.base, c.branch); got != c.want {
+			t.Errorf("previewSlug(%q, %q) = %q, want %q", c.base, c.branch, got, c.want)
+		}
+	}
+
+	long := "feature/" + strings.Repeat("very-long-name-", 6)
+	a, b := previewSlug("mymod", long), previewSlug("mymod", long+"2")
+	if len(a) > maxPreviewSlugLen || !strings.HasPrefix(a, "mymod-preview-feature-very-long") {
+		t.Errorf("long branch: %q (%d chars)", a, len(a))
+	}
+	if a == b {
+		t.Errorf("branches cut to the same prefix share slug %q", a)
+	}
+	if got := previewSlug("mymod", "///"); !strings.HasPrefix(got, "mymod-preview-") || len(got) != len("mymod-preview-")+8 {
+		t.Errorf("branch without letters: %q", got)
+	}
+
+	longBase := strings.Repeat("wiki-", 12) + "home"
+	for _, branch := range []string{"fix", long} {
+		got := previewSlug(longBase, branch)
+		if len(got) > maxPreviewSlugLen || !strings.HasPrefix(got, "wiki-wiki-") || strings.Contains(got, "--") {
+			t.Errorf("long base, branch %q: %q (%d chars)", branch, got, len(got))
+		}
+	}
+	if previewSlug(longBase, "fix") == previewSlug(longBase+"-other", "fix") {
+		t.Error("bases cut to the same prefix share a preview slug")
+	}
+}
+
+func TestPreviewSlugBlueGreenProjects(t *testing.T) {
+	for _, branch := range []string{"green", "Green", "--green", "x--green", "feature--x", "-", "green/" + strings.Repeat("x", 80)} {
+		slug := previewSlug("mymod", branch)
+		if slug == "mymod"+greenProjectSuffix {
+			t.Errorf("branch %q: preview slug is the green project of its parent", branch)
+		}
+		if strings.Contains(slug, "--") {
+			t.Errorf("branch %q: preview slug %q contains \"--\"", branch, slug)
+		}
+		if got := moduleSlugFromProject(slug); got != slug {
+			t.Errorf("branch %q: project %q maps to module %q", branch, slug, got)
+		}
+		if got := moduleSlugFromProject(otherComposeProject(slug, slug)); got != slug {
+			t.Errorf("branch %q: green project of %q maps to module %q", branch, slug, got)
+		}
+	}
+}
+
+func TestValidPreviewBranch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	for _, b := range []string{"feature-x", "fix/login", "release/1.2"} {
+		if err := validPreviewBranch(b); err != nil {
+			t.Errorf("%q rejected: %v", b, err)
+		}
+	}
+	for _, b := range []string{"", "-x", "a..b", "a b", "x.lock", "a~1"} {
+		if err := validPreviewBranch(b); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%q: want ErrInvalidInput, got %v", b, err)
+		}
+	}
+}
+
+func TestPreviewSecrets(t *testing.T) {
+	parent := map[string]string{"SENTRY_DSN": "https://sentry", "API_TOKEN": "prod-token", "DATABASE_URL": "postgres://prod"}
+
+	if got := previewSecrets(parent, nil, false); len(got) != 0 {
+		t.Errorf("no allow-list copied %v", got)
+	}
+	got := previewSecrets(parent, []string{"SENTRY_DSN", "MISSING"}, false)
+	if len(got) != 1 || got["SENTRY_DSN"] != "https://sentry" {
+		t.Errorf("allow-list: got %v", got)
+	}
+	got = previewSecrets(parent, []string{"SENTRY_DSN", "DATABASE_URL"}, true)
+	if _, ok := got["DATABASE_URL"]; ok || len(got) != 1 {
+		t.Errorf("managed database credentials copied: %v", got)
+	}
+	got = previewSecrets(parent, []string{"DATABASE_URL"}, false)
+	if got["DATABASE_URL"] != "postgres://prod" {
+		t.Errorf("own DATABASE_URL not copied: %v", got)
+	}
+}
+
+func TestSetModulePreviewSettingsSecretNames(t *testing.T) {
+	_, err := SetModulePreviewSettings(context.Background(), Module{ID: "module_x"}, ModulePreviewSettings{PreviewSecrets: []string{"SENTRY_DSN", "not a name"}}, nil)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("error = %v, want ErrInvalidInput", err)
+	}
+}
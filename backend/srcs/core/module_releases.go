@@ -0,0 +1,258 @@
+package core
+
+import (
+	"backend/database"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ModuleReleaseTracking makes a module deploy the highest git tag matching
+// Constraint instead of the head of its branch.
+type ModuleReleaseTracking struct {
+	ModuleID string `json:"module_id"`
+	// Constraint is a semver range such as "^1.2"; empty follows the branch.
+	Constraint string `json:"constraint"`
+	// Prereleases lets tags like v2.0.0-rc.1 match.
+	Prereleases bool       `json:"prereleases"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+// moduleRelease is a tag resolved by the release tracking.
+type moduleRelease struct {
+	Tag     string
+	Version semver
+	Commit  string
+}
+
+// GetModuleReleaseTracking returns the release tracking of a module, with an
+// empty constraint when it follows its branch.
+func GetModuleReleaseTracking(ctx context.Context, moduleID string) (ModuleReleaseTracking, error) {
+	t, err := database.GetModuleReleaseTracking(ctx, moduleID)
+	if errors.Is(err, database.ErrNotFound) {
+		return ModuleReleaseTracking{ModuleID: moduleID}, nil
+	}
+	if err != nil {
+		return ModuleReleaseTracking{}, err
+	}
+	updated := t.UpdatedAt
+	return ModuleReleaseTracking{ModuleID: t.ModuleID, Constraint: t.Constraint, Prereleases: t.Prereleases, UpdatedAt: &updated}, nil
+}
+
+// SetModuleReleaseTracking stores the constraint of a module, fetches its
+// tags and recomputes its versions. Nothing is checked out before the next
+// pull or deploy. An empty constraint goes back to following the branch;
+// HEAD stays on the last release until a branch is checked out.
+func SetModuleReleaseTracking(ctx context.Context, module Module, in ModuleReleaseTracking, actor *User) (ModuleReleaseTracking, error) {
+	in.Constraint = strings.TrimSpace(in.Constraint)
+	meta := map[string]any{}
+	if actor != nil {
+		meta["actor"] = actor.FtLogin
+	}
+	if in.Constraint == "" {
+		if err := database.DeleteModuleReleaseTracking(ctx, module.ID); err != nil {
+			return ModuleReleaseTracking{}, err
+		}
+		empty := ""
+		_, _ = database.PatchModule(database.ModulePatch{ID: module.ID, Version: &empty, LatestVersion: &empty})
+		LogModule(module.ID, "INFO", fmt.Sprintf("Release tracking disabled, following %s again", module.GitBranch), meta, nil)
+		_ = updateGitComputed(module, nil, nil)
+		return GetModuleReleaseTracking(ctx, module.ID)
+	}
+	if _, err := parseVersionConstraint(in.Constraint); err != nil {
+		return ModuleReleaseTracking{}, err
+	}
+	if _, err := database.UpsertModuleReleaseTracking(ctx, database.ModuleReleaseTracking{
+		ModuleID:    module.ID,
+		Constraint:  in.Constraint,
+		Prereleases: in.Prereleases,
+	}); err != nil {
+		return ModuleReleaseTracking{}, err
+	}
+	meta["constraint"] = in.Constraint
+	meta["prereleases"] = in.Prereleases
+	LogModule(module.ID, "INFO", fmt.Sprintf("Release tracking set to %s", in.Constraint), meta, nil)
+	_ = updateGitComputed(module, nil, nil)
+	return GetModuleReleaseTracking(ctx, module.ID)
+}
+
+// moduleReleaseConstraint returns the parsed constraint of a module and
+// whether prereleases match, or nil when it follows its branch.
+func moduleReleaseConstraint(module Module) (versionConstraint, bool) {
+	t, err := GetModuleReleaseTracking(moduleJobContext(module.ID), module.ID)
+	if err != nil || t.Constraint == "" {
+		return nil, false
+	}
+	c, err := parseVersionConstraint(t.Constraint)
+	if err != nil {
+		return nil, false
+	}
+	return c, t.Prereleases
+}
+
+// fetchModuleTags updates the tags of a repository from origin. Tags moved
+// upstream are moved here too.
+func fetchModuleTags(module Module, repoDir string, gitEnv []string) error {
+	cmd := exec.Command("git", "-C", repoDir, "fetch", "--tags", "--force", "origin")
+	cmd.Env = append(os.Environ(), gitEnv...)
+	return runAndLog(module.ID, cmd)
+}
+
+// gitSemverTags lists the tags of a repository that parse as versions, with
+// the commit each one points to.
+func gitSemverTags(repoDir string) ([]moduleRelease, error) {
+	out, err := exec.Command("git", "-C", repoDir, "for-each-ref",
+		"--format=%(refname:strip=2)%00%(objectname)%00%(*objectname)", "refs/tags").Output()
+	if err != nil {
+		return nil, err
+	}
+	list := []moduleRelease{}
+	for _, line := range strings.Split(string(out), "\n") {
+		f := strings.Split(line, "\x00")
+		if len(f) != 3 {
+			continue
+		}
+		v, ok := parseSemver(f[0])
+		if !ok {
+			continue
+		}
+		// annotated tags point to a tag object, peeled to its commit
+		commit := f[2]
+		if commit == "" {
+			commit = f[1]
+		}
+		list = append(list, moduleRelease{Tag: f[0], Version: v, Commit: commit})
+	}
+	return list, nil
+}
+
+// latestRelease returns the highest tag matching c. On equal versions (v1.2.0
+// and 1.2.0) the first tag in name order wins, so the choice is stable.
+func latestRelease(tags []moduleRelease, c versionConstraint, prereleases bool) (moduleRelease, bool) {
+	var best moduleRelease
+	found := false
+	for _, t := range tags {
+		if !c.matches(t.Version, prereleases) {
+			continue
+		}
+		if !found || compareSemver(t.Version, best.Version) > 0 ||
+			(compareSemver(t.Version, best.Version) == 0 && t.Tag < best.Tag) {
+			best, found = t, true
+		}
+	}
+	return best, found
+}
+
+// currentRelease returns the highest version tag pointing at HEAD.
+func currentRelease(tags []moduleRelease, head string) (moduleRelease, bool) {
+	var best moduleRelease
+	found := false
+	for _, t := range tags {
+		if t.Commit != head {
+			continue
+		}
+		if !found || compareSemver(t.Version, best.Version) > 0 ||
+			(compareSemver(t.Version, best.Version) == 0 && t.Tag < best.Tag) {
+			best, found = t, true
+		}
+	}
+	return best, found
+}
+
+// applyReleaseSnapshot fills the version fields of patch for a module that
+// tracks releases: version is the tag at HEAD, latest_version the highest tag
+// matching c, and late_commits is non zero while they differ.
+func applyReleaseSnapshot(repoDir, head string, c versionConstraint, prereleases bool, patch *database.ModulePatch) {
+	tags, err := gitSemverTags(repoDir)
+	if err != nil {
+		return
+	}
+	current := ""
+	if t, ok := currentRelease(tags, head); ok {
+		current = t.Tag
+	}
+	patch.Version = &current
+	behind := 0
+	patch.LateCommits = &behind
+	rel, ok := latestRelease(tags, c, prereleases)
+	if !ok {
+		none := ""
+		patch.LatestVersion = &none
+		return
+	}
+	patch.LatestVersion = &rel.Tag
+	patch.LatestCommitHash = &rel.Commit
+	subjB, _ := exec.Command("git", "-C", repoDir, "log", "-1", "--pretty=%s", rel.Commit).Output()
+	if subj := string(bytesTrimSpace(subjB)); subj != "" {
+		patch.LatestCommitSubject = &subj
+	}
+	if rel.Commit != head {
+		cntB, _ := exec.Command("git", "-C", repoDir, "rev-list", "--count", "HEAD.."+rel.Commit).Output()
+		behind, _ = strconv.Atoi(string(bytesTrimSpace(cntB)))
+		// a release on another line of history is still an update
+		if behind == 0 {
+			behind = 1
+		}
+	}
+}
+
+// checkoutTrackedRelease checks out the tag stored in latest_version, detached,
+// for a module that tracks releases. It is a no-op for other modules and when
+// HEAD is already there.
+func checkoutTrackedRelease(module Module) error {
+	if c, _ := moduleReleaseConstraint(module); c == nil {
+		return nil
+	}
+	repoDir := repoDirFor(module)
+	_ = ensureSafeDirectory(module, repoDir)
+	fresh, err := GetModule(module.ID)
+	if err != nil {
+		return err
+	}
+	if fresh.LatestVersion == "" {
+		// never fetched since tracking was enabled
+		if err := GitFetchModule(fresh); err != nil {
+			return err
+		}
+		if fresh, err = GetModule(module.ID); err != nil {
+			return err
+		}
+	}
+	tag := fresh.LatestVersion
+	if tag == "" {
+		return LogModule(module.ID, "ERROR", "No tag matches the release constraint", nil,
+			fmt.Errorf("%w: no tag matches the release constraint", ErrConflict))
+	}
+	commitB, err := exec.Command("git", "-C", repoDir, "rev-parse", "--verify", "-q", "refs/tags/"+tag+"^{commit}").Output()
+	if err != nil {
+		return LogModule(module.ID, "ERROR", fmt.Sprintf("Tag %s not found", tag), nil, fmt.Errorf("%w: tag %s not found", ErrConflict, tag))
+	}
+	if string(bytesTrimSpace(commitB)) == headCommit(module) && currentBranch(repoDir) == "" {
+		return nil
+	}
+	if reason := gitTreeNotClean(module); reason != "" {
+		return LogModule(module.ID, "ERROR", fmt.Sprintf("Cannot check out %s", tag), nil, fmt.Errorf("%w: cannot check out %s, %s", ErrConflict, tag, reason))
+	}
+	LogModule(module.ID, "INFO", fmt.Sprintf("Checking out release %s", tag), nil, nil)
+	if err := runAndLog(module.ID, exec.Command("git", "-C", repoDir, "checkout", "-q", "--detach", "refs/tags/"+tag)); err != nil {
+		return LogModule(module.ID, "ERROR", fmt.Sprintf("git checkout %s failed", tag), nil, err)
+	}
+
+	gitEnv, cleanup, err := gitAuthForModule(module)
+	if err != nil {
+		return LogModule(module.ID, "ERROR", "failed to prepare git credentials", nil, err)
+	}
+	defer cleanup()
+	if err := syncModuleRepoExtras(module, repoDir, gitEnv); err != nil {
+		return err
+	}
+	_ = persistHeadCommit(module)
+	_ = updateGitComputed(module, nil, nil)
+	broadcastGitStatus(module)
+	return nil
+}
@@ -0,0 +1,206 @@
+package core
+
+import (
+	"backend/database"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+const maxModuleSecretSize = 64 * 1024
+
+// ModuleSecret is what the API exposes about a secret: never its value.
+type ModuleSecret struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func moduleSecretAAD(moduleID, name string) []byte {
+	return []byte("module-secret:" + moduleID + "/" + name)
+}
+
+func moduleSecretFromSealed(moduleID, name string, s sealedSecret) database.ModuleSecret {
+	return database.ModuleSecret{
+		ModuleID:   moduleID,
+		Name:       name,
+		Nonce:      s.Nonce,
+		Ciphertext: s.Ciphertext,
+		DataKey:    s.DataKey,
+		KeyVersion: sql.NullInt64{Int64: int64(s.KeyVersion), Valid: true},
+	}
+}
+
+func openModuleSecret(ring *masterKeyRing, s database.ModuleSecret) ([]byte, error) {
+	aad := moduleSecretAAD(s.ModuleID, s.Name)
+	if !s.KeyVersion.Valid {
+		// stored before envelope encryption, sealed directly with master key 1
+		key, err := ring.key(1)
+		if err != nil {
+			return nil, err
+		}
+		return openSecret(key, s.Nonce, s.Ciphertext, aad)
+	}
+	return ring.openEnvelope(sealedFromModuleSecret(s), aad)
+}
+
+func sealedFromModuleSecret(s database.ModuleSecret) sealedSecret {
+	return sealedSecret{
+		KeyVersion: int(s.KeyVersion.Int64),
+		DataKey:    s.DataKey,
+		Nonce:      s.Nonce,
+		Ciphertext: s.Ciphertext,
+	}
+}
+
+func ListModuleSecrets(ctx context.Context, moduleID string) ([]ModuleSecret, error) {
+	rows, err := database.ListModuleSecrets(ctx, moduleID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ModuleSecret, 0, len(rows))
+	for _, s := range rows {
+		out = append(out, ModuleSecret{Name: s.Name, CreatedAt: s.CreatedAt, UpdatedAt: s.UpdatedAt})
+	}
+	return out, nil
+}
+
+// SetModuleSecret encrypts and stores a secret, replacing any previous value.
+// It reports whether the secret was created.
+func SetModuleSecret(ctx context.Context, moduleID, name, value string) (ModuleSecret, bool, error) {
+	name = strings.TrimSpace(name)
+	if !manifestEnvNameRe.MatchString(name) {
+		return ModuleSecret{}, false, fmt.Errorf("%w: secret name must be a valid environment variable name", ErrInvalidInput)
+	}
+	if len(value) > maxModuleSecretSize {
+		return ModuleSecret{}, false, fmt.Errorf("%w: secret value is larger than %d bytes", ErrInvalidInput, maxModuleSecretSize)
+	}
+	ring, err := loadMasterKeyRing()
+	if err != nil {
+		return ModuleSecret{}, false, err
+	}
+	sealed, err := ring.sealEnvelope([]byte(value), moduleSecretAAD(moduleID, name))
+	if err != nil {
+		return ModuleSecret{}, false, fmt.Errorf("encrypt secret: %w", err)
+	}
+
+	stored, created, err := database.UpsertModuleSecret(ctx, moduleSecretFromSealed(moduleID, name, sealed))
+	if err != nil {
+		return ModuleSecret{}, false, err
+	}
+	action := "updated"
+	if created {
+		action = "created"
+	}
+	LogModule(moduleID, "INFO", fmt.Sprintf("Secret %s %s", name, action), nil, nil)
+	return ModuleSecret{Name: stored.Name, CreatedAt: stored.CreatedAt, UpdatedAt: stored.UpdatedAt}, created, nil
+}
+
+func DeleteModuleSecret(ctx context.Context, moduleID, name string) error {
+	if err := database.DeleteModuleSecret(ctx, moduleID, name); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	LogModule(moduleID, "INFO", fmt.Sprintf("Secret %s deleted", name), nil, nil)
+	return nil
+}
+
+// moduleSecretEnv decrypts every secret of a module into an env map.
+func moduleSecretEnv(ctx context.Context, moduleID string) (map[string]string, error) {
+	rows, err := database.ListModuleSecrets(ctx, moduleID)
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string, len(rows))
+	if len(rows) == 0 {
+		return env, nil
+	}
+	ring, err := loadMasterKeyRing()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range rows {
+		plain, err := openModuleSecret(ring, s)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt secret %s: %w", s.Name, err)
+		}
+		env[s.Name] = string(plain)
+	}
+	return env, nil
+}
+
+func moduleSecretsDir() string {
+	if dir := strings.TrimSpace(os.Getenv("MODULE_SECRETS_DIR")); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "pan-bagnat-secrets")
+}
+
+// composeFileArgs returns the -f arguments for docker compose. When the module
+// has secrets, a compose override injecting them into every service is written
+// outside the repo; cleanup removes it once compose has created the containers.
+func composeFileArgs(module Module, dir, file string) ([]string, func(), error) {
+	args := []string{"-f", file}
+	noop := func() {}
+
+	env, err := moduleSecretEnv(context.Background(), module.ID)
+	if err != nil {
+		return nil, noop, err
+	}
+	if len(env) == 0 {
+		return args, noop, nil
+	}
+
+	cmd := exec.Command("docker", "compose", "-f", file, "config", "--services")
+	cmd.Dir = dir
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return nil, noop, fmt.Errorf("list compose services: %w", err)
+	}
+
+	// Compose interpolates $ in override files too, so escape it
+	escaped := make(map[string]string, len(env))
+	for k, v := range env {
+		escaped[k] = strings.ReplaceAll(v, "$", "$$")
+	}
+	services := map[string]any{}
+	for _, svc := range strings.Fields(out.String()) {
+		services[svc] = map[string]any{"environment": escaped}
+	}
+	data, err := yaml.Marshal(map[string]any{"services": services})
+	if err != nil {
+		return nil, noop, err
+	}
+
+	secretsDir := moduleSecretsDir()
+	if err := os.MkdirAll(secretsDir, 0o700); err != nil {
+		return nil, noop, err
+	}
+	f, err := os.CreateTemp(secretsDir, module.Slug+"-*.override.yml")
+	if err != nil {
+		return nil, noop, err
+	}
+	cleanup := func() { _ = os.Remove(f.Name()) }
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		cleanup()
+		return nil, noop, err
+	}
+	if err := f.Close(); err != nil {
+		cleanup()
+		return nil, noop, err
+	}
+	return append(args, "-f", f.Name()), cleanup, nil
+}
@@ -0,0 +1,413 @@
+package core
+
+import (
+	"backend/database"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Placeholders replaced in the file contents and paths of a template.
+const (
+	TemplateVarName = "{{module_name}}"
+	TemplateVarSlug = "{{module_slug}}"
+	TemplateVarPort = "{{module_port}}"
+)
+
+// templateMaxFileSize bounds the files variables are substituted in. Larger
+// files are copied as they are.
+const templateMaxFileSize = 2 << 20
+
+// templateCommitAuthor signs the first commit of a module created from a template.
+const templateCommitAuthor = "Pan Bagnat"
+
+type ModuleTemplate struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	GitURL      string    `json:"git_url"`
+	GitBranch   string    `json:"git_branch"`
+	SSHKeyID    string    `json:"ssh_key_id,omitempty"`
+	DefaultPort int       `json:"default_port"`
+	ActorUserID string    `json:"actor_user_id,omitempty"`
+	ActorLogin  string    `json:"actor_login,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ModuleFromTemplate describes the module to create from a template.
+type ModuleFromTemplate struct {
+	Name string
+	// Port defaults to the template's default port.
+	Port int
+	// GitURL is set as origin when given. It should be an empty repository
+	// for the push to succeed.
+	GitURL    string
+	GitBranch string
+	SSHKeyID  string
+	// Push pushes the first commit to GitURL with the module's SSH key.
+	Push bool
+}
+
+func dbTemplateToTemplate(t database.ModuleTemplate) ModuleTemplate {
+	return ModuleTemplate{
+		ID:          t.ID,
+		Name:        t.Name,
+		Description: t.Description,
+		GitURL:      t.GitURL,
+		GitBranch:   t.GitBranch,
+		SSHKeyID:    t.SSHKeyID.String,
+		DefaultPort: t.DefaultPort,
+		ActorUserID: t.ActorUserID.String,
+		ActorLogin:  t.ActorLogin.String,
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+	}
+}
+
+func ListModuleTemplates(ctx context.Context) ([]ModuleTemplate, error) {
+	rows, err := database.ListModuleTemplates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ModuleTemplate, 0, len(rows))
+	for _, t := range rows {
+		out = append(out, dbTemplateToTemplate(t))
+	}
+	return out, nil
+}
+
+func GetModuleTemplate(ctx context.Context, id string) (ModuleTemplate, error) {
+	t, err := database.GetModuleTemplate(ctx, id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ModuleTemplate{}, ErrNotFound
+		}
+		return ModuleTemplate{}, err
+	}
+	return dbTemplateToTemplate(t), nil
+}
+
+// SaveModuleTemplate registers a template, or updates it when t.ID is set.
+func SaveModuleTemplate(ctx context.Context, t ModuleTemplate, actor *User) (ModuleTemplate, error) {
+	if err := validateModuleTemplate(&t); err != nil {
+		return ModuleTemplate{}, err
+	}
+	if t.SSHKeyID != "" {
+		if _, err := GetSSHKey(t.SSHKeyID); err != nil {
+			return ModuleTemplate{}, fmt.Errorf("%w: unknown ssh key %q", ErrInvalidInput, t.SSHKeyID)
+		}
+	}
+	row := database.ModuleTemplate{
+		ID:          t.ID,
+		Name:        t.Name,
+		Description: t.Description,
+		GitURL:      t.GitURL,
+		GitBranch:   t.GitBranch,
+		SSHKeyID:    sql.NullString{String: t.SSHKeyID, Valid: t.SSHKeyID != ""},
+		DefaultPort: t.DefaultPort,
+	}
+	if actor != nil && actor.ID != "" {
+		row.ActorUserID = sql.NullString{String: actor.ID, Valid: true}
+	}
+
+	var (
+		stored database.ModuleTemplate
+		err    error
+	)
+	if t.ID == "" {
+		stored, err = database.InsertModuleTemplate(ctx, row)
+	} else {
+		stored, err = database.UpdateModuleTemplate(ctx, row)
+	}
+	switch {
+	case errors.Is(err, database.ErrAlreadyExists):
+		return ModuleTemplate{}, fmt.Errorf("%w: a template named %q already exists", ErrConflict, t.Name)
+	case errors.Is(err, database.ErrNotFound):
+		return ModuleTemplate{}, ErrNotFound
+	case err != nil:
+		return ModuleTemplate{}, err
+	}
+	return dbTemplateToTemplate(stored), nil
+}
+
+func DeleteModuleTemplate(ctx context.Context, id string) error {
+	if err := database.DeleteModuleTemplate(ctx, id); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func validateModuleTemplate(t *ModuleTemplate) error {
+	t.Name = strings.TrimSpace(t.Name)
+	t.Description = strings.TrimSpace(t.Description)
+	t.GitURL = strings.TrimSpace(t.GitURL)
+	t.GitBranch = strings.TrimSpace(t.GitBranch)
+	t.SSHKeyID = strings.TrimSpace(t.SSHKeyID)
+	if t.Name == "" || t.GitURL == "" {
+		return fmt.Errorf("%w: name and git_url are required", ErrInvalidInput)
+	}
+	if strings.HasPrefix(t.GitURL, "-") || strings.HasPrefix(t.GitBranch, "-") {
+		return fmt.Errorf("%w: invalid git_url or git_branch", ErrInvalidInput)
+	}
+	if t.GitBranch == "" {
+		t.GitBranch = "main"
+	}
+	if t.DefaultPort == 0 {
+		t.DefaultPort = 80
+	}
+	if t.DefaultPort < 1 || t.DefaultPort > 65535 {
+		return fmt.Errorf("%w: default_port must be between 1 and 65535", ErrInvalidInput)
+	}
+	return nil
+}
+
+// CreateModuleFromTemplate registers a module and queues the job that builds
+// its repository from the template. The module has no repository until the
+// job is done.
+func CreateModuleFromTemplate(ctx context.Context, templateID string, in ModuleFromTemplate, actor *User) (Module, ModuleJob, error) {
+	tpl, err := GetModuleTemplate(ctx, templateID)
+	if err != nil {
+		return Module{}, ModuleJob{}, err
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	in.GitURL = strings.TrimSpace(in.GitURL)
+	in.GitBranch = strings.TrimSpace(in.GitBranch)
+	if in.Name == "" {
+		return Module{}, ModuleJob{}, fmt.Errorf("%w: name is required", ErrInvalidInput)
+	}
+	if in.GitBranch == "" {
+		in.GitBranch = "main"
+	}
+	if strings.HasPrefix(in.GitURL, "-") || strings.HasPrefix(in.GitBranch, "-") {
+		return Module{}, ModuleJob{}, fmt.Errorf("%w: invalid git_url or git_branch", ErrInvalidInput)
+	}
+	if in.Port == 0 {
+		in.Port = tpl.DefaultPort
+	}
+	if in.Port < 1 || in.Port > 65535 {
+		return Module{}, ModuleJob{}, fmt.Errorf("%w: port must be between 1 and 65535", ErrInvalidInput)
+	}
+	if in.Push && in.GitURL == "" {
+		return Module{}, ModuleJob{}, fmt.Errorf("%w: git_url is required to push", ErrInvalidInput)
+	}
+	in.SSHKeyID = strings.TrimSpace(in.SSHKeyID)
+	if in.SSHKeyID != "" {
+		if _, err := GetSSHKey(in.SSHKeyID); err != nil {
+			return Module{}, ModuleJob{}, fmt.Errorf("%w: unknown ssh key %q", ErrInvalidInput, in.SSHKeyID)
+		}
+	}
+
+	module, err := ImportModule(actor, in.Name, in.GitURL, in.GitBranch, in.SSHKeyID)
+	if err != nil {
+		return Module{}, ModuleJob{}, err
+	}
+	LogModule(module.ID, "INFO", fmt.Sprintf("Module created from template %s", tpl.Name), map[string]any{
+		"template_id": tpl.ID,
+		"port":        in.Port,
+		"push":        in.Push,
+	}, nil)
+
+	job, err := EnqueueModuleJob(ctx, module, ModuleJobClone, ModuleJobParams{
+		TemplateID:   tpl.ID,
+		TemplatePort: in.Port,
+		Push:         in.Push,
+	}, actor)
+	if err != nil {
+		return module, ModuleJob{}, err
+	}
+	return module, job, nil
+}
+
+// InitModuleRepoFromTemplate builds the repository of a module from a
+// template: the template is cloned, its variables substituted, and the
+// result committed to a fresh repository. The first commit is pushed to the
+// module's remote when push is set.
+func InitModuleRepoFromTemplate(module Module, templateID string, port int, push bool) error {
+	tpl, err := GetModuleTemplate(moduleJobContext(module.ID), templateID)
+	if err != nil {
+		return LogModule(module.ID, "ERROR", "template not found", nil, err)
+	}
+	targetDir, err := ModuleRepoPath(module)
+	if err != nil {
+		return LogModule(module.ID, "ERROR", "invalid module slug", nil, err)
+	}
+	if _, err := os.Stat(targetDir); err == nil {
+		return LogModule(module.ID, "ERROR", "repository already exists", nil, fmt.Errorf("%w: %s", ErrConflict, targetDir))
+	}
+	LogModule(module.ID, "INFO", fmt.Sprintf("Creating repos/%s from template %s", module.Slug, tpl.Name), nil, nil)
+
+	workDir, err := os.MkdirTemp("", module.Slug+"-template-")
+	if err != nil {
+		return LogModule(module.ID, "ERROR", "failed to create work directory", nil, err)
+	}
+	defer os.RemoveAll(workDir)
+	srcDir := filepath.Join(workDir, "src")
+
+	cmd := exec.Command("git", "clone", "--depth", "1", "-b", tpl.GitBranch, tpl.GitURL, srcDir)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if tpl.SSHKeyID != "" {
+		sshCommand, cleanup, err := tempSSHForModule(Module{SSHKeyID: tpl.SSHKeyID})
+		if err != nil {
+			return LogModule(module.ID, "ERROR", "failed to prepare temp ssh key", nil, err)
+		}
+		defer cleanup()
+		cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND="+sshCommand)
+	}
+	if err := runAndLog(module.ID, cmd); err != nil {
+		return LogModule(module.ID, "ERROR", "template clone failed", nil, err)
+	}
+
+	vars := map[string]string{
+		TemplateVarName: module.Name,
+		TemplateVarSlug: module.Slug,
+		TemplateVarPort: strconv.Itoa(port),
+	}
+	if err := renderTemplateTree(srcDir, targetDir, vars); err != nil {
+		_ = os.RemoveAll(targetDir)
+		return LogModule(module.ID, "ERROR", "failed to render template", nil, err)
+	}
+
+	commitMsg := fmt.Sprintf("Create %s from template %s", module.Name, tpl.Name)
+	steps := [][]string{
+		{"init", "-q", "-b", module.GitBranch},
+		{"add", "-A"},
+		{"-c", "user.name=" + templateCommitAuthor, "-c", "user.email=pan-bagnat@localhost", "commit", "-q", "-m", commitMsg},
+	}
+	if module.GitURL != "" {
+		steps = append(steps, []string{"remote", "add", "origin", module.GitURL})
+	}
+	for _, args := range steps {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = targetDir
+		if err := runAndLog(module.ID, cmd); err != nil {
+			_ = os.RemoveAll(targetDir)
+			return LogModule(module.ID, "ERROR", "failed to initialize repository", nil, err)
+		}
+	}
+
+	var pushErr error
+	if push && module.GitURL != "" {
+		pushErr = pushTemplateRepo(module, targetDir)
+	}
+
+	if err := finishModuleClone(module, targetDir); err != nil {
+		return err
+	}
+	if _, err := ApplyModuleManifest(module, ""); err != nil && !errors.Is(err, ErrNotFound) {
+		LogModule(module.ID, "WARN", "Template manifest was not applied; review it from the manifest tab", nil, err)
+	}
+	if pushErr != nil {
+		return LogModule(module.ID, "ERROR", "push to the new remote failed; add the module's deploy key with write access and push again", nil, pushErr)
+	}
+	return nil
+}
+
+func pushTemplateRepo(module Module, repoDir string) error {
+	gitEnv, cleanup, err := gitAuthForModule(module)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	cmd := exec.Command("git", "push", "-u", "origin", module.GitBranch)
+	cmd.Dir = repoDir
+	cmd.Env = append(os.Environ(), gitEnv...)
+	return runAndLog(module.ID, cmd)
+}
+
+// renderTemplateTree copies src to dst, skipping .git, with the template
+// variables substituted in paths and in text files.
+func renderTemplateTree(src, dst string, vars map[string]string) error {
+	pairs := make([]string, 0, len(vars)*2)
+	for k, v := range vars {
+		pairs = append(pairs, k, v)
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return os.MkdirAll(dst, 0o755)
+		}
+		if d.Name() == ".git" {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		parts := strings.Split(rel, string(filepath.Separator))
+		for i, p := range parts {
+			p = replacer.Replace(p)
+			if p == "" || p == "." || p == ".." || strings.ContainsAny(p, `/\`) {
+				return fmt.Errorf("%w: %s renders to an invalid path", ErrInvalidInput, rel)
+			}
+			parts[i] = p
+		}
+		out := filepath.Join(append([]string{dst}, parts...)...)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(out, info.Mode().Perm()|0o700)
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(target, out)
+		case !info.Mode().IsRegular():
+			return nil
+		}
+		return renderTemplateFile(path, out, info, replacer)
+	})
+}
+
+func renderTemplateFile(path, out string, info fs.FileInfo, replacer *strings.Replacer) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if info.Size() > templateMaxFileSize {
+		f, err := os.OpenFile(out, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, in); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	// Files with a NUL byte are treated as binary and left alone
+	if bytes.IndexByte(data, 0) < 0 {
+		data = []byte(replacer.Replace(string(data)))
+	}
+	return os.WriteFile(out, data, info.Mode().Perm())
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderTemplateTree(t *testing.T) {
+	src := t.TempDir()
+	dst := filepath.Join(t.TempDir(), "out")
+	write := func(rel string, data []byte, mode os.FileMode) {
+		t.Helper()
+		p := filepath.Join(src, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, data, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("docker-compose.yml", []byte("services:\n  {{module_slug}}:\n    ports: [\"{{module_port}}:80\"]\n"), 0o644)
+	write("{{module_slug}}/README.md", []byte("# {{module_name}}\n"), 0o644)
+	write("run.sh", []byte("#!/bin/sh\necho {{module_slug}}\n"), 0o755)
+	write("logo.png", []byte("\x89PNG\x00{{module_slug}}"), 0o644)
+	write(".git/HEAD", []byte("ref: refs/heads/main\n"), 0o644)
+
+	vars := map[string]string{TemplateVarName: "My Wiki", TemplateVarSlug: "my-wiki", TemplateVarPort: "3000"}
+	if err := renderTemplateTree(src, dst, vars); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(rel string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dst, rel))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if got := read("docker-compose.yml"); got != "services:\n  my-wiki:\n    ports: [\"3000:80\"]\n" {
+		t.Errorf("compose not rendered: %q", got)
+	}
+	if got := read("my-wiki/README.md"); got != "# My Wiki\n" {
+		t.Errorf("renamed file not rendered: %q", got)
+	}
+	if got := read("logo.png"); got != "\x89PNG\x00{{module_slug}}" {
+		t.Errorf("binary file changed: %q", got)
+	}
+	if info, err := os.Stat(filepath.Join(dst, "run.sh")); err != nil || info.Mode().Perm()&0o100 == 0 {
+		t.Errorf("file mode not kept: %v %v", info, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, ".git")); !os.IsNotExist(err) {
+		t.Errorf(".git copied: %v", err)
+	}
+}
+
+func TestRenderTemplateTreeRejectsEscapingPaths(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "{{module_name}}.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]string{TemplateVarName: "../../etc/passwd"}
+	err := renderTemplateTree(src, filepath.Join(t.TempDir(), "out"), vars)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("want ErrInvalidInput, got %v", err)
+	}
+}
+
+func TestValidateModuleTemplate(t *testing.T) {
+	tpl := ModuleTemplate{Name: " Static site ", GitURL: "git@github.com:org/static.git"}
+	if err := validateModuleTemplate(&tpl); err != nil {
+		t.Fatal(err)
+	}
+	if tpl.Name != "Static site" || tpl.GitBranch != "main" || tpl.DefaultPort != 80 {
+		t.Errorf("defaults not applied: %+v", tpl)
+	}
+
+	bad := []ModuleTemplate{
+		{Name: "x"},
+		{Name: "x", GitURL: "--upload-pack=evil"},
+		{Name: "x", GitURL: "git@host:r.git", DefaultPort: 70000},
+	}
+	for _, b := range bad {
+		if err := validateModuleTemplate(&b); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%+v: want ErrInvalidInput, got %v", b, err)
+		}
+	}
+}
@@ -0,0 +1,483 @@
+package core
+
+import (
+	"backend/database"
+	"backend/websocket"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	UpdateModeManual         = "manual"
+	UpdateModeNotify         = "notify"
+	UpdateModeAutoPull       = "auto_pull"
+	UpdateModeAutoPullDeploy = "auto_pull_deploy"
+)
+
+// Outcomes of an update check, stored as last_result.
+const (
+	UpdateResultUpToDate      = "up_to_date"
+	UpdateResultNotified      = "notified"
+	UpdateResultPulled        = "pulled"
+	UpdateResultDeployed      = "deployed"
+	UpdateResultOutsideWindow = "skipped_outside_window"
+	UpdateResultDirty         = "skipped_dirty"
+	UpdateResultDeploying     = "skipped_deploying"
+	UpdateResultPaused        = "skipped_paused"
+	UpdateResultError         = "error"
+)
+
+const minUpdateCheckInterval = 5 * time.Minute
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// MaintenanceWindow allows automatic pulls/deploys between Start and End
+// (HH:MM, policy timezone) on the listed days. An End before Start spans
+// midnight; Days refer to the day the window opens. No days means every day.
+type MaintenanceWindow struct {
+	Days  []string `json:"days,omitempty" example:"mon,tue"`
+	Start string   `json:"start" example:"02:00"`
+	End   string   `json:"end" example:"05:00"`
+}
+
+// ModuleUpdatePolicy controls what the scheduler does when a module's branch
+// is behind its upstream.
+type ModuleUpdatePolicy struct {
+	ModuleID             string              `json:"module_id"`
+	Mode                 string              `json:"mode"`
+	CheckIntervalSeconds int                 `json:"check_interval_seconds"`
+	Timezone             string              `json:"timezone"`
+	MaintenanceWindows   []MaintenanceWindow `json:"maintenance_windows"`
+	LastCheckedAt        *time.Time          `json:"last_checked_at,omitempty"`
+	LastResult           string              `json:"last_result,omitempty"`
+	LastError            string              `json:"last_error,omitempty"`
+	PausedAt             *time.Time          `json:"paused_at,omitempty"`
+	PausedReason         string              `json:"paused_reason,omitempty"`
+	UpdatedAt            *time.Time          `json:"updated_at,omitempty"`
+
+	lastNotifiedHash string
+}
+
+var (
+	moduleUpdateOnce sync.Once
+	// moduleUpdateLocks serializes the checks of each module; checks of
+	// different modules run side by side.
+	moduleUpdateLocksMu sync.Mutex
+	moduleUpdateLocks   = map[string]*sync.Mutex{}
+)
+
+func moduleUpdateLock(moduleID string) *sync.Mutex {
+	moduleUpdateLocksMu.Lock()
+	defer moduleUpdateLocksMu.Unlock()
+	lock, ok := moduleUpdateLocks[moduleID]
+	if !ok {
+		lock = &sync.Mutex{}
+		moduleUpdateLocks[moduleID] = lock
+	}
+	return lock
+}
+
+func defaultModuleUpdatePolicy(moduleID string) ModuleUpdatePolicy {
+	return ModuleUpdatePolicy{
+		ModuleID:             moduleID,
+		Mode:                 UpdateModeManual,
+		CheckIntervalSeconds: 3600,
+		Timezone:             "UTC",
+		MaintenanceWindows:   []MaintenanceWindow{},
+	}
+}
+
+func dbUpdatePolicyToUpdatePolicy(p database.ModuleUpdatePolicy) ModuleUpdatePolicy {
+	out := ModuleUpdatePolicy{
+		ModuleID:             p.ModuleID,
+		Mode:                 p.Mode,
+		CheckIntervalSeconds: p.CheckInterval,
+		Timezone:             p.Timezone,
+		MaintenanceWindows:   []MaintenanceWindow{},
+		LastResult:           p.LastResult,
+		LastError:            p.LastError,
+		lastNotifiedHash:     p.LastNotifiedHash,
+	}
+	if len(p.MaintenanceWindows) > 0 {
+		_ = json.Unmarshal(p.MaintenanceWindows, &out.MaintenanceWindows)
+	}
+	if p.LastCheckedAt.Valid {
+		t := p.LastCheckedAt.Time
+		out.LastCheckedAt = &t
+	}
+	if p.PausedAt.Valid {
+		t := p.PausedAt.Time
+		out.PausedAt = &t
+		out.PausedReason = p.PausedReason
+	}
+	if !p.UpdatedAt.IsZero() {
+		t := p.UpdatedAt
+		out.UpdatedAt = &t
+	}
+	return out
+}
+
+// GetModuleUpdatePolicy returns the module policy, or the manual default when
+// none was set.
+func GetModuleUpdatePolicy(ctx context.Context, moduleID string) (ModuleUpdatePolicy, error) {
+	p, err := database.GetModuleUpdatePolicy(ctx, moduleID)
+	if errors.Is(err, database.ErrNotFound) {
+		return defaultModuleUpdatePolicy(moduleID), nil
+	}
+	if err != nil {
+		return ModuleUpdatePolicy{}, err
+	}
+	return dbUpdatePolicyToUpdatePolicy(p), nil
+}
+
+// SetModuleUpdatePolicy validates and stores the policy settings.
+func SetModuleUpdatePolicy(ctx context.Context, policy ModuleUpdatePolicy) (ModuleUpdatePolicy, error) {
+	if err := validateModuleUpdatePolicy(&policy); err != nil {
+		return ModuleUpdatePolicy{}, err
+	}
+	windows, err := json.Marshal(policy.MaintenanceWindows)
+	if err != nil {
+		return ModuleUpdatePolicy{}, err
+	}
+	stored, err := database.UpsertModuleUpdatePolicy(ctx, database.ModuleUpdatePolicy{
+		ModuleID:           policy.ModuleID,
+		Mode:               policy.Mode,
+		CheckInterval:      policy.CheckIntervalSeconds,
+		Timezone:           policy.Timezone,
+		MaintenanceWindows: windows,
+	})
+	if err != nil {
+		return ModuleUpdatePolicy{}, err
+	}
+	LogModule(policy.ModuleID, "INFO", fmt.Sprintf("Update policy set to %s", policy.Mode), map[string]any{
+		"check_interval_seconds": policy.CheckIntervalSeconds,
+		"maintenance_windows":    len(policy.MaintenanceWindows),
+	}, nil)
+	return dbUpdatePolicyToUpdatePolicy(stored), nil
+}
+
+func validateModuleUpdatePolicy(p *ModuleUpdatePolicy) error {
+	p.Mode = strings.ToLower(strings.TrimSpace(p.Mode))
+	switch p.Mode {
+	case UpdateModeManual, UpdateModeNotify, UpdateModeAutoPull, UpdateModeAutoPullDeploy:
+	default:
+		return fmt.Errorf("%w: mode must be one of manual, notify, auto_pull, auto_pull_deploy", ErrInvalidInput)
+	}
+	if p.CheckIntervalSeconds == 0 {
+		p.CheckIntervalSeconds = 3600
+	}
+	if time.Duration(p.CheckIntervalSeconds)*time.Second < minUpdateCheckInterval {
+		return fmt.Errorf("%w: check_interval_seconds must be at least %d", ErrInvalidInput, int(minUpdateCheckInterval.Seconds()))
+	}
+	p.Timezone = strings.TrimSpace(p.Timezone)
+	if p.Timezone == "" {
+		p.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(p.Timezone); err != nil {
+		return fmt.Errorf("%w: unknown timezone %q", ErrInvalidInput, p.Timezone)
+	}
+	if p.MaintenanceWindows == nil {
+		p.MaintenanceWindows = []MaintenanceWindow{}
+	}
+	for i := range p.MaintenanceWindows {
+		w := &p.MaintenanceWindows[i]
+		start, errStart := parseClock(w.Start)
+		end, errEnd := parseClock(w.End)
+		if errStart != nil || errEnd != nil {
+			return fmt.Errorf("%w: maintenance window %d: start and end must be HH:MM", ErrInvalidInput, i+1)
+		}
+		if start == end {
+			return fmt.Errorf("%w: maintenance window %d is empty", ErrInvalidInput, i+1)
+		}
+		for j, d := range w.Days {
+			d = strings.ToLower(strings.TrimSpace(d))
+			if len(d) > 3 {
+				d = d[:3]
+			}
+			if _, ok := weekdayNames[d]; !ok {
+				return fmt.Errorf("%w: maintenance window %d: unknown day %q", ErrInvalidInput, i+1, w.Days[j])
+			}
+			w.Days[j] = d
+		}
+	}
+	return nil
+}
+
+// parseClock returns the minutes since midnight of an HH:MM string.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// inMaintenanceWindow reports whether automatic actions may run at now. A
+// policy without windows is always open.
+func inMaintenanceWindow(windows []MaintenanceWindow, loc *time.Location, now time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	for _, w := range windows {
+		start, err1 := parseClock(w.Start)
+		end, err2 := parseClock(w.End)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		day := local.Weekday()
+		var open bool
+		if start < end {
+			open = minute >= start && minute < end
+		} else if minute >= start {
+			open = true
+		} else if minute < end {
+			// early part of a window that opened the day before
+			open = true
+			day = (day + 6) % 7
+		}
+		if open && windowHasDay(w.Days, day) {
+			return true
+		}
+	}
+	return false
+}
+
+func windowHasDay(days []string, day time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		if weekdayNames[d] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// StartModuleUpdateScheduler looks for due update policies every
+// MODULE_UPDATE_TICK (default 1m; 0 disables the scheduler).
+func StartModuleUpdateScheduler() {
+	tick := envDuration("MODULE_UPDATE_TICK", time.Minute)
+	if tick <= 0 {
+		return
+	}
+	moduleUpdateOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(tick)
+			defer ticker.Stop()
+			for range ticker.C {
+				runDueModuleUpdates(context.Background())
+			}
+		}()
+	})
+}
+
+func runDueModuleUpdates(ctx context.Context) {
+	policies, err := database.ListDueModuleUpdatePolicies(ctx, time.Now())
+	if err != nil {
+		log.Printf("[module-updates] list due policies: %v", err)
+		return
+	}
+	for _, p := range policies {
+		// A check can wait for a long deploy: run each module on its own and
+		// skip those whose previous check is still going.
+		lock := moduleUpdateLock(p.ModuleID)
+		if !lock.TryLock() {
+			continue
+		}
+		go func(moduleID string) {
+			defer lock.Unlock()
+			if _, err := runModuleUpdateCheck(ctx, moduleID); err != nil {
+				log.Printf("[module-updates] %s: %v", moduleID, err)
+			}
+		}(p.ModuleID)
+	}
+}
+
+// RunModuleUpdateCheck fetches the module and applies its policy once. The
+// outcome is stored on the policy and returned. It waits for a check of the
+// same module already in progress, not for other modules.
+func RunModuleUpdateCheck(ctx context.Context, moduleID string) (ModuleUpdatePolicy, error) {
+	lock := moduleUpdateLock(moduleID)
+	lock.Lock()
+	defer lock.Unlock()
+	return runModuleUpdateCheck(ctx, moduleID)
+}
+
+func runModuleUpdateCheck(ctx context.Context, moduleID string) (ModuleUpdatePolicy, error) {
+	policy, err := GetModuleUpdatePolicy(ctx, moduleID)
+	if err != nil {
+		return ModuleUpdatePolicy{}, err
+	}
+	module, err := GetModule(moduleID)
+	if err != nil {
+		return policy, err
+	}
+
+	now := time.Now().UTC()
+	result, notified, checkErr := applyModuleUpdatePolicy(module, policy, now)
+	policy.LastCheckedAt = &now
+	policy.LastResult = result
+	policy.LastError = ""
+	if checkErr != nil {
+		policy.LastError = checkErr.Error()
+	}
+	if notified != "" {
+		policy.lastNotifiedHash = notified
+	}
+	// no-op for modules still on the implicit manual default
+	if err := database.RecordModuleUpdateCheck(ctx, moduleID, now, result, policy.LastError, policy.lastNotifiedHash); err != nil {
+		return policy, err
+	}
+	return policy, nil
+}
+
+// applyModuleUpdatePolicy does the actual work of a check and returns the
+// result, the commit hash that was announced (if any) and the failure cause.
+func applyModuleUpdatePolicy(module Module, policy ModuleUpdatePolicy, now time.Time) (string, string, error) {
+	if err := GitFetchModule(module); err != nil {
+		return UpdateResultError, "", err
+	}
+	refreshed, err := GetModule(module.ID)
+	if err != nil {
+		return UpdateResultError, "", err
+	}
+	module = refreshed
+	if module.LateCommits == 0 {
+		return UpdateResultUpToDate, "", nil
+	}
+
+	// Announce each upstream commit once, whatever happens next
+	notified := ""
+	if module.LatestCommitHash != policy.lastNotifiedHash {
+		notified = module.LatestCommitHash
+		msg := fmt.Sprintf("Update available: %d new commit(s) on %s", module.LateCommits, module.GitBranch)
+		if module.LatestVersion != "" {
+			msg = fmt.Sprintf("Update available: release %s", module.LatestVersion)
+		}
+		LogModule(module.ID, "INFO", msg,
+			map[string]any{"latest_commit": module.LatestCommitHash, "latest_subject": module.LatestCommitSubject}, nil)
+		websocket.SendGenericModuleEvent(module.ID, "module_update_available", map[string]any{
+			"module_id":      module.ID,
+			"module_name":    module.Name,
+			"behind":         module.LateCommits,
+			"latest_hash":    module.LatestCommitHash,
+			"latest_subject": module.LatestCommitSubject,
+			"latest_version": module.LatestVersion,
+			"mode":           policy.Mode,
+		})
+	}
+	if result := updatePolicyHold(policy, now); result != "" {
+		return result, notified, nil
+	}
+	if busy, err := database.ModuleHasActiveJobs(context.Background(), module.ID); err != nil {
+		return UpdateResultError, notified, err
+	} else if busy {
+		return UpdateResultDeploying, notified, nil
+	}
+	if reason := gitTreeNotClean(module); reason != "" {
+		LogModule(module.ID, "WARN", "Automatic update skipped: "+reason, nil, nil)
+		return UpdateResultDirty, notified, nil
+	}
+
+	// Pull (and deploy) through the job queue so it never overlaps another operation
+	deploy := policy.Mode == UpdateModeAutoPullDeploy
+	LogModule(module.ID, "INFO", "Automatic update: pulling", nil, nil)
+	_, err = RunModuleJob(context.Background(), module, ModuleJobPull, ModuleJobParams{Deploy: deploy, Source: DeploySourceAutoUpdate}, nil)
+	switch {
+	case err != nil && !errors.Is(err, ErrJobCancelled) && gitTreeNotClean(module) != "":
+		// the pull left conflicts behind, so the deploy was skipped
+		return UpdateResultDirty, notified, nil
+	case err != nil:
+		return UpdateResultError, notified, err
+	case !deploy:
+		return UpdateResultPulled, notified, nil
+	}
+	return UpdateResultDeployed, notified, nil
+}
+
+// updatePolicyHold returns the result of a check that must stop after
+// announcing the update, or "" when the policy allows pulling now.
+func updatePolicyHold(policy ModuleUpdatePolicy, now time.Time) string {
+	if policy.Mode == UpdateModeNotify || policy.Mode == UpdateModeManual {
+		return UpdateResultNotified
+	}
+	if policy.PausedAt != nil {
+		return UpdateResultPaused
+	}
+	loc, err := time.LoadLocation(policy.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	if !inMaintenanceWindow(policy.MaintenanceWindows, loc, now) {
+		return UpdateResultOutsideWindow
+	}
+	return ""
+}
+
+// PauseModuleUpdates stops automatic pulls and deploys of a module until
+// ResumeModuleUpdates is called. Checks keep announcing new commits.
+func PauseModuleUpdates(ctx context.Context, moduleID, reason string) error {
+	if err := database.PauseModuleUpdates(ctx, moduleID, reason); err != nil {
+		return err
+	}
+	LogModule(moduleID, "WARN", "Automatic updates paused: "+reason, nil, nil)
+	return nil
+}
+
+// ResumeModuleUpdates lets the update policy of a module pull and deploy again.
+func ResumeModuleUpdates(ctx context.Context, moduleID string, actor *User) (ModuleUpdatePolicy, error) {
+	resumed, err := database.ResumeModuleUpdates(ctx, moduleID)
+	if err != nil {
+		return ModuleUpdatePolicy{}, err
+	}
+	if !resumed {
+		return ModuleUpdatePolicy{}, fmt.Errorf("%w: automatic updates are not paused", ErrConflict)
+	}
+	meta := map[string]any{}
+	if actor != nil {
+		meta["actor"] = actor.FtLogin
+	}
+	LogModule(moduleID, "INFO", "Automatic updates resumed", meta, nil)
+	return GetModuleUpdatePolicy(ctx, moduleID)
+}
+
+// gitTreeNotClean explains why the working tree must not be touched
+// automatically, or returns "" when it is clean.
+func gitTreeNotClean(module Module) string {
+	repoDir := repoDirFor(module)
+	if _, err := os.Stat(filepath.Join(repoDir, ".git", "MERGE_HEAD")); err == nil {
+		return "a merge is in progress"
+	}
+	out, err := exec.Command("git", "-C", repoDir, "status", "--porcelain").CombinedOutput()
+	if err != nil {
+		return "git status failed"
+	}
+	for _, line := range splitLines(string(out)) {
+		if len(line) < 2 {
+			continue
+		}
+		switch line[:2] {
+		case "DD", "AU", "UD", "UA", "DU", "AA", "UU":
+			return "the working tree has conflicts"
+		}
+	}
+	if len(bytesTrimSpace(out)) > 0 {
+		return "the working tree has local changes"
+	}
+	return ""
+}
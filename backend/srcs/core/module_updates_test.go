@@ -0,0 +1,107 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidateModuleUpdatePolicy(t *testing.T) {
+	p := ModuleUpdatePolicy{Mode: "Auto_Pull", MaintenanceWindows: []MaintenanceWindow{{Days: []string{"Monday", "fri"}, Start: "22:00", End: "02:00"}}}
+	if err := validateModuleUpdatePolicy(&p); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Mode != UpdateModeAutoPull || p.CheckIntervalSeconds != 3600 || p.Timezone != "UTC" {
+		t.Errorf("defaults not applied: %+v", p)
+	}
+	if got := p.MaintenanceWindows[0].Days; got[0] != "mon" || got[1] != "fri" {
+		t.Errorf("days not normalized: %v", got)
+	}
+
+	invalid := []ModuleUpdatePolicy{
+		{Mode: "yolo"},
+		{Mode: UpdateModeNotify, CheckIntervalSeconds: 60},
+		{Mode: UpdateModeNotify, Timezone: "Mars/Olympus"},
+		{Mode: UpdateModeNotify, MaintenanceWindows: []MaintenanceWindow{{Start: "25:00", End: "03:00"}}},
+		{Mode: UpdateModeNotify, MaintenanceWindows: []MaintenanceWindow{{Start: "03:00", End: "03:00"}}},
+		{Mode: UpdateModeNotify, MaintenanceWindows: []MaintenanceWindow{{Days: []string{"someday"}, Start: "01:00", End: "03:00"}}},
+	}
+	for _, p := range invalid {
+		if err := validateModuleUpdatePolicy(&p); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%+v: expected ErrInvalidInput, got %v", p, err)
+		}
+	}
+}
+
+func TestInMaintenanceWindow(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("tzdata not available")
+	}
+	// Monday 2025-06-02
+	at := func(day, hour, min int) time.Time { return time.Date(2025, 6, day, hour, min, 0, 0, paris) }
+	nightly := []MaintenanceWindow{{Days: []string{"mon"}, Start: "23:00", End: "02:00"}}
+	office := []MaintenanceWindow{{Start: "09:00", End: "17:00"}}
+
+	tests := []struct {
+		name    string
+		windows []MaintenanceWindow
+		now     time.Time
+		want    bool
+	}{
+		{"no window", nil, at(2, 12, 0), true},
+		{"inside", office, at(4, 9, 0), true},
+		{"end excluded", office, at(4, 17, 0), false},
+		{"overnight start day", nightly, at(2, 23, 30), true},
+		{"overnight after midnight", nightly, at(3, 1, 59), true},
+		{"overnight wrong day", nightly, at(3, 23, 30), false},
+		{"overnight before start", nightly, at(2, 22, 59), false},
+		{"utc instant converted", office, time.Date(2025, 6, 4, 7, 30, 0, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		if got := inMaintenanceWindow(tt.windows, paris, tt.now); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUpdatePolicyHold(t *testing.T) {
+	now := time.Date(2025, 6, 4, 12, 0, 0, 0, time.UTC)
+	paused := now.Add(-time.Hour)
+	night := []MaintenanceWindow{{Start: "23:00", End: "02:00"}}
+
+	tests := []struct {
+		name   string
+		policy ModuleUpdatePolicy
+		want   string
+	}{
+		{"auto deploy", ModuleUpdatePolicy{Mode: UpdateModeAutoPullDeploy, Timezone: "UTC"}, ""},
+		{"notify", ModuleUpdatePolicy{Mode: UpdateModeNotify, Timezone: "UTC"}, UpdateResultNotified},
+		{"notify while paused", ModuleUpdatePolicy{Mode: UpdateModeNotify, Timezone: "UTC", PausedAt: &paused}, UpdateResultNotified},
+		{"paused after rollback", ModuleUpdatePolicy{Mode: UpdateModeAutoPullDeploy, Timezone: "UTC", PausedAt: &paused}, UpdateResultPaused},
+		{"paused auto pull", ModuleUpdatePolicy{Mode: UpdateModeAutoPull, Timezone: "UTC", PausedAt: &paused}, UpdateResultPaused},
+		{"outside window", ModuleUpdatePolicy{Mode: UpdateModeAutoPull, Timezone: "UTC", MaintenanceWindows: night}, UpdateResultOutsideWindow},
+	}
+	for _, tt := range tests {
+		if got := updatePolicyHold(tt.policy, now); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestModuleUpdateLock(t *testing.T) {
+	a, b := moduleUpdateLock("module_a"), moduleUpdateLock("module_b")
+	if a != moduleUpdateLock("module_a") {
+		t.Fatal("the same module got two locks")
+	}
+	a.Lock()
+	defer a.Unlock()
+	// a long check of one module must not hold back the others
+	if !b.TryLock() {
+		t.Fatal("module_b is blocked by a check of module_a")
+	}
+	b.Unlock()
+	if a.TryLock() {
+		t.Error("a second check of module_a was let through")
+	}
+}
@@ -0,0 +1,361 @@
+package core
+
+import (
+	"backend/database"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	ForgeGitHub = "github"
+	ForgeGitLab = "gitlab"
+	ForgeGitea  = "gitea"
+)
+
+// Delivery statuses. accepted deliveries run the update policy in the
+// background and end as succeeded or failed.
+const (
+	WebhookDeliveryRejected  = "rejected"
+	WebhookDeliveryIgnored   = "ignored"
+	WebhookDeliveryAccepted  = "accepted"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// ModuleWebhook is the inbound push webhook of a module. Secret is only set
+// right after it was generated.
+type ModuleWebhook struct {
+	ModuleID  string    `json:"module_id"`
+	URL       string    `json:"url"`
+	Enabled   bool      `json:"enabled"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ModuleWebhookDelivery struct {
+	ID          int64      `json:"id"`
+	ReceivedAt  time.Time  `json:"received_at"`
+	Provider    string     `json:"provider"`
+	Event       string     `json:"event"`
+	DeliveryID  string     `json:"delivery_id,omitempty"`
+	Ref         string     `json:"ref,omitempty"`
+	AfterCommit string     `json:"after_commit,omitempty"`
+	Status      string     `json:"status"`
+	Result      string     `json:"result,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// forgePush is the part of a GitHub, GitLab or Gitea push payload we use.
+type forgePush struct {
+	Ref     string `json:"ref"`
+	After   string `json:"after"`
+	Deleted bool   `json:"deleted"`
+}
+
+func moduleWebhookAAD(moduleID string) []byte {
+	return []byte("module-webhook:" + moduleID)
+}
+
+// moduleWebhookURL is the address forges deliver to. It is relative when
+// HOST_NAME is not set.
+func moduleWebhookURL(moduleID string) string {
+	path := "/api/v1/webhooks/modules/" + moduleID
+	if host := strings.TrimSpace(os.Getenv("HOST_NAME")); host != "" {
+		return "https://" + host + path
+	}
+	return path
+}
+
+func dbWebhookToWebhook(w database.ModuleWebhook) ModuleWebhook {
+	return ModuleWebhook{
+		ModuleID:  w.ModuleID,
+		URL:       moduleWebhookURL(w.ModuleID),
+		Enabled:   w.Enabled,
+		CreatedAt: w.CreatedAt,
+		UpdatedAt: w.UpdatedAt,
+	}
+}
+
+func dbDeliveryToDelivery(d database.ModuleWebhookDelivery) ModuleWebhookDelivery {
+	out := ModuleWebhookDelivery{
+		ID:          d.ID,
+		ReceivedAt:  d.ReceivedAt,
+		Provider:    d.Provider,
+		Event:       d.Event,
+		DeliveryID:  d.DeliveryID,
+		Ref:         d.Ref,
+		AfterCommit: d.AfterCommit,
+		Status:      d.Status,
+		Result:      d.Result,
+		Error:       d.Error,
+	}
+	if d.FinishedAt.Valid {
+		t := d.FinishedAt.Time
+		out.FinishedAt = &t
+	}
+	return out
+}
+
+func GetModuleWebhook(ctx context.Context, moduleID string) (ModuleWebhook, error) {
+	w, err := database.GetModuleWebhook(ctx, moduleID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ModuleWebhook{}, ErrNotFound
+		}
+		return ModuleWebhook{}, err
+	}
+	return dbWebhookToWebhook(w), nil
+}
+
+// RotateModuleWebhook creates the webhook of a module, or replaces its
+// secret, and enables it. The new secret is only returned here.
+func RotateModuleWebhook(ctx context.Context, moduleID string) (ModuleWebhook, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return ModuleWebhook{}, err
+	}
+	secret := hex.EncodeToString(raw)
+
+	ring, err := loadMasterKeyRing()
+	if err != nil {
+		return ModuleWebhook{}, err
+	}
+	sealed, err := ring.sealEnvelope([]byte(secret), moduleWebhookAAD(moduleID))
+	if err != nil {
+		return ModuleWebhook{}, fmt.Errorf("encrypt webhook secret: %w", err)
+	}
+	stored, err := database.UpsertModuleWebhook(ctx, database.ModuleWebhook{
+		ModuleID:   moduleID,
+		Nonce:      sealed.Nonce,
+		Ciphertext: sealed.Ciphertext,
+		DataKey:    sealed.DataKey,
+		KeyVersion: sealed.KeyVersion,
+	})
+	if err != nil {
+		return ModuleWebhook{}, err
+	}
+	LogModule(moduleID, "INFO", "Push webhook secret generated", nil, nil)
+	out := dbWebhookToWebhook(stored)
+	out.Secret = secret
+	return out, nil
+}
+
+func SetModuleWebhookEnabled(ctx context.Context, moduleID string, enabled bool) (ModuleWebhook, error) {
+	w, err := database.SetModuleWebhookEnabled(ctx, moduleID, enabled)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ModuleWebhook{}, ErrNotFound
+		}
+		return ModuleWebhook{}, err
+	}
+	return dbWebhookToWebhook(w), nil
+}
+
+func DeleteModuleWebhook(ctx context.Context, moduleID string) error {
+	if err := database.DeleteModuleWebhook(ctx, moduleID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	LogModule(moduleID, "INFO", "Push webhook deleted", nil, nil)
+	return nil
+}
+
+func ListModuleWebhookDeliveries(ctx context.Context, moduleID string, limit int) ([]ModuleWebhookDelivery, error) {
+	rows, err := database.ListModuleWebhookDeliveries(ctx, moduleID, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ModuleWebhookDelivery, 0, len(rows))
+	for _, d := range rows {
+		out = append(out, dbDeliveryToDelivery(d))
+	}
+	return out, nil
+}
+
+func openModuleWebhookSecret(ring *masterKeyRing, w database.ModuleWebhook) ([]byte, error) {
+	return ring.openEnvelope(sealedFromModuleWebhook(w), moduleWebhookAAD(w.ModuleID))
+}
+
+func sealedFromModuleWebhook(w database.ModuleWebhook) sealedSecret {
+	return sealedSecret{KeyVersion: w.KeyVersion, DataKey: w.DataKey, Nonce: w.Nonce, Ciphertext: w.Ciphertext}
+}
+
+// detectForge tells which forge sent a delivery from its headers, and
+// returns the event name and delivery ID it announced. Gitea also sends
+// GitHub headers, so it is checked first.
+func detectForge(h http.Header) (provider, event, deliveryID string) {
+	switch {
+	case h.Get("X-Gitea-Event") != "":
+		return ForgeGitea, h.Get("X-Gitea-Event"), h.Get("X-Gitea-Delivery")
+	case h.Get("X-Gitlab-Event") != "":
+		return ForgeGitLab, h.Get("X-Gitlab-Event"), h.Get("X-Gitlab-Event-UUID")
+	case h.Get("X-GitHub-Event") != "":
+		return ForgeGitHub, h.Get("X-GitHub-Event"), h.Get("X-GitHub-Delivery")
+	}
+	return "", "", ""
+}
+
+// verifyForgeSignature checks a delivery against the webhook secret:
+// GitHub signs the body as "sha256=<hex>", Gitea as bare hex, and GitLab
+// sends the secret itself as a token.
+func verifyForgeSignature(provider string, h http.Header, body, secret []byte) bool {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	switch provider {
+	case ForgeGitHub:
+		sig := strings.TrimPrefix(h.Get("X-Hub-Signature-256"), "sha256=")
+		return hmac.Equal([]byte(expected), []byte(strings.ToLower(sig)))
+	case ForgeGitea:
+		return hmac.Equal([]byte(expected), []byte(strings.ToLower(h.Get("X-Gitea-Signature"))))
+	case ForgeGitLab:
+		return hmac.Equal(secret, []byte(h.Get("X-Gitlab-Token")))
+	}
+	return false
+}
+
+// isForgePushEvent recognizes branch and tag pushes. GitLab sends tag pushes
+// as a separate event; the others use push for both.
+func isForgePushEvent(provider, event string) bool {
+	if provider == ForgeGitLab {
+		return event == "Push Hook" || event == "Tag Push Hook"
+	}
+	return event == "push"
+}
+
+// pushUpdatesModule reports whether a push can move the module: a push to
+// branch, or any new tag when the module tracks releases instead of its
+// branch. Deletions never match.
+func pushUpdatesModule(p forgePush, branch string, tracksReleases bool) bool {
+	if p.Deleted || strings.Trim(p.After, "0") == "" {
+		return false
+	}
+	if tracksReleases {
+		return strings.HasPrefix(p.Ref, "refs/tags/")
+	}
+	return p.Ref == "refs/heads/"+branch
+}
+
+// HandleModuleWebhook verifies and records a forge delivery for a module.
+// A push to the module branch runs its update policy in the background, as
+// a scheduled check would. ErrNotFound means the module has no webhook;
+// ErrUnauthorized is returned with the recorded rejected delivery.
+func HandleModuleWebhook(ctx context.Context, moduleID string, h http.Header, body []byte) (ModuleWebhookDelivery, error) {
+	hook, err := database.GetModuleWebhook(ctx, moduleID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ModuleWebhookDelivery{}, ErrNotFound
+		}
+		return ModuleWebhookDelivery{}, err
+	}
+	module, err := GetModule(moduleID)
+	if err != nil {
+		return ModuleWebhookDelivery{}, err
+	}
+
+	provider, event, deliveryID := detectForge(h)
+	d := database.ModuleWebhookDelivery{
+		ModuleID:   moduleID,
+		Provider:   provider,
+		Event:      event,
+		DeliveryID: deliveryID,
+	}
+	record := func(status, result, errMsg string) (ModuleWebhookDelivery, error) {
+		d.Status, d.Result, d.Error = status, result, errMsg
+		stored, err := database.InsertModuleWebhookDelivery(ctx, d)
+		if err != nil {
+			return ModuleWebhookDelivery{}, err
+		}
+		return dbDeliveryToDelivery(stored), nil
+	}
+
+	if provider == "" {
+		out, err := record(WebhookDeliveryRejected, "", "unknown sender: no GitHub, GitLab or Gitea event header")
+		if err != nil {
+			return out, err
+		}
+		return out, ErrUnauthorized
+	}
+	ring, err := loadMasterKeyRing()
+	if err != nil {
+		return ModuleWebhookDelivery{}, err
+	}
+	secret, err := openModuleWebhookSecret(ring, hook)
+	if err != nil {
+		return ModuleWebhookDelivery{}, fmt.Errorf("decrypt webhook secret: %w", err)
+	}
+	if !verifyForgeSignature(provider, h, body, secret) {
+		out, err := record(WebhookDeliveryRejected, "", "invalid signature")
+		if err != nil {
+			return out, err
+		}
+		return out, ErrUnauthorized
+	}
+	if !hook.Enabled {
+		return record(WebhookDeliveryIgnored, "", "webhook disabled")
+	}
+	if !isForgePushEvent(provider, event) {
+		return record(WebhookDeliveryIgnored, "", fmt.Sprintf("event %q is not a push", event))
+	}
+
+	var push forgePush
+	if err := json.Unmarshal(body, &push); err != nil {
+		return record(WebhookDeliveryIgnored, "", "invalid push payload")
+	}
+	d.Ref, d.AfterCommit = push.Ref, push.After
+	tracking, err := GetModuleReleaseTracking(ctx, moduleID)
+	if err != nil {
+		return ModuleWebhookDelivery{}, err
+	}
+	tracksReleases := tracking.Constraint != ""
+	if !pushUpdatesModule(push, module.GitBranch, tracksReleases) {
+		if tracksReleases {
+			return record(WebhookDeliveryIgnored, "", fmt.Sprintf("push to %s is not a tag and the module tracks releases", push.Ref))
+		}
+		return record(WebhookDeliveryIgnored, "", fmt.Sprintf("push to %s does not update branch %s", push.Ref, module.GitBranch))
+	}
+
+	out, err := record(WebhookDeliveryAccepted, "", "")
+	if err != nil {
+		return out, err
+	}
+	ref := module.GitBranch
+	if tracksReleases {
+		ref = strings.TrimPrefix(push.Ref, "refs/tags/")
+	}
+	LogModule(moduleID, "INFO", fmt.Sprintf("Push webhook from %s: %s is now %s", provider, ref, shortHash(push.After)),
+		map[string]any{"delivery_id": deliveryID}, nil)
+	go runWebhookDelivery(moduleID, out.ID)
+	return out, nil
+}
+
+func runWebhookDelivery(moduleID string, deliveryID int64) {
+	ctx := context.Background()
+	status := WebhookDeliverySucceeded
+	policy, err := RunModuleUpdateCheck(ctx, moduleID)
+	errMsg := policy.LastError
+	if err != nil {
+		errMsg = err.Error()
+	}
+	if errMsg != "" || policy.LastResult == UpdateResultError {
+		status = WebhookDeliveryFailed
+	}
+	if err := database.FinishModuleWebhookDelivery(ctx, deliveryID, status, policy.LastResult, errMsg); err != nil {
+		log.Printf("[webhooks] record delivery %d of %s: %v", deliveryID, moduleID, err)
+	}
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+)
+
+func TestVerifyForgeSignature(t *testing.T) {
+	secret := []byte("s3cret")
+	body := []byte(`{"ref":"refs/heads/main"}`)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	sig := hex.EncodeToString(mac.Sum(nil))
+
+	cases := []struct {
+		name    string
+		headers map[string]string
+		want    bool
+	}{
+		{"github", map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=" + sig}, true},
+		{"github bad", map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=00"}, false},
+		{"gitea", map[string]string{"X-Gitea-Event": "push", "X-GitHub-Event": "push", "X-Gitea-Signature": sig}, true},
+		{"gitea with github prefix only", map[string]string{"X-Gitea-Event": "push", "X-Hub-Signature-256": "sha256=" + sig}, false},
+		{"gitlab", map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "s3cret"}, true},
+		{"gitlab bad", map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "nope"}, false},
+		{"unknown", map[string]string{"X-Hub-Signature-256": "sha256=" + sig}, false},
+	}
+	for _, c := range cases {
+		h := http.Header{}
+		for k, v := range c.headers {
+			h.Set(k, v)
+		}
+		provider, _, _ := detectForge(h)
+		if got := verifyForgeSignature(provider, h, body, secret); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestIsForgePushEvent(t *testing.T) {
+	if !isForgePushEvent(ForgeGitHub, "push") || !isForgePushEvent(ForgeGitea, "push") || !isForgePushEvent(ForgeGitLab, "Push Hook") || !isForgePushEvent(ForgeGitLab, "Tag Push Hook") {
+		t.Error("push events not recognized")
+	}
+	if isForgePushEvent(ForgeGitHub, "ping") || isForgePushEvent(ForgeGitLab, "Merge Request Hook") {
+		t.Error("non-push events recognized as push")
+	}
+}
+
+func TestPushUpdatesModule(t *testing.T) {
+	commit := "9fceb02d0ae598e95dc970b74767f19372d61af8"
+	cases := []struct {
+		name     string
+		push     forgePush
+		releases bool
+		want     bool
+	}{
+		{"module branch", forgePush{Ref: "refs/heads/main", After: commit}, false, true},
+		{"other branch", forgePush{Ref: "refs/heads/dev", After: commit}, false, false},
+		{"tag", forgePush{Ref: "refs/tags/main", After: commit}, false, false},
+		{"github deletion", forgePush{Ref: "refs/heads/main", After: commit, Deleted: true}, false, false},
+		{"gitlab deletion", forgePush{Ref: "refs/heads/main", After: "0000000000000000000000000000000000000000"}, false, false},
+		{"release tag", forgePush{Ref: "refs/tags/v1.3.0", After: commit}, true, true},
+		{"branch of a release module", forgePush{Ref: "refs/heads/main", After: commit}, true, false},
+		{"tag deletion", forgePush{Ref: "refs/tags/v1.3.0", After: commit, Deleted: true}, true, false},
+	}
+	for _, c := range cases {
+		if got := pushUpdatesModule(c.push, "main", c.releases); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -26,7 +26,6 @@ const (
 type Module struct {
 	ID                   string       `json:"id"`
 	SSHPublicKey         string       `json:"ssh_public_key"`
-	SSHPrivateKey        string       `json:"ssh_private_key"`
 	SSHKeyID             string       `json:"ssh_key_id"`
 	Name                 string       `json:"name"`
 	Slug                 string       `json:"slug"`
@@ -99,12 +98,13 @@ type ModulePagination struct {
 }
 
 type ModuleLog struct {
-	ID        int64          `json:"id"`
-	ModuleID  string         `json:"module_id"`
-	Level     string         `json:"level"`
-	Message   string         `json:"message"`
-	Meta      map[string]any `json:"meta"`
-	CreatedAt time.Time      `json:"created_at"`
+	ID           int64          `json:"id"`
+	ModuleID     string         `json:"module_id"`
+	Level        string         `json:"level"`
+	Message      string         `json:"message"`
+	Meta         map[string]any `json:"meta"`
+	CreatedAt    time.Time      `json:"created_at"`
+	DeploymentID string         `json:"deployment_id,omitempty"`
 }
 
 type ModuleLogsPagination struct {
@@ -602,6 +602,15 @@ func ensureModuleSSHKeyForSlug(slug string) (SSHKey, error) {
 }
 
 func ImportModule(actor *User, name string, gitURL string, gitBranch string, sshKeyID string) (Module, error) {
+	slug := GenerateModuleSlug(name, gitBranch)
+	if slug == "" {
+		return Module{}, fmt.Errorf("failed to generate a valid slug")
+	}
+	return importModule(actor, name, slug, gitURL, gitBranch, sshKeyID)
+}
+
+// importModule registers a module under a slug the caller made sure is free.
+func importModule(actor *User, name, slug, gitURL, gitBranch, sshKeyID string) (Module, error) {
 	var dest Module
 
 	// Generate a ULID for the module
@@ -610,11 +619,6 @@ func ImportModule(actor *User, name string, gitURL string, gitBranch string, ssh
 		return Module{}, fmt.Errorf("failed to generate module ID: %w", err)
 	}
 
-	slug := GenerateModuleSlug(name, gitBranch)
-	if slug == "" {
-		return Module{}, fmt.Errorf("failed to generate a valid slug: %w", err)
-	}
-
 	// Determine SSH key to use
 	var (
 		key          SSHKey
@@ -636,14 +640,13 @@ func ImportModule(actor *User, name string, gitURL string, gitBranch string, ssh
 
 	// Prepare module struct
 	dest = Module{
-		ID:            moduleID,
-		Name:          name,
-		Slug:          slug,
-		GitURL:        gitURL,
-		GitBranch:     gitBranch,
-		SSHPublicKey:  key.PublicKey,
-		SSHPrivateKey: key.PrivateKey,
-		SSHKeyID:      key.ID,
+		ID:           moduleID,
+		Name:         name,
+		Slug:         slug,
+		GitURL:       gitURL,
+		GitBranch:    gitBranch,
+		SSHPublicKey: key.PublicKey,
+		SSHKeyID:     key.ID,
 	}
 
 	// Insert into DB
@@ -808,11 +811,6 @@ func GetModulePages(pagination ModulePagesPagination) ([]ModulePage, string, err
 }
 
 func ImportModulePage(moduleID, name string, slug *string, targetContainer *string, targetPort *int, iframeOnly, pageOnly, needAuth, isVisible bool, network string) (ModulePage, error) {
-	pageID, err := GenerateULID(PageKind)
-	if err != nil {
-		return ModulePage{}, fmt.Errorf("failed to generate page ID: %w", err)
-	}
-
 	if err := validatePageMode(iframeOnly, pageOnly); err != nil {
 		return ModulePage{}, err
 	}
@@ -840,6 +838,7 @@ func ImportModulePage(moduleID, name string, slug *string, targetContainer *stri
 
 	pageSlug := ""
 	if slug != nil && strings.TrimSpace(*slug) != "" {
+		var err error
 		pageSlug, err = ensurePageSlugAvailable(*slug, "")
 		if err != nil {
 			return ModulePage{}, err
@@ -848,9 +847,7 @@ func ImportModulePage(moduleID, name string, slug *string, targetContainer *stri
 		pageSlug = GeneratePageSlug(name)
 	}
 
-	// Prepare module struct
-	dest := ModulePage{
-		ID:              pageID,
+	return insertModulePage(ModulePage{
 		ModuleID:        moduleID,
 		Name:            name,
 		Slug:            pageSlug,
@@ -861,7 +858,17 @@ func ImportModulePage(moduleID, name string, slug *string, targetContainer *stri
 		NeedAuth:        needAuth,
 		IsVisible:       isVisible,
 		NetworkName:     strings.TrimSpace(network),
+	})
+}
+
+// insertModulePage stores a validated page under a new ID, with the admin
+// role as its only role.
+func insertModulePage(dest ModulePage) (ModulePage, error) {
+	pageID, err := GenerateULID(PageKind)
+	if err != nil {
+		return ModulePage{}, fmt.Errorf("failed to generate page ID: %w", err)
 	}
+	dest.ID = pageID
 
 	// Insert into DB
 	if err := database.InsertModulePage(database.ModulePage{
@@ -892,6 +899,7 @@ func DeleteModule(moduleID string) error {
 	if err != nil {
 		return fmt.Errorf("module %s not found", moduleID)
 	}
+	deleteModulePreviews(moduleID)
 	if module.SSHKeyID != "" {
 		_ = AppendSSHKeyEvent(module.SSHKeyID, nil, &module.ID, fmt.Sprintf("Key unassigned from module %s", module.Name))
 	}
@@ -899,6 +907,7 @@ func DeleteModule(moduleID string) error {
 	if err != nil {
 		LogModule(moduleID, "WARN", "couldn't clean docker ressources", nil, err)
 	}
+	deprovisionDeletedModuleDatabase(module)
 
 	err = DeleteModuleRepoDir(module)
 	if err != nil {
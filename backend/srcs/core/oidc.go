@@ -53,6 +53,7 @@ type OIDCUserClaims struct {
 	Module            map[string]any `json:"module,omitempty"`
 	Roles             []string       `json:"roles,omitempty"`
 	RoleSlugs         []string       `json:"role_slugs,omitempty"`
+	Custom            map[string]any `json:"custom,omitempty"`
 	Nonce             string         `json:"nonce,omitempty"`
 }
 
@@ -72,6 +73,7 @@ type oidcIDTokenClaims struct {
 	Module            map[string]any `json:"module,omitempty"`
 	Roles             []string       `json:"roles,omitempty"`
 	RoleSlugs         []string       `json:"role_slugs,omitempty"`
+	Custom            map[string]any `json:"custom,omitempty"`
 }
 
 type oidcTokenResponse struct {
@@ -342,6 +344,9 @@ func BuildOIDCUserClaims(user User, module Module, _ database.OIDCClient, scopes
 		claims.Name = user.FtLogin
 		claims.PreferredUsername = user.FtLogin
 		claims.Picture = user.PhotoURL
+		if custom, err := userCustomAttributes(user.ID, true); err == nil && len(custom) > 0 {
+			claims.Custom = custom
+		}
 	}
 	if includeEmail {
 		claims.Email = buildOIDCEmail(user)
@@ -394,6 +399,7 @@ func BuildOIDCIDTokenClaims(user User, module Module, client database.OIDCClient
 		Module:            claims.Module,
 		Roles:             claims.Roles,
 		RoleSlugs:         claims.RoleSlugs,
+		Custom:            claims.Custom,
 	}
 }
 
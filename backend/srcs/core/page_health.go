@@ -0,0 +1,413 @@
+package core
+
+import (
+	"backend/database"
+	"backend/websocket"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	PageHealthUnknown = "unknown"
+	PageHealthUp      = "up"
+	PageHealthDown    = "down"
+
+	ModuleHealthUnknown  = "unknown"
+	ModuleHealthHealthy  = "healthy"
+	ModuleHealthDegraded = "degraded"
+	ModuleHealthDown     = "down"
+)
+
+// pageDownAfterFailures is how many probes in a row must fail before a page
+// is reported down, so a single slow answer does not flap the status.
+const pageDownAfterFailures = 2
+
+// PageHealthCheck is the probe configuration of a page and its latest state.
+type PageHealthCheck struct {
+	PageID              string     `json:"page_id"`
+	ModuleID            string     `json:"module_id"`
+	PageName            string     `json:"page_name"`
+	PageSlug            string     `json:"page_slug"`
+	Enabled             bool       `json:"enabled"`
+	Path                string     `json:"path"`
+	ExpectedStatus      int        `json:"expected_status"`
+	IntervalSeconds     int        `json:"interval_seconds"`
+	TimeoutSeconds      int        `json:"timeout_seconds"`
+	Status              string     `json:"status"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastCheckedAt       *time.Time `json:"last_checked_at,omitempty"`
+	LastStatusCode      *int       `json:"last_status_code,omitempty"`
+	LastLatencyMs       *int       `json:"last_latency_ms,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+type PageHealthResult struct {
+	CheckedAt  time.Time `json:"checked_at"`
+	OK         bool      `json:"ok"`
+	StatusCode *int      `json:"status_code,omitempty"`
+	LatencyMs  int       `json:"latency_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// ModuleHealth is computed from the probes of the module pages.
+type ModuleHealth struct {
+	Status string            `json:"status"`
+	Pages  []PageHealthCheck `json:"pages"`
+}
+
+var (
+	pageHealthOnce sync.Once
+	// lastModuleHealth caches the last broadcast status per module ID.
+	lastModuleHealth sync.Map
+)
+
+func dbPageHealthToPageHealth(h database.ModulePageHealthCheck) PageHealthCheck {
+	out := PageHealthCheck{
+		PageID:              h.PageID,
+		ModuleID:            h.ModuleID,
+		PageName:            h.PageName,
+		PageSlug:            h.PageSlug,
+		Enabled:             h.Enabled,
+		Path:                h.Path,
+		ExpectedStatus:      h.ExpectedStatus,
+		IntervalSeconds:     h.IntervalSeconds,
+		TimeoutSeconds:      h.TimeoutSeconds,
+		Status:              h.Status,
+		ConsecutiveFailures: h.ConsecutiveFailures,
+		LastError:           h.LastError,
+	}
+	if h.LastCheckedAt.Valid {
+		t := h.LastCheckedAt.Time
+		out.LastCheckedAt = &t
+	}
+	if h.LastStatusCode.Valid {
+		code := int(h.LastStatusCode.Int64)
+		out.LastStatusCode = &code
+	}
+	if h.LastLatencyMs.Valid {
+		ms := int(h.LastLatencyMs.Int64)
+		out.LastLatencyMs = &ms
+	}
+	return out
+}
+
+// pageOfModule loads a page and checks that it belongs to moduleID.
+func pageOfModule(moduleID, pageID string) (*database.ModulePage, error) {
+	page, err := database.GetPageByID(pageID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && page.ModuleID != moduleID) {
+		return nil, ErrNotFound
+	}
+	return page, err
+}
+
+func GetPageHealthCheck(ctx context.Context, moduleID, pageID string) (PageHealthCheck, error) {
+	if _, err := pageOfModule(moduleID, pageID); err != nil {
+		return PageHealthCheck{}, err
+	}
+	h, err := database.GetModulePageHealthCheck(ctx, pageID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return PageHealthCheck{}, ErrNotFound
+		}
+		return PageHealthCheck{}, err
+	}
+	return dbPageHealthToPageHealth(h), nil
+}
+
+// SetPageHealthCheck validates and stores the probe of a page. Its state is
+// reset, so it is probed again on the next tick.
+func SetPageHealthCheck(ctx context.Context, moduleID, pageID string, in PageHealthCheck) (PageHealthCheck, error) {
+	if _, err := pageOfModule(moduleID, pageID); err != nil {
+		return PageHealthCheck{}, err
+	}
+	if err := validatePageHealthCheck(&in); err != nil {
+		return PageHealthCheck{}, err
+	}
+	if err := database.UpsertModulePageHealthCheck(ctx, database.ModulePageHealthCheck{
+		PageID:          pageID,
+		ModuleID:        moduleID,
+		Enabled:         in.Enabled,
+		Path:            in.Path,
+		ExpectedStatus:  in.ExpectedStatus,
+		IntervalSeconds: in.IntervalSeconds,
+		TimeoutSeconds:  in.TimeoutSeconds,
+	}); err != nil {
+		return PageHealthCheck{}, err
+	}
+	refreshModuleHealth(ctx, moduleID)
+	return GetPageHealthCheck(ctx, moduleID, pageID)
+}
+
+func DeletePageHealthCheck(ctx context.Context, moduleID, pageID string) error {
+	if _, err := pageOfModule(moduleID, pageID); err != nil {
+		return err
+	}
+	if err := database.DeleteModulePageHealthCheck(ctx, pageID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	refreshModuleHealth(ctx, moduleID)
+	return nil
+}
+
+func validatePageHealthCheck(h *PageHealthCheck) error {
+	h.Path = strings.TrimSpace(h.Path)
+	if h.Path == "" {
+		h.Path = "/"
+	}
+	if !strings.HasPrefix(h.Path, "/") || strings.ContainsAny(h.Path, " \t\r\n#") {
+		return fmt.Errorf("%w: path must be an absolute URL path such as /healthz", ErrInvalidInput)
+	}
+	if h.ExpectedStatus == 0 {
+		h.ExpectedStatus = http.StatusOK
+	}
+	if h.ExpectedStatus < 100 || h.ExpectedStatus > 599 {
+		return fmt.Errorf("%w: expected_status must be an HTTP status code", ErrInvalidInput)
+	}
+	if h.IntervalSeconds == 0 {
+		h.IntervalSeconds = 60
+	}
+	if h.IntervalSeconds < 10 {
+		return fmt.Errorf("%w: interval_seconds must be at least 10", ErrInvalidInput)
+	}
+	if h.TimeoutSeconds == 0 {
+		h.TimeoutSeconds = 5
+	}
+	if h.TimeoutSeconds < 1 || h.TimeoutSeconds > 60 || h.TimeoutSeconds >= h.IntervalSeconds {
+		return fmt.Errorf("%w: timeout_seconds must be between 1 and 60, and below interval_seconds", ErrInvalidInput)
+	}
+	return nil
+}
+
+func GetPageHealthHistory(ctx context.Context, moduleID, pageID string, since time.Time, limit int) ([]PageHealthResult, error) {
+	if _, err := pageOfModule(moduleID, pageID); err != nil {
+		return nil, err
+	}
+	rows, err := database.ListModulePageHealthHistory(ctx, pageID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]PageHealthResult, 0, len(rows))
+	for _, r := range rows {
+		res := PageHealthResult{CheckedAt: r.CheckedAt, OK: r.OK, LatencyMs: r.LatencyMs, Error: r.Error}
+		if r.StatusCode.Valid {
+			code := int(r.StatusCode.Int64)
+			res.StatusCode = &code
+		}
+		out = append(out, res)
+	}
+	return out, nil
+}
+
+func GetModuleHealth(ctx context.Context, moduleID string) (ModuleHealth, error) {
+	rows, err := database.ListModulePageHealthChecks(ctx, moduleID)
+	if err != nil {
+		return ModuleHealth{}, err
+	}
+	pages := make([]PageHealthCheck, 0, len(rows))
+	for _, h := range rows {
+		pages = append(pages, dbPageHealthToPageHealth(h))
+	}
+	return ModuleHealth{Status: computeModuleHealth(pages), Pages: pages}, nil
+}
+
+// computeModuleHealth is healthy when every probed page is up, down when every
+// one is down and degraded in between. Pages not probed yet do not count.
+func computeModuleHealth(pages []PageHealthCheck) string {
+	up, down := 0, 0
+	for _, p := range pages {
+		if !p.Enabled {
+			continue
+		}
+		switch p.Status {
+		case PageHealthUp:
+			up++
+		case PageHealthDown:
+			down++
+		}
+	}
+	switch {
+	case up == 0 && down == 0:
+		return ModuleHealthUnknown
+	case down == 0:
+		return ModuleHealthHealthy
+	case up == 0:
+		return ModuleHealthDown
+	}
+	return ModuleHealthDegraded
+}
+
+// nextPageHealth derives the page status from a probe outcome.
+func nextPageHealth(prev string, failures int, ok bool) (string, int) {
+	if ok {
+		return PageHealthUp, 0
+	}
+	failures++
+	if failures >= pageDownAfterFailures {
+		return PageHealthDown, failures
+	}
+	return prev, failures
+}
+
+// pageGatewayURL targets the gateway the net-controller runs for a page, on
+// the shared proxy network. Going through it checks the same path as users.
+func pageGatewayURL(pageSlug, path string) string {
+	return fmt.Sprintf("http://gateway-%s:%d%s", dnsSafeSlug(pageSlug), envInt("MODULES_GATEWAY_PORT", 8080), path)
+}
+
+// dnsSafeSlug mirrors the net-controller gateway naming.
+func dnsSafeSlug(slug string) string {
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	var b strings.Builder
+	for _, r := range slug {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('-')
+		}
+	}
+	cleaned := strings.Trim(b.String(), "-")
+	if cleaned == "" {
+		return "page"
+	}
+	return cleaned
+}
+
+func probePage(ctx context.Context, h PageHealthCheck) PageHealthResult {
+	client := &http.Client{
+		Timeout: time.Duration(h.TimeoutSeconds) * time.Second,
+		// a login redirect is an answer too; expected_status decides
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	res := PageHealthResult{CheckedAt: time.Now().UTC()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageGatewayURL(h.PageSlug, h.Path), nil)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	req.Header.Set("User-Agent", "pan-bagnat-health/1")
+	start := time.Now()
+	resp, err := client.Do(req)
+	res.LatencyMs = int(time.Since(start).Milliseconds())
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	resp.Body.Close()
+	code := resp.StatusCode
+	res.StatusCode = &code
+	res.OK = code == h.ExpectedStatus
+	if !res.OK {
+		res.Error = fmt.Sprintf("got HTTP %d, expected %d", code, h.ExpectedStatus)
+	}
+	return res
+}
+
+// StartPageHealthProbes runs due page probes every MODULE_HEALTH_TICK
+// (default 10s; 0 disables them) and drops history older than
+// MODULE_HEALTH_RETENTION (default 168h).
+func StartPageHealthProbes() {
+	tick := envDuration("MODULE_HEALTH_TICK", 10*time.Second)
+	if tick <= 0 {
+		return
+	}
+	retention := envDuration("MODULE_HEALTH_RETENTION", 7*24*time.Hour)
+	pageHealthOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(tick)
+			defer ticker.Stop()
+			var lastPrune time.Time
+			for range ticker.C {
+				ctx := context.Background()
+				runDuePageProbes(ctx)
+				if retention > 0 && time.Since(lastPrune) > time.Hour {
+					lastPrune = time.Now()
+					if _, err := database.PruneModulePageHealthHistory(ctx, time.Now().Add(-retention)); err != nil {
+						log.Printf("[health] prune history: %v", err)
+					}
+				}
+			}
+		}()
+	})
+}
+
+func runDuePageProbes(ctx context.Context) {
+	due, err := database.ListDueModulePageHealthChecks(ctx, time.Now())
+	if err != nil {
+		log.Printf("[health] list due probes: %v", err)
+		return
+	}
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		touched = map[string]bool{}
+		sem     = make(chan struct{}, 8)
+	)
+	for _, row := range due {
+		h := dbPageHealthToPageHealth(row)
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			res := probePage(ctx, h)
+			status, failures := nextPageHealth(h.Status, h.ConsecutiveFailures, res.OK)
+			rec := database.ModulePageHealthResult{
+				PageID:    h.PageID,
+				ModuleID:  h.ModuleID,
+				CheckedAt: res.CheckedAt,
+				OK:        res.OK,
+				LatencyMs: res.LatencyMs,
+				Error:     res.Error,
+			}
+			if res.StatusCode != nil {
+				rec.StatusCode = sql.NullInt64{Int64: int64(*res.StatusCode), Valid: true}
+			}
+			if err := database.RecordModulePageHealthResult(ctx, rec, status, failures); err != nil {
+				log.Printf("[health] record probe of page %s: %v", h.PageID, err)
+				return
+			}
+			if status != h.Status {
+				LogModule(h.ModuleID, "INFO", fmt.Sprintf("Page %s is %s", h.PageName, status), map[string]any{"page_id": h.PageID, "error": res.Error}, nil)
+			}
+			mu.Lock()
+			touched[h.ModuleID] = true
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	for moduleID := range touched {
+		refreshModuleHealth(ctx, moduleID)
+	}
+}
+
+// refreshModuleHealth recomputes the module health and pushes it over
+// WebSocket when the module or any page status changed.
+func refreshModuleHealth(ctx context.Context, moduleID string) {
+	health, err := GetModuleHealth(ctx, moduleID)
+	if err != nil {
+		log.Printf("[health] module %s: %v", moduleID, err)
+		return
+	}
+	key := health.Status
+	for _, p := range health.Pages {
+		key += "|" + p.PageID + "=" + p.Status
+	}
+	if prev, ok := lastModuleHealth.Load(moduleID); ok && prev.(string) == key {
+		return
+	}
+	lastModuleHealth.Store(moduleID, key)
+	websocket.SendGenericModuleEvent(moduleID, "module_health", map[string]any{
+		"module_id": moduleID,
+		"status":    health.Status,
+		"pages":     health.Pages,
+	})
+}
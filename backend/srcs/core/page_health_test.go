@@ -0,0 +1,75 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestComputeModuleHealth(t *testing.T) {
+	page := func(status string, enabled bool) PageHealthCheck {
+		return PageHealthCheck{Status: status, Enabled: enabled}
+	}
+	cases := []struct {
+		name  string
+		pages []PageHealthCheck
+		want  string
+	}{
+		{"no probes", nil, ModuleHealthUnknown},
+		{"not probed yet", []PageHealthCheck{page(PageHealthUnknown, true)}, ModuleHealthUnknown},
+		{"all up", []PageHealthCheck{page(PageHealthUp, true), page(PageHealthUnknown, true)}, ModuleHealthHealthy},
+		{"all down", []PageHealthCheck{page(PageHealthDown, true)}, ModuleHealthDown},
+		{"mixed", []PageHealthCheck{page(PageHealthUp, true), page(PageHealthDown, true)}, ModuleHealthDegraded},
+		{"disabled ignored", []PageHealthCheck{page(PageHealthUp, true), page(PageHealthDown, false)}, ModuleHealthHealthy},
+	}
+	for _, c := range cases {
+		if got := computeModuleHealth(c.pages); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestNextPageHealth(t *testing.T) {
+	status, failures := nextPageHealth(PageHealthUp, 0, false)
+	if status != PageHealthUp || failures != 1 {
+		t.Errorf("first failure: got %s/%d, want up/1", status, failures)
+	}
+	status, failures = nextPageHealth(status, failures, false)
+	if status != PageHealthDown || failures != 2 {
+		t.Errorf("second failure: got %s/%d, want down/2", status, failures)
+	}
+	status, failures = nextPageHealth(status, failures, true)
+	if status != PageHealthUp || failures != 0 {
+		t.Errorf("recovery: got %s/%d, want up/0", status, failures)
+	}
+}
+
+func TestValidatePageHealthCheck(t *testing.T) {
+	h := PageHealthCheck{}
+	if err := validatePageHealthCheck(&h); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if h.Path != "/" || h.ExpectedStatus != 200 || h.IntervalSeconds != 60 || h.TimeoutSeconds != 5 {
+		t.Errorf("defaults not applied: %+v", h)
+	}
+
+	invalid := []PageHealthCheck{
+		{Path: "healthz"},
+		{Path: "/a b"},
+		{ExpectedStatus: 42},
+		{IntervalSeconds: 5},
+		{IntervalSeconds: 10, TimeoutSeconds: 10},
+		{TimeoutSeconds: 61, IntervalSeconds: 120},
+	}
+	for _, h := range invalid {
+		if err := validatePageHealthCheck(&h); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%+v: got %v, want ErrInvalidInput", h, err)
+		}
+	}
+}
+
+func TestPageGatewayURL(t *testing.T) {
+	t.Setenv("MODULES_GATEWAY_PORT", "")
+	if got := pageGatewayURL("My_Page", "/healthz"); got != "http://gateway-my-page:8080/healthz" {
+		t.Errorf("got %q", got)
+	}
+}
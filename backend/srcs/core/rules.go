@@ -287,9 +287,9 @@ func ApplyRoleRulesNow(ctx context.Context, roleID string) (int, error) {
 		return 0, err
 	}
 
-	// No rules stored → remove this role from everyone.
+	// No rules stored → remove the rule-granted links of this role.
 	if len(raw) == 0 {
-		return database.RemoveRoleFromAllUsers(ctx, roleID)
+		return database.RemoveRuleRoleFromAllUsers(ctx, roleID)
 	}
 
 	var rules map[string]any
@@ -304,21 +304,24 @@ func ApplyRoleRulesNow(ctx context.Context, roleID string) (int, error) {
 
 	changed := 0
 	for _, u := range users {
-		u42, err := GetUser42(u.FtLogin)
+		u42, err := GetUser42(intraUserKey(u))
 		if err != nil {
 			// Skip flaky users; don't fail the whole job.
-			log.Printf("ApplyRoleRulesNow: GetUser42(%s) error: %v", u.FtLogin, err)
+			log.Printf("ApplyRoleRulesNow: GetUser42(%s) error: %v", intraUserKey(u), err)
 			continue
 		}
 
 		// Convert the typed struct to a generic map for the evaluator.
-		var payload map[string]any
-		if err := json.Unmarshal(mustMarshal(u42), &payload); err != nil {
+		payload, err := userRulePayload(u.ID, u42)
+		if err != nil {
 			log.Printf("ApplyRoleRulesNow: marshal user payload %s error: %v", u.FtLogin, err)
 			continue
 		}
 
 		shouldHave := evalRuleNode(rules, payload)
+		if !ruleRoleChangeAllowed(ctx, u.ID, roleID, shouldHave) {
+			continue
+		}
 		c, err := database.EnsureUserRole(ctx, u.ID, roleID, shouldHave)
 		if err != nil {
 			return changed, err
@@ -0,0 +1,217 @@
+package core
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrSecretsKeyMissing is returned when no master key is configured or it is malformed.
+var ErrSecretsKeyMissing = errors.New("master key is not configured (MASTER_KEY or MASTER_KEY_FILE)")
+
+// masterKeyRing holds every known master key by version. New data is always
+// wrapped with the active (highest) version; older versions stay readable until
+// rotation has re-wrapped everything.
+type masterKeyRing struct {
+	active int
+	keys   map[int][]byte
+}
+
+// loadMasterKeyRing reads the master keys from MASTER_KEY_FILE, or MASTER_KEY
+// when no file is set. Both hold `<version>:<base64 key>` entries (one per line
+// in the file, comma separated in the env var); a bare base64 key is version 1.
+// Keys are 32 random bytes, e.g. `openssl rand -base64 32`.
+// The legacy MODULE_SECRETS_KEY is still accepted as version 1.
+func loadMasterKeyRing() (*masterKeyRing, error) {
+	var entries []string
+	if path := strings.TrimSpace(os.Getenv("MASTER_KEY_FILE")); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSecretsKeyMissing, err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			entries = append(entries, line)
+		}
+	} else if raw := strings.TrimSpace(os.Getenv("MASTER_KEY")); raw != "" {
+		for _, e := range strings.Split(raw, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	ring := &masterKeyRing{keys: map[int][]byte{}}
+	for _, e := range entries {
+		version, key, err := parseMasterKeyEntry(e)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := ring.keys[version]; dup {
+			return nil, fmt.Errorf("%w: duplicate key version %d", ErrSecretsKeyMissing, version)
+		}
+		ring.keys[version] = key
+	}
+	if legacy := strings.TrimSpace(os.Getenv("MODULE_SECRETS_KEY")); legacy != "" {
+		if _, ok := ring.keys[1]; !ok {
+			_, key, err := parseMasterKeyEntry(legacy)
+			if err != nil {
+				return nil, err
+			}
+			ring.keys[1] = key
+		}
+	}
+	if len(ring.keys) == 0 {
+		return nil, ErrSecretsKeyMissing
+	}
+	for v := range ring.keys {
+		if v > ring.active {
+			ring.active = v
+		}
+	}
+	return ring, nil
+}
+
+func parseMasterKeyEntry(entry string) (int, []byte, error) {
+	version, encoded := 1, entry
+	if i := strings.Index(entry, ":"); i >= 0 {
+		v, err := strconv.Atoi(strings.TrimSpace(entry[:i]))
+		if err != nil || v <= 0 {
+			return 0, nil, fmt.Errorf("%w: invalid key version %q", ErrSecretsKeyMissing, entry[:i])
+		}
+		version, encoded = v, strings.TrimSpace(entry[i+1:])
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return 0, nil, fmt.Errorf("%w: key version %d must be 32 base64-encoded bytes", ErrSecretsKeyMissing, version)
+	}
+	return version, key, nil
+}
+
+func (r *masterKeyRing) key(version int) ([]byte, error) {
+	key, ok := r.keys[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: key version %d is not loaded", ErrSecretsKeyMissing, version)
+	}
+	return key, nil
+}
+
+// sealedSecret is an envelope: the payload is encrypted with a random data key,
+// and the data key is wrapped (nonce || ciphertext) with master key KeyVersion.
+type sealedSecret struct {
+	KeyVersion int
+	DataKey    []byte
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// sealEnvelope encrypts plaintext under a fresh data key wrapped with the
+// active master key. aad binds both layers to their owner.
+func (r *masterKeyRing) sealEnvelope(plaintext, aad []byte) (sealedSecret, error) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return sealedSecret{}, err
+	}
+	nonce, ciphertext, err := sealSecret(dek, plaintext, aad)
+	if err != nil {
+		return sealedSecret{}, err
+	}
+	wrapped, err := r.wrapDataKey(r.active, dek, aad)
+	if err != nil {
+		return sealedSecret{}, err
+	}
+	return sealedSecret{KeyVersion: r.active, DataKey: wrapped, Nonce: nonce, Ciphertext: ciphertext}, nil
+}
+
+func (r *masterKeyRing) openEnvelope(s sealedSecret, aad []byte) ([]byte, error) {
+	dek, err := r.unwrapDataKey(s, aad)
+	if err != nil {
+		return nil, err
+	}
+	return openSecret(dek, s.Nonce, s.Ciphertext, aad)
+}
+
+// rewrapEnvelope re-wraps the data key with the active master key. The payload
+// itself is left untouched.
+func (r *masterKeyRing) rewrapEnvelope(s sealedSecret, aad []byte) (sealedSecret, error) {
+	dek, err := r.unwrapDataKey(s, aad)
+	if err != nil {
+		return sealedSecret{}, err
+	}
+	wrapped, err := r.wrapDataKey(r.active, dek, aad)
+	if err != nil {
+		return sealedSecret{}, err
+	}
+	s.KeyVersion, s.DataKey = r.active, wrapped
+	return s, nil
+}
+
+func (r *masterKeyRing) wrapDataKey(version int, dek, aad []byte) ([]byte, error) {
+	master, err := r.key(version)
+	if err != nil {
+		return nil, err
+	}
+	nonce, ciphertext, err := sealSecret(master, dek, aad)
+	if err != nil {
+		return nil, err
+	}
+	return append(nonce, ciphertext...), nil
+}
+
+func (r *masterKeyRing) unwrapDataKey(s sealedSecret, aad []byte) ([]byte, error) {
+	master, err := r.key(s.KeyVersion)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newSecretsGCM(master)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.DataKey) < gcm.NonceSize() {
+		return nil, errors.New("invalid wrapped data key")
+	}
+	ns := gcm.NonceSize()
+	return gcm.Open(nil, s.DataKey[:ns], s.DataKey[ns:], aad)
+}
+
+// sealSecret encrypts plaintext with AES-256-GCM. aad binds the ciphertext to
+// its owner so a value copied to another row fails to decrypt.
+func sealSecret(key, plaintext, aad []byte) (nonce, ciphertext []byte, err error) {
+	gcm, err := newSecretsGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, gcm.Seal(nil, nonce, plaintext, aad), nil
+}
+
+func openSecret(key, nonce, ciphertext, aad []byte) ([]byte, error) {
+	gcm, err := newSecretsGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid nonce")
+	}
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+func newSecretsGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
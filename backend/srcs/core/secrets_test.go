@@ -0,0 +1,127 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testMasterKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func TestLoadMasterKeyRing(t *testing.T) {
+	t.Setenv("MASTER_KEY_FILE", "")
+	t.Setenv("MASTER_KEY", "")
+	t.Setenv("MODULE_SECRETS_KEY", "")
+	if _, err := loadMasterKeyRing(); !errors.Is(err, ErrSecretsKeyMissing) {
+		t.Fatalf("expected ErrSecretsKeyMissing, got %v", err)
+	}
+	t.Setenv("MASTER_KEY", base64.StdEncoding.EncodeToString(make([]byte, 16)))
+	if _, err := loadMasterKeyRing(); !errors.Is(err, ErrSecretsKeyMissing) {
+		t.Fatalf("expected ErrSecretsKeyMissing for a short key, got %v", err)
+	}
+
+	t.Setenv("MASTER_KEY", testMasterKey(7))
+	ring, err := loadMasterKeyRing()
+	if err != nil || ring.active != 1 {
+		t.Fatalf("bare key: got %+v, err %v", ring, err)
+	}
+
+	t.Setenv("MASTER_KEY", "1:"+testMasterKey(1)+", 3:"+testMasterKey(3))
+	ring, err = loadMasterKeyRing()
+	if err != nil || ring.active != 3 || len(ring.keys) != 2 {
+		t.Fatalf("versioned keys: got %+v, err %v", ring, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "master.keys")
+	content := "# old key, remove once rotated\n1:" + testMasterKey(1) + "\n2:" + testMasterKey(2) + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MASTER_KEY_FILE", path)
+	ring, err = loadMasterKeyRing()
+	if err != nil || ring.active != 2 {
+		t.Fatalf("key file: got %+v, err %v", ring, err)
+	}
+
+	t.Setenv("MASTER_KEY_FILE", "")
+	t.Setenv("MASTER_KEY", "2:"+testMasterKey(2))
+	t.Setenv("MODULE_SECRETS_KEY", testMasterKey(9))
+	ring, err = loadMasterKeyRing()
+	if err != nil || ring.active != 2 || !bytes.Equal(ring.keys[1], bytes.Repeat([]byte{9}, 32)) {
+		t.Fatalf("legacy key should be version 1: got %+v, err %v", ring, err)
+	}
+}
+
+func TestEnvelopeRewrap(t *testing.T) {
+	aad := sshKeyAAD("ssh-key_1")
+	t.Setenv("MASTER_KEY_FILE", "")
+	t.Setenv("MODULE_SECRETS_KEY", "")
+	t.Setenv("MASTER_KEY", "1:"+testMasterKey(1))
+	old, err := loadMasterKeyRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := old.sealEnvelope([]byte("private"), aad)
+	if err != nil || sealed.KeyVersion != 1 {
+		t.Fatalf("seal: %+v, err %v", sealed, err)
+	}
+
+	t.Setenv("MASTER_KEY", "1:"+testMasterKey(1)+",2:"+testMasterKey(2))
+	ring, err := loadMasterKeyRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rewrapped, err := ring.rewrapEnvelope(sealed, aad)
+	if err != nil || rewrapped.KeyVersion != 2 {
+		t.Fatalf("rewrap: %+v, err %v", rewrapped, err)
+	}
+	if !bytes.Equal(rewrapped.Ciphertext, sealed.Ciphertext) {
+		t.Error("rewrap should not re-encrypt the payload")
+	}
+
+	// once rotated, the old master key can be dropped
+	t.Setenv("MASTER_KEY", "2:"+testMasterKey(2))
+	ring, err = loadMasterKeyRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := ring.openEnvelope(rewrapped, aad)
+	if err != nil || string(plain) != "private" {
+		t.Fatalf("open: got %q, err %v", plain, err)
+	}
+	if _, err := ring.openEnvelope(sealed, aad); !errors.Is(err, ErrSecretsKeyMissing) {
+		t.Errorf("expected missing key version 1, got %v", err)
+	}
+	if _, err := ring.openEnvelope(rewrapped, sshKeyAAD("ssh-key_2")); err == nil {
+		t.Error("expected open to fail for another key id")
+	}
+}
+
+func TestSealOpenSecret(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	aad := moduleSecretAAD("module_1", "DB_PASSWORD")
+
+	nonce, ciphertext, err := sealSecret(key, []byte("hunter2"), aad)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if bytes.Contains(ciphertext, []byte("hunter2")) {
+		t.Fatal("ciphertext contains the plaintext")
+	}
+	plain, err := openSecret(key, nonce, ciphertext, aad)
+	if err != nil || string(plain) != "hunter2" {
+		t.Fatalf("open: got %q, err %v", plain, err)
+	}
+
+	if _, err := openSecret(key, nonce, ciphertext, moduleSecretAAD("module_2", "DB_PASSWORD")); err == nil {
+		t.Error("expected open to fail for another module")
+	}
+	if _, err := openSecret(bytes.Repeat([]byte{2}, 32), nonce, ciphertext, aad); err == nil {
+		t.Error("expected open to fail with another key")
+	}
+}
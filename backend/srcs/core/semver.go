@@ -0,0 +1,298 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// semver is a parsed semantic version. Build metadata is dropped since it
+// does not take part in ordering.
+type semver struct {
+	Major, Minor, Patch int
+	Pre                 []string
+}
+
+// parseSemver parses a version or tag such as "v1.2.3-rc.1+build". Missing
+// minor and patch numbers are read as 0, so "v1.2" is 1.2.0.
+func parseSemver(s string) (semver, bool) {
+	v, parts, ok := parsePartialSemver(s)
+	if !ok || parts == 0 {
+		return semver{}, false
+	}
+	return v, true
+}
+
+// parsePartialSemver parses a possibly partial version and returns how many
+// of major, minor and patch were given. "*", "x" and "X" stop the version.
+func parsePartialSemver(s string) (semver, int, bool) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	var v semver
+	core, pre, hasPre := strings.Cut(s, "-")
+	if hasPre {
+		if pre == "" {
+			return semver{}, 0, false
+		}
+		v.Pre = strings.Split(pre, ".")
+		for _, id := range v.Pre {
+			if id == "" {
+				return semver{}, 0, false
+			}
+		}
+	}
+	if core == "" || core == "*" || core == "x" || core == "X" {
+		return v, 0, !hasPre
+	}
+	fields := strings.Split(core, ".")
+	if len(fields) > 3 {
+		return semver{}, 0, false
+	}
+	nums := [3]int{}
+	parts := 0
+	for i, f := range fields {
+		if f == "*" || f == "x" || f == "X" {
+			// a wildcard cannot be followed by numbers or a prerelease
+			for _, rest := range fields[i+1:] {
+				if rest != "*" && rest != "x" && rest != "X" {
+					return semver{}, 0, false
+				}
+			}
+			if hasPre {
+				return semver{}, 0, false
+			}
+			break
+		}
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 || (len(f) > 1 && f[0] == '0') {
+			return semver{}, 0, false
+		}
+		nums[i] = n
+		parts++
+	}
+	if hasPre && parts < 3 {
+		return semver{}, 0, false
+	}
+	v.Major, v.Minor, v.Patch = nums[0], nums[1], nums[2]
+	return v, parts, true
+}
+
+func (v semver) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Pre) > 0 {
+		s += "-" + strings.Join(v.Pre, ".")
+	}
+	return s
+}
+
+// compareSemver orders versions by semver precedence.
+func compareSemver(a, b semver) int {
+	for _, d := range [3]int{a.Major - b.Major, a.Minor - b.Minor, a.Patch - b.Patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	switch {
+	case len(a.Pre) == 0 && len(b.Pre) == 0:
+		return 0
+	case len(a.Pre) == 0:
+		return 1
+	case len(b.Pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(a.Pre) && i < len(b.Pre); i++ {
+		x, y := a.Pre[i], b.Pre[i]
+		xn, xErr := strconv.Atoi(x)
+		yn, yErr := strconv.Atoi(y)
+		switch {
+		case xErr == nil && yErr == nil:
+			if xn != yn {
+				return sign(xn - yn)
+			}
+		case xErr == nil:
+			return -1
+		case yErr == nil:
+			return 1
+		case x != y:
+			return strings.Compare(x, y)
+		}
+	}
+	return sign(len(a.Pre) - len(b.Pre))
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// versionComparator is one bound of a constraint, e.g. ">=1.2.0".
+type versionComparator struct {
+	op string
+	v  semver
+}
+
+func (c versionComparator) matches(v semver) bool {
+	d := compareSemver(v, c.v)
+	switch c.op {
+	case "=":
+		return d == 0
+	case ">":
+		return d > 0
+	case ">=":
+		return d >= 0
+	case "<":
+		return d < 0
+	case "<=":
+		return d <= 0
+	}
+	return false
+}
+
+// versionConstraint is a semver range in the npm/Composer style: comparators
+// separated by spaces or commas must all match, and "||" separates
+// alternatives. Supported comparators are ^1.2, ~1.2.3, 1.2.x, =, >, >=, <,
+// <= and *.
+type versionConstraint [][]versionComparator
+
+func parseVersionConstraint(s string) (versionConstraint, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, fmt.Errorf("%w: empty version constraint", ErrInvalidInput)
+	}
+	var out versionConstraint
+	for _, alt := range strings.Split(s, "||") {
+		tokens := strings.Fields(strings.ReplaceAll(alt, ",", " "))
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("%w: empty alternative in version constraint %q", ErrInvalidInput, s)
+		}
+		set := []versionComparator{}
+		for i := 0; i < len(tokens); i++ {
+			tok := tokens[i]
+			// allow a space between the operator and the version: ">= 1.2"
+			if strings.Trim(tok, "<>=^~") == "" && i+1 < len(tokens) {
+				i++
+				tok += tokens[i]
+			}
+			cs, err := parseVersionComparator(tok)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q in version constraint %q: %v", ErrInvalidInput, tok, s, err)
+			}
+			set = append(set, cs...)
+		}
+		out = append(out, set)
+	}
+	return out, nil
+}
+
+// parseVersionComparator expands one comparator into plain bounds.
+func parseVersionComparator(tok string) ([]versionComparator, error) {
+	op := ""
+	for _, p := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(tok, p) {
+			op, tok = p, tok[len(p):]
+			break
+		}
+	}
+	if tok == "" {
+		return nil, fmt.Errorf("missing version")
+	}
+	v, parts, ok := parsePartialSemver(tok)
+	if !ok {
+		return nil, fmt.Errorf("not a version")
+	}
+	// upper bounds exclude the prereleases of the next version
+	below := func(major, minor, patch int) versionComparator {
+		return versionComparator{"<", semver{Major: major, Minor: minor, Patch: patch, Pre: []string{"0"}}}
+	}
+	atLeast := versionComparator{">=", v}
+
+	switch op {
+	case "^":
+		switch {
+		case parts == 0:
+			return nil, nil
+		case v.Major > 0 || parts == 1:
+			return []versionComparator{atLeast, below(v.Major+1, 0, 0)}, nil
+		case v.Minor > 0 || parts == 2:
+			return []versionComparator{atLeast, below(0, v.Minor+1, 0)}, nil
+		}
+		return []versionComparator{atLeast, below(0, 0, v.Patch+1)}, nil
+	case "~":
+		switch parts {
+		case 0:
+			return nil, nil
+		case 1:
+			return []versionComparator{atLeast, below(v.Major+1, 0, 0)}, nil
+		}
+		return []versionComparator{atLeast, below(v.Major, v.Minor+1, 0)}, nil
+	case "", "=":
+		switch parts {
+		case 0:
+			return nil, nil
+		case 1:
+			return []versionComparator{atLeast, below(v.Major+1, 0, 0)}, nil
+		case 2:
+			return []versionComparator{atLeast, below(v.Major, v.Minor+1, 0)}, nil
+		}
+		return []versionComparator{{"=", v}}, nil
+	case ">":
+		switch parts {
+		case 0:
+			return nil, fmt.Errorf("nothing is greater than *")
+		case 1:
+			return []versionComparator{{">=", semver{Major: v.Major + 1}}}, nil
+		case 2:
+			return []versionComparator{{">=", semver{Major: v.Major, Minor: v.Minor + 1}}}, nil
+		}
+		return []versionComparator{{">", v}}, nil
+	case ">=":
+		if parts == 0 {
+			return nil, nil
+		}
+		return []versionComparator{atLeast}, nil
+	case "<":
+		if parts == 0 {
+			return nil, fmt.Errorf("nothing is lower than *")
+		}
+		return []versionComparator{{"<", v}}, nil
+	case "<=":
+		switch parts {
+		case 0:
+			return nil, nil
+		case 1:
+			return []versionComparator{below(v.Major+1, 0, 0)}, nil
+		case 2:
+			return []versionComparator{below(v.Major, v.Minor+1, 0)}, nil
+		}
+		return []versionComparator{{"<=", v}}, nil
+	}
+	return nil, fmt.Errorf("unknown operator")
+}
+
+// matches reports whether v satisfies the constraint. Prereleases only match
+// when prereleases is set.
+func (c versionConstraint) matches(v semver, prereleases bool) bool {
+	if len(v.Pre) > 0 && !prereleases {
+		return false
+	}
+	for _, set := range c {
+		ok := true
+		for _, cmp := range set {
+			if !cmp.matches(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package core
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSemver(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"v1.2.3", "1.2.3", true},
+		{"1.2.3-rc.1+build.5", "1.2.3-rc.1", true},
+		{"V2.0", "2.0.0", true},
+		{"v3", "3.0.0", true},
+		{"1.02.3", "", false},
+		{"release-1.2", "", false},
+		{"1.2.3.4", "", false},
+		{"1.2-rc.1", "", false},
+		{"latest", "", false},
+	}
+	for _, c := range cases {
+		v, ok := parseSemver(c.in)
+		if ok != c.ok || (ok && v.String() != c.want) {
+			t.Errorf("%q: got %q, %v; want %q, %v", c.in, v.String(), ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestCompareSemver(t *testing.T) {
+	ordered := []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.10.0", "2.0.0"}
+	for i := 1; i < len(ordered); i++ {
+		a, _ := parseSemver(ordered[i-1])
+		b, _ := parseSemver(ordered[i])
+		if compareSemver(a, b) >= 0 || compareSemver(b, a) <= 0 {
+			t.Errorf("%s should sort before %s", ordered[i-1], ordered[i])
+		}
+	}
+}
+
+func TestVersionConstraint(t *testing.T) {
+	cases := []struct {
+		constraint string
+		version    string
+		pre        bool
+		want       bool
+	}{
+		{"^1.2", "1.2.0", false, true},
+		{"^1.2", "1.9.7", false, true},
+		{"^1.2", "1.1.9", false, false},
+		{"^1.2", "2.0.0", false, false},
+		{"^1.2", "2.0.0-rc.1", true, false},
+		{"^0.3", "0.3.9", false, true},
+		{"^0.3", "0.4.0", false, false},
+		{"^0.0.3", "0.0.4", false, false},
+		{"~1.2.3", "1.2.9", false, true},
+		{"~1.2.3", "1.3.0", false, false},
+		{"~1", "1.9.0", false, true},
+		{"1.2.x", "1.2.5", false, true},
+		{"1.2", "1.3.0", false, false},
+		{"=1.2.3", "1.2.3", false, true},
+		{">=1.2 <1.4", "1.3.2", false, true},
+		{">= 1.2, < 1.4", "1.4.0", false, false},
+		{">1.2", "1.2.9", false, false},
+		{">1.2", "1.3.0", false, true},
+		{"<=1.2", "1.2.9", false, true},
+		{"^1.0 || ^3.0", "3.1.0", false, true},
+		{"^1.0 || ^3.0", "2.1.0", false, false},
+		{"*", "7.0.0", false, true},
+		{"^2.0.0-rc.1", "2.0.0-rc.2", false, false},
+		{"^2.0.0-rc.1", "2.0.0-rc.2", true, true},
+		{"^2.0.0-rc.1", "2.0.0", false, true},
+	}
+	for _, c := range cases {
+		cons, err := parseVersionConstraint(c.constraint)
+		if err != nil {
+			t.Fatalf("%q: %v", c.constraint, err)
+		}
+		v, ok := parseSemver(c.version)
+		if !ok {
+			t.Fatalf("bad version %q", c.version)
+		}
+		if got := cons.matches(v, c.pre); got != c.want {
+			t.Errorf("%q matches %s (prereleases %v): got %v, want %v", c.constraint, c.version, c.pre, got, c.want)
+		}
+	}
+	for _, bad := range []string{"", "^", "~>1.2", "1.2 - 1.4", ">*", "^1.x.3", "^1.2 ||"} {
+		if _, err := parseVersionConstraint(bad); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%q: want ErrInvalidInput, got %v", bad, err)
+		}
+	}
+}
+
+func TestLatestRelease(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := filepath.Join(t.TempDir(), "repo")
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@localhost"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q", "-b", "main", repo)
+	for _, tag := range []string{"v1.2.0", "v1.3.0", "v2.0.0-rc.1", "v2.0.0", "nightly"} {
+		git("-C", repo, "commit", "-q", "--allow-empty", "-m", tag)
+		git("-C", repo, "tag", "-a", "-m", tag, tag)
+	}
+	tags, err := gitSemverTags(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 4 {
+		t.Fatalf("got %d version tags: %+v", len(tags), tags)
+	}
+	head := headOf(t, repo, "v1.3.0^{commit}")
+	for _, tg := range tags {
+		if tg.Tag == "v1.3.0" && tg.Commit != head {
+			t.Errorf("annotated tag not peeled: %s vs %s", tg.Commit, head)
+		}
+	}
+
+	c, _ := parseVersionConstraint("^1.2")
+	if rel, ok := latestRelease(tags, c, false); !ok || rel.Tag != "v1.3.0" {
+		t.Errorf("^1.2: got %+v, %v", rel, ok)
+	}
+	c, _ = parseVersionConstraint(">=2.0.0-0")
+	if rel, ok := latestRelease(tags, c, true); !ok || rel.Tag != "v2.0.0" {
+		t.Errorf(">=2.0.0-0: got %+v, %v", rel, ok)
+	}
+	c, _ = parseVersionConstraint("^3")
+	if _, ok := latestRelease(tags, c, false); ok {
+		t.Error("^3 matched")
+	}
+	if rel, ok := currentRelease(tags, head); !ok || rel.Tag != "v1.3.0" {
+		t.Errorf("current release: got %+v, %v", rel, ok)
+	}
+}
+
+func headOf(t *testing.T, repo, ref string) string {
+	t.Helper()
+	out, err := exec.Command("git", "-C", repo, "rev-parse", ref).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bytesTrimSpace(out))
+}
@@ -21,7 +21,6 @@ type SSHKey struct {
 	CreatedAt  time.Time    `json:"created_at"`
 	UpdatedAt  time.Time    `json:"updated_at"`
 	LastUsedAt time.Time    `json:"last_used_at"`
-	PrivateKey string       `json:"-"`
 	UsageCount int          `json:"usage_count"`
 	CreatedBy  *SSHKeyOwner `json:"created_by,omitempty"`
 }
@@ -42,7 +41,6 @@ func dbSSHKeyToSSHKey(k database.SSHKey) SSHKey {
 		CreatedAt:  k.CreatedAt,
 		UpdatedAt:  k.UpdatedAt,
 		LastUsedAt: k.LastUsedAt,
-		PrivateKey: k.PrivateKey,
 	}
 }
 
@@ -93,6 +91,62 @@ func GetSSHKey(id string) (SSHKey, error) {
 	return dbSSHKeyToSSHKey(k), nil
 }
 
+func sshKeyAAD(id string) []byte {
+	return []byte("ssh-key:" + id)
+}
+
+func sealSSHPrivateKey(id, privateKey string) (database.SSHKeyCipher, error) {
+	ring, err := loadMasterKeyRing()
+	if err != nil {
+		return database.SSHKeyCipher{}, err
+	}
+	sealed, err := ring.sealEnvelope([]byte(privateKey), sshKeyAAD(id))
+	if err != nil {
+		return database.SSHKeyCipher{}, fmt.Errorf("encrypt ssh key: %w", err)
+	}
+	return sshKeyCipherFromSealed(sealed), nil
+}
+
+func sshKeyCipherFromSealed(s sealedSecret) database.SSHKeyCipher {
+	return database.SSHKeyCipher{
+		PrivateKeyEnc:   s.Ciphertext,
+		PrivateKeyNonce: s.Nonce,
+		DataKey:         s.DataKey,
+		KeyVersion:      sql.NullInt64{Int64: int64(s.KeyVersion), Valid: true},
+	}
+}
+
+func sealedFromSSHKeyCipher(c database.SSHKeyCipher) sealedSecret {
+	return sealedSecret{
+		KeyVersion: int(c.KeyVersion.Int64),
+		DataKey:    c.DataKey,
+		Nonce:      c.PrivateKeyNonce,
+		Ciphertext: c.PrivateKeyEnc,
+	}
+}
+
+// sshPrivateKey decrypts the private key of an SSH key. It only lives in
+// memory for as long as git needs it.
+func sshPrivateKey(id string) (string, error) {
+	k, err := database.GetSSHKey(id)
+	if err != nil {
+		return "", err
+	}
+	if !k.KeyVersion.Valid {
+		// not encrypted yet, see RewrapStoredSecrets
+		return k.PrivateKey, nil
+	}
+	ring, err := loadMasterKeyRing()
+	if err != nil {
+		return "", err
+	}
+	plain, err := ring.openEnvelope(sealedFromSSHKeyCipher(k.SSHKeyCipher), sshKeyAAD(k.ID))
+	if err != nil {
+		return "", fmt.Errorf("decrypt ssh key %s: %w", k.ID, err)
+	}
+	return string(plain), nil
+}
+
 func ensureSSHKeyName(name string) (string, error) {
 	trimmed := strings.TrimSpace(name)
 	if trimmed == "" {
@@ -136,11 +190,16 @@ func CreateSSHKey(name string, providedPrivate string, ownerUserID *string, owne
 		ownerModule = sql.NullString{String: *ownerModuleID, Valid: true}
 	}
 
+	enc, err := sealSSHPrivateKey(id, priv)
+	if err != nil {
+		return SSHKey{}, err
+	}
+
 	inserted, err := database.InsertSSHKey(database.SSHKey{
 		ID:                id,
 		Name:              trimmedName,
 		PublicKey:         pub,
-		PrivateKey:        priv,
+		SSHKeyCipher:      enc,
 		CreatedByUserID:   ownerUser,
 		CreatedByModuleID: ownerModule,
 	})
@@ -169,7 +228,11 @@ func RegenerateSSHKey(id string, providedPrivate string, ownerUserID *string) (S
 		}
 		priv = trimmed
 	}
-	updated, err := database.UpdateSSHKeyMaterial(id, pub, priv, ownerUserID)
+	enc, err := sealSSHPrivateKey(id, priv)
+	if err != nil {
+		return SSHKey{}, err
+	}
+	updated, err := database.UpdateSSHKeyMaterial(id, pub, enc, ownerUserID)
 	if err != nil {
 		return SSHKey{}, err
 	}
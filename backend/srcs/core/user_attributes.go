@@ -0,0 +1,379 @@
+package core
+
+import (
+	"backend/database"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	UserAttrString = "string"
+	UserAttrNumber = "number"
+	UserAttrBool   = "bool"
+	UserAttrDate   = "date"
+)
+
+// Keys end up in rule paths (custom.<key>) and claim names, so no dots or spaces.
+var userAttrKeyRe = regexp.MustCompile(`^[a-z][a-z0-9_]{0,62}$`)
+
+type UserAttributeDef struct {
+	Key            string    `json:"key"`
+	Label          string    `json:"label"`
+	Type           string    `json:"type"`
+	Description    string    `json:"description"`
+	ExposeInClaims bool      `json:"expose_in_claims"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type UserAttributeImportRow struct {
+	User   string         `json:"user"`
+	Values map[string]any `json:"values"`
+}
+
+type UserAttributeImportError struct {
+	Line  int    `json:"line"`
+	User  string `json:"user"`
+	Error string `json:"error"`
+}
+
+type UserAttributeImportReport struct {
+	Updated int                        `json:"updated"`
+	Errors  []UserAttributeImportError `json:"errors"`
+}
+
+func dbUserAttributeDefToDef(d database.UserAttributeDef) UserAttributeDef {
+	return UserAttributeDef{
+		Key:            d.Key,
+		Label:          d.Label,
+		Type:           d.Type,
+		Description:    d.Description,
+		ExposeInClaims: d.ExposeInClaims,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+	}
+}
+
+func ListUserAttributeDefs() ([]UserAttributeDef, error) {
+	defs, err := database.ListUserAttributeDefs()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]UserAttributeDef, 0, len(defs))
+	for _, d := range defs {
+		out = append(out, dbUserAttributeDefToDef(d))
+	}
+	return out, nil
+}
+
+func CreateUserAttributeDef(def UserAttributeDef) (UserAttributeDef, error) {
+	def.Key = strings.TrimSpace(def.Key)
+	def.Label = strings.TrimSpace(def.Label)
+	if !userAttrKeyRe.MatchString(def.Key) {
+		return UserAttributeDef{}, fmt.Errorf("%w: key must match %s", ErrInvalidInput, userAttrKeyRe.String())
+	}
+	switch def.Type {
+	case UserAttrString, UserAttrNumber, UserAttrBool, UserAttrDate:
+	default:
+		return UserAttributeDef{}, fmt.Errorf("%w: type must be string, number, bool or date", ErrInvalidInput)
+	}
+	if def.Label == "" {
+		def.Label = def.Key
+	}
+	if _, err := database.GetUserAttributeDef(def.Key); err == nil {
+		return UserAttributeDef{}, ErrAlreadyExists
+	}
+
+	created, err := database.InsertUserAttributeDef(database.UserAttributeDef{
+		Key:            def.Key,
+		Label:          def.Label,
+		Type:           def.Type,
+		Description:    def.Description,
+		ExposeInClaims: def.ExposeInClaims,
+	})
+	if err != nil {
+		return UserAttributeDef{}, err
+	}
+	return dbUserAttributeDefToDef(created), nil
+}
+
+// UpdateUserAttributeDef changes label, description and claim exposure. The type cannot change.
+func UpdateUserAttributeDef(key string, label, description *string, exposeInClaims *bool) (UserAttributeDef, error) {
+	current, err := database.GetUserAttributeDef(key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return UserAttributeDef{}, ErrNotFound
+		}
+		return UserAttributeDef{}, err
+	}
+	if label != nil && strings.TrimSpace(*label) != "" {
+		current.Label = strings.TrimSpace(*label)
+	}
+	if description != nil {
+		current.Description = *description
+	}
+	if exposeInClaims != nil {
+		current.ExposeInClaims = *exposeInClaims
+	}
+	updated, err := database.UpdateUserAttributeDef(current)
+	if err != nil {
+		return UserAttributeDef{}, err
+	}
+	return dbUserAttributeDefToDef(updated), nil
+}
+
+func DeleteUserAttributeDef(key string) error {
+	if err := database.DeleteUserAttributeDef(key); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// coerceUserAttributeValue validates raw against the attribute type and returns
+// its canonical form: string, float64, bool, or a "2006-01-02" date string.
+func coerceUserAttributeValue(attrType string, raw any) (any, error) {
+	switch attrType {
+	case UserAttrString:
+		switch v := raw.(type) {
+		case string:
+			return v, nil
+		case float64, bool:
+			return fmt.Sprint(v), nil
+		}
+	case UserAttrNumber:
+		switch v := raw.(type) {
+		case float64:
+			return v, nil
+		case int:
+			return float64(v), nil
+		case string:
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return f, nil
+			}
+		}
+	case UserAttrBool:
+		switch v := raw.(type) {
+		case bool:
+			return v, nil
+		case string:
+			switch strings.ToLower(strings.TrimSpace(v)) {
+			case "true", "yes", "y", "1":
+				return true, nil
+			case "false", "no", "n", "0":
+				return false, nil
+			}
+		}
+	case UserAttrDate:
+		if v, ok := raw.(string); ok {
+			if t, ok := tryParseDate(v); ok {
+				return t.Format("2006-01-02"), nil
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown attribute type %q", attrType)
+	}
+	return nil, fmt.Errorf("expected a %s, got %v", attrType, raw)
+}
+
+// SetUserAttributes validates and stores custom attribute values for a user.
+// A nil value removes the attribute.
+func SetUserAttributes(ctx context.Context, identifier string, values map[string]any) error {
+	userID, err := ResolveUserIdentifier(identifier)
+	if err != nil {
+		return ErrNotFound
+	}
+	defs, err := userAttributeDefsByKey()
+	if err != nil {
+		return err
+	}
+	return setUserAttributes(ctx, userID, defs, values)
+}
+
+func setUserAttributes(ctx context.Context, userID string, defs map[string]database.UserAttributeDef, values map[string]any) error {
+	upserts := map[string]json.RawMessage{}
+	var deletes []string
+	for key, raw := range values {
+		def, ok := defs[key]
+		if !ok {
+			return fmt.Errorf("%w: unknown attribute %q", ErrInvalidInput, key)
+		}
+		if raw == nil {
+			deletes = append(deletes, key)
+			continue
+		}
+		v, err := coerceUserAttributeValue(def.Type, raw)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidInput, key, err.Error())
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		upserts[key] = b
+	}
+	return database.SetUserAttributeValues(ctx, userID, upserts, deletes)
+}
+
+func userAttributeDefsByKey() (map[string]database.UserAttributeDef, error) {
+	defs, err := database.ListUserAttributeDefs()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]database.UserAttributeDef, len(defs))
+	for _, d := range defs {
+		out[d.Key] = d
+	}
+	return out, nil
+}
+
+// GetUserAttributes returns the decoded custom attributes of a user.
+func GetUserAttributes(identifier string) (map[string]any, error) {
+	userID, err := ResolveUserIdentifier(identifier)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	return userCustomAttributes(userID, false)
+}
+
+func userCustomAttributes(userID string, exposedOnly bool) (map[string]any, error) {
+	raw, err := database.GetUserAttributeValues(userID, exposedOnly)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]any, len(raw))
+	for key, value := range raw {
+		var v any
+		if err := json.Unmarshal(value, &v); err != nil {
+			continue
+		}
+		out[key] = v
+	}
+	return out, nil
+}
+
+// ImportUserAttributes applies many rows at once. Rows are independent: a bad
+// row is reported and skipped without affecting the others. The rule roles of
+// every updated user are re-evaluated in the background.
+func ImportUserAttributes(ctx context.Context, rows []UserAttributeImportRow) (UserAttributeImportReport, error) {
+	report := UserAttributeImportReport{Errors: []UserAttributeImportError{}}
+	defs, err := userAttributeDefsByKey()
+	if err != nil {
+		return report, err
+	}
+	var updated []string
+	seen := map[string]bool{}
+	for i, row := range rows {
+		userID, err := importUserAttributeRow(ctx, defs, row)
+		if err != nil {
+			report.Errors = append(report.Errors, UserAttributeImportError{Line: i + 1, User: row.User, Error: err.Error()})
+			continue
+		}
+		report.Updated++
+		if !seen[userID] {
+			seen[userID] = true
+			updated = append(updated, userID)
+		}
+	}
+	RefreshUsersRulesAsync(updated)
+	return report, nil
+}
+
+// ImportUserAttributesCSV reads a CSV whose first column is the user (login or
+// ID) and whose header names the attribute keys. Empty cells are left untouched.
+func ImportUserAttributesCSV(ctx context.Context, r io.Reader) (UserAttributeImportReport, error) {
+	rows, err := parseUserAttributesCSV(r)
+	if err != nil {
+		return UserAttributeImportReport{}, fmt.Errorf("%w: %s", ErrInvalidInput, err.Error())
+	}
+	return ImportUserAttributes(ctx, rows)
+}
+
+func parseUserAttributesCSV(r io.Reader) ([]UserAttributeImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if len(header) < 2 {
+		return nil, fmt.Errorf("header needs a user column and at least one attribute")
+	}
+
+	var rows []UserAttributeImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := UserAttributeImportRow{User: strings.TrimSpace(record[0]), Values: map[string]any{}}
+		for i := 1; i < len(header) && i < len(record); i++ {
+			if cell := strings.TrimSpace(record[i]); cell != "" {
+				row.Values[strings.TrimSpace(header[i])] = cell
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func importUserAttributeRow(ctx context.Context, defs map[string]database.UserAttributeDef, row UserAttributeImportRow) (string, error) {
+	if row.User == "" {
+		return "", fmt.Errorf("missing user")
+	}
+	userID, err := ResolveUserIdentifier(row.User)
+	if err != nil {
+		return "", fmt.Errorf("user not found")
+	}
+	return userID, setUserAttributes(ctx, userID, defs, row.Values)
+}
+
+// RefreshUserRulesAsync re-evaluates a user's rule roles in the background, e.g.
+// after their custom attributes changed.
+func RefreshUserRulesAsync(identifier string) {
+	go refreshUserRules(identifier)
+}
+
+// RefreshUsersRulesAsync is RefreshUserRulesAsync for many users, one after
+// the other.
+func RefreshUsersRulesAsync(userIDs []string) {
+	if len(userIDs) == 0 {
+		return
+	}
+	go func() {
+		for _, id := range userIDs {
+			refreshUserRules(id)
+		}
+	}()
+}
+
+// refreshUserRules re-evaluates the rules against the stored 42 profile, so no
+// 42 API call is made. Users never synced get their rules at their next login
+// or profile sync, which reads the attributes too.
+func refreshUserRules(identifier string) {
+	dbUser, err := database.GetUser(identifier)
+	if err != nil {
+		return
+	}
+	_, _, err = SyncStoredUserRoleRules(context.Background(), dbUser.ID)
+	if errors.Is(err, ErrNotFound) {
+		log.Printf("[user-attributes] %s has no stored 42 profile yet, rules wait for the next sync", dbUser.FtLogin)
+	} else if err != nil {
+		log.Printf("[user-attributes] sync rules for %s: %v", dbUser.FtLogin, err)
+	}
+}
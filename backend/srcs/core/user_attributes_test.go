@@ -0,0 +1,89 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUserAttributeKeyPattern(t *testing.T) {
+	valid := []string{"badge", "campus_badge", "a1"}
+	invalid := []string{"", "Badge", "1badge", "campus.badge", "campus badge", "_badge", strings.Repeat("a", 64)}
+	for _, k := range valid {
+		if !userAttrKeyRe.MatchString(k) {
+			t.Errorf("expected %q to be a valid key", k)
+		}
+	}
+	for _, k := range invalid {
+		if userAttrKeyRe.MatchString(k) {
+			t.Errorf("expected %q to be rejected", k)
+		}
+	}
+}
+
+func TestCoerceUserAttributeValue(t *testing.T) {
+	cases := []struct {
+		typ  string
+		raw  any
+		want any
+	}{
+		{UserAttrString, "blue", "blue"},
+		{UserAttrString, float64(42), "42"},
+		{UserAttrNumber, float64(3.5), 3.5},
+		{UserAttrNumber, " 12 ", float64(12)},
+		{UserAttrBool, true, true},
+		{UserAttrBool, "yes", true},
+		{UserAttrBool, "0", false},
+		{UserAttrDate, "2024-09-01", "2024-09-01"},
+		{UserAttrDate, "2024-09-01T10:00:00Z", "2024-09-01"},
+	}
+	for _, c := range cases {
+		got, err := coerceUserAttributeValue(c.typ, c.raw)
+		if err != nil {
+			t.Errorf("%s %v: unexpected error %v", c.typ, c.raw, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s %v: got %#v, want %#v", c.typ, c.raw, got, c.want)
+		}
+	}
+
+	bad := []struct {
+		typ string
+		raw any
+	}{
+		{UserAttrNumber, "twelve"},
+		{UserAttrBool, "maybe"},
+		{UserAttrDate, "01/09/2024"},
+		{UserAttrDate, float64(20240901)},
+		{UserAttrString, []any{"a"}},
+		{"color", "red"},
+	}
+	for _, c := range bad {
+		if _, err := coerceUserAttributeValue(c.typ, c.raw); err == nil {
+			t.Errorf("%s %v: expected an error", c.typ, c.raw)
+		}
+	}
+}
+
+func TestParseUserAttributesCSV(t *testing.T) {
+	rows, err := parseUserAttributesCSV(strings.NewReader("login,badge,alumni\nheinz,1234,\nbob,,yes\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	if rows[0].User != "heinz" || rows[0].Values["badge"] != "1234" {
+		t.Errorf("unexpected first row: %+v", rows[0])
+	}
+	if _, ok := rows[0].Values["alumni"]; ok {
+		t.Errorf("empty cell should be skipped: %+v", rows[0])
+	}
+	if _, ok := rows[1].Values["badge"]; ok || rows[1].Values["alumni"] != "yes" {
+		t.Errorf("unexpected second row: %+v", rows[1])
+	}
+
+	if _, err := parseUserAttributesCSV(strings.NewReader("login\nheinz\n")); err == nil {
+		t.Error("expected an error for a header without attributes")
+	}
+}
@@ -0,0 +1,323 @@
+package core
+
+import (
+	"archive/zip"
+	"backend/database"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// UserExport is everything Pan Bagnat stores about a user, as returned by the
+// GDPR export. Secrets (session IDs, token and code hashes) are never included.
+type UserExport struct {
+	GeneratedAt  time.Time                  `json:"generated_at"`
+	Profile      UserExportProfile          `json:"profile"`
+	RuleProfile  json.RawMessage            `json:"rule_profile,omitempty" swaggertype:"object"`
+	Roles        []UserExportRole           `json:"roles"`
+	Sessions     []UserExportSession        `json:"sessions"`
+	Preferences  map[string]json.RawMessage `json:"preferences"`
+	Attributes   map[string]any             `json:"attributes"`
+	OIDCGrants   []UserExportOIDCGrant      `json:"oidc_grants"`
+	OIDCTokens   []UserExportOIDCToken      `json:"oidc_tokens"`
+	AuditEntries []UserExportAuditEntry     `json:"audit_entries"`
+}
+
+type UserExportProfile struct {
+	ID        string    `json:"id"`
+	FtLogin   string    `json:"ft_login"`
+	FtID      int       `json:"ft_id"`
+	FtIsStaff bool      `json:"ft_is_staff"`
+	PhotoURL  string    `json:"photo_url"`
+	LastSeen  time.Time `json:"last_seen"`
+	IsAdmin   bool      `json:"is_admin"`
+}
+
+type UserExportRole struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Source string `json:"source"`
+}
+
+type UserExportSession struct {
+	DeviceLabel string    `json:"device_label"`
+	UserAgent   string    `json:"user_agent"`
+	IP          string    `json:"ip"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastSeen    time.Time `json:"last_seen"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type UserExportOIDCGrant struct {
+	ModuleID    string     `json:"module_id"`
+	ModuleName  string     `json:"module_name"`
+	ClientID    string     `json:"client_id"`
+	RedirectURI string     `json:"redirect_uri"`
+	Scopes      []string   `json:"scopes"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ConsumedAt  *time.Time `json:"consumed_at"`
+}
+
+type UserExportOIDCToken struct {
+	ID         string     `json:"id"`
+	ModuleID   string     `json:"module_id"`
+	ModuleName string     `json:"module_name"`
+	ClientID   string     `json:"client_id"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// UserExportAuditEntry is an audit entry the user performed (relation
+// "actor") or that was about them or something they own ("target").
+type UserExportAuditEntry struct {
+	Source    string    `json:"source"`
+	Relation  string    `json:"relation"`
+	SubjectID string    `json:"subject_id"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExportUserData gathers the GDPR export for a user (by ID or login).
+func ExportUserData(ctx context.Context, identifier string) (UserExport, error) {
+	var out UserExport
+
+	dbUser, err := database.GetUser(identifier)
+	if errors.Is(err, sql.ErrNoRows) {
+		return out, ErrNotFound
+	}
+	if err != nil {
+		return out, fmt.Errorf("couldn't get user: %w", err)
+	}
+	isAdmin, _ := database.UserHasRoleByID(ctx, dbUser.ID, RoleIDAdmin)
+
+	out.GeneratedAt = time.Now().UTC()
+	out.Profile = UserExportProfile{
+		ID:        dbUser.ID,
+		FtLogin:   dbUser.FtLogin,
+		FtID:      dbUser.FtID,
+		FtIsStaff: dbUser.FtIsStaff,
+		PhotoURL:  dbUser.PhotoURL,
+		LastSeen:  dbUser.LastSeen,
+		IsAdmin:   isAdmin,
+	}
+
+	out.RuleProfile, err = database.GetUserRuleProfile(dbUser.ID)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return out, fmt.Errorf("couldn't get rule profile: %w", err)
+	}
+
+	grants, err := database.GetUserRoleGrants(dbUser.ID)
+	if err != nil {
+		return out, fmt.Errorf("couldn't list roles: %w", err)
+	}
+	out.Roles = make([]UserExportRole, 0, len(grants))
+	for _, g := range grants {
+		out.Roles = append(out.Roles, UserExportRole{ID: g.ID, Name: g.Name, Source: g.Source})
+	}
+
+	sessions, err := database.ListSessionsByUserID(ctx, dbUser.ID)
+	if err != nil {
+		return out, fmt.Errorf("couldn't list sessions: %w", err)
+	}
+	out.Sessions = make([]UserExportSession, 0, len(sessions))
+	for _, s := range sessions {
+		out.Sessions = append(out.Sessions, UserExportSession{
+			DeviceLabel: s.DeviceLabel,
+			UserAgent:   s.UserAgent,
+			IP:          s.IP,
+			CreatedAt:   s.CreatedAt,
+			LastSeen:    s.LastSeen,
+			ExpiresAt:   s.ExpiresAt,
+		})
+	}
+
+	out.Preferences, err = database.ListUserPrefs(ctx, dbUser.ID)
+	if err != nil {
+		return out, fmt.Errorf("couldn't list preferences: %w", err)
+	}
+
+	out.Attributes, err = userCustomAttributes(dbUser.ID, false)
+	if err != nil {
+		return out, fmt.Errorf("couldn't list custom attributes: %w", err)
+	}
+
+	moduleNames := map[string]string{}
+	moduleName := func(moduleID string) string {
+		if name, ok := moduleNames[moduleID]; ok {
+			return name
+		}
+		name := ""
+		if mod, err := database.GetModule(moduleID); err == nil {
+			name = mod.Name
+		}
+		moduleNames[moduleID] = name
+		return name
+	}
+
+	codes, err := database.ListOIDCAuthorizationCodesByUser(dbUser.ID)
+	if err != nil {
+		return out, fmt.Errorf("couldn't list oidc grants: %w", err)
+	}
+	out.OIDCGrants = make([]UserExportOIDCGrant, 0, len(codes))
+	for _, c := range codes {
+		grant := UserExportOIDCGrant{
+			ModuleID:    c.ModuleID,
+			ModuleName:  moduleName(c.ModuleID),
+			ClientID:    c.ClientID,
+			RedirectURI: c.RedirectURI,
+			Scopes:      c.Scopes,
+			CreatedAt:   c.CreatedAt,
+		}
+		if c.ConsumedAt.Valid {
+			t := c.ConsumedAt.Time
+			grant.ConsumedAt = &t
+		}
+		out.OIDCGrants = append(out.OIDCGrants, grant)
+	}
+
+	tokens, err := database.ListOIDCAccessTokensByUser(dbUser.ID)
+	if err != nil {
+		return out, fmt.Errorf("couldn't list oidc tokens: %w", err)
+	}
+	out.OIDCTokens = make([]UserExportOIDCToken, 0, len(tokens))
+	for _, t := range tokens {
+		token := UserExportOIDCToken{
+			ID:         t.ID,
+			ModuleID:   t.ModuleID,
+			ModuleName: moduleName(t.ModuleID),
+			ClientID:   t.ClientID,
+			Scopes:     t.Scopes,
+			CreatedAt:  t.CreatedAt,
+			ExpiresAt:  t.ExpiresAt,
+		}
+		if t.RevokedAt.Valid {
+			revoked := t.RevokedAt.Time
+			token.RevokedAt = &revoked
+		}
+		out.OIDCTokens = append(out.OIDCTokens, token)
+	}
+
+	events, err := database.ListSSHKeyEventsByActor(dbUser.ID)
+	if err != nil {
+		return out, fmt.Errorf("couldn't list audit entries: %w", err)
+	}
+	out.AuditEntries = make([]UserExportAuditEntry, 0, len(events))
+	for _, ev := range events {
+		out.AuditEntries = append(out.AuditEntries, UserExportAuditEntry{
+			Source:    "ssh_key_events",
+			Relation:  "actor",
+			SubjectID: ev.SSHKeyID,
+			Message:   ev.Message,
+			CreatedAt: ev.CreatedAt,
+		})
+	}
+
+	events, err = database.ListSSHKeyEventsOnUserKeys(dbUser.ID)
+	if err != nil {
+		return out, fmt.Errorf("couldn't list audit entries: %w", err)
+	}
+	for _, ev := range events {
+		out.AuditEntries = append(out.AuditEntries, UserExportAuditEntry{
+			Source:    "ssh_key_events",
+			Relation:  "target",
+			SubjectID: ev.SSHKeyID,
+			Message:   ev.Message,
+			CreatedAt: ev.CreatedAt,
+		})
+	}
+
+	runs, err := database.ListUserLifecycleRunsForUser(ctx, dbUser.ID)
+	if err != nil {
+		return out, fmt.Errorf("couldn't list lifecycle runs: %w", err)
+	}
+	for _, run := range runs {
+		var report UserLifecycleReport
+		if err := json.Unmarshal(run.Report, &report); err != nil {
+			continue
+		}
+		report.RunID = run.ID
+		out.AuditEntries = append(out.AuditEntries, lifecycleAuditEntries(report, dbUser.ID)...)
+	}
+
+	return out, nil
+}
+
+// lifecycleAuditEntries lists what a lifecycle run did to a user.
+func lifecycleAuditEntries(report UserLifecycleReport, userID string) []UserExportAuditEntry {
+	prefix := ""
+	if report.DryRun {
+		prefix = "(dry run) "
+	}
+	var out []UserExportAuditEntry
+	add := func(entries []UserLifecycleEntry, message func(UserLifecycleEntry) string) {
+		for _, e := range entries {
+			if e.UserID != userID {
+				continue
+			}
+			out = append(out, UserExportAuditEntry{
+				Source:    "user_lifecycle_runs",
+				Relation:  "target",
+				SubjectID: report.RunID,
+				Message:   prefix + message(e),
+				CreatedAt: report.FinishedAt,
+			})
+		}
+	}
+	fixed := func(msg string) func(UserLifecycleEntry) string {
+		return func(UserLifecycleEntry) string { return msg }
+	}
+	add(report.MarkedDormant, fixed("Account marked dormant"))
+	add(report.Notified, fixed("Notified of the upcoming account removal"))
+	add(report.Reactivated, fixed("Account reactivated"))
+	add(report.Anonymized, fixed("Account anonymized"))
+	add(report.Deleted, fixed("Account deleted"))
+	add(report.Errors, func(e UserLifecycleEntry) string { return "Lifecycle action failed: " + e.Error })
+	return out
+}
+
+// WriteUserExportZip writes the export as a zip archive with one JSON file per section.
+func WriteUserExportZip(w io.Writer, export UserExport) error {
+	zw := zip.NewWriter(w)
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"manifest.json", map[string]any{
+			"generated_at": export.GeneratedAt,
+			"user_id":      export.Profile.ID,
+			"ft_login":     export.Profile.FtLogin,
+		}},
+		{"profile.json", export.Profile},
+		{"rule_profile.json", export.RuleProfile},
+		{"roles.json", export.Roles},
+		{"sessions.json", export.Sessions},
+		{"preferences.json", export.Preferences},
+		{"attributes.json", export.Attributes},
+		{"oidc_grants.json", export.OIDCGrants},
+		{"oidc_tokens.json", export.OIDCTokens},
+		{"audit_entries.json", export.AuditEntries},
+	}
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     f.name,
+			Method:   zip.Deflate,
+			Modified: export.GeneratedAt,
+		})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
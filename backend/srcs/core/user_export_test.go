@@ -0,0 +1,98 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestLifecycleAuditEntries(t *testing.T) {
+	finished := time.Date(2026, 3, 1, 4, 0, 0, 0, time.UTC)
+	report := UserLifecycleReport{
+		RunID:         "lifecycle-run_01",
+		FinishedAt:    finished,
+		MarkedDormant: []UserLifecycleEntry{{UserID: "user_a"}, {UserID: "user_b"}},
+		Notified:      []UserLifecycleEntry{{UserID: "user_a"}},
+		Errors:        []UserLifecycleEntry{{UserID: "user_a", Error: "webhook timeout"}},
+		Deleted:       []UserLifecycleEntry{{UserID: "user_b"}},
+	}
+
+	got := lifecycleAuditEntries(report, "user_a")
+	want := []string{
+		"Account marked dormant",
+		"Notified of the upcoming account removal",
+		"Lifecycle action failed: webhook timeout",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(got), len(want), got)
+	}
+	for i, e := range got {
+		if e.Message != want[i] || e.Source != "user_lifecycle_runs" || e.Relation != "target" ||
+			e.SubjectID != "lifecycle-run_01" || !e.CreatedAt.Equal(finished) {
+			t.Errorf("entry %d = %+v, want message %q", i, e, want[i])
+		}
+	}
+
+	report.DryRun = true
+	got = lifecycleAuditEntries(report, "user_b")
+	if len(got) != 2 || got[1].Message != "(dry run) Account deleted" {
+		t.Errorf("dry run entries = %+v", got)
+	}
+	if got := lifecycleAuditEntries(report, "user_c"); len(got) != 0 {
+		t.Errorf("unrelated user got %+v", got)
+	}
+}
+
+func TestWriteUserExportZip(t *testing.T) {
+	export := UserExport{
+		GeneratedAt: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		Profile:     UserExportProfile{ID: "user_a", FtLogin: "heinz"},
+		AuditEntries: []UserExportAuditEntry{
+			{Source: "ssh_key_events", Relation: "actor", SubjectID: "ssh-key_1", Message: "created"},
+		},
+	}
+	var buf bytes.Buffer
+	if err := WriteUserExportZip(&buf, export); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		files[f.Name] = f
+	}
+	sort.Strings(names)
+	wantNames := []string{
+		"attributes.json", "audit_entries.json", "manifest.json", "oidc_grants.json",
+		"oidc_tokens.json", "preferences.json", "profile.json", "roles.json", "rule_profile.json", "sessions.json",
+	}
+	if len(names) != len(wantNames) {
+		t.Fatalf("files = %v, want %v", names, wantNames)
+	}
+	for i := range names {
+		if names[i] != wantNames[i] {
+			t.Fatalf("files = %v, want %v", names, wantNames)
+		}
+	}
+
+	rc, err := files["audit_entries.json"].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	var entries []UserExportAuditEntry
+	if err := json.NewDecoder(rc).Decode(&entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Relation != "actor" || entries[0].SubjectID != "ssh-key_1" {
+		t.Errorf("audit_entries.json = %+v", entries)
+	}
+}
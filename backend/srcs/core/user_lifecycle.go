@@ -0,0 +1,304 @@
+package core
+
+import (
+	"backend/database"
+	"backend/websocket"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	LifecycleActionNone      = "none"
+	LifecycleActionAnonymize = "anonymize"
+	LifecycleActionDelete    = "delete"
+)
+
+// UserLifecyclePolicy decides when inactive accounts become dormant and what
+// happens to them once the grace period is over.
+type UserLifecyclePolicy struct {
+	DormantAfter time.Duration `json:"dormant_after" swaggertype:"integer"`
+	GracePeriod  time.Duration `json:"grace_period" swaggertype:"integer"`
+	Action       string        `json:"action"`
+	NotifyURL    string        `json:"-"`
+}
+
+type UserLifecycleEntry struct {
+	UserID string `json:"user_id"`
+	Error  string `json:"error,omitempty"`
+}
+
+// UserLifecycleReport is produced by every run and stored in user_lifecycle_runs.
+// Accounts are listed by ID only so the report holds no personal data. A run
+// that stops early is stored too, with what it did so far and Error set.
+type UserLifecycleReport struct {
+	RunID         string               `json:"run_id"`
+	StartedAt     time.Time            `json:"started_at"`
+	FinishedAt    time.Time            `json:"finished_at"`
+	DryRun        bool                 `json:"dry_run"`
+	Policy        UserLifecyclePolicy  `json:"policy"`
+	MarkedDormant []UserLifecycleEntry `json:"marked_dormant"`
+	Notified      []UserLifecycleEntry `json:"notified"`
+	Reactivated   []UserLifecycleEntry `json:"reactivated"`
+	Anonymized    []UserLifecycleEntry `json:"anonymized"`
+	Deleted       []UserLifecycleEntry `json:"deleted"`
+	Errors        []UserLifecycleEntry `json:"errors"`
+	Error         string               `json:"error,omitempty"`
+}
+
+var (
+	userLifecycleOnce sync.Once
+	userLifecycleMu   sync.Mutex
+)
+
+// LoadUserLifecyclePolicy reads the policy from USER_LIFECYCLE_* env vars.
+// Defaults: dormant after 18 months, 30 days grace, no destructive action.
+func LoadUserLifecyclePolicy() UserLifecyclePolicy {
+	action := strings.ToLower(strings.TrimSpace(os.Getenv("USER_LIFECYCLE_ACTION")))
+	switch action {
+	case LifecycleActionAnonymize, LifecycleActionDelete:
+	default:
+		action = LifecycleActionNone
+	}
+	return UserLifecyclePolicy{
+		DormantAfter: envDuration("USER_LIFECYCLE_DORMANT_AFTER", 18*30*24*time.Hour),
+		GracePeriod:  envDuration("USER_LIFECYCLE_GRACE_PERIOD", 30*24*time.Hour),
+		Action:       action,
+		NotifyURL:    strings.TrimSpace(os.Getenv("USER_LIFECYCLE_NOTIFY_URL")),
+	}
+}
+
+// StartUserLifecycleJob runs the lifecycle policy every USER_LIFECYCLE_INTERVAL.
+// The job is off unless the interval is set.
+func StartUserLifecycleJob() {
+	interval := envDuration("USER_LIFECYCLE_INTERVAL", 0)
+	if interval <= 0 {
+		return
+	}
+	userLifecycleOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for range ticker.C {
+				report, err := RunUserLifecycle(context.Background(), false)
+				if err != nil {
+					log.Printf("[user-lifecycle] run %s failed: %v", report.RunID, err)
+					continue
+				}
+				log.Printf("[user-lifecycle] run %s: %d dormant, %d reactivated, %d anonymized, %d deleted, %d errors",
+					report.RunID, len(report.MarkedDormant), len(report.Reactivated),
+					len(report.Anonymized), len(report.Deleted), len(report.Errors))
+			}
+		}()
+	})
+}
+
+// RunUserLifecycle applies the policy once and stores the report. With dryRun
+// nothing is changed and nobody is notified, but the report lists what would happen.
+func RunUserLifecycle(ctx context.Context, dryRun bool) (UserLifecycleReport, error) {
+	userLifecycleMu.Lock()
+	defer userLifecycleMu.Unlock()
+
+	policy := LoadUserLifecyclePolicy()
+	now := time.Now()
+	report := UserLifecycleReport{
+		StartedAt:     now,
+		DryRun:        dryRun,
+		Policy:        policy,
+		MarkedDormant: []UserLifecycleEntry{},
+		Notified:      []UserLifecycleEntry{},
+		Reactivated:   []UserLifecycleEntry{},
+		Anonymized:    []UserLifecycleEntry{},
+		Deleted:       []UserLifecycleEntry{},
+		Errors:        []UserLifecycleEntry{},
+	}
+	runErr := applyUserLifecycle(ctx, policy, now, dryRun, &report)
+	if runErr != nil {
+		report.Error = runErr.Error()
+	}
+
+	// Stored even when the run stopped early: the actions already taken are kept
+	report.FinishedAt = time.Now()
+	raw, err := json.Marshal(report)
+	if err != nil {
+		return report, errors.Join(runErr, err)
+	}
+	run, err := database.InsertUserLifecycleRun(ctx, database.UserLifecycleRun{
+		StartedAt:  report.StartedAt,
+		FinishedAt: report.FinishedAt,
+		DryRun:     dryRun,
+		Report:     raw,
+	})
+	if err != nil {
+		return report, errors.Join(runErr, fmt.Errorf("store lifecycle report: %w", err))
+	}
+	report.RunID = run.ID
+	return report, runErr
+}
+
+// applyUserLifecycle runs the policy steps, filling report as it goes.
+func applyUserLifecycle(ctx context.Context, policy UserLifecyclePolicy, now time.Time, dryRun bool, report *UserLifecycleReport) error {
+	fail := func(u database.User, err error) {
+		report.Errors = append(report.Errors, UserLifecycleEntry{UserID: u.ID, Error: err.Error()})
+	}
+
+	// 1) Dormant accounts that came back (or became exempt) are cleared first
+	reactivated, err := database.ListReactivatedDormant(ctx)
+	if err != nil {
+		return fmt.Errorf("list reactivated users: %w", err)
+	}
+	for _, u := range reactivated {
+		if !dryRun {
+			if err := database.ClearUserDormant(ctx, u.ID); err != nil {
+				fail(u, err)
+				continue
+			}
+		}
+		report.Reactivated = append(report.Reactivated, UserLifecycleEntry{UserID: u.ID})
+	}
+
+	// 2) Prune accounts whose grace period is over
+	if policy.Action != LifecycleActionNone {
+		due, err := database.ListUsersDueForPruning(ctx, now.Add(-policy.GracePeriod))
+		if err != nil {
+			return fmt.Errorf("list users due for pruning: %w", err)
+		}
+		for _, u := range due {
+			entry := UserLifecycleEntry{UserID: u.ID}
+			if policy.Action == LifecycleActionDelete {
+				if !dryRun {
+					if err := DeleteUserAndAssociations(ctx, u.ID); err != nil {
+						fail(u, err)
+						continue
+					}
+				}
+				report.Deleted = append(report.Deleted, entry)
+			} else {
+				if !dryRun {
+					if err := database.AnonymizeUser(ctx, u.ID, now); err != nil {
+						fail(u, err)
+						continue
+					}
+				}
+				report.Anonymized = append(report.Anonymized, entry)
+			}
+		}
+	}
+
+	// 3) Mark newly inactive accounts dormant and let them know
+	candidates, err := database.ListDormancyCandidates(ctx, now.Add(-policy.DormantAfter))
+	if err != nil {
+		return fmt.Errorf("list dormancy candidates: %w", err)
+	}
+	for _, u := range candidates {
+		if !dryRun {
+			if err := database.SetUserDormant(ctx, u.ID, now); err != nil {
+				fail(u, err)
+				continue
+			}
+		}
+		entry := UserLifecycleEntry{UserID: u.ID}
+		report.MarkedDormant = append(report.MarkedDormant, entry)
+
+		if dryRun || policy.NotifyURL == "" {
+			continue
+		}
+		if err := notifyDormantUser(ctx, policy, u, now); err != nil {
+			fail(u, fmt.Errorf("notify: %w", err))
+			continue
+		}
+		report.Notified = append(report.Notified, entry)
+	}
+	return nil
+}
+
+// notifyDormantUser posts a signed notice to USER_LIFECYCLE_NOTIFY_URL, which is
+// expected to relay it to the user (mail, intra message...). Signed like /webhooks/events.
+func notifyDormantUser(ctx context.Context, policy UserLifecyclePolicy, u database.User, now time.Time) error {
+	body, err := json.Marshal(map[string]any{
+		"event":        "user.dormant",
+		"user_id":      u.ID,
+		"login":        u.FtLogin,
+		"last_seen":    u.LastSeen,
+		"dormant_at":   now,
+		"action":       policy.Action,
+		"action_after": now.Add(policy.GracePeriod),
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, policy.NotifyURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(websocket.Secret) > 0 {
+		mac := hmac.New(sha256.New, websocket.Secret)
+		mac.Write(body)
+		req.Header.Set("X-Hook-Signature", hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notify endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SetUserLifecycleExempt exempts (or un-exempts) a user from the lifecycle policy.
+func SetUserLifecycleExempt(ctx context.Context, identifier string, exempt bool, reason string) error {
+	userID, err := ResolveUserIdentifier(identifier)
+	if err != nil {
+		return ErrNotFound
+	}
+	var reasonPtr *string
+	if exempt && strings.TrimSpace(reason) != "" {
+		trimmed := strings.TrimSpace(reason)
+		reasonPtr = &trimmed
+	}
+	if err := database.SetUserLifecycleExempt(ctx, userID, exempt, reasonPtr); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	if exempt {
+		return database.ClearUserDormant(ctx, userID)
+	}
+	return nil
+}
+
+func ListUserLifecycleReports(ctx context.Context, limit int) ([]UserLifecycleReport, error) {
+	runs, err := database.ListUserLifecycleRuns(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]UserLifecycleReport, 0, len(runs))
+	for _, run := range runs {
+		var report UserLifecycleReport
+		if err := json.Unmarshal(run.Report, &report); err != nil {
+			continue
+		}
+		report.RunID = run.ID
+		out = append(out, report)
+	}
+	return out, nil
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"backend/database"
+	"backend/websocket"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoadUserLifecyclePolicy(t *testing.T) {
+	t.Setenv("USER_LIFECYCLE_DORMANT_AFTER", "")
+	t.Setenv("USER_LIFECYCLE_GRACE_PERIOD", "")
+	t.Setenv("USER_LIFECYCLE_ACTION", "")
+	t.Setenv("USER_LIFECYCLE_NOTIFY_URL", "")
+	p := LoadUserLifecyclePolicy()
+	if p.DormantAfter != 18*30*24*time.Hour || p.GracePeriod != 30*24*time.Hour || p.Action != LifecycleActionNone {
+		t.Errorf("defaults = %+v", p)
+	}
+
+	t.Setenv("USER_LIFECYCLE_DORMANT_AFTER", "48h")
+	t.Setenv("USER_LIFECYCLE_GRACE_PERIOD", "1h")
+	t.Setenv("USER_LIFECYCLE_ACTION", " Delete ")
+	t.Setenv("USER_LIFECYCLE_NOTIFY_URL", " https://hooks.example/dormant ")
+	p = LoadUserLifecyclePolicy()
+	if p.DormantAfter != 48*time.Hour || p.GracePeriod != time.Hour || p.Action != LifecycleActionDelete || p.NotifyURL != "https://hooks.example/dormant" {
+		t.Errorf("policy = %+v", p)
+	}
+
+	// Unknown actions must never turn into something destructive
+	t.Setenv("USER_LIFECYCLE_ACTION", "purge")
+	if p := LoadUserLifecyclePolicy(); p.Action != LifecycleActionNone {
+		t.Errorf("unknown action = %q, want none", p.Action)
+	}
+}
+
+func TestNotifyDormantUser(t *testing.T) {
+	oldSecret := websocket.Secret
+	websocket.Secret = []byte("s3cret")
+	t.Cleanup(func() { websocket.Secret = oldSecret })
+
+	var body []byte
+	var signature string
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get("X-Hook-Signature")
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	policy := UserLifecyclePolicy{GracePeriod: 24 * time.Hour, Action: LifecycleActionAnonymize, NotifyURL: srv.URL}
+	user := database.User{ID: "user_a", FtLogin: "heinz", LastSeen: now.AddDate(-2, 0, 0)}
+	if err := notifyDormantUser(context.Background(), policy, user, now); err != nil {
+		t.Fatalf("notifyDormantUser() error = %v", err)
+	}
+
+	mac := hmac.New(sha256.New, websocket.Secret)
+	mac.Write(body)
+	if want := hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Errorf("signature = %q, want %q", signature, want)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["event"] != "user.dormant" || got["user_id"] != "user_a" || got["login"] != "heinz" ||
+		got["action"] != LifecycleActionAnonymize || got["action_after"] != now.Add(24*time.Hour).Format(time.RFC3339) {
+		t.Errorf("payload = %v", got)
+	}
+
+	status = http.StatusBadGateway
+	if err := notifyDormantUser(context.Background(), policy, user, now); err == nil {
+		t.Error("notifyDormantUser() accepted a 502")
+	}
+}
@@ -0,0 +1,190 @@
+package core
+
+import (
+	"backend/database"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseUserQuery turns a user search string into database query terms.
+//
+// Supported syntax (terms are space separated and AND-ed):
+//
+//	heinz                free text, matches login or 42 id
+//	role:tutor           has role (by id, id without "roles_" prefix, or name)
+//	-role:student        any term can be negated with a leading '-'
+//	staff:true           42 staff flag
+//	ft_id:1492           exact 42 id
+//	login:heinz          exact login
+//	login:~^ab           login matches a (case-insensitive) regex
+//	last_seen<30d        seen within the last 30 days (units: h, d, w, y)
+//	last_seen>90d        not seen for more than 90 days
+//
+// Values containing spaces can be double-quoted: role:"Club Member".
+func ParseUserQuery(query string) ([]database.UserQueryTerm, error) {
+	return parseUserQuery(query, time.Now())
+}
+
+func parseUserQuery(query string, now time.Time) ([]database.UserQueryTerm, error) {
+	tokens, err := tokenizeUserQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var terms []database.UserQueryTerm
+	for _, tok := range tokens {
+		term, err := parseUserQueryToken(tok, now)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidInput, err.Error())
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+// tokenizeUserQuery splits on whitespace, keeping double-quoted sections
+// together and dropping the quotes themselves.
+func tokenizeUserQuery(query string) ([]string, error) {
+	var tokens []string
+	var cur strings.Builder
+	inQuotes := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case (r == ' ' || r == '\t' || r == '\n') && !inQuotes:
+			if cur.Len() > 0 {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("%w: unterminated quote in query", ErrInvalidInput)
+	}
+	if cur.Len() > 0 {
+		tokens = append(tokens, cur.String())
+	}
+	return tokens, nil
+}
+
+func parseUserQueryToken(tok string, now time.Time) (database.UserQueryTerm, error) {
+	term := database.UserQueryTerm{Op: database.UserQueryEq}
+
+	if len(tok) > 1 && tok[0] == '-' {
+		term.Negate = true
+		tok = tok[1:]
+	}
+
+	if strings.HasPrefix(tok, "last_seen") {
+		return parseLastSeenTerm(term, strings.TrimPrefix(tok, "last_seen"), now)
+	}
+
+	key, value, found := strings.Cut(tok, ":")
+	if !found {
+		term.Field = database.UserQueryText
+		term.Value = tok
+		return term, nil
+	}
+	if value == "" {
+		return term, fmt.Errorf("missing value for %q", key)
+	}
+
+	switch strings.ToLower(key) {
+	case "role":
+		term.Field = database.UserQueryRole
+		term.Value = value
+	case "staff":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return term, fmt.Errorf("staff expects true or false, got %q", value)
+		}
+		term.Field = database.UserQueryStaff
+		term.Value = b
+	case "ft_id":
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return term, fmt.Errorf("ft_id expects a number, got %q", value)
+		}
+		term.Field = database.UserQueryFtID
+		term.Value = id
+	case "login":
+		term.Field = database.UserQueryLogin
+		if strings.HasPrefix(value, "~") {
+			pattern := value[1:]
+			if _, err := regexp.Compile(pattern); err != nil {
+				return term, fmt.Errorf("invalid login regex %q", pattern)
+			}
+			term.Op = database.UserQueryRegex
+			value = pattern
+		}
+		term.Value = value
+	default:
+		return term, fmt.Errorf("unknown query key %q", key)
+	}
+	return term, nil
+}
+
+// parseLastSeenTerm handles "<30d", ">=2w"... An age below the duration means
+// the user was seen after the cutoff, so the comparison is flipped for SQL.
+func parseLastSeenTerm(term database.UserQueryTerm, rest string, now time.Time) (database.UserQueryTerm, error) {
+	term.Field = database.UserQueryLastSeen
+
+	var op string
+	for _, candidate := range []string{"<=", ">=", "<", ">"} {
+		if strings.HasPrefix(rest, candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		return term, fmt.Errorf("last_seen expects <, <=, > or >= followed by a duration")
+	}
+
+	age, err := parseQueryDuration(strings.TrimPrefix(rest, op))
+	if err != nil {
+		return term, err
+	}
+
+	switch op {
+	case "<":
+		term.Op = database.UserQueryGt
+	case "<=":
+		term.Op = database.UserQueryGte
+	case ">":
+		term.Op = database.UserQueryLt
+	case ">=":
+		term.Op = database.UserQueryLte
+	}
+	term.Value = now.Add(-age)
+	return term, nil
+}
+
+func parseQueryDuration(s string) (time.Duration, error) {
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	var unit time.Duration
+	switch s[len(s)-1] {
+	case 'h':
+		unit = time.Hour
+	case 'd':
+		unit = 24 * time.Hour
+	case 'w':
+		unit = 7 * 24 * time.Hour
+	case 'y':
+		unit = 365 * 24 * time.Hour
+	default:
+		return 0, fmt.Errorf("invalid duration unit in %q (use h, d, w or y)", s)
+	}
+	return time.Duration(n) * unit, nil
+}
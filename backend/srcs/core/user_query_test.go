@@ -0,0 +1,109 @@
+package core
+
+import (
+	"backend/database"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseUserQuery(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []database.UserQueryTerm
+	}{
+		{
+			name:  "empty",
+			query: "   ",
+			want:  nil,
+		},
+		{
+			name:  "free text",
+			query: "heinz",
+			want: []database.UserQueryTerm{
+				{Field: database.UserQueryText, Op: database.UserQueryEq, Value: "heinz"},
+			},
+		},
+		{
+			name:  "roles and negation",
+			query: "role:tutor -role:student",
+			want: []database.UserQueryTerm{
+				{Field: database.UserQueryRole, Op: database.UserQueryEq, Value: "tutor"},
+				{Field: database.UserQueryRole, Op: database.UserQueryEq, Value: "student", Negate: true},
+			},
+		},
+		{
+			name:  "quoted role name",
+			query: `role:"Club Member"`,
+			want: []database.UserQueryTerm{
+				{Field: database.UserQueryRole, Op: database.UserQueryEq, Value: "Club Member"},
+			},
+		},
+		{
+			name:  "staff and ft_id",
+			query: "staff:true ft_id:1492",
+			want: []database.UserQueryTerm{
+				{Field: database.UserQueryStaff, Op: database.UserQueryEq, Value: true},
+				{Field: database.UserQueryFtID, Op: database.UserQueryEq, Value: 1492},
+			},
+		},
+		{
+			name:  "login exact and regex",
+			query: "login:heinz login:~^ab",
+			want: []database.UserQueryTerm{
+				{Field: database.UserQueryLogin, Op: database.UserQueryEq, Value: "heinz"},
+				{Field: database.UserQueryLogin, Op: database.UserQueryRegex, Value: "^ab"},
+			},
+		},
+		{
+			name:  "admins not seen in 90 days",
+			query: "role:admin last_seen>90d",
+			want: []database.UserQueryTerm{
+				{Field: database.UserQueryRole, Op: database.UserQueryEq, Value: "admin"},
+				{Field: database.UserQueryLastSeen, Op: database.UserQueryLt, Value: now.Add(-90 * 24 * time.Hour)},
+			},
+		},
+		{
+			name:  "seen recently",
+			query: "last_seen<=2w",
+			want: []database.UserQueryTerm{
+				{Field: database.UserQueryLastSeen, Op: database.UserQueryGte, Value: now.Add(-14 * 24 * time.Hour)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseUserQuery(tt.query, now)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("parseUserQuery(%q) mismatch (-want +got):\n%s", tt.query, diff)
+			}
+		})
+	}
+}
+
+func TestParseUserQuery_Invalid(t *testing.T) {
+	cases := []string{
+		"foo:bar",
+		"role:",
+		"staff:maybe",
+		"ft_id:abc",
+		"login:~(",
+		"last_seen=30d",
+		"last_seen<30x",
+		`role:"unterminated`,
+	}
+	for _, q := range cases {
+		if _, err := ParseUserQuery(q); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("ParseUserQuery(%q) error = %v, want ErrInvalidInput", q, err)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package core
+
+import (
+	"backend/database"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var userSyncOnce sync.Once
+
+// userRulePayload builds the map role rules are evaluated against for a user:
+// the 42 profile, plus admin-defined attributes under "custom".
+func userRulePayload(userID string, intra User42) (map[string]any, error) {
+	custom, err := userCustomAttributes(userID, false)
+	if err != nil {
+		return nil, fmt.Errorf("load custom attributes: %w", err)
+	}
+	return rulePayload(intra, custom)
+}
+
+func rulePayload(intra User42, custom map[string]any) (map[string]any, error) {
+	payload, err := toEvalMap(intra)
+	if err != nil {
+		return nil, err
+	}
+	payload["custom"] = custom
+	return payload, nil
+}
+
+// intraProfile returns user with the profile fields of the 42 account. The
+// login may differ when it was renamed on the intra side; the ft_id may not,
+// unless the row has none yet.
+func intraProfile(user database.User, intra User42) (database.User, error) {
+	if user.FtID != 0 && user.FtID != intra.ID {
+		return user, fmt.Errorf("%w: 42 account %d is not the one of user %s (%d)", ErrConflict, intra.ID, user.ID, user.FtID)
+	}
+	user.FtLogin = intra.Login
+	user.FtID = intra.ID
+	user.FtIsStaff = intra.Staff
+	user.PhotoURL = intra.Image.Link
+	return user, nil
+}
+
+// intraUserKey is what the 42 API is queried with for user: the stable ft_id,
+// or the login for rows without one.
+func intraUserKey(user database.User) string {
+	if user.FtID != 0 {
+		return strconv.Itoa(user.FtID)
+	}
+	return user.FtLogin
+}
+
+// matchIntraUser picks the local row of the 42 account ftID among the rows
+// found by ft_id and by login. ft_id is the stable key: the login row only
+// matches when it has no ft_id yet. A login row left over is stale, its login
+// now belongs to the account logging in.
+func matchIntraUser(byID, byLogin *database.User, ftID int) (match, stale *database.User) {
+	match = byID
+	if match == nil && byLogin != nil && (byLogin.FtID == 0 || byLogin.FtID == ftID) {
+		match = byLogin
+	}
+	if byLogin != nil && (match == nil || byLogin.ID != match.ID) {
+		stale = byLogin
+	}
+	return match, stale
+}
+
+// RefreshUserFromIntra mirrors the 42 profile onto the local user row and
+// re-evaluates every rule-based role for that user. user is only updated once
+// the row is, so a failed rename leaves the login the sessions still use.
+func RefreshUserFromIntra(ctx context.Context, user *database.User, intra User42) error {
+	updated, err := intraProfile(*user, intra)
+	if err != nil {
+		return err
+	}
+	if err := database.UpdateUserProfile(updated, time.Now()); err != nil {
+		return fmt.Errorf("update profile: %w", err)
+	}
+	*user = updated
+
+	added, removed, err := SyncUserRoleRules(ctx, user.ID, intra)
+	if err != nil {
+		return fmt.Errorf("sync role rules: %w", err)
+	}
+	if added > 0 || removed > 0 {
+		log.Printf("[user-sync] %s: +%d/-%d rule roles", user.FtLogin, added, removed)
+	}
+	return nil
+}
+
+// SyncUserRoleRules evaluates all non-default roles carrying rules against the
+// user's 42 profile. Matching roles are linked with source "rule", and rule
+// links that no longer match are removed. Manual and default links are kept.
+// The profile is stored so SyncStoredUserRoleRules can evaluate again later.
+func SyncUserRoleRules(ctx context.Context, userID string, intra User42) (added int, removed int, err error) {
+	raw, err := json.Marshal(intra)
+	if err != nil {
+		return 0, 0, fmt.Errorf("marshal rule profile: %w", err)
+	}
+	if err := database.SetUserRuleProfile(userID, raw); err != nil {
+		return 0, 0, fmt.Errorf("store rule profile: %w", err)
+	}
+	return syncRuleRoles(ctx, userID, intra)
+}
+
+// SyncStoredUserRoleRules is SyncUserRoleRules against the profile stored by
+// the last 42 sync, for when only the custom attributes changed. It returns
+// ErrNotFound when the user was never synced.
+func SyncStoredUserRoleRules(ctx context.Context, userID string) (added int, removed int, err error) {
+	raw, err := database.GetUserRuleProfile(userID)
+	if errors.Is(err, database.ErrNotFound) {
+		return 0, 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("load rule profile: %w", err)
+	}
+	var intra User42
+	if err := json.Unmarshal(raw, &intra); err != nil {
+		return 0, 0, fmt.Errorf("decode rule profile: %w", err)
+	}
+	return syncRuleRoles(ctx, userID, intra)
+}
+
+func syncRuleRoles(ctx context.Context, userID string, intra User42) (added int, removed int, err error) {
+	payload, err := userRulePayload(userID, intra)
+	if err != nil {
+		return 0, 0, fmt.Errorf("normalize eval payload: %w", err)
+	}
+
+	roles, err := database.ListRolesWithRules()
+	if err != nil {
+		return 0, 0, fmt.Errorf("list roles with rules: %w", err)
+	}
+
+	for _, d := range ruleRoleDecisions(roles, payload) {
+		if !ruleRoleChangeAllowed(ctx, userID, d.RoleID, d.ShouldHave) {
+			continue
+		}
+		changed, err := database.EnsureUserRole(ctx, userID, d.RoleID, d.ShouldHave)
+		if err != nil {
+			return added, removed, err
+		}
+		if changed && d.ShouldHave {
+			added++
+		} else if changed {
+			removed++
+		}
+	}
+	return added, removed, nil
+}
+
+type ruleRoleDecision struct {
+	RoleID     string
+	ShouldHave bool
+}
+
+// ruleRoleDecisions evaluates the rules of roles against payload. Default
+// roles, and roles without rules or with invalid ones, are left out.
+func ruleRoleDecisions(roles []database.Role, payload map[string]any) []ruleRoleDecision {
+	var out []ruleRoleDecision
+	for _, r := range roles {
+		if r.IsDefault {
+			continue // defaults handled separately
+		}
+		if len(r.Rules) == 0 || string(r.Rules) == "null" {
+			continue
+		}
+		var node any
+		if err := json.Unmarshal(r.Rules, &node); err != nil {
+			continue // skip invalid rules
+		}
+		out = append(out, ruleRoleDecision{RoleID: r.ID, ShouldHave: evalRuleNode(node, payload)})
+	}
+	return out
+}
+
+// ruleRoleChangeAllowed applies the same last-admin guards as manual role edits.
+func ruleRoleChangeAllowed(ctx context.Context, userID, roleID string, shouldHave bool) bool {
+	var guarded bool
+	switch {
+	case roleID == RoleIDAdmin && !shouldHave:
+		guarded = true
+	case roleID == RoleIDBlacklist && shouldHave:
+		guarded = true
+	}
+	if !guarded {
+		return true
+	}
+
+	isAdmin, err := database.UserHasRoleByID(ctx, userID, RoleIDAdmin)
+	if err != nil || !isAdmin {
+		return err == nil
+	}
+	adminCount, err := database.CountActiveUsersWithRole(ctx, RoleIDAdmin, RoleIDBlacklist)
+	if err != nil {
+		return false
+	}
+	if adminCount <= 1 {
+		log.Printf("[user-sync] keeping %s unchanged for %s: last admin", roleID, userID)
+		return false
+	}
+	return true
+}
+
+// StartUserProfileSync periodically refreshes the 42 profile and rule roles of
+// users that have not logged in recently. Disabled when USER_SYNC_INTERVAL is 0.
+func StartUserProfileSync() {
+	interval := envDuration("USER_SYNC_INTERVAL", time.Hour)
+	if interval <= 0 {
+		log.Printf("[user-sync] periodic sync disabled")
+		return
+	}
+	userSyncOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for range ticker.C {
+				n, err := SyncStaleUserProfiles(context.Background())
+				if err != nil {
+					log.Printf("[user-sync] run failed: %v", err)
+					continue
+				}
+				if n > 0 {
+					log.Printf("[user-sync] refreshed %d users", n)
+				}
+			}
+		}()
+	})
+}
+
+// SyncStaleUserProfiles refreshes one batch of users not seen nor synced within
+// USER_SYNC_STALE_AFTER. It returns how many users were refreshed.
+func SyncStaleUserProfiles(ctx context.Context) (int, error) {
+	staleAfter := envDuration("USER_SYNC_STALE_AFTER", 72*time.Hour)
+	batch := envInt("USER_SYNC_BATCH_SIZE", 50)
+	// 42 API allows ~2 req/s per application
+	delay := envDuration("USER_SYNC_REQUEST_DELAY", 600*time.Millisecond)
+
+	users, err := database.ListUsersForProfileSync(time.Now().Add(-staleAfter), batch)
+	if err != nil {
+		return 0, err
+	}
+
+	done := 0
+	for i := range users {
+		u := users[i]
+		intra, err := GetUser42(intraUserKey(u))
+		if err != nil {
+			log.Printf("[user-sync] GetUser42(%s) error: %v", intraUserKey(u), err)
+			// Stamp anyway so a failing account doesn't block the head of every batch
+			_ = database.MarkUserProfileSynced(u.ID, time.Now())
+		} else if err := RefreshUserFromIntra(ctx, &u, intra); err != nil {
+			log.Printf("[user-sync] refresh %s error: %v", u.FtLogin, err)
+		} else {
+			done++
+		}
+		time.Sleep(delay)
+	}
+	return done, nil
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"backend/database"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIntraProfile(t *testing.T) {
+	seen := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	user := database.User{ID: "user_a", FtLogin: "heinz", FtID: 42, PhotoURL: "old.png", LastSeen: seen}
+
+	var intra User42
+	intra.ID = 42
+	intra.Login = "heinz"
+	intra.Staff = true
+	intra.Image.Link = "new.png"
+	got, err := intraProfile(user, intra)
+	if err != nil || got.ID != "user_a" || got.FtLogin != "heinz" || !got.FtIsStaff || got.PhotoURL != "new.png" || !got.LastSeen.Equal(seen) {
+		t.Errorf("refresh = %+v, %v", got, err)
+	}
+
+	// Renamed on the intra side: same ft_id, new login
+	intra.Login = "heinz2"
+	got, err = intraProfile(user, intra)
+	if err != nil || got.ID != "user_a" || got.FtLogin != "heinz2" || got.FtID != 42 {
+		t.Errorf("rename = %+v, %v", got, err)
+	}
+	if user.FtLogin != "heinz" || user.PhotoURL != "old.png" {
+		t.Errorf("intraProfile changed its input: %+v", user)
+	}
+
+	// The login now belongs to someone else: the row keeps its ft_id
+	intra.ID, intra.Login = 99, "heinz"
+	if _, err := intraProfile(user, intra); !errors.Is(err, ErrConflict) {
+		t.Errorf("other account: error = %v, want ErrConflict", err)
+	}
+	// A row without ft_id takes the one of its account
+	user.FtID = 0
+	if got, err := intraProfile(user, intra); err != nil || got.FtID != 99 {
+		t.Errorf("row without ft_id = %+v, %v", got, err)
+	}
+}
+
+func TestIntraUserKey(t *testing.T) {
+	if got := intraUserKey(database.User{FtLogin: "heinz", FtID: 42}); got != "42" {
+		t.Errorf("got %q, want the ft_id", got)
+	}
+	if got := intraUserKey(database.User{FtLogin: "heinz"}); got != "heinz" {
+		t.Errorf("got %q, want the login", got)
+	}
+}
+
+func TestMatchIntraUser(t *testing.T) {
+	owner := &database.User{ID: "user_owner", FtLogin: "heinz", FtID: 42}
+	legacy := &database.User{ID: "user_legacy", FtLogin: "heinz"}
+	previous := &database.User{ID: "user_previous", FtLogin: "heinz", FtID: 7}
+	renamed := &database.User{ID: "user_owner", FtLogin: "oldheinz", FtID: 42}
+
+	cases := []struct {
+		name          string
+		byID, byLogin *database.User
+		match, stale  *database.User
+	}{
+		{"new account", nil, nil, nil, nil},
+		{"same login", owner, owner, owner, nil},
+		{"renamed", renamed, nil, renamed, nil},
+		{"row without ft_id", nil, legacy, legacy, nil},
+		{"login reused by a new account", nil, previous, nil, previous},
+		{"login reused by a known account", renamed, previous, renamed, previous},
+	}
+	for _, c := range cases {
+		match, stale := matchIntraUser(c.byID, c.byLogin, 42)
+		if match != c.match || stale != c.stale {
+			t.Errorf("%s: got %v, %v; want %v, %v", c.name, match, stale, c.match, c.stale)
+		}
+	}
+}
+
+func TestRuleRoleDecisions(t *testing.T) {
+	rule := func(v any) json.RawMessage {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	loginRule := rule(map[string]any{"kind": "scalar", "path": "login", "op": "startswith", "value": "hei"})
+	staffRule := rule(map[string]any{"kind": "scalar", "path": "staff?", "op": "eq", "valueType": "boolean", "value": true})
+	badgeRule := rule(map[string]any{"kind": "scalar", "path": "custom.badge", "op": "eq", "value": "gold"})
+	roles := []database.Role{
+		{ID: "role_login", Rules: loginRule},
+		{ID: "role_staff", Rules: staffRule},
+		{ID: "role_badge", Rules: badgeRule},
+		{ID: "role_default", IsDefault: true, Rules: loginRule},
+		{ID: "role_none"},
+		{ID: "role_null", Rules: json.RawMessage("null")},
+		{ID: "role_invalid", Rules: json.RawMessage("{")},
+	}
+
+	eval := func(intra User42, custom map[string]any) map[string]bool {
+		payload, err := rulePayload(intra, custom)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := map[string]bool{}
+		for _, d := range ruleRoleDecisions(roles, payload) {
+			out[d.RoleID] = d.ShouldHave
+		}
+		return out
+	}
+
+	var intra User42
+	intra.Login = "heinz"
+	got := eval(intra, map[string]any{"badge": "gold"})
+	want := map[string]bool{"role_login": true, "role_staff": false, "role_badge": true}
+	if len(got) != len(want) {
+		t.Fatalf("decisions = %v, want %v", got, want)
+	}
+	for id, should := range want {
+		if got[id] != should {
+			t.Errorf("%s = %v, want %v", id, got[id], should)
+		}
+	}
+
+	// Re-evaluated after a rename, a promotion and an attribute change
+	intra.Login = "ltcherep"
+	intra.Staff = true
+	got = eval(intra, map[string]any{"badge": "silver"})
+	if got["role_login"] || !got["role_staff"] || got["role_badge"] {
+		t.Errorf("decisions after changes = %v", got)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
@@ -125,7 +126,16 @@ func GetUsers(pagination UserPagination) ([]User, string, error) {
 		realLimit = 0
 	}
 
-	users, err := database.GetAllUsers(&pagination.OrderBy, pagination.Filter, pagination.LastUser, realLimit)
+	// The raw filter is kept in the pagination token and re-parsed on every page
+	terms, err := ParseUserQuery(pagination.Filter)
+	if err != nil {
+		return nil, "", err
+	}
+
+	users, err := database.SearchUsers(&pagination.OrderBy, terms, pagination.LastUser, realLimit)
+	if errors.Is(err, database.ErrInvalidQuery) {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
 	if err != nil {
 		return nil, "", fmt.Errorf("couldn't get users in db: %w", err)
 	}
@@ -180,41 +190,6 @@ func toEvalMap(v any) (map[string]any, error) {
 	return out, nil
 }
 
-func ApplyRoleRulesForNewUser(userID string, evalPayload any) error {
-	payload, err := toEvalMap(evalPayload)
-	if err != nil {
-		return fmt.Errorf("normalize eval payload: %w", err)
-	}
-
-	roles, err := database.ListRolesWithRules()
-	if err != nil {
-		return fmt.Errorf("list roles with rules: %w", err)
-	}
-
-	var addIDs []string
-	for _, r := range roles {
-		if r.IsDefault {
-			continue // defaults handled separately
-		}
-		if len(r.Rules) == 0 || string(r.Rules) == "null" {
-			continue
-		}
-		var node any
-		if err := json.Unmarshal(r.Rules, &node); err != nil {
-			continue // skip invalid rules
-		}
-		if evalRuleNode(node, payload) {
-			addIDs = append(addIDs, r.ID)
-		}
-	}
-
-	if len(addIDs) == 0 {
-		return nil
-	}
-	// Bulk insert; no deletions for new users.
-	return database.BulkAddUserRoles(userID, addIDs)
-}
-
 func HandleUser42Connection(ctx context.Context, token *oauth2.Token, meta DeviceMeta) (string, error) {
 	// Use an OAuth2-aware client for the token to avoid subtle header/refresh issues
 	httpClient := oauth2.NewClient(ctx, oauth2.StaticTokenSource(token))
@@ -233,28 +208,43 @@ func HandleUser42Connection(ctx context.Context, token *oauth2.Token, meta Devic
 		return "", fmt.Errorf("couldn't decode user")
 	}
 
-	user, err := database.GetUserByLogin(intra.Login)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			user = &database.User{
-				FtLogin:   intra.Login,
-				FtID:      intra.ID,
-				FtIsStaff: intra.Staff,
-				PhotoURL:  intra.Image.Link,
-				LastSeen:  time.Now(),
-			}
-			if err := database.AddUser(user); err != nil {
-				return "", fmt.Errorf("failed to create user: %w", err)
-			}
-			if err := ApplyRoleRulesForNewUser(user.ID, intra); err != nil {
-				fmt.Printf("failed to link default roles: %s\n", err.Error())
-			}
-			if err := database.LinkDefaultRolesToUser(user.ID); err != nil {
-				fmt.Printf("failed to link default roles: %s\n", err.Error())
-			}
-		} else {
-			return "", fmt.Errorf("failed to get user: %w", err)
+	// ft_id is stable, logins can be renamed and then reused on the intra side
+	byID, err := database.GetUserByFtID(intra.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("failed to get user: %w", err)
+	}
+	byLogin, err := database.GetUserByLogin(intra.Login)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("failed to get user: %w", err)
+	}
+	user, stale := matchIntraUser(byID, byLogin, intra.ID)
+	if stale != nil {
+		// The owner of the stale row gets their current login back at their next login or sync
+		released := fmt.Sprintf("%s~%s", stale.FtLogin, stale.ID)
+		if err := database.RenameUserLogin(stale.ID, released); err != nil {
+			return "", fmt.Errorf("failed to release login %s: %w", intra.Login, err)
+		}
+		log.Printf("login %s moved from user %s to 42 account %d", intra.Login, stale.ID, intra.ID)
+	}
+	if user == nil {
+		user = &database.User{
+			FtLogin:   intra.Login,
+			FtID:      intra.ID,
+			FtIsStaff: intra.Staff,
+			PhotoURL:  intra.Image.Link,
+			LastSeen:  time.Now(),
+		}
+		if err := database.AddUser(user); err != nil {
+			return "", fmt.Errorf("failed to create user: %w", err)
 		}
+		if err := database.LinkDefaultRolesToUser(user.ID); err != nil {
+			fmt.Printf("failed to link default roles: %s\n", err.Error())
+		}
+	}
+
+	// Profile fields and rule roles are refreshed on every login
+	if err := RefreshUserFromIntra(ctx, user, intra); err != nil {
+		fmt.Printf("failed to refresh user %s from intra: %s\n", intra.Login, err.Error())
 	}
 
 	user.LastSeen = time.Now()
@@ -12,4 +12,9 @@ const (
 var (
 	// Public sentinel used by handlers.
 	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists is returned when a unique column is already used.
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrInvalidQuery is returned when Postgres rejects a user supplied
+	// pattern, such as a login regex.
+	ErrInvalidQuery = errors.New("invalid query")
 )
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"backend/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// GitCredential is an HTTPS username/token pair. The token is decrypted in core.
+type GitCredential struct {
+	ID              string         `json:"id" db:"id"`
+	Name            string         `json:"name" db:"name"`
+	Username        string         `json:"username" db:"username"`
+	Nonce           []byte         `json:"-" db:"nonce"`
+	Ciphertext      []byte         `json:"-" db:"ciphertext"`
+	DataKey         []byte         `json:"-" db:"data_key"`
+	KeyVersion      int            `json:"-" db:"key_version"`
+	CreatedByUserID sql.NullString `json:"created_by_user_id" db:"created_by_user_id"`
+	CreatedByLogin  sql.NullString `json:"created_by_login" db:"created_by_login"`
+	CreatedAt       time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at" db:"updated_at"`
+	LastUsedAt      sql.NullTime   `json:"last_used_at" db:"last_used_at"`
+	UsageCount      int            `json:"usage_count" db:"usage_count"`
+}
+
+const gitCredentialSelect = `
+	SELECT c.id, c.name, c.username, c.nonce, c.ciphertext, c.data_key, c.key_version,
+	       c.created_by_user_id, u.ft_login AS created_by_login, c.created_at, c.updated_at, c.last_used_at,
+	       (SELECT COUNT(*) FROM modules m WHERE m.git_credential_id = c.id) AS usage_count
+	  FROM git_credentials c
+	  LEFT JOIN users u ON u.id = c.created_by_user_id`
+
+func ListGitCredentials(ctx context.Context) ([]GitCredential, error) {
+	var out []GitCredential
+	err := mainDB.SelectContext(ctx, &out, gitCredentialSelect+`
+		 ORDER BY c.name
+	`)
+	return out, err
+}
+
+func GetGitCredential(ctx context.Context, id string) (GitCredential, error) {
+	var c GitCredential
+	err := mainDB.GetContext(ctx, &c, gitCredentialSelect+`
+		 WHERE c.id = $1
+	`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return GitCredential{}, ErrNotFound
+	}
+	return c, err
+}
+
+// InsertGitCredential stores a credential. ErrAlreadyExists is returned when
+// the name is taken.
+func InsertGitCredential(ctx context.Context, c GitCredential) (GitCredential, error) {
+	if c.ID == "" {
+		c.ID = utils.GenerateULID(utils.Type("git-credential"))
+	}
+	_, err := mainDB.ExecContext(ctx, `
+		INSERT INTO git_credentials (id, name, username, nonce, ciphertext, data_key, key_version, created_by_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, c.ID, c.Name, c.Username, c.Nonce, c.Ciphertext, c.DataKey, c.KeyVersion, c.CreatedByUserID)
+	if isUniqueViolation(err) {
+		return GitCredential{}, ErrAlreadyExists
+	}
+	if err != nil {
+		return GitCredential{}, err
+	}
+	return GetGitCredential(ctx, c.ID)
+}
+
+// UpdateGitCredential replaces the name, username and sealed token of a credential.
+func UpdateGitCredential(ctx context.Context, c GitCredential) (GitCredential, error) {
+	res, err := mainDB.ExecContext(ctx, `
+		UPDATE git_credentials
+		   SET name = $2,
+		       username = $3,
+		       nonce = $4,
+		       ciphertext = $5,
+		       data_key = $6,
+		       key_version = $7,
+		       updated_at = NOW()
+		 WHERE id = $1
+	`, c.ID, c.Name, c.Username, c.Nonce, c.Ciphertext, c.DataKey, c.KeyVersion)
+	if isUniqueViolation(err) {
+		return GitCredential{}, ErrAlreadyExists
+	}
+	if err != nil {
+		return GitCredential{}, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return GitCredential{}, ErrNotFound
+	}
+	return GetGitCredential(ctx, c.ID)
+}
+
+func DeleteGitCredential(ctx context.Context, id string) error {
+	res, err := mainDB.ExecContext(ctx, `DELETE FROM git_credentials WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func TouchGitCredential(ctx context.Context, id string) error {
+	_, err := mainDB.ExecContext(ctx, `UPDATE git_credentials SET last_used_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+// ListGitCredentialsToRewrap returns the credentials whose data key is not
+// wrapped with activeVersion.
+func ListGitCredentialsToRewrap(ctx context.Context, activeVersion int) ([]GitCredential, error) {
+	var out []GitCredential
+	err := mainDB.SelectContext(ctx, &out, gitCredentialSelect+`
+		 WHERE c.key_version <> $1
+		 ORDER BY c.id
+	`, activeVersion)
+	return out, err
+}
+
+// ReplaceGitCredentialCipher swaps the sealed token of a credential without
+// touching updated_at. The write is skipped (false) if the token changed
+// since it was read.
+func ReplaceGitCredentialCipher(ctx context.Context, c GitCredential, previousVersion int) (bool, error) {
+	res, err := mainDB.ExecContext(ctx, `
+		UPDATE git_credentials
+		   SET nonce = $2,
+		       ciphertext = $3,
+		       data_key = $4,
+		       key_version = $5
+		 WHERE id = $1 AND key_version = $6
+	`, c.ID, c.Nonce, c.Ciphertext, c.DataKey, c.KeyVersion, previousVersion)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// GetModuleGitCredentialID returns the credential selected for a module, or
+// an empty string when it uses its SSH key.
+func GetModuleGitCredentialID(ctx context.Context, moduleID string) (string, error) {
+	var id sql.NullString
+	err := mainDB.GetContext(ctx, &id, `SELECT git_credential_id FROM modules WHERE id = $1`, moduleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return id.String, err
+}
+
+func SetModuleGitCredentialID(ctx context.Context, moduleID string, credentialID sql.NullString) error {
+	res, err := mainDB.ExecContext(ctx, `UPDATE modules SET git_credential_id = $2 WHERE id = $1`, moduleID, credentialID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
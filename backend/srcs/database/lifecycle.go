@@ -0,0 +1,197 @@
+package database
+
+import (
+	"backend/utils"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type UserLifecycleRun struct {
+	ID         string          `json:"id" db:"id"`
+	StartedAt  time.Time       `json:"started_at" db:"started_at"`
+	FinishedAt time.Time       `json:"finished_at" db:"finished_at"`
+	DryRun     bool            `json:"dry_run" db:"dry_run"`
+	Report     json.RawMessage `json:"report" db:"report"`
+}
+
+// Users eligible for lifecycle actions: not exempt, not anonymized, holding no protected role.
+const lifecycleEligibleCond = `
+	NOT u.lifecycle_exempt
+	AND u.anonymized_at IS NULL
+	AND NOT EXISTS (
+		SELECT 1 FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = u.id AND r.is_protected
+	)`
+
+func scanLifecycleUsers(rows *sql.Rows) ([]User, error) {
+	defer rows.Close()
+	var out []User
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.FtLogin, &u.FtID, &u.FtIsStaff, &u.PhotoURL, &u.LastSeen); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// ListDormancyCandidates returns eligible users not seen since inactiveBefore and not yet dormant.
+func ListDormancyCandidates(ctx context.Context, inactiveBefore time.Time) ([]User, error) {
+	rows, err := mainDB.QueryContext(ctx, `
+		SELECT u.id, u.ft_login, u.ft_id, u.ft_is_staff, u.photo_url, u.last_seen
+		  FROM users u
+		 WHERE u.last_seen < $1
+		   AND u.dormant_since IS NULL
+		   AND `+lifecycleEligibleCond+`
+		 ORDER BY u.last_seen
+	`, inactiveBefore)
+	if err != nil {
+		return nil, err
+	}
+	return scanLifecycleUsers(rows)
+}
+
+// ListReactivatedDormant returns dormant users who logged in again or became exempt.
+func ListReactivatedDormant(ctx context.Context) ([]User, error) {
+	rows, err := mainDB.QueryContext(ctx, `
+		SELECT u.id, u.ft_login, u.ft_id, u.ft_is_staff, u.photo_url, u.last_seen
+		  FROM users u
+		 WHERE u.dormant_since IS NOT NULL
+		   AND (u.last_seen > u.dormant_since OR NOT (`+lifecycleEligibleCond+`))
+		 ORDER BY u.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return scanLifecycleUsers(rows)
+}
+
+// ListUsersDueForPruning returns eligible users dormant since before dormantBefore with no login since.
+func ListUsersDueForPruning(ctx context.Context, dormantBefore time.Time) ([]User, error) {
+	rows, err := mainDB.QueryContext(ctx, `
+		SELECT u.id, u.ft_login, u.ft_id, u.ft_is_staff, u.photo_url, u.last_seen
+		  FROM users u
+		 WHERE u.dormant_since IS NOT NULL
+		   AND u.dormant_since < $1
+		   AND u.last_seen <= u.dormant_since
+		   AND `+lifecycleEligibleCond+`
+		 ORDER BY u.dormant_since
+	`, dormantBefore)
+	if err != nil {
+		return nil, err
+	}
+	return scanLifecycleUsers(rows)
+}
+
+func SetUserDormant(ctx context.Context, userID string, since time.Time) error {
+	_, err := mainDB.ExecContext(ctx, `UPDATE users SET dormant_since = $2 WHERE id = $1`, userID, since)
+	return err
+}
+
+func ClearUserDormant(ctx context.Context, userID string) error {
+	_, err := mainDB.ExecContext(ctx, `UPDATE users SET dormant_since = NULL WHERE id = $1 AND dormant_since IS NOT NULL`, userID)
+	return err
+}
+
+// SetUserLifecycleExempt toggles the per-account exemption from the lifecycle policy.
+func SetUserLifecycleExempt(ctx context.Context, userID string, exempt bool, reason *string) error {
+	res, err := mainDB.ExecContext(ctx, `
+		UPDATE users
+		   SET lifecycle_exempt = $2,
+		       lifecycle_exempt_reason = $3
+		 WHERE id = $1
+	`, userID, exempt, reason)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AnonymizeUser strips personal data from a user row while keeping its ID so
+// audit rows and foreign keys stay valid. Sessions, prefs, role links and custom
+// attributes are removed.
+func AnonymizeUser(ctx context.Context, userID string, at time.Time) error {
+	tx, err := mainDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// sessions reference users(ft_login), so they must go before the login changes
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM sessions
+		 WHERE ft_login = (SELECT ft_login FROM users WHERE id = $1)
+	`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_roles WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_attribute_values WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if err := ensureUserPrefsTable(ctx); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_prefs WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE users
+		   SET ft_login = 'anonymized-' || id,
+		       ft_id = 0,
+		       ft_is_staff = FALSE,
+		       photo_url = '',
+		       rule_profile = NULL,
+		       dormant_since = NULL,
+		       anonymized_at = $2
+		 WHERE id = $1
+	`, userID, at); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func InsertUserLifecycleRun(ctx context.Context, run UserLifecycleRun) (UserLifecycleRun, error) {
+	if run.ID == "" {
+		run.ID = utils.GenerateULID(utils.Type("lifecycle-run"))
+	}
+	_, err := mainDB.ExecContext(ctx, `
+		INSERT INTO user_lifecycle_runs (id, started_at, finished_at, dry_run, report)
+		VALUES ($1, $2, $3, $4, $5)
+	`, run.ID, run.StartedAt, run.FinishedAt, run.DryRun, []byte(run.Report))
+	return run, err
+}
+
+func ListUserLifecycleRuns(ctx context.Context, limit int) ([]UserLifecycleRun, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	var runs []UserLifecycleRun
+	err := mainDB.SelectContext(ctx, &runs, `
+		SELECT id, started_at, finished_at, dry_run, report
+		  FROM user_lifecycle_runs
+		 ORDER BY started_at DESC
+		 LIMIT $1
+	`, limit)
+	return runs, err
+}
+
+// ListUserLifecycleRunsForUser returns the lifecycle runs whose report lists
+// the user, oldest first.
+func ListUserLifecycleRunsForUser(ctx context.Context, userID string) ([]UserLifecycleRun, error) {
+	var runs []UserLifecycleRun
+	err := mainDB.SelectContext(ctx, &runs, `
+		SELECT id, started_at, finished_at, dry_run, report
+		  FROM user_lifecycle_runs
+		 WHERE jsonb_path_exists(report, '$.*[*] ? (@.user_id == $uid)', jsonb_build_object('uid', $1::text))
+		 ORDER BY started_at
+	`, userID)
+	return runs, err
+}
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func lifecycleUserIDs(t *testing.T, users []User, err error) []string {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+	return ids
+}
+
+func sameIDs(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestUserLifecycleQueries(t *testing.T) {
+	CreateAndPopulateDatabase(t, "test_user_lifecycle_db", testDataSQL)
+	ctx := context.Background()
+	const (
+		heinz    = "user_01HZXYZDE0420"
+		ltcherep = "user_01HZXYZDE0430"
+		tac      = "user_01HZXYZDE0440"
+		yoshi    = "user_01HZXYZDE0450"
+	)
+	at := func(year int) time.Time { return time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	// tac holds a protected role, yoshi is exempt
+	if _, err := mainDB.Exec(`
+		INSERT INTO roles (id, name, color, is_protected) VALUES ('role_protected', 'Protected', '#000000', TRUE);
+		INSERT INTO user_roles (user_id, role_id) VALUES ('user_01HZXYZDE0440', 'role_protected');
+	`); err != nil {
+		t.Fatal(err)
+	}
+	reason := "staff account"
+	if err := SetUserLifecycleExempt(ctx, yoshi, true, &reason); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetUserLifecycleExempt(ctx, "user_missing", true, nil); err != ErrNotFound {
+		t.Errorf("SetUserLifecycleExempt(missing) = %v, want ErrNotFound", err)
+	}
+
+	users, err := ListDormancyCandidates(ctx, at(2010))
+	if got := lifecycleUserIDs(t, users, err); !sameIDs(got, []string{ltcherep, heinz}) {
+		t.Errorf("dormancy candidates = %v", got)
+	}
+	users, err = ListDormancyCandidates(ctx, at(2001))
+	if got := lifecycleUserIDs(t, users, err); !sameIDs(got, []string{ltcherep}) {
+		t.Errorf("dormancy candidates before 2001 = %v", got)
+	}
+
+	if err := SetUserDormant(ctx, heinz, at(2005)); err != nil {
+		t.Fatal(err)
+	}
+	users, err = ListDormancyCandidates(ctx, at(2010))
+	if got := lifecycleUserIDs(t, users, err); !sameIDs(got, []string{ltcherep}) {
+		t.Errorf("dormancy candidates after marking = %v", got)
+	}
+	users, err = ListUsersDueForPruning(ctx, at(2004))
+	if got := lifecycleUserIDs(t, users, err); len(got) != 0 {
+		t.Errorf("pruning before the grace period = %v", got)
+	}
+	users, err = ListUsersDueForPruning(ctx, at(2006))
+	if got := lifecycleUserIDs(t, users, err); !sameIDs(got, []string{heinz}) {
+		t.Errorf("pruning after the grace period = %v", got)
+	}
+
+	// heinz logs in again, yoshi was dormant before being exempted
+	if _, err := mainDB.Exec(`UPDATE users SET last_seen = $2 WHERE id = $1`, heinz, at(2006)); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetUserDormant(ctx, yoshi, at(2005)); err != nil {
+		t.Fatal(err)
+	}
+	users, err = ListReactivatedDormant(ctx)
+	if got := lifecycleUserIDs(t, users, err); !sameIDs(got, []string{heinz, yoshi}) {
+		t.Errorf("reactivated = %v", got)
+	}
+	users, err = ListUsersDueForPruning(ctx, at(2010))
+	if got := lifecycleUserIDs(t, users, err); len(got) != 0 {
+		t.Errorf("pruning after login = %v", got)
+	}
+	for _, id := range []string{heinz, yoshi} {
+		if err := ClearUserDormant(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	users, err = ListReactivatedDormant(ctx)
+	if got := lifecycleUserIDs(t, users, err); len(got) != 0 {
+		t.Errorf("reactivated after clearing = %v", got)
+	}
+
+	if err := AddSession(Session{SessionID: "sess_ltcherep", Login: "ltcherep"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetUserRuleProfile(ltcherep, json.RawMessage(`{"login":"ltcherep"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AnonymizeUser(ctx, ltcherep, at(2011)); err != nil {
+		t.Fatalf("AnonymizeUser() error = %v", err)
+	}
+	u, err := GetUserByID(ltcherep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.FtLogin != "anonymized-"+ltcherep || u.FtID != 0 || u.PhotoURL != "" {
+		t.Errorf("anonymized user = %+v", u)
+	}
+	if _, err := GetSession("sess_ltcherep"); err == nil {
+		t.Error("session of the anonymized user kept")
+	}
+	if _, err := GetUserRuleProfile(ltcherep); !errors.Is(err, ErrNotFound) {
+		t.Errorf("rule profile of the anonymized user kept (error = %v)", err)
+	}
+	var roles int
+	if err := mainDB.Get(&roles, `SELECT COUNT(*) FROM user_roles WHERE user_id = $1`, ltcherep); err != nil {
+		t.Fatal(err)
+	}
+	if roles != 0 {
+		t.Errorf("anonymized user kept %d roles", roles)
+	}
+	users, err = ListDormancyCandidates(ctx, at(2010))
+	if got := lifecycleUserIDs(t, users, err); len(got) != 0 {
+		t.Errorf("dormancy candidates after anonymizing = %v", got)
+	}
+}
+
+func TestUserLifecycleRuns(t *testing.T) {
+	CreateAndPopulateDatabase(t, "test_user_lifecycle_runs_db", testDataSQL)
+	ctx := context.Background()
+
+	report, _ := json.Marshal(map[string]any{
+		"marked_dormant": []map[string]string{{"user_id": "user_01HZXYZDE0420", "login": "heinz"}},
+		"errors":         []map[string]string{},
+	})
+	now := time.Now().UTC()
+	run, err := InsertUserLifecycleRun(ctx, UserLifecycleRun{StartedAt: now, FinishedAt: now, Report: report})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.ID == "" {
+		t.Error("run ID not generated")
+	}
+
+	runs, err := ListUserLifecycleRuns(ctx, 0)
+	if err != nil || len(runs) != 1 || runs[0].ID != run.ID {
+		t.Errorf("ListUserLifecycleRuns() = %v, %v", runs, err)
+	}
+	runs, err = ListUserLifecycleRunsForUser(ctx, "user_01HZXYZDE0420")
+	if err != nil || len(runs) != 1 {
+		t.Errorf("runs for heinz = %v, %v", runs, err)
+	}
+	runs, err = ListUserLifecycleRunsForUser(ctx, "user_01HZXYZDE0440")
+	if err != nil || len(runs) != 0 {
+		t.Errorf("runs for tac = %v, %v", runs, err)
+	}
+}
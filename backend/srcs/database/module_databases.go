@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type ModuleDatabase struct {
+	ID         int64          `json:"id" db:"id"`
+	ModuleID   sql.NullString `json:"module_id" db:"module_id"`
+	ModuleSlug string         `json:"module_slug" db:"module_slug"`
+	DBName     string         `json:"db_name" db:"db_name"`
+	RoleName   string         `json:"role_name" db:"role_name"`
+	Network    string         `json:"network" db:"network"`
+	Status     string         `json:"status" db:"status"`
+	DumpPath   string         `json:"dump_path" db:"dump_path"`
+	Error      string         `json:"error" db:"error"`
+	CreatedAt  time.Time      `json:"created_at" db:"created_at"`
+	RetiredAt  sql.NullTime   `json:"retired_at" db:"retired_at"`
+	PurgeAfter sql.NullTime   `json:"purge_after" db:"purge_after"`
+}
+
+const moduleDatabaseColumns = `id, module_id, module_slug, db_name, role_name, network, status, dump_path, error, created_at, retired_at, purge_after`
+
+// GetActiveModuleDatabase returns the database a module currently uses.
+func GetActiveModuleDatabase(ctx context.Context, moduleID string) (ModuleDatabase, error) {
+	var d ModuleDatabase
+	err := mainDB.GetContext(ctx, &d, `
+		SELECT `+moduleDatabaseColumns+`
+		  FROM module_databases
+		 WHERE module_id = $1 AND status = 'active'
+	`, moduleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ModuleDatabase{}, ErrNotFound
+	}
+	return d, err
+}
+
+// InsertModuleDatabase records a provisioned database. ErrAlreadyExists is
+// returned when the module already has an active one or a name is taken.
+func InsertModuleDatabase(ctx context.Context, d ModuleDatabase) (ModuleDatabase, error) {
+	var out ModuleDatabase
+	err := mainDB.GetContext(ctx, &out, `
+		INSERT INTO module_databases (module_id, module_slug, db_name, role_name, network)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+moduleDatabaseColumns,
+		d.ModuleID, d.ModuleSlug, d.DBName, d.RoleName, d.Network)
+	if isUniqueViolation(err) {
+		return ModuleDatabase{}, ErrAlreadyExists
+	}
+	return out, err
+}
+
+// RetireModuleDatabase marks a database as no longer used by its module.
+func RetireModuleDatabase(ctx context.Context, id int64) (ModuleDatabase, error) {
+	var out ModuleDatabase
+	err := mainDB.GetContext(ctx, &out, `
+		UPDATE module_databases
+		   SET status = 'retired', retired_at = COALESCE(retired_at, NOW())
+		 WHERE id = $1 AND status = 'active'
+		RETURNING `+moduleDatabaseColumns, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ModuleDatabase{}, ErrNotFound
+	}
+	return out, err
+}
+
+// MarkModuleDatabaseDropped records the dump of a retired database once it
+// is dropped.
+func MarkModuleDatabaseDropped(ctx context.Context, id int64, dumpPath string, purgeAfter time.Time) error {
+	_, err := mainDB.ExecContext(ctx, `
+		UPDATE module_databases
+		   SET status = 'dropped', dump_path = $2, purge_after = $3, error = ''
+		 WHERE id = $1
+	`, id, dumpPath, purgeAfter)
+	return err
+}
+
+func SetModuleDatabaseError(ctx context.Context, id int64, msg string) error {
+	_, err := mainDB.ExecContext(ctx, `UPDATE module_databases SET error = $2 WHERE id = $1`, id, msg)
+	return err
+}
+
+// ListRetiredModuleDatabases returns the databases waiting for their dump, and
+// the dropped ones whose dump is due for removal at now.
+func ListRetiredModuleDatabases(ctx context.Context, now time.Time) ([]ModuleDatabase, error) {
+	out := []ModuleDatabase{}
+	err := mainDB.SelectContext(ctx, &out, `
+		SELECT `+moduleDatabaseColumns+`
+		  FROM module_databases
+		 WHERE status = 'retired'
+		    OR (status = 'dropped' AND purge_after <= $1)
+		 ORDER BY id
+	`, now)
+	return out, err
+}
+
+func DeleteModuleDatabase(ctx context.Context, id int64) error {
+	_, err := mainDB.ExecContext(ctx, `DELETE FROM module_databases WHERE id = $1`, id)
+	return err
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type ModuleDeploySettings struct {
+	ModuleID             string    `json:"module_id" db:"module_id"`
+	Strategy             string    `json:"strategy" db:"strategy"`
+	HealthTimeoutSeconds int       `json:"health_timeout_seconds" db:"health_timeout_seconds"`
+	ActiveProject        string    `json:"active_project" db:"active_project"`
+	UpdatedAt            time.Time `json:"updated_at" db:"updated_at"`
+}
+
+const moduleDeploySettingsColumns = `module_id, strategy, health_timeout_seconds, active_project, updated_at`
+
+func GetModuleDeploySettings(ctx context.Context, moduleID string) (ModuleDeploySettings, error) {
+	var s ModuleDeploySettings
+	err := mainDB.GetContext(ctx, &s, `
+		SELECT `+moduleDeploySettingsColumns+`
+		  FROM module_deploy_settings
+		 WHERE module_id = $1
+	`, moduleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ModuleDeploySettings{}, ErrNotFound
+	}
+	return s, err
+}
+
+// UpsertModuleDeploySettings stores the strategy of a module. The active
+// project is left alone: it follows the stacks actually running.
+func UpsertModuleDeploySettings(ctx context.Context, s ModuleDeploySettings) (ModuleDeploySettings, error) {
+	var out ModuleDeploySettings
+	err := mainDB.GetContext(ctx, &out, `
+		INSERT INTO module_deploy_settings (module_id, strategy, health_timeout_seconds)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (module_id)
+		DO UPDATE SET strategy = EXCLUDED.strategy,
+		              health_timeout_seconds = EXCLUDED.health_timeout_seconds,
+		              updated_at = NOW()
+		RETURNING `+moduleDeploySettingsColumns,
+		s.ModuleID, s.Strategy, s.HealthTimeoutSeconds)
+	return out, err
+}
+
+// SetModuleActiveProject records the compose project serving a module.
+func SetModuleActiveProject(ctx context.Context, moduleID, project string) error {
+	_, err := mainDB.ExecContext(ctx, `
+		INSERT INTO module_deploy_settings (module_id, active_project)
+		VALUES ($1, $2)
+		ON CONFLICT (module_id)
+		DO UPDATE SET active_project = EXCLUDED.active_project,
+		              updated_at = NOW()
+	`, moduleID, project)
+	return err
+}
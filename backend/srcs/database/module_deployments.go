@@ -0,0 +1,143 @@
+package database
+
+import (
+	"backend/utils"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type ModuleDeployment struct {
+	ID           string         `json:"id" db:"id"`
+	ModuleID     string         `json:"module_id" db:"module_id"`
+	ActorUserID  sql.NullString `json:"actor_user_id" db:"actor_user_id"`
+	ActorLogin   sql.NullString `json:"actor_login" db:"actor_login"`
+	ActorPhoto   sql.NullString `json:"actor_photo" db:"actor_photo"`
+	Source       string         `json:"source" db:"source"`
+	RollbackOf   sql.NullString `json:"rollback_of" db:"rollback_of"`
+	Status       string         `json:"status" db:"status"`
+	CommitBefore string         `json:"commit_before" db:"commit_before"`
+	CommitAfter  string         `json:"commit_after" db:"commit_after"`
+	ComposeHash  string         `json:"compose_hash" db:"compose_hash"`
+	Error        string         `json:"error" db:"error"`
+	StartedAt    time.Time      `json:"started_at" db:"started_at"`
+	FinishedAt   sql.NullTime   `json:"finished_at" db:"finished_at"`
+	DurationMs   sql.NullInt64  `json:"duration_ms" db:"duration_ms"`
+}
+
+const moduleDeploymentSelect = `
+	SELECT d.id, d.module_id, d.actor_user_id, u.ft_login AS actor_login, u.photo_url AS actor_photo,
+	       d.source, d.rollback_of, d.status, d.commit_before, d.commit_after, d.compose_hash,
+	       d.error, d.started_at, d.finished_at, d.duration_ms
+	  FROM module_deployments d
+	  LEFT JOIN users u ON u.id = d.actor_user_id`
+
+// InsertModuleDeployment records a deployment that just started.
+func InsertModuleDeployment(ctx context.Context, d ModuleDeployment) (ModuleDeployment, error) {
+	if d.ID == "" {
+		d.ID = utils.GenerateULID(utils.Type("deployment"))
+	}
+	err := mainDB.QueryRowContext(ctx, `
+		INSERT INTO module_deployments (id, module_id, actor_user_id, source, rollback_of, status,
+		                                commit_before, commit_after, compose_hash)
+		VALUES ($1, $2, $3, $4, $5, 'running', $6, $7, $8)
+		RETURNING status, started_at
+	`, d.ID, d.ModuleID, d.ActorUserID, d.Source, d.RollbackOf, d.CommitBefore, d.CommitAfter, d.ComposeHash,
+	).Scan(&d.Status, &d.StartedAt)
+	return d, err
+}
+
+func FinishModuleDeployment(ctx context.Context, id, status, errMsg string, finishedAt time.Time) error {
+	_, err := mainDB.ExecContext(ctx, `
+		UPDATE module_deployments
+		   SET status = $2,
+		       error = $3,
+		       finished_at = $4,
+		       duration_ms = (EXTRACT(EPOCH FROM ($4 - started_at)) * 1000)::BIGINT
+		 WHERE id = $1
+	`, id, status, errMsg, finishedAt)
+	return err
+}
+
+// FailRunningModuleDeployments closes the deployments left running by a
+// previous backend process.
+func FailRunningModuleDeployments(ctx context.Context) (int64, error) {
+	res, err := mainDB.ExecContext(ctx, `
+		UPDATE module_deployments
+		   SET status = 'failed',
+		       error = 'interrupted by a backend restart',
+		       finished_at = NOW(),
+		       duration_ms = (EXTRACT(EPOCH FROM (NOW() - started_at)) * 1000)::BIGINT
+		 WHERE status = 'running'
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ListModuleDeployments returns the most recent deployments of a module first.
+func ListModuleDeployments(ctx context.Context, moduleID string, limit int) ([]ModuleDeployment, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	var out []ModuleDeployment
+	err := mainDB.SelectContext(ctx, &out, moduleDeploymentSelect+`
+		 WHERE d.module_id = $1
+		 ORDER BY d.started_at DESC
+		 LIMIT $2
+	`, moduleID, limit)
+	return out, err
+}
+
+// ListSuccessfulModuleDeployments returns the successful deployments of a
+// module, most recent first.
+func ListSuccessfulModuleDeployments(ctx context.Context, moduleID string) ([]ModuleDeployment, error) {
+	var out []ModuleDeployment
+	err := mainDB.SelectContext(ctx, &out, moduleDeploymentSelect+`
+		 WHERE d.module_id = $1 AND d.status = 'success'
+		 ORDER BY d.started_at DESC
+	`, moduleID)
+	return out, err
+}
+
+func GetModuleDeployment(ctx context.Context, moduleID, id string) (ModuleDeployment, error) {
+	var d ModuleDeployment
+	err := mainDB.GetContext(ctx, &d, moduleDeploymentSelect+`
+		 WHERE d.module_id = $1 AND d.id = $2
+	`, moduleID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ModuleDeployment{}, ErrNotFound
+	}
+	return d, err
+}
+
+// ListDeploymentLogs returns the log lines written during a deployment, oldest first.
+func ListDeploymentLogs(ctx context.Context, deploymentID string) ([]ModuleLog, error) {
+	rows, err := mainDB.QueryContext(ctx, `
+		SELECT id, module_id, created_at, level, message, meta, deployment_id
+		  FROM module_log
+		 WHERE deployment_id = $1
+		 ORDER BY id ASC
+	`, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ModuleLog
+	for rows.Next() {
+		var l ModuleLog
+		var metaBytes []byte
+		if err := rows.Scan(&l.ID, &l.ModuleID, &l.CreatedAt, &l.Level, &l.Message, &metaBytes, &l.DeploymentID); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(metaBytes, &l.Meta); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
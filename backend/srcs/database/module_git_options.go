@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type ModuleGitOptions struct {
+	ModuleID   string    `json:"module_id" db:"module_id"`
+	Submodules bool      `json:"submodules" db:"submodules"`
+	LFS        bool      `json:"lfs" db:"lfs"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+const moduleGitOptionsColumns = `module_id, submodules, lfs, updated_at`
+
+func GetModuleGitOptions(ctx context.Context, moduleID string) (ModuleGitOptions, error) {
+	var o ModuleGitOptions
+	err := mainDB.GetContext(ctx, &o, `
+		SELECT `+moduleGitOptionsColumns+`
+		  FROM module_git_options
+		 WHERE module_id = $1
+	`, moduleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ModuleGitOptions{}, ErrNotFound
+	}
+	return o, err
+}
+
+func UpsertModuleGitOptions(ctx context.Context, o ModuleGitOptions) (ModuleGitOptions, error) {
+	var out ModuleGitOptions
+	err := mainDB.GetContext(ctx, &out, `
+		INSERT INTO module_git_options (module_id, submodules, lfs)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (module_id)
+		DO UPDATE SET submodules = EXCLUDED.submodules,
+		              lfs = EXCLUDED.lfs,
+		              updated_at = NOW()
+		RETURNING `+moduleGitOptionsColumns,
+		o.ModuleID, o.Submodules, o.LFS)
+	return out, err
+}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"backend/utils"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ModuleJob struct {
+	ID              string          `json:"id" db:"id"`
+	ModuleID        string          `json:"module_id" db:"module_id"`
+	Kind            string          `json:"kind" db:"kind"`
+	Params          json.RawMessage `json:"params" db:"params"`
+	Status          string          `json:"status" db:"status"`
+	ActorUserID     sql.NullString  `json:"actor_user_id" db:"actor_user_id"`
+	Error           string          `json:"error" db:"error"`
+	CancelRequested bool            `json:"cancel_requested" db:"cancel_requested"`
+	WorkerID        sql.NullString  `json:"worker_id" db:"worker_id"`
+	Attempts        int             `json:"attempts" db:"attempts"`
+	CreatedAt       time.Time       `json:"created_at" db:"created_at"`
+	StartedAt       sql.NullTime    `json:"started_at" db:"started_at"`
+	FinishedAt      sql.NullTime    `json:"finished_at" db:"finished_at"`
+}
+
+const moduleJobColumns = `id, module_id, kind, params, status, actor_user_id, error, cancel_requested,
+	       worker_id, attempts, created_at, started_at, finished_at`
+
+func InsertModuleJob(ctx context.Context, j ModuleJob) (ModuleJob, error) {
+	if j.ID == "" {
+		j.ID = utils.GenerateULID(utils.Type("job"))
+	}
+	if len(j.Params) == 0 {
+		j.Params = json.RawMessage(`{}`)
+	}
+	var out ModuleJob
+	err := mainDB.GetContext(ctx, &out, `
+		INSERT INTO module_jobs (id, module_id, kind, params, actor_user_id)
+		VALUES ($1, $2, $3, $4::jsonb, $5)
+		RETURNING `+moduleJobColumns,
+		j.ID, j.ModuleID, j.Kind, string(j.Params), j.ActorUserID)
+	return out, err
+}
+
+// ClaimNextModuleJob marks the oldest runnable job as running for workerID.
+// A job is runnable when it is the oldest queued job of its module and that
+// module has no running job. Jobs are ordered by their insertion sequence:
+// IDs made in the same millisecond do not sort in creation order. ok is false when there is nothing to run, or when
+// another worker won the race for the same module.
+func ClaimNextModuleJob(ctx context.Context, workerID string) (job ModuleJob, ok bool, err error) {
+	err = mainDB.GetContext(ctx, &job, `
+		UPDATE module_jobs
+		   SET status = 'running',
+		       started_at = NOW(),
+		       worker_id = $1,
+		       attempts = attempts + 1
+		 WHERE id = (
+		        SELECT j.id
+		          FROM module_jobs j
+		         WHERE j.status = 'queued'
+		           AND NOT EXISTS (SELECT 1 FROM module_jobs r
+		                            WHERE r.module_id = j.module_id AND r.status = 'running')
+		           AND NOT EXISTS (SELECT 1 FROM module_jobs q
+		                            WHERE q.module_id = j.module_id AND q.status = 'queued' AND q.seq < j.seq)
+		         ORDER BY j.seq
+		         LIMIT 1
+		           FOR UPDATE SKIP LOCKED
+		       )
+		RETURNING `+moduleJobColumns,
+		workerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ModuleJob{}, false, nil
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		// uniq_module_jobs_running: a concurrent claim took this module
+		return ModuleJob{}, false, nil
+	}
+	if err != nil {
+		return ModuleJob{}, false, err
+	}
+	return job, true, nil
+}
+
+func FinishModuleJob(ctx context.Context, id, status, errMsg string) (ModuleJob, error) {
+	var out ModuleJob
+	err := mainDB.GetContext(ctx, &out, `
+		UPDATE module_jobs
+		   SET status = $2,
+		       error = $3,
+		       finished_at = NOW()
+		 WHERE id = $1
+		RETURNING `+moduleJobColumns,
+		id, status, errMsg)
+	return out, err
+}
+
+func GetModuleJob(ctx context.Context, moduleID, id string) (ModuleJob, error) {
+	var j ModuleJob
+	err := mainDB.GetContext(ctx, &j, `
+		SELECT `+moduleJobColumns+`
+		  FROM module_jobs
+		 WHERE module_id = $1 AND id = $2
+	`, moduleID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ModuleJob{}, ErrNotFound
+	}
+	return j, err
+}
+
+// ListModuleJobs returns the most recent jobs of a module first. An empty
+// status list means every status.
+func ListModuleJobs(ctx context.Context, moduleID string, statuses []string, limit int) ([]ModuleJob, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	var out []ModuleJob
+	err := mainDB.SelectContext(ctx, &out, `
+		SELECT `+moduleJobColumns+`
+		  FROM module_jobs
+		 WHERE module_id = $1
+		   AND (cardinality($2::text[]) = 0 OR status = ANY($2::text[]))
+		 ORDER BY seq DESC
+		 LIMIT $3
+	`, moduleID, pq.Array(statuses), limit)
+	return out, err
+}
+
+func ModuleHasActiveJobs(ctx context.Context, moduleID string) (bool, error) {
+	var active bool
+	err := mainDB.GetContext(ctx, &active, `
+		SELECT EXISTS (SELECT 1 FROM module_jobs
+		                WHERE module_id = $1 AND status IN ('queued', 'running'))
+	`, moduleID)
+	return active, err
+}
+
+// CancelModuleJob cancels a queued job right away and flags a running one so
+// its worker stops it. ok is false when the job is already finished.
+func CancelModuleJob(ctx context.Context, moduleID, id string) (job ModuleJob, ok bool, err error) {
+	err = mainDB.GetContext(ctx, &job, `
+		UPDATE module_jobs
+		   SET cancel_requested = TRUE,
+		       status = CASE WHEN status = 'queued' THEN 'cancelled' ELSE status END,
+		       finished_at = CASE WHEN status = 'queued' THEN NOW() ELSE finished_at END
+		 WHERE module_id = $1 AND id = $2 AND status IN ('queued', 'running')
+		RETURNING `+moduleJobColumns,
+		moduleID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ModuleJob{}, false, nil
+	}
+	if err != nil {
+		return ModuleJob{}, false, err
+	}
+	return job, true, nil
+}
+
+// ListCancelRequestedModuleJobs returns the IDs of running jobs that should be stopped.
+func ListCancelRequestedModuleJobs(ctx context.Context) ([]string, error) {
+	var ids []string
+	err := mainDB.SelectContext(ctx, &ids, `
+		SELECT id FROM module_jobs WHERE status = 'running' AND cancel_requested
+	`)
+	return ids, err
+}
+
+// RecoverModuleJobs settles the jobs left running by a previous backend
+// process: cancelled ones are closed, ones that already used maxAttempts are
+// failed, and the rest go back to the queue.
+func RecoverModuleJobs(ctx context.Context, maxAttempts int) (requeued, failed int64, err error) {
+	tx, err := mainDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE module_jobs
+		   SET status = 'cancelled', finished_at = NOW()
+		 WHERE status = 'running' AND cancel_requested
+	`); err != nil {
+		return 0, 0, err
+	}
+	res, err := tx.ExecContext(ctx, `
+		UPDATE module_jobs
+		   SET status = 'failed', error = 'interrupted by a backend restart', finished_at = NOW()
+		 WHERE status = 'running' AND attempts >= $1
+	`, maxAttempts)
+	if err != nil {
+		return 0, 0, err
+	}
+	failed, _ = res.RowsAffected()
+	res, err = tx.ExecContext(ctx, `
+		UPDATE module_jobs
+		   SET status = 'queued', started_at = NULL, worker_id = NULL
+		 WHERE status = 'running'
+	`)
+	if err != nil {
+		return 0, 0, err
+	}
+	requeued, _ = res.RowsAffected()
+	return requeued, failed, tx.Commit()
+}
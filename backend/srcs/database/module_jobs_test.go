@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"testing"
+)
+
+func TestClaimNextModuleJobOrder(t *testing.T) {
+	CreateAndPopulateDatabase(t, "test_module_jobs_order_db", moduleTestDataSQL)
+	ctx := context.Background()
+	const moduleID = "module_01HZXYZDE0420"
+
+	// IDs generated in the same millisecond can sort in any order
+	clone, err := InsertModuleJob(ctx, ModuleJob{ID: "job_01HZZZZZZZZZZZZZZZZZZZZZZZ", ModuleID: moduleID, Kind: "clone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deploy, err := InsertModuleJob(ctx, ModuleJob{ID: "job_01HZ0000000000000000000000", ModuleID: moduleID, Kind: "deploy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, ok, err := ClaimNextModuleJob(ctx, "worker-1")
+	if err != nil || !ok {
+		t.Fatalf("claim = %v, %v", ok, err)
+	}
+	if job.ID != clone.ID {
+		t.Fatalf("claimed %s (%s), want the clone queued first", job.ID, job.Kind)
+	}
+	if _, ok, err := ClaimNextModuleJob(ctx, "worker-2"); err != nil || ok {
+		t.Fatalf("claimed a second job of a busy module: %v, %v", ok, err)
+	}
+	if _, err := FinishModuleJob(ctx, clone.ID, "succeeded", ""); err != nil {
+		t.Fatal(err)
+	}
+	job, ok, err = ClaimNextModuleJob(ctx, "worker-1")
+	if err != nil || !ok || job.ID != deploy.ID {
+		t.Fatalf("second claim = %s, %v, %v; want %s", job.ID, ok, err, deploy.ID)
+	}
+
+	jobs, err := ListModuleJobs(ctx, moduleID, nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != deploy.ID {
+		t.Errorf("ListModuleJobs is not most recent first: %v", jobs)
+	}
+}
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type ModulePageHealthCheck struct {
+	PageID              string        `json:"page_id" db:"page_id"`
+	ModuleID            string        `json:"module_id" db:"module_id"`
+	PageName            string        `json:"page_name" db:"page_name"`
+	PageSlug            string        `json:"page_slug" db:"page_slug"`
+	Enabled             bool          `json:"enabled" db:"enabled"`
+	Path                string        `json:"path" db:"path"`
+	ExpectedStatus      int           `json:"expected_status" db:"expected_status"`
+	IntervalSeconds     int           `json:"interval_seconds" db:"interval_seconds"`
+	TimeoutSeconds      int           `json:"timeout_seconds" db:"timeout_seconds"`
+	Status              string        `json:"status" db:"status"`
+	ConsecutiveFailures int           `json:"consecutive_failures" db:"consecutive_failures"`
+	LastCheckedAt       sql.NullTime  `json:"last_checked_at" db:"last_checked_at"`
+	LastStatusCode      sql.NullInt64 `json:"last_status_code" db:"last_status_code"`
+	LastLatencyMs       sql.NullInt64 `json:"last_latency_ms" db:"last_latency_ms"`
+	LastError           string        `json:"last_error" db:"last_error"`
+	UpdatedAt           time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+type ModulePageHealthResult struct {
+	ID         int64         `json:"id" db:"id"`
+	PageID     string        `json:"page_id" db:"page_id"`
+	ModuleID   string        `json:"module_id" db:"module_id"`
+	CheckedAt  time.Time     `json:"checked_at" db:"checked_at"`
+	OK         bool          `json:"ok" db:"ok"`
+	StatusCode sql.NullInt64 `json:"status_code" db:"status_code"`
+	LatencyMs  int           `json:"latency_ms" db:"latency_ms"`
+	Error      string        `json:"error" db:"error"`
+}
+
+const modulePageHealthSelect = `
+	SELECT h.page_id, h.module_id, p.name AS page_name, p.slug AS page_slug, h.enabled, h.path,
+	       h.expected_status, h.interval_seconds, h.timeout_seconds, h.status, h.consecutive_failures,
+	       h.last_checked_at, h.last_status_code, h.last_latency_ms, h.last_error, h.updated_at
+	  FROM module_page_health_checks h
+	  JOIN module_page p ON p.id = h.page_id`
+
+func GetModulePageHealthCheck(ctx context.Context, pageID string) (ModulePageHealthCheck, error) {
+	var h ModulePageHealthCheck
+	err := mainDB.GetContext(ctx, &h, modulePageHealthSelect+`
+		 WHERE h.page_id = $1
+	`, pageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ModulePageHealthCheck{}, ErrNotFound
+	}
+	return h, err
+}
+
+func ListModulePageHealthChecks(ctx context.Context, moduleID string) ([]ModulePageHealthCheck, error) {
+	var out []ModulePageHealthCheck
+	err := mainDB.SelectContext(ctx, &out, modulePageHealthSelect+`
+		 WHERE h.module_id = $1
+		 ORDER BY p.name
+	`, moduleID)
+	return out, err
+}
+
+// UpsertModulePageHealthCheck stores the probe settings of a page. Changing
+// them resets the probe state so the next tick checks it again.
+func UpsertModulePageHealthCheck(ctx context.Context, h ModulePageHealthCheck) error {
+	_, err := mainDB.ExecContext(ctx, `
+		INSERT INTO module_page_health_checks (page_id, module_id, enabled, path, expected_status, interval_seconds, timeout_seconds)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (page_id)
+		DO UPDATE SET enabled = EXCLUDED.enabled,
+		              path = EXCLUDED.path,
+		              expected_status = EXCLUDED.expected_status,
+		              interval_seconds = EXCLUDED.interval_seconds,
+		              timeout_seconds = EXCLUDED.timeout_seconds,
+		              status = 'unknown',
+		              consecutive_failures = 0,
+		              last_checked_at = NULL,
+		              updated_at = NOW()
+	`, h.PageID, h.ModuleID, h.Enabled, h.Path, h.ExpectedStatus, h.IntervalSeconds, h.TimeoutSeconds)
+	return err
+}
+
+func DeleteModulePageHealthCheck(ctx context.Context, pageID string) error {
+	res, err := mainDB.ExecContext(ctx, `DELETE FROM module_page_health_checks WHERE page_id = $1`, pageID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListDueModulePageHealthChecks returns the enabled probes whose interval has
+// elapsed since their last check.
+func ListDueModulePageHealthChecks(ctx context.Context, now time.Time) ([]ModulePageHealthCheck, error) {
+	var out []ModulePageHealthCheck
+	err := mainDB.SelectContext(ctx, &out, modulePageHealthSelect+`
+		 WHERE h.enabled
+		   AND (h.last_checked_at IS NULL
+		        OR h.last_checked_at + make_interval(secs => h.interval_seconds) <= $1)
+		 ORDER BY h.last_checked_at NULLS FIRST
+	`, now)
+	return out, err
+}
+
+// RecordModulePageHealthResult appends a probe result to the history and
+// stores it as the latest state of the page. status is the page status
+// derived from the result.
+func RecordModulePageHealthResult(ctx context.Context, r ModulePageHealthResult, status string, consecutiveFailures int) error {
+	tx, err := mainDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO module_page_health_history (page_id, module_id, checked_at, ok, status_code, latency_ms, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, r.PageID, r.ModuleID, r.CheckedAt, r.OK, r.StatusCode, r.LatencyMs, r.Error); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE module_page_health_checks
+		   SET status = $2,
+		       consecutive_failures = $3,
+		       last_checked_at = $4,
+		       last_status_code = $5,
+		       last_latency_ms = $6,
+		       last_error = $7
+		 WHERE page_id = $1
+	`, r.PageID, status, consecutiveFailures, r.CheckedAt, r.StatusCode, r.LatencyMs, r.Error); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListModulePageHealthHistory returns the results of a page since a given
+// time, most recent first.
+func ListModulePageHealthHistory(ctx context.Context, pageID string, since time.Time, limit int) ([]ModulePageHealthResult, error) {
+	if limit <= 0 {
+		limit = 500
+	}
+	var out []ModulePageHealthResult
+	err := mainDB.SelectContext(ctx, &out, `
+		SELECT id, page_id, module_id, checked_at, ok, status_code, latency_ms, error
+		  FROM module_page_health_history
+		 WHERE page_id = $1 AND checked_at >= $2
+		 ORDER BY checked_at DESC
+		 LIMIT $3
+	`, pageID, since, limit)
+	return out, err
+}
+
+func PruneModulePageHealthHistory(ctx context.Context, before time.Time) (int64, error) {
+	res, err := mainDB.ExecContext(ctx, `DELETE FROM module_page_health_history WHERE checked_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
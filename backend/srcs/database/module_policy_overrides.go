@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type ModulePolicyOverride struct {
+	ModuleID    string         `json:"module_id" db:"module_id"`
+	Rule        string         `json:"rule" db:"rule"`
+	Reason      string         `json:"reason" db:"reason"`
+	ActorUserID sql.NullString `json:"actor_user_id" db:"actor_user_id"`
+	ActorLogin  sql.NullString `json:"actor_login" db:"actor_login"`
+	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
+}
+
+const modulePolicyOverrideSelect = `
+	SELECT o.module_id, o.rule, o.reason, o.actor_user_id, u.ft_login AS actor_login, o.created_at
+	  FROM module_policy_overrides o
+	  LEFT JOIN users u ON u.id = o.actor_user_id`
+
+func ListModulePolicyOverrides(ctx context.Context, moduleID string) ([]ModulePolicyOverride, error) {
+	var out []ModulePolicyOverride
+	err := mainDB.SelectContext(ctx, &out, modulePolicyOverrideSelect+`
+		 WHERE o.module_id = $1
+		 ORDER BY o.rule
+	`, moduleID)
+	return out, err
+}
+
+// UpsertModulePolicyOverride allows rule for a module, replacing the reason
+// and actor of an existing override.
+func UpsertModulePolicyOverride(ctx context.Context, o ModulePolicyOverride) error {
+	_, err := mainDB.ExecContext(ctx, `
+		INSERT INTO module_policy_overrides (module_id, rule, reason, actor_user_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (module_id, rule)
+		DO UPDATE SET reason = EXCLUDED.reason,
+		              actor_user_id = EXCLUDED.actor_user_id,
+		              created_at = NOW()
+	`, o.ModuleID, o.Rule, o.Reason, o.ActorUserID)
+	return err
+}
+
+func DeleteModulePolicyOverride(ctx context.Context, moduleID, rule string) error {
+	res, err := mainDB.ExecContext(ctx, `
+		DELETE FROM module_policy_overrides WHERE module_id = $1 AND rule = $2
+	`, moduleID, rule)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
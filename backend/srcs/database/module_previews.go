@@ -0,0 +1,164 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ModulePreviewSettings struct {
+	ModuleID       string         `json:"module_id" db:"module_id"`
+	TTLSeconds     int            `json:"ttl_seconds" db:"ttl_seconds"`
+	PreviewSecrets pq.StringArray `json:"preview_secrets" db:"preview_secrets"`
+	UpdatedAt      time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+type ModulePreview struct {
+	ModuleID    string         `json:"module_id" db:"module_id"`
+	ParentID    string         `json:"parent_id" db:"parent_id"`
+	Branch      string         `json:"branch" db:"branch"`
+	ExpiresAt   time.Time      `json:"expires_at" db:"expires_at"`
+	ActorUserID sql.NullString `json:"actor_user_id" db:"actor_user_id"`
+	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
+}
+
+const modulePreviewSettingsColumns = `module_id, ttl_seconds, preview_secrets, updated_at`
+
+const modulePreviewColumns = `module_id, parent_id, branch, expires_at, actor_user_id, created_at`
+
+func GetModulePreviewSettings(ctx context.Context, moduleID string) (ModulePreviewSettings, error) {
+	var s ModulePreviewSettings
+	err := mainDB.GetContext(ctx, &s, `
+		SELECT `+modulePreviewSettingsColumns+`
+		  FROM module_preview_settings
+		 WHERE module_id = $1
+	`, moduleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ModulePreviewSettings{}, ErrNotFound
+	}
+	return s, err
+}
+
+// UpsertModulePreviewSettings stores the preview settings of a module and
+// replaces its maintainer roles.
+func UpsertModulePreviewSettings(ctx context.Context, s ModulePreviewSettings, maintainerRoleIDs []string) (ModulePreviewSettings, error) {
+	tx, err := mainDB.BeginTxx(ctx, nil)
+	if err != nil {
+		return ModulePreviewSettings{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	secrets := s.PreviewSecrets
+	if secrets == nil {
+		// a nil array is stored as NULL
+		secrets = pq.StringArray{}
+	}
+	var out ModulePreviewSettings
+	if err := tx.GetContext(ctx, &out, `
+		INSERT INTO module_preview_settings (module_id, ttl_seconds, preview_secrets)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (module_id)
+		DO UPDATE SET ttl_seconds = EXCLUDED.ttl_seconds,
+		              preview_secrets = EXCLUDED.preview_secrets,
+		              updated_at = NOW()
+		RETURNING `+modulePreviewSettingsColumns,
+		s.ModuleID, s.TTLSeconds, secrets); err != nil {
+		return ModulePreviewSettings{}, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM module_maintainer_roles WHERE module_id = $1`, s.ModuleID); err != nil {
+		return ModulePreviewSettings{}, err
+	}
+	for _, id := range maintainerRoleIDs {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO module_maintainer_roles (module_id, role_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, s.ModuleID, id); err != nil {
+			return ModulePreviewSettings{}, err
+		}
+	}
+	return out, tx.Commit()
+}
+
+func ListModuleMaintainerRoleIDs(ctx context.Context, moduleID string) ([]string, error) {
+	out := []string{}
+	err := mainDB.SelectContext(ctx, &out, `
+		SELECT role_id
+		  FROM module_maintainer_roles
+		 WHERE module_id = $1
+		 ORDER BY role_id
+	`, moduleID)
+	return out, err
+}
+
+// InsertModulePreview registers a preview. ErrAlreadyExists is returned when
+// the parent already has a preview of the branch.
+func InsertModulePreview(ctx context.Context, p ModulePreview) (ModulePreview, error) {
+	var out ModulePreview
+	err := mainDB.GetContext(ctx, &out, `
+		INSERT INTO module_previews (module_id, parent_id, branch, expires_at, actor_user_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+modulePreviewColumns,
+		p.ModuleID, p.ParentID, p.Branch, p.ExpiresAt, p.ActorUserID)
+	if isUniqueViolation(err) {
+		return ModulePreview{}, ErrAlreadyExists
+	}
+	return out, err
+}
+
+func GetModulePreview(ctx context.Context, moduleID string) (ModulePreview, error) {
+	var p ModulePreview
+	err := mainDB.GetContext(ctx, &p, `
+		SELECT `+modulePreviewColumns+`
+		  FROM module_previews
+		 WHERE module_id = $1
+	`, moduleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ModulePreview{}, ErrNotFound
+	}
+	return p, err
+}
+
+func GetModulePreviewByBranch(ctx context.Context, parentID, branch string) (ModulePreview, error) {
+	var p ModulePreview
+	err := mainDB.GetContext(ctx, &p, `
+		SELECT `+modulePreviewColumns+`
+		  FROM module_previews
+		 WHERE parent_id = $1 AND branch = $2
+	`, parentID, branch)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ModulePreview{}, ErrNotFound
+	}
+	return p, err
+}
+
+// ListModulePreviews returns the previews of a module, or of every module
+// when parentID is empty, oldest first.
+func ListModulePreviews(ctx context.Context, parentID string) ([]ModulePreview, error) {
+	out := []ModulePreview{}
+	err := mainDB.SelectContext(ctx, &out, `
+		SELECT `+modulePreviewColumns+`
+		  FROM module_previews
+		 WHERE $1 = '' OR parent_id = $1
+		 ORDER BY created_at, module_id
+	`, parentID)
+	return out, err
+}
+
+// ExtendModulePreview moves the expiry of a preview.
+func ExtendModulePreview(ctx context.Context, moduleID string, expiresAt time.Time) (ModulePreview, error) {
+	var out ModulePreview
+	err := mainDB.GetContext(ctx, &out, `
+		UPDATE module_previews
+		   SET expires_at = $2
+		 WHERE module_id = $1
+		RETURNING `+modulePreviewColumns,
+		moduleID, expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ModulePreview{}, ErrNotFound
+	}
+	return out, err
+}
@@ -0,0 +1,32 @@
+package database
+
+import (
+	"context"
+	"testing"
+)
+
+func TestModulePreviewSettingsSecrets(t *testing.T) {
+	CreateAndPopulateDatabase(t, "test_module_preview_settings_db", moduleTestDataSQL)
+	ctx := context.Background()
+	const moduleID = "module_01HZXYZDE0420"
+
+	// Settings stored without an allow-list copy no secret
+	s, err := UpsertModulePreviewSettings(ctx, ModulePreviewSettings{ModuleID: moduleID, TTLSeconds: 3600}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.PreviewSecrets == nil || len(s.PreviewSecrets) != 0 {
+		t.Errorf("default preview secrets = %#v, want empty", s.PreviewSecrets)
+	}
+
+	if _, err := UpsertModulePreviewSettings(ctx, ModulePreviewSettings{ModuleID: moduleID, TTLSeconds: 3600, PreviewSecrets: []string{"SENTRY_DSN", "API_URL"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	s, err = GetModulePreviewSettings(ctx, moduleID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.PreviewSecrets) != 2 || s.PreviewSecrets[0] != "SENTRY_DSN" || s.PreviewSecrets[1] != "API_URL" {
+		t.Errorf("preview secrets = %v", s.PreviewSecrets)
+	}
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type ModuleReleaseTracking struct {
+	ModuleID    string    `json:"module_id" db:"module_id"`
+	Constraint  string    `json:"version_constraint" db:"version_constraint"`
+	Prereleases bool      `json:"prereleases" db:"prereleases"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+const moduleReleaseTrackingColumns = `module_id, version_constraint, prereleases, updated_at`
+
+func GetModuleReleaseTracking(ctx context.Context, moduleID string) (ModuleReleaseTracking, error) {
+	var t ModuleReleaseTracking
+	err := mainDB.GetContext(ctx, &t, `
+		SELECT `+moduleReleaseTrackingColumns+`
+		  FROM module_release_tracking
+		 WHERE module_id = $1
+	`, moduleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ModuleReleaseTracking{}, ErrNotFound
+	}
+	return t, err
+}
+
+func UpsertModuleReleaseTracking(ctx context.Context, t ModuleReleaseTracking) (ModuleReleaseTracking, error) {
+	var out ModuleReleaseTracking
+	err := mainDB.GetContext(ctx, &out, `
+		INSERT INTO module_release_tracking (module_id, version_constraint, prereleases)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (module_id)
+		DO UPDATE SET version_constraint = EXCLUDED.version_constraint,
+		              prereleases = EXCLUDED.prereleases,
+		              updated_at = NOW()
+		RETURNING `+moduleReleaseTrackingColumns,
+		t.ModuleID, t.Constraint, t.Prereleases)
+	return out, err
+}
+
+func DeleteModuleReleaseTracking(ctx context.Context, moduleID string) error {
+	_, err := mainDB.ExecContext(ctx, `DELETE FROM module_release_tracking WHERE module_id = $1`, moduleID)
+	return err
+}
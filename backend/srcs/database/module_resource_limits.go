@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type ModuleResourceLimits struct {
+	ModuleID  string          `json:"module_id" db:"module_id"`
+	CPUs      sql.NullFloat64 `json:"cpus" db:"cpus"`
+	MemoryMB  sql.NullInt64   `json:"memory_mb" db:"memory_mb"`
+	Pids      sql.NullInt64   `json:"pids" db:"pids"`
+	DiskMB    sql.NullInt64   `json:"disk_mb" db:"disk_mb"`
+	UpdatedAt time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+const moduleResourceLimitsColumns = `module_id, cpus, memory_mb, pids, disk_mb, updated_at`
+
+func GetModuleResourceLimits(ctx context.Context, moduleID string) (ModuleResourceLimits, error) {
+	var l ModuleResourceLimits
+	err := mainDB.GetContext(ctx, &l, `
+		SELECT `+moduleResourceLimitsColumns+`
+		  FROM module_resource_limits
+		 WHERE module_id = $1
+	`, moduleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ModuleResourceLimits{}, ErrNotFound
+	}
+	return l, err
+}
+
+func UpsertModuleResourceLimits(ctx context.Context, l ModuleResourceLimits) (ModuleResourceLimits, error) {
+	var out ModuleResourceLimits
+	err := mainDB.GetContext(ctx, &out, `
+		INSERT INTO module_resource_limits (module_id, cpus, memory_mb, pids, disk_mb)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (module_id)
+		DO UPDATE SET cpus = EXCLUDED.cpus,
+		              memory_mb = EXCLUDED.memory_mb,
+		              pids = EXCLUDED.pids,
+		              disk_mb = EXCLUDED.disk_mb,
+		              updated_at = NOW()
+		RETURNING `+moduleResourceLimitsColumns,
+		l.ModuleID, l.CPUs, l.MemoryMB, l.Pids, l.DiskMB)
+	return out, err
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ModuleSecret is an encrypted module secret. Decryption happens in core.
+type ModuleSecret struct {
+	ModuleID   string        `json:"module_id" db:"module_id"`
+	Name       string        `json:"name" db:"name"`
+	Nonce      []byte        `json:"-" db:"nonce"`
+	Ciphertext []byte        `json:"-" db:"ciphertext"`
+	DataKey    []byte        `json:"-" db:"data_key"` // NULL: encrypted directly with master key 1
+	KeyVersion sql.NullInt64 `json:"-" db:"key_version"`
+	CreatedAt  time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+func ListModuleSecrets(ctx context.Context, moduleID string) ([]ModuleSecret, error) {
+	var secrets []ModuleSecret
+	err := mainDB.SelectContext(ctx, &secrets, `
+		SELECT module_id, name, nonce, ciphertext, data_key, key_version, created_at, updated_at
+		  FROM module_secrets
+		 WHERE module_id = $1
+		 ORDER BY name
+	`, moduleID)
+	return secrets, err
+}
+
+// UpsertModuleSecret stores a secret and reports whether it was newly created.
+func UpsertModuleSecret(ctx context.Context, s ModuleSecret) (ModuleSecret, bool, error) {
+	var out ModuleSecret
+	var created bool
+	err := mainDB.QueryRowxContext(ctx, `
+		INSERT INTO module_secrets (module_id, name, nonce, ciphertext, data_key, key_version)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (module_id, name)
+		DO UPDATE SET nonce = EXCLUDED.nonce,
+		              ciphertext = EXCLUDED.ciphertext,
+		              data_key = EXCLUDED.data_key,
+		              key_version = EXCLUDED.key_version,
+		              updated_at = NOW()
+		RETURNING module_id, name, nonce, ciphertext, data_key, key_version, created_at, updated_at, (xmax = 0) AS created
+	`, s.ModuleID, s.Name, s.Nonce, s.Ciphertext, s.DataKey, s.KeyVersion).Scan(
+		&out.ModuleID, &out.Name, &out.Nonce, &out.Ciphertext, &out.DataKey, &out.KeyVersion,
+		&out.CreatedAt, &out.UpdatedAt, &created,
+	)
+	return out, created, err
+}
+
+func DeleteModuleSecret(ctx context.Context, moduleID, name string) error {
+	res, err := mainDB.ExecContext(ctx, `DELETE FROM module_secrets WHERE module_id = $1 AND name = $2`, moduleID, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListModuleSecretsToRewrap returns the secrets not wrapped with activeVersion,
+// across all modules.
+func ListModuleSecretsToRewrap(ctx context.Context, activeVersion int) ([]ModuleSecret, error) {
+	var secrets []ModuleSecret
+	err := mainDB.SelectContext(ctx, &secrets, `
+		SELECT module_id, name, nonce, ciphertext, data_key, key_version, created_at, updated_at
+		  FROM module_secrets
+		 WHERE key_version IS NULL OR key_version <> $1
+		 ORDER BY module_id, name
+	`, activeVersion)
+	return secrets, err
+}
+
+// ReplaceModuleSecretCipher swaps the encrypted value of a secret without
+// touching updated_at. The write is skipped (false) if the secret changed
+// since it was read.
+func ReplaceModuleSecretCipher(ctx context.Context, s ModuleSecret, previousVersion sql.NullInt64) (bool, error) {
+	res, err := mainDB.ExecContext(ctx, `
+		UPDATE module_secrets
+		   SET nonce = $3,
+		       ciphertext = $4,
+		       data_key = $5,
+		       key_version = $6
+		 WHERE module_id = $1 AND name = $2
+		   AND key_version IS NOT DISTINCT FROM $7
+	`, s.ModuleID, s.Name, s.Nonce, s.Ciphertext, s.DataKey, s.KeyVersion, previousVersion)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"backend/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ModuleTemplate struct {
+	ID          string         `json:"id" db:"id"`
+	Name        string         `json:"name" db:"name"`
+	Description string         `json:"description" db:"description"`
+	GitURL      string         `json:"git_url" db:"git_url"`
+	GitBranch   string         `json:"git_branch" db:"git_branch"`
+	SSHKeyID    sql.NullString `json:"ssh_key_id" db:"ssh_key_id"`
+	DefaultPort int            `json:"default_port" db:"default_port"`
+	ActorUserID sql.NullString `json:"actor_user_id" db:"actor_user_id"`
+	ActorLogin  sql.NullString `json:"actor_login" db:"actor_login"`
+	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+const moduleTemplateSelect = `
+	SELECT t.id, t.name, t.description, t.git_url, t.git_branch, t.ssh_key_id, t.default_port,
+	       t.actor_user_id, u.ft_login AS actor_login, t.created_at, t.updated_at
+	  FROM module_templates t
+	  LEFT JOIN users u ON u.id = t.actor_user_id`
+
+func ListModuleTemplates(ctx context.Context) ([]ModuleTemplate, error) {
+	var out []ModuleTemplate
+	err := mainDB.SelectContext(ctx, &out, moduleTemplateSelect+`
+		 ORDER BY t.name
+	`)
+	return out, err
+}
+
+func GetModuleTemplate(ctx context.Context, id string) (ModuleTemplate, error) {
+	var t ModuleTemplate
+	err := mainDB.GetContext(ctx, &t, moduleTemplateSelect+`
+		 WHERE t.id = $1
+	`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ModuleTemplate{}, ErrNotFound
+	}
+	return t, err
+}
+
+// InsertModuleTemplate registers a template. ErrAlreadyExists is returned
+// when the name is taken.
+func InsertModuleTemplate(ctx context.Context, t ModuleTemplate) (ModuleTemplate, error) {
+	if t.ID == "" {
+		t.ID = utils.GenerateULID(utils.Type("template"))
+	}
+	_, err := mainDB.ExecContext(ctx, `
+		INSERT INTO module_templates (id, name, description, git_url, git_branch, ssh_key_id, default_port, actor_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, t.ID, t.Name, t.Description, t.GitURL, t.GitBranch, t.SSHKeyID, t.DefaultPort, t.ActorUserID)
+	if isUniqueViolation(err) {
+		return ModuleTemplate{}, ErrAlreadyExists
+	}
+	if err != nil {
+		return ModuleTemplate{}, err
+	}
+	return GetModuleTemplate(ctx, t.ID)
+}
+
+// UpdateModuleTemplate replaces every editable field of a template.
+func UpdateModuleTemplate(ctx context.Context, t ModuleTemplate) (ModuleTemplate, error) {
+	res, err := mainDB.ExecContext(ctx, `
+		UPDATE module_templates
+		   SET name = $2,
+		       description = $3,
+		       git_url = $4,
+		       git_branch = $5,
+		       ssh_key_id = $6,
+		       default_port = $7,
+		       actor_user_id = $8,
+		       updated_at = NOW()
+		 WHERE id = $1
+	`, t.ID, t.Name, t.Description, t.GitURL, t.GitBranch, t.SSHKeyID, t.DefaultPort, t.ActorUserID)
+	if isUniqueViolation(err) {
+		return ModuleTemplate{}, ErrAlreadyExists
+	}
+	if err != nil {
+		return ModuleTemplate{}, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ModuleTemplate{}, ErrNotFound
+	}
+	return GetModuleTemplate(ctx, t.ID)
+}
+
+func DeleteModuleTemplate(ctx context.Context, id string) error {
+	res, err := mainDB.ExecContext(ctx, `DELETE FROM module_templates WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type ModuleUpdatePolicy struct {
+	ModuleID           string          `json:"module_id" db:"module_id"`
+	Mode               string          `json:"mode" db:"mode"`
+	CheckInterval      int             `json:"check_interval_seconds" db:"check_interval_seconds"`
+	Timezone           string          `json:"timezone" db:"timezone"`
+	MaintenanceWindows json.RawMessage `json:"maintenance_windows" db:"maintenance_windows"`
+	LastCheckedAt      sql.NullTime    `json:"last_checked_at" db:"last_checked_at"`
+	LastResult         string          `json:"last_result" db:"last_result"`
+	LastError          string          `json:"last_error" db:"last_error"`
+	LastNotifiedHash   string          `json:"last_notified_hash" db:"last_notified_hash"`
+	PausedAt           sql.NullTime    `json:"paused_at" db:"paused_at"`
+	PausedReason       string          `json:"paused_reason" db:"paused_reason"`
+	UpdatedAt          time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+const moduleUpdatePolicyColumns = `module_id, mode, check_interval_seconds, timezone, maintenance_windows,
+	       last_checked_at, last_result, last_error, last_notified_hash,
+	       paused_at, paused_reason, updated_at`
+
+func GetModuleUpdatePolicy(ctx context.Context, moduleID string) (ModuleUpdatePolicy, error) {
+	var p ModuleUpdatePolicy
+	err := mainDB.GetContext(ctx, &p, `
+		SELECT `+moduleUpdatePolicyColumns+`
+		  FROM module_update_policies
+		 WHERE module_id = $1
+	`, moduleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ModuleUpdatePolicy{}, ErrNotFound
+	}
+	return p, err
+}
+
+// UpsertModuleUpdatePolicy stores the settings of a policy. Check results are
+// kept so changing the mode does not re-notify about the same commit.
+func UpsertModuleUpdatePolicy(ctx context.Context, p ModuleUpdatePolicy) (ModuleUpdatePolicy, error) {
+	var out ModuleUpdatePolicy
+	err := mainDB.GetContext(ctx, &out, `
+		INSERT INTO module_update_policies (module_id, mode, check_interval_seconds, timezone, maintenance_windows)
+		VALUES ($1, $2, $3, $4, $5::jsonb)
+		ON CONFLICT (module_id)
+		DO UPDATE SET mode = EXCLUDED.mode,
+		              check_interval_seconds = EXCLUDED.check_interval_seconds,
+		              timezone = EXCLUDED.timezone,
+		              maintenance_windows = EXCLUDED.maintenance_windows,
+		              updated_at = NOW()
+		RETURNING `+moduleUpdatePolicyColumns,
+		p.ModuleID, p.Mode, p.CheckInterval, p.Timezone, string(p.MaintenanceWindows))
+	return out, err
+}
+
+// ListDueModuleUpdatePolicies returns the non-manual policies whose interval
+// has elapsed since their last check.
+func ListDueModuleUpdatePolicies(ctx context.Context, now time.Time) ([]ModuleUpdatePolicy, error) {
+	var policies []ModuleUpdatePolicy
+	err := mainDB.SelectContext(ctx, &policies, `
+		SELECT `+moduleUpdatePolicyColumns+`
+		  FROM module_update_policies
+		 WHERE mode <> 'manual'
+		   AND (last_checked_at IS NULL
+		        OR last_checked_at + make_interval(secs => check_interval_seconds) <= $1)
+		 ORDER BY last_checked_at NULLS FIRST
+	`, now)
+	return policies, err
+}
+
+func RecordModuleUpdateCheck(ctx context.Context, moduleID string, checkedAt time.Time, result, errMsg, notifiedHash string) error {
+	_, err := mainDB.ExecContext(ctx, `
+		UPDATE module_update_policies
+		   SET last_checked_at = $2,
+		       last_result = $3,
+		       last_error = $4,
+		       last_notified_hash = $5
+		 WHERE module_id = $1
+	`, moduleID, checkedAt, result, errMsg, notifiedHash)
+	return err
+}
+
+// PauseModuleUpdates stops automatic pulls and deploys of a module. The row is
+// created with the manual defaults when the module has no policy yet, so the
+// pause survives a later switch to an automatic mode.
+func PauseModuleUpdates(ctx context.Context, moduleID, reason string) error {
+	_, err := mainDB.ExecContext(ctx, `
+		INSERT INTO module_update_policies (module_id, paused_at, paused_reason)
+		VALUES ($1, NOW(), $2)
+		ON CONFLICT (module_id)
+		DO UPDATE SET paused_at = NOW(),
+		              paused_reason = EXCLUDED.paused_reason
+	`, moduleID, reason)
+	return err
+}
+
+// ResumeModuleUpdates clears the pause of a module. It reports whether the
+// module was paused.
+func ResumeModuleUpdates(ctx context.Context, moduleID string) (bool, error) {
+	res, err := mainDB.ExecContext(ctx, `
+		UPDATE module_update_policies
+		   SET paused_at = NULL,
+		       paused_reason = ''
+		 WHERE module_id = $1
+		   AND paused_at IS NOT NULL
+	`, moduleID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
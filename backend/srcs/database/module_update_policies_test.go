@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestPauseModuleUpdates(t *testing.T) {
+	CreateAndPopulateDatabase(t, "test_module_update_pause_db", moduleTestDataSQL)
+	ctx := context.Background()
+	const moduleID = "module_01HZXYZDE0420"
+
+	// A module without a policy gets a manual row carrying the pause
+	if err := PauseModuleUpdates(ctx, moduleID, "rolled back to abc1234"); err != nil {
+		t.Fatal(err)
+	}
+	p, err := GetModuleUpdatePolicy(ctx, moduleID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Mode != "manual" || !p.PausedAt.Valid || p.PausedReason != "rolled back to abc1234" {
+		t.Fatalf("after pause: %+v", p)
+	}
+
+	// Changing the policy settings keeps the pause
+	p, err = UpsertModuleUpdatePolicy(ctx, ModuleUpdatePolicy{
+		ModuleID: moduleID, Mode: "auto_pull_deploy", CheckInterval: 3600, Timezone: "UTC", MaintenanceWindows: json.RawMessage(`[]`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.PausedAt.Valid {
+		t.Error("upsert cleared the pause")
+	}
+
+	resumed, err := ResumeModuleUpdates(ctx, moduleID)
+	if err != nil || !resumed {
+		t.Fatalf("resume = %v, %v", resumed, err)
+	}
+	p, err = GetModuleUpdatePolicy(ctx, moduleID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.PausedAt.Valid || p.PausedReason != "" || p.Mode != "auto_pull_deploy" {
+		t.Errorf("after resume: %+v", p)
+	}
+	if resumed, err := ResumeModuleUpdates(ctx, moduleID); err != nil || resumed {
+		t.Errorf("second resume = %v, %v", resumed, err)
+	}
+}
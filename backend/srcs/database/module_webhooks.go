@@ -0,0 +1,187 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// moduleWebhookDeliveriesKept is how many deliveries are kept per module.
+const moduleWebhookDeliveriesKept = 200
+
+// ModuleWebhook is the inbound push webhook of a module. The secret is
+// decrypted in core.
+type ModuleWebhook struct {
+	ModuleID   string    `json:"module_id" db:"module_id"`
+	Enabled    bool      `json:"enabled" db:"enabled"`
+	Nonce      []byte    `json:"-" db:"nonce"`
+	Ciphertext []byte    `json:"-" db:"ciphertext"`
+	DataKey    []byte    `json:"-" db:"data_key"`
+	KeyVersion int       `json:"-" db:"key_version"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type ModuleWebhookDelivery struct {
+	ID          int64        `json:"id" db:"id"`
+	ModuleID    string       `json:"module_id" db:"module_id"`
+	ReceivedAt  time.Time    `json:"received_at" db:"received_at"`
+	Provider    string       `json:"provider" db:"provider"`
+	Event       string       `json:"event" db:"event"`
+	DeliveryID  string       `json:"delivery_id" db:"delivery_id"`
+	Ref         string       `json:"ref" db:"ref"`
+	AfterCommit string       `json:"after_commit" db:"after_commit"`
+	Status      string       `json:"status" db:"status"`
+	Result      string       `json:"result" db:"result"`
+	Error       string       `json:"error" db:"error"`
+	FinishedAt  sql.NullTime `json:"finished_at" db:"finished_at"`
+}
+
+const moduleWebhookColumns = `module_id, enabled, nonce, ciphertext, data_key, key_version, created_at, updated_at`
+
+const moduleWebhookDeliveryColumns = `id, module_id, received_at, provider, event, delivery_id, ref, after_commit,
+	       status, result, error, finished_at`
+
+func GetModuleWebhook(ctx context.Context, moduleID string) (ModuleWebhook, error) {
+	var w ModuleWebhook
+	err := mainDB.GetContext(ctx, &w, `
+		SELECT `+moduleWebhookColumns+`
+		  FROM module_webhooks
+		 WHERE module_id = $1
+	`, moduleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ModuleWebhook{}, ErrNotFound
+	}
+	return w, err
+}
+
+// UpsertModuleWebhook stores a new secret for a module webhook and enables it.
+func UpsertModuleWebhook(ctx context.Context, w ModuleWebhook) (ModuleWebhook, error) {
+	var out ModuleWebhook
+	err := mainDB.GetContext(ctx, &out, `
+		INSERT INTO module_webhooks (module_id, enabled, nonce, ciphertext, data_key, key_version)
+		VALUES ($1, TRUE, $2, $3, $4, $5)
+		ON CONFLICT (module_id)
+		DO UPDATE SET enabled = TRUE,
+		              nonce = EXCLUDED.nonce,
+		              ciphertext = EXCLUDED.ciphertext,
+		              data_key = EXCLUDED.data_key,
+		              key_version = EXCLUDED.key_version,
+		              updated_at = NOW()
+		RETURNING `+moduleWebhookColumns,
+		w.ModuleID, w.Nonce, w.Ciphertext, w.DataKey, w.KeyVersion)
+	return out, err
+}
+
+func SetModuleWebhookEnabled(ctx context.Context, moduleID string, enabled bool) (ModuleWebhook, error) {
+	var out ModuleWebhook
+	err := mainDB.GetContext(ctx, &out, `
+		UPDATE module_webhooks
+		   SET enabled = $2, updated_at = NOW()
+		 WHERE module_id = $1
+		RETURNING `+moduleWebhookColumns,
+		moduleID, enabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ModuleWebhook{}, ErrNotFound
+	}
+	return out, err
+}
+
+func DeleteModuleWebhook(ctx context.Context, moduleID string) error {
+	res, err := mainDB.ExecContext(ctx, `DELETE FROM module_webhooks WHERE module_id = $1`, moduleID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListModuleWebhooksToRewrap returns the webhooks whose data key is not
+// wrapped with activeVersion.
+func ListModuleWebhooksToRewrap(ctx context.Context, activeVersion int) ([]ModuleWebhook, error) {
+	var out []ModuleWebhook
+	err := mainDB.SelectContext(ctx, &out, `
+		SELECT `+moduleWebhookColumns+`
+		  FROM module_webhooks
+		 WHERE key_version <> $1
+		 ORDER BY module_id
+	`, activeVersion)
+	return out, err
+}
+
+// ReplaceModuleWebhookCipher swaps the encrypted secret of a webhook without
+// touching updated_at. The write is skipped (false) if the secret changed
+// since it was read.
+func ReplaceModuleWebhookCipher(ctx context.Context, w ModuleWebhook, previousVersion int) (bool, error) {
+	res, err := mainDB.ExecContext(ctx, `
+		UPDATE module_webhooks
+		   SET nonce = $2,
+		       ciphertext = $3,
+		       data_key = $4,
+		       key_version = $5
+		 WHERE module_id = $1 AND key_version = $6
+	`, w.ModuleID, w.Nonce, w.Ciphertext, w.DataKey, w.KeyVersion, previousVersion)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// InsertModuleWebhookDelivery records a delivery and drops the oldest ones
+// beyond moduleWebhookDeliveriesKept for the module.
+func InsertModuleWebhookDelivery(ctx context.Context, d ModuleWebhookDelivery) (ModuleWebhookDelivery, error) {
+	tx, err := mainDB.BeginTxx(ctx, nil)
+	if err != nil {
+		return ModuleWebhookDelivery{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var out ModuleWebhookDelivery
+	if err := tx.GetContext(ctx, &out, `
+		INSERT INTO module_webhook_deliveries (module_id, provider, event, delivery_id, ref, after_commit, status, result, error, finished_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, CASE WHEN $7 = 'accepted' THEN NULL ELSE NOW() END)
+		RETURNING `+moduleWebhookDeliveryColumns,
+		d.ModuleID, d.Provider, d.Event, d.DeliveryID, d.Ref, d.AfterCommit, d.Status, d.Result, d.Error); err != nil {
+		return ModuleWebhookDelivery{}, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM module_webhook_deliveries
+		 WHERE module_id = $1
+		   AND id <= (SELECT id FROM module_webhook_deliveries
+		               WHERE module_id = $1
+		               ORDER BY id DESC
+		              OFFSET $2 LIMIT 1)
+	`, d.ModuleID, moduleWebhookDeliveriesKept); err != nil {
+		return ModuleWebhookDelivery{}, err
+	}
+	return out, tx.Commit()
+}
+
+func FinishModuleWebhookDelivery(ctx context.Context, id int64, status, result, errMsg string) error {
+	_, err := mainDB.ExecContext(ctx, `
+		UPDATE module_webhook_deliveries
+		   SET status = $2, result = $3, error = $4, finished_at = NOW()
+		 WHERE id = $1
+	`, id, status, result, errMsg)
+	return err
+}
+
+// ListModuleWebhookDeliveries returns the most recent deliveries first.
+func ListModuleWebhookDeliveries(ctx context.Context, moduleID string, limit int) ([]ModuleWebhookDelivery, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	var out []ModuleWebhookDelivery
+	err := mainDB.SelectContext(ctx, &out, `
+		SELECT `+moduleWebhookDeliveryColumns+`
+		  FROM module_webhook_deliveries
+		 WHERE module_id = $1
+		 ORDER BY id DESC
+		 LIMIT $2
+	`, moduleID, limit)
+	return out, err
+}
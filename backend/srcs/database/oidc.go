@@ -456,3 +456,73 @@ func HashOIDCSecret(secret string) string {
 	sum := sha256.Sum256([]byte(secret))
 	return hex.EncodeToString(sum[:])
 }
+
+// ListOIDCAuthorizationCodesByUser returns every authorization code issued to a user, newest first.
+func ListOIDCAuthorizationCodesByUser(userID string) ([]OIDCAuthorizationCode, error) {
+	rows, err := mainDB.Query(`
+		SELECT id, code_hash, client_id, module_id, user_id, redirect_uri, scopes, nonce, expires_at, consumed_at, created_at
+		FROM oidc_authorization_codes
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []OIDCAuthorizationCode
+	for rows.Next() {
+		var code OIDCAuthorizationCode
+		if err := rows.Scan(
+			&code.ID,
+			&code.CodeHash,
+			&code.ClientID,
+			&code.ModuleID,
+			&code.UserID,
+			&code.RedirectURI,
+			pq.Array(&code.Scopes),
+			&code.Nonce,
+			&code.ExpiresAt,
+			&code.ConsumedAt,
+			&code.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, code)
+	}
+	return out, rows.Err()
+}
+
+// ListOIDCAccessTokensByUser returns every access token issued to a user, newest first.
+func ListOIDCAccessTokensByUser(userID string) ([]OIDCAccessToken, error) {
+	rows, err := mainDB.Query(`
+		SELECT id, token_hash, client_id, module_id, user_id, scopes, expires_at, revoked_at, created_at
+		FROM oidc_access_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []OIDCAccessToken
+	for rows.Next() {
+		var token OIDCAccessToken
+		if err := rows.Scan(
+			&token.ID,
+			&token.TokenHash,
+			&token.ClientID,
+			&token.ModuleID,
+			&token.UserID,
+			pq.Array(&token.Scopes),
+			&token.ExpiresAt,
+			&token.RevokedAt,
+			&token.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, token)
+	}
+	return out, rows.Err()
+}
@@ -67,3 +67,31 @@ func DeleteUserPrefs(ctx context.Context, userID string) error {
     `, userID)
 	return err
 }
+
+// ListUserPrefs returns every preference stored for a user, keyed by pref_key.
+func ListUserPrefs(ctx context.Context, userID string) (map[string]json.RawMessage, error) {
+	if err := ensureUserPrefsTable(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := mainDB.QueryContext(ctx, `
+        SELECT pref_key, value
+          FROM user_prefs
+         WHERE user_id = $1
+         ORDER BY pref_key
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]json.RawMessage{}
+	for rows.Next() {
+		var key string
+		var data []byte
+		if err := rows.Scan(&key, &data); err != nil {
+			return nil, err
+		}
+		out[key] = json.RawMessage(data)
+	}
+	return out, rows.Err()
+}
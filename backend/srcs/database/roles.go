@@ -370,9 +370,10 @@ func DeleteAllRolesForUser(userID string) error {
 
 func LinkDefaultRolesToUser(userID string) error {
 	_, err := mainDB.Exec(`
-		INSERT INTO user_roles (user_id, role_id)
-		SELECT $1, id FROM roles WHERE is_default = TRUE;
-	`, userID)
+		INSERT INTO user_roles (user_id, role_id, source)
+		SELECT $1, id, $2 FROM roles WHERE is_default = TRUE
+		ON CONFLICT DO NOTHING;
+	`, userID, UserRoleSourceDefault)
 	return err
 }
 
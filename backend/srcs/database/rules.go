@@ -51,10 +51,11 @@ func UpdateRoleRulesJSON(ctx context.Context, roleID string, rulesJSON []byte) e
 func ListActiveUsers(ctx context.Context) ([]User, error) {
 	// Minimal filter: only users with a 42 login
 	rows, err := mainDB.QueryContext(ctx, `
-		SELECT id, ft_login
+		SELECT id, ft_login, ft_id
 		  FROM users
 		 WHERE ft_login IS NOT NULL
 		   AND ft_login <> ''
+		   AND anonymized_at IS NULL
 	`)
 	if err != nil {
 		return nil, err
@@ -64,7 +65,7 @@ func ListActiveUsers(ctx context.Context) ([]User, error) {
 	var out []User
 	for rows.Next() {
 		var u User
-		if err := rows.Scan(&u.ID, &u.FtLogin); err != nil {
+		if err := rows.Scan(&u.ID, &u.FtLogin, &u.FtID); err != nil {
 			return nil, err
 		}
 		out = append(out, u)
@@ -75,32 +76,23 @@ func ListActiveUsers(ctx context.Context) ([]User, error) {
 	return out, nil
 }
 
-// EnsureUserRole makes membership match shouldHave. Returns true if a change occurred.
+// EnsureUserRole makes rule-sourced membership match shouldHave. Links created
+// manually or through default roles are never removed. Returns true if a change occurred.
 func EnsureUserRole(ctx context.Context, userID, roleID string, shouldHave bool) (bool, error) {
+	var res sql.Result
+	var err error
 	if shouldHave {
-		_, err := mainDB.ExecContext(ctx, `
-			INSERT INTO user_roles (user_id, role_id)
-			VALUES ($1, $2)
+		res, err = mainDB.ExecContext(ctx, `
+			INSERT INTO user_roles (user_id, role_id, source)
+			VALUES ($1, $2, $3)
 			ON CONFLICT (user_id, role_id) DO NOTHING
-		`, userID, roleID)
-		if err != nil {
-			return false, err
-		}
-		var exists bool
-		err = mainDB.QueryRowContext(ctx, `
-			SELECT EXISTS (
-				SELECT 1 FROM user_roles WHERE user_id = $1 AND role_id = $2
-			)`, userID, roleID).Scan(&exists)
-		if err != nil {
-			return false, err
-		}
-		return true, nil
+		`, userID, roleID, UserRoleSourceRule)
+	} else {
+		res, err = mainDB.ExecContext(ctx, `
+			DELETE FROM user_roles
+			 WHERE user_id = $1 AND role_id = $2 AND source = $3
+		`, userID, roleID, UserRoleSourceRule)
 	}
-
-	res, err := mainDB.ExecContext(ctx, `
-		DELETE FROM user_roles
-		 WHERE user_id = $1 AND role_id = $2
-	`, userID, roleID)
 	if err != nil {
 		return false, err
 	}
@@ -156,6 +148,19 @@ func ListRolesWithRules() ([]Role, error) {
 	return out, nil
 }
 
+// RemoveRuleRoleFromAllUsers strips the rule-sourced links of a role, returning how many rows were removed.
+func RemoveRuleRoleFromAllUsers(ctx context.Context, roleID string) (int, error) {
+	res, err := mainDB.ExecContext(ctx, `
+		DELETE FROM user_roles
+		 WHERE role_id = $1 AND source = $2
+	`, roleID, UserRoleSourceRule)
+	if err != nil {
+		return 0, err
+	}
+	ra, _ := res.RowsAffected()
+	return int(ra), nil
+}
+
 // GetRoleRulesJSON fetches the stored rules JSON (nil if NULL).
 func GetRoleRulesJSON(roleID string) ([]byte, *time.Time, error) {
 	const q = `
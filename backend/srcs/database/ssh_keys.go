@@ -1,16 +1,18 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
 )
 
 type SSHKey struct {
-	ID                string         `json:"id" db:"id"`
-	Name              string         `json:"name" db:"name"`
-	PublicKey         string         `json:"public_key" db:"public_key"`
-	PrivateKey        string         `json:"private_key" db:"private_key"`
+	ID         string `json:"id" db:"id"`
+	Name       string `json:"name" db:"name"`
+	PublicKey  string `json:"public_key" db:"public_key"`
+	PrivateKey string `json:"-" db:"private_key"` // legacy plaintext, empty once encrypted
+	SSHKeyCipher
 	CreatedAt         time.Time      `json:"created_at" db:"created_at"`
 	UpdatedAt         time.Time      `json:"updated_at" db:"updated_at"`
 	LastUsedAt        time.Time      `json:"last_used_at" db:"last_used_at"`
@@ -18,6 +20,14 @@ type SSHKey struct {
 	CreatedByModuleID sql.NullString `json:"created_by_module_id" db:"created_by_module_id"`
 }
 
+// SSHKeyCipher is the envelope-encrypted private key. Decryption happens in core.
+type SSHKeyCipher struct {
+	PrivateKeyEnc   []byte        `json:"-" db:"private_key_enc"`
+	PrivateKeyNonce []byte        `json:"-" db:"private_key_nonce"`
+	DataKey         []byte        `json:"-" db:"data_key"`
+	KeyVersion      sql.NullInt64 `json:"-" db:"key_version"`
+}
+
 type SSHKeyWithUsage struct {
 	SSHKey
 	UsageCount          int
@@ -45,7 +55,6 @@ func ListSSHKeysWithUsage() ([]SSHKeyWithUsage, error) {
         SELECT k.id,
                k.name,
                k.public_key,
-               k.private_key,
                k.created_at,
                k.updated_at,
                k.last_used_at,
@@ -60,7 +69,7 @@ func ListSSHKeysWithUsage() ([]SSHKeyWithUsage, error) {
           LEFT JOIN modules m ON m.ssh_key_id = k.id
           LEFT JOIN users u ON u.id = k.created_by_user_id
           LEFT JOIN modules cm ON cm.id = k.created_by_module_id
-      GROUP BY k.id, k.name, k.public_key, k.private_key, k.created_at, k.updated_at,
+      GROUP BY k.id, k.name, k.public_key, k.created_at, k.updated_at,
                k.created_by_user_id, k.created_by_module_id,
                u.ft_login, u.photo_url, cm.name, cm.icon_url
       ORDER BY k.name ASC
@@ -77,7 +86,6 @@ func ListSSHKeysWithUsage() ([]SSHKeyWithUsage, error) {
 			&k.ID,
 			&k.Name,
 			&k.PublicKey,
-			&k.PrivateKey,
 			&k.CreatedAt,
 			&k.UpdatedAt,
 			&k.LastUsedAt,
@@ -99,7 +107,8 @@ func ListSSHKeysWithUsage() ([]SSHKeyWithUsage, error) {
 func GetSSHKey(id string) (SSHKey, error) {
 	var k SSHKey
 	err := mainDB.Get(&k, `
-        SELECT id, name, public_key, private_key, created_at, updated_at, last_used_at,
+        SELECT id, name, public_key, private_key, private_key_enc, private_key_nonce,
+               data_key, key_version, created_at, updated_at, last_used_at,
                created_by_user_id, created_by_module_id
           FROM ssh_keys
          WHERE id = $1
@@ -116,7 +125,8 @@ func GetSSHKey(id string) (SSHKey, error) {
 func GetSSHKeyByName(name string) (SSHKey, error) {
 	var k SSHKey
 	err := mainDB.Get(&k, `
-        SELECT id, name, public_key, private_key, created_at, updated_at, last_used_at,
+        SELECT id, name, public_key, private_key, private_key_enc, private_key_nonce,
+               data_key, key_version, created_at, updated_at, last_used_at,
                created_by_user_id, created_by_module_id
           FROM ssh_keys
          WHERE name = $1
@@ -129,18 +139,20 @@ func GetSSHKeyByName(name string) (SSHKey, error) {
 
 func InsertSSHKey(k SSHKey) (SSHKey, error) {
 	row := mainDB.QueryRow(`
-        INSERT INTO ssh_keys (id, name, public_key, private_key, created_by_user_id, created_by_module_id)
-        VALUES ($1, $2, $3, $4, $5, $6)
-        RETURNING id, name, public_key, private_key, created_at, updated_at, last_used_at,
+        INSERT INTO ssh_keys (id, name, public_key, private_key_enc, private_key_nonce, data_key, key_version,
+                              created_by_user_id, created_by_module_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING id, name, public_key, created_at, updated_at, last_used_at,
                   created_by_user_id, created_by_module_id
-	`, k.ID, k.Name, k.PublicKey, k.PrivateKey, k.CreatedByUserID, k.CreatedByModuleID)
-	if err := row.Scan(&k.ID, &k.Name, &k.PublicKey, &k.PrivateKey, &k.CreatedAt, &k.UpdatedAt, &k.LastUsedAt, &k.CreatedByUserID, &k.CreatedByModuleID); err != nil {
+	`, k.ID, k.Name, k.PublicKey, k.PrivateKeyEnc, k.PrivateKeyNonce, k.DataKey, k.KeyVersion,
+		k.CreatedByUserID, k.CreatedByModuleID)
+	if err := row.Scan(&k.ID, &k.Name, &k.PublicKey, &k.CreatedAt, &k.UpdatedAt, &k.LastUsedAt, &k.CreatedByUserID, &k.CreatedByModuleID); err != nil {
 		return SSHKey{}, err
 	}
 	return k, nil
 }
 
-func UpdateSSHKeyMaterial(id, publicKey, privateKey string, newOwnerUserID *string) (SSHKey, error) {
+func UpdateSSHKeyMaterial(id, publicKey string, enc SSHKeyCipher, newOwnerUserID *string) (SSHKey, error) {
 	var ownerUser sql.NullString
 	if newOwnerUserID != nil && *newOwnerUserID != "" {
 		ownerUser = sql.NullString{String: *newOwnerUserID, Valid: true}
@@ -149,22 +161,61 @@ func UpdateSSHKeyMaterial(id, publicKey, privateKey string, newOwnerUserID *stri
 	row := mainDB.QueryRow(`
         UPDATE ssh_keys
            SET public_key = $2,
-               private_key = $3,
-               created_by_user_id = $4,
+               private_key = '',
+               private_key_enc = $3,
+               private_key_nonce = $4,
+               data_key = $5,
+               key_version = $6,
+               created_by_user_id = $7,
                created_by_module_id = NULL,
                updated_at = NOW(),
                last_used_at = NOW()
          WHERE id = $1
-     RETURNING id, name, public_key, private_key, created_at, updated_at, last_used_at,
+     RETURNING id, name, public_key, created_at, updated_at, last_used_at,
                created_by_user_id, created_by_module_id
-    `, id, publicKey, privateKey, ownerUser)
+    `, id, publicKey, enc.PrivateKeyEnc, enc.PrivateKeyNonce, enc.DataKey, enc.KeyVersion, ownerUser)
 	var k SSHKey
-	if err := row.Scan(&k.ID, &k.Name, &k.PublicKey, &k.PrivateKey, &k.CreatedAt, &k.UpdatedAt, &k.LastUsedAt, &k.CreatedByUserID, &k.CreatedByModuleID); err != nil {
+	if err := row.Scan(&k.ID, &k.Name, &k.PublicKey, &k.CreatedAt, &k.UpdatedAt, &k.LastUsedAt, &k.CreatedByUserID, &k.CreatedByModuleID); err != nil {
 		return SSHKey{}, err
 	}
 	return k, nil
 }
 
+// ListSSHKeysToRewrap returns the keys still in plaintext or wrapped with a
+// master key version other than activeVersion.
+func ListSSHKeysToRewrap(ctx context.Context, activeVersion int) ([]SSHKey, error) {
+	var keys []SSHKey
+	err := mainDB.SelectContext(ctx, &keys, `
+        SELECT id, name, public_key, private_key, private_key_enc, private_key_nonce,
+               data_key, key_version, created_at, updated_at, last_used_at,
+               created_by_user_id, created_by_module_id
+          FROM ssh_keys
+         WHERE key_version IS NULL OR key_version <> $1
+         ORDER BY id
+    `, activeVersion)
+	return keys, err
+}
+
+// ReplaceSSHKeyCipher stores a new envelope for a key and clears any legacy
+// plaintext. The write is skipped (false) if the key changed since it was read.
+func ReplaceSSHKeyCipher(ctx context.Context, id string, previousVersion sql.NullInt64, c SSHKeyCipher) (bool, error) {
+	res, err := mainDB.ExecContext(ctx, `
+        UPDATE ssh_keys
+           SET private_key = '',
+               private_key_enc = $2,
+               private_key_nonce = $3,
+               data_key = $4,
+               key_version = $5
+         WHERE id = $1
+           AND key_version IS NOT DISTINCT FROM $6
+    `, id, c.PrivateKeyEnc, c.PrivateKeyNonce, c.DataKey, c.KeyVersion, previousVersion)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
 func DeleteSSHKey(id string) error {
 	_, err := mainDB.Exec(`DELETE FROM ssh_keys WHERE id = $1`, id)
 	return err
@@ -235,3 +286,86 @@ func ListSSHKeyEvents(sshKeyID string, limit int) ([]SSHKeyEvent, error) {
 	}
 	return events, nil
 }
+
+// ListSSHKeyEventsByActor returns the SSH key audit entries performed by a user.
+func ListSSHKeyEventsByActor(userID string) ([]SSHKeyEvent, error) {
+	rows, err := mainDB.Query(`
+        SELECT e.id, e.ssh_key_id, e.message,
+               e.actor_user_id, u.ft_login, u.photo_url,
+               e.actor_module_id, m.name, m.icon_url,
+               e.created_at
+          FROM ssh_key_events e
+          LEFT JOIN users u ON u.id = e.actor_user_id
+          LEFT JOIN modules m ON m.id = e.actor_module_id
+         WHERE e.actor_user_id = $1
+         ORDER BY e.created_at DESC
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []SSHKeyEvent
+	for rows.Next() {
+		var ev SSHKeyEvent
+		if err := rows.Scan(
+			&ev.ID,
+			&ev.SSHKeyID,
+			&ev.Message,
+			&ev.ActorUserID,
+			&ev.ActorUserLogin,
+			&ev.ActorUserPhoto,
+			&ev.ActorModuleID,
+			&ev.ActorModuleName,
+			&ev.ActorModuleIcon,
+			&ev.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
+
+// ListSSHKeyEventsOnUserKeys returns the audit entries of the SSH keys a user
+// created, performed by someone else.
+func ListSSHKeyEventsOnUserKeys(userID string) ([]SSHKeyEvent, error) {
+	rows, err := mainDB.Query(`
+        SELECT e.id, e.ssh_key_id, e.message,
+               e.actor_user_id, u.ft_login, u.photo_url,
+               e.actor_module_id, m.name, m.icon_url,
+               e.created_at
+          FROM ssh_key_events e
+          JOIN ssh_keys k ON k.id = e.ssh_key_id
+          LEFT JOIN users u ON u.id = e.actor_user_id
+          LEFT JOIN modules m ON m.id = e.actor_module_id
+         WHERE k.created_by_user_id = $1
+           AND e.actor_user_id IS DISTINCT FROM $1
+         ORDER BY e.created_at DESC
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []SSHKeyEvent
+	for rows.Next() {
+		var ev SSHKeyEvent
+		if err := rows.Scan(
+			&ev.ID,
+			&ev.SSHKeyID,
+			&ev.Message,
+			&ev.ActorUserID,
+			&ev.ActorUserLogin,
+			&ev.ActorUserPhoto,
+			&ev.ActorModuleID,
+			&ev.ActorModuleName,
+			&ev.ActorModuleIcon,
+			&ev.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type UserAttributeDef struct {
+	Key            string    `json:"key" db:"key"`
+	Label          string    `json:"label" db:"label"`
+	Type           string    `json:"type" db:"type"`
+	Description    string    `json:"description" db:"description"`
+	ExposeInClaims bool      `json:"expose_in_claims" db:"expose_in_claims"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func ListUserAttributeDefs() ([]UserAttributeDef, error) {
+	var defs []UserAttributeDef
+	err := mainDB.Select(&defs, `
+		SELECT key, label, type, description, expose_in_claims, created_at, updated_at
+		  FROM user_attribute_defs
+		 ORDER BY key
+	`)
+	return defs, err
+}
+
+func GetUserAttributeDef(key string) (UserAttributeDef, error) {
+	var def UserAttributeDef
+	err := mainDB.Get(&def, `
+		SELECT key, label, type, description, expose_in_claims, created_at, updated_at
+		  FROM user_attribute_defs
+		 WHERE key = $1
+	`, key)
+	return def, err
+}
+
+func InsertUserAttributeDef(def UserAttributeDef) (UserAttributeDef, error) {
+	var out UserAttributeDef
+	err := mainDB.Get(&out, `
+		INSERT INTO user_attribute_defs (key, label, type, description, expose_in_claims)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING key, label, type, description, expose_in_claims, created_at, updated_at
+	`, def.Key, def.Label, def.Type, def.Description, def.ExposeInClaims)
+	return out, err
+}
+
+// UpdateUserAttributeDef updates the mutable fields of a definition. The type is
+// fixed at creation since stored values are validated against it.
+func UpdateUserAttributeDef(def UserAttributeDef) (UserAttributeDef, error) {
+	var out UserAttributeDef
+	err := mainDB.Get(&out, `
+		UPDATE user_attribute_defs
+		   SET label = $2,
+		       description = $3,
+		       expose_in_claims = $4,
+		       updated_at = NOW()
+		 WHERE key = $1
+		RETURNING key, label, type, description, expose_in_claims, created_at, updated_at
+	`, def.Key, def.Label, def.Description, def.ExposeInClaims)
+	return out, err
+}
+
+func DeleteUserAttributeDef(key string) error {
+	res, err := mainDB.Exec(`DELETE FROM user_attribute_defs WHERE key = $1`, key)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetUserAttributeValues returns the raw JSON values of a user's custom attributes.
+// With exposedOnly, only attributes flagged expose_in_claims are returned.
+func GetUserAttributeValues(userID string, exposedOnly bool) (map[string]json.RawMessage, error) {
+	rows, err := mainDB.Query(`
+		SELECT v.attr_key, v.value
+		  FROM user_attribute_values v
+		  JOIN user_attribute_defs d ON d.key = v.attr_key
+		 WHERE v.user_id = $1
+		   AND (NOT $2 OR d.expose_in_claims)
+	`, userID, exposedOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]json.RawMessage{}
+	for rows.Next() {
+		var key string
+		var data []byte
+		if err := rows.Scan(&key, &data); err != nil {
+			return nil, err
+		}
+		out[key] = json.RawMessage(data)
+	}
+	return out, rows.Err()
+}
+
+// SetUserAttributeValues upserts values and removes the keys in deletes, atomically.
+func SetUserAttributeValues(ctx context.Context, userID string, values map[string]json.RawMessage, deletes []string) error {
+	tx, err := mainDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for key, value := range values {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO user_attribute_values (user_id, attr_key, value, updated_at)
+			VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (user_id, attr_key)
+			DO UPDATE SET value = EXCLUDED.value, updated_at = EXCLUDED.updated_at
+		`, userID, key, []byte(value)); err != nil {
+			return err
+		}
+	}
+	for _, key := range deletes {
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM user_attribute_values WHERE user_id = $1 AND attr_key = $2
+		`, userID, key); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
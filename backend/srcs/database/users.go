@@ -2,9 +2,14 @@ package database
 
 import (
 	"backend/utils"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type User struct {
@@ -40,6 +45,37 @@ type UserOrder struct {
 	Order OrderDirection
 }
 
+type UserQueryField string
+
+const (
+	UserQueryText     UserQueryField = "text"
+	UserQueryRole     UserQueryField = "role"
+	UserQueryStaff    UserQueryField = "staff"
+	UserQueryLastSeen UserQueryField = "last_seen"
+	UserQueryLogin    UserQueryField = "login"
+	UserQueryFtID     UserQueryField = "ft_id"
+)
+
+type UserQueryOp string
+
+const (
+	UserQueryEq    UserQueryOp = "="
+	UserQueryRegex UserQueryOp = "~"
+	UserQueryLt    UserQueryOp = "<"
+	UserQueryLte   UserQueryOp = "<="
+	UserQueryGt    UserQueryOp = ">"
+	UserQueryGte   UserQueryOp = ">="
+)
+
+// UserQueryTerm is one compiled condition of a user search. Values are always
+// bound as parameters; only Field and Op (both whitelisted) reach the SQL text.
+type UserQueryTerm struct {
+	Field  UserQueryField
+	Op     UserQueryOp
+	Value  any
+	Negate bool
+}
+
 func GetUser(identifier string) (*User, error) {
 	if strings.HasPrefix(identifier, "user_") {
 		return GetUserByID(identifier)
@@ -80,6 +116,21 @@ func GetAllUsers(
 	filter string,
 	lastUser *User,
 	limit int,
+) ([]User, error) {
+	var terms []UserQueryTerm
+	if filter != "" {
+		terms = append(terms, UserQueryTerm{Field: UserQueryText, Op: UserQueryEq, Value: filter})
+	}
+	return SearchUsers(orderBy, terms, lastUser, limit)
+}
+
+// SearchUsers is GetAllUsers with structured query terms instead of a single
+// free-text filter. All terms are AND-ed together.
+func SearchUsers(
+	orderBy *[]UserOrder,
+	terms []UserQueryTerm,
+	lastUser *User,
+	limit int,
 ) ([]User, error) {
 	// 1) Default to ordering by ID ASC if none provided
 	if orderBy == nil || len(*orderBy) == 0 {
@@ -157,12 +208,17 @@ func GetAllUsers(
 		)
 	}
 
-	// Text‐filter on login and 42id
-	if filter != "" {
-		whereConds = append(whereConds,
-			fmt.Sprintf("(ft_login ILIKE '%%' || $%d || '%%' OR ft_id::text ILIKE '%%' || $%d || '%%')", argPos, argPos),
-		)
-		args = append(args, filter)
+	// Query terms (free text, role:, staff:, last_seen<, login:~ ...)
+	for _, term := range terms {
+		cond, err := userQueryTermSQL(term, argPos)
+		if err != nil {
+			return nil, err
+		}
+		if term.Negate {
+			cond = "NOT " + cond
+		}
+		whereConds = append(whereConds, cond)
+		args = append(args, term.Value)
 		argPos++
 	}
 
@@ -188,7 +244,7 @@ FROM users`,
 	// 5) Execute and scan
 	rows, err := mainDB.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, invalidQueryError(err)
 	}
 	defer rows.Close()
 
@@ -207,9 +263,54 @@ FROM users`,
 		}
 		out = append(out, u)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, invalidQueryError(err)
+	}
 	return out, nil
 }
 
+// invalidQueryError wraps the errors Postgres raises on a malformed login
+// regex in ErrInvalidQuery. Patterns are checked with Go's regexp before the
+// query, but the two regex dialects differ.
+func invalidQueryError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "2201B" {
+		return fmt.Errorf("%w: %s", ErrInvalidQuery, pqErr.Message)
+	}
+	return err
+}
+
+// userQueryTermSQL renders a single term as a parenthesised condition that
+// uses $argPos as its only placeholder.
+func userQueryTermSQL(term UserQueryTerm, argPos int) (string, error) {
+	switch term.Field {
+	case UserQueryText:
+		return fmt.Sprintf("(ft_login ILIKE '%%' || $%d || '%%' OR ft_id::text ILIKE '%%' || $%d || '%%')", argPos, argPos), nil
+	case UserQueryRole:
+		return fmt.Sprintf(`(EXISTS (
+	SELECT 1 FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+	WHERE ur.user_id = users.id AND (r.id = $%d OR r.id = 'roles_' || $%d OR LOWER(r.name) = LOWER($%d))
+))`, argPos, argPos, argPos), nil
+	case UserQueryStaff:
+		return fmt.Sprintf("(ft_is_staff = $%d)", argPos), nil
+	case UserQueryFtID:
+		return fmt.Sprintf("(ft_id = $%d)", argPos), nil
+	case UserQueryLogin:
+		switch term.Op {
+		case UserQueryEq:
+			return fmt.Sprintf("(LOWER(ft_login) = LOWER($%d))", argPos), nil
+		case UserQueryRegex:
+			return fmt.Sprintf("(ft_login ~* $%d)", argPos), nil
+		}
+	case UserQueryLastSeen:
+		switch term.Op {
+		case UserQueryLt, UserQueryLte, UserQueryGt, UserQueryGte:
+			return fmt.Sprintf("(last_seen %s $%d)", term.Op, argPos), nil
+		}
+	}
+	return "", fmt.Errorf("unsupported user query term %s %s", term.Field, term.Op)
+}
+
 func AddUser(user *User) error {
 	if user.ID == "" {
 		user.ID = utils.GenerateULID(utils.User)
@@ -227,6 +328,97 @@ func AddUser(user *User) error {
 	return err
 }
 
+func GetUserByFtID(ftID int) (*User, error) {
+	var user User
+	err := mainDB.QueryRow(`
+		SELECT id, ft_login, ft_id, ft_is_staff, photo_url, last_seen
+		FROM users
+		WHERE ft_id = $1
+	`, ftID).Scan(&user.ID, &user.FtLogin, &user.FtID, &user.FtIsStaff, &user.PhotoURL, &user.LastSeen)
+
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateUserProfile overwrites the fields mirrored from the 42 intranet and stamps profile_synced_at.
+func UpdateUserProfile(user User, syncedAt time.Time) error {
+	_, err := mainDB.Exec(`
+		UPDATE users
+		   SET ft_login = $2,
+		       ft_id = $3,
+		       ft_is_staff = $4,
+		       photo_url = $5,
+		       profile_synced_at = $6
+		 WHERE id = $1
+	`, user.ID, user.FtLogin, user.FtID, user.FtIsStaff, user.PhotoURL, syncedAt)
+	return err
+}
+
+// RenameUserLogin changes the login of a user; sessions follow it.
+func RenameUserLogin(id, login string) error {
+	_, err := mainDB.Exec(`UPDATE users SET ft_login = $2 WHERE id = $1`, id, login)
+	return err
+}
+
+// SetUserRuleProfile stores the 42 profile the user's rule roles were evaluated against.
+func SetUserRuleProfile(id string, profile json.RawMessage) error {
+	_, err := mainDB.Exec(`UPDATE users SET rule_profile = $2 WHERE id = $1`, id, []byte(profile))
+	return err
+}
+
+// GetUserRuleProfile returns the profile stored by SetUserRuleProfile, or
+// ErrNotFound when the user has none yet.
+func GetUserRuleProfile(id string) (json.RawMessage, error) {
+	var profile []byte
+	err := mainDB.QueryRow(`SELECT rule_profile FROM users WHERE id = $1`, id).Scan(&profile)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if profile == nil {
+		return nil, ErrNotFound
+	}
+	return json.RawMessage(profile), nil
+}
+
+func MarkUserProfileSynced(id string, syncedAt time.Time) error {
+	_, err := mainDB.Exec(`UPDATE users SET profile_synced_at = $2 WHERE id = $1`, id, syncedAt)
+	return err
+}
+
+// ListUsersForProfileSync returns up to limit users not seen and not synced since staleBefore,
+// least recently synced first.
+func ListUsersForProfileSync(staleBefore time.Time, limit int) ([]User, error) {
+	rows, err := mainDB.Query(`
+		SELECT id, ft_login, ft_id, ft_is_staff, photo_url, last_seen
+		FROM users
+		WHERE last_seen < $1
+		  AND (profile_synced_at IS NULL OR profile_synced_at < $1)
+		  AND ft_login <> ''
+		  AND anonymized_at IS NULL
+		ORDER BY profile_synced_at ASC NULLS FIRST, id
+		LIMIT $2
+	`, staleBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []User
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.FtLogin, &u.FtID, &u.FtIsStaff, &u.PhotoURL, &u.LastSeen); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
 func UpdateUserLastSeen(login string, ts time.Time) error {
 	_, err := mainDB.Exec(`
         UPDATE users SET last_seen = $1 WHERE ft_login = $2 OR id = $2
@@ -262,6 +454,43 @@ func GetUserRoles(userID string) ([]Role, error) {
 	return roles, nil
 }
 
+const (
+	UserRoleSourceManual  = "manual"
+	UserRoleSourceDefault = "default"
+	UserRoleSourceRule    = "rule"
+)
+
+// UserRoleGrant is a role held by a user along with where it came from.
+type UserRoleGrant struct {
+	Role
+	Source string `json:"source" db:"source"`
+}
+
+// GetUserRoleGrants lists a user's roles with the source recorded on the link.
+func GetUserRoleGrants(userID string) ([]UserRoleGrant, error) {
+	rows, err := mainDB.Query(`
+		SELECT r.id, r.name, r.color, COALESCE(r.is_default, false), ur.source
+		FROM roles r
+		JOIN user_roles ur ON ur.role_id = r.id
+		WHERE ur.user_id = $1
+		ORDER BY r.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var grants []UserRoleGrant
+	for rows.Next() {
+		var g UserRoleGrant
+		if err := rows.Scan(&g.ID, &g.Name, &g.Color, &g.IsDefault, &g.Source); err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
 func PatchUser(patch UserPatch) error {
 	if patch.ID == "" {
 		return fmt.Errorf("user ID is required")
@@ -2,10 +2,13 @@ package database
 
 import (
 	_ "embed"
+	"encoding/json"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/lib/pq"
 )
 
 //go:embed test_data/test_data_users.sql
@@ -381,3 +384,74 @@ func TestGetAllUsers(t *testing.T) {
 		})
 	}
 }
+
+func TestSearchUsers_InvalidRegex(t *testing.T) {
+	CreateAndPopulateDatabase(t, "test_search_users_regex_db", testDataSQL)
+	// Valid for Go's regexp, rejected by Postgres
+	terms := []UserQueryTerm{{Field: UserQueryLogin, Op: UserQueryRegex, Value: "(?P<n>a)"}}
+	if _, err := SearchUsers(nil, terms, nil, 0); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("SearchUsers() error = %v, want ErrInvalidQuery", err)
+	}
+
+	terms[0].Value = "^a"
+	if _, err := SearchUsers(nil, terms, nil, 0); err != nil {
+		t.Errorf("SearchUsers() error = %v", err)
+	}
+}
+
+func TestInvalidQueryError(t *testing.T) {
+	err := invalidQueryError(&pq.Error{Code: "2201B", Message: "invalid regular expression: quantifier operand invalid"})
+	if !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("invalidQueryError(2201B) = %v, want ErrInvalidQuery", err)
+	}
+	other := &pq.Error{Code: "42P01"}
+	if err := invalidQueryError(other); err != other {
+		t.Errorf("invalidQueryError(42P01) = %v, want it unchanged", err)
+	}
+}
+
+func TestUpdateUserProfile_RenameKeepsSessions(t *testing.T) {
+	CreateAndPopulateDatabase(t, "test_user_rename_db", testDataSQL)
+	if err := AddSession(Session{SessionID: "sess_rename", Login: "heinz"}); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := GetUserByLogin("heinz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.FtLogin = "heinz2"
+	if err := UpdateUserProfile(*user, time.Now()); err != nil {
+		t.Fatalf("UpdateUserProfile() error = %v", err)
+	}
+
+	sess, err := GetSession("sess_rename")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.Login != "heinz2" {
+		t.Errorf("session login = %q, want heinz2", sess.Login)
+	}
+}
+
+func TestUserRuleProfile(t *testing.T) {
+	CreateAndPopulateDatabase(t, "test_user_rule_profile_db", testDataSQL)
+	const heinz = "user_01HZXYZDE0420"
+
+	if _, err := GetUserRuleProfile(heinz); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetUserRuleProfile() before any sync error = %v, want ErrNotFound", err)
+	}
+	if err := SetUserRuleProfile(heinz, json.RawMessage(`{"login":"heinz"}`)); err != nil {
+		t.Fatalf("SetUserRuleProfile() error = %v", err)
+	}
+	got, err := GetUserRuleProfile(heinz)
+	if err != nil {
+		t.Fatalf("GetUserRuleProfile() error = %v", err)
+	}
+	if string(got) != `{"login": "heinz"}` {
+		t.Errorf("GetUserRuleProfile() = %s", got)
+	}
+	if _, err := GetUserRuleProfile("user_unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetUserRuleProfile(unknown) error = %v, want ErrNotFound", err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"backend/api/auth"
+	"backend/api/gitcredentials"
 	"backend/api/integrations"
 	"backend/api/modules"
 	"backend/api/oidc"
@@ -123,6 +124,9 @@ func main() {
 			log.Println("No .env file found, and BUILD_MODE not set! (may be fine in production)")
 		}
 	}
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
 	// Set up the CORS middleware
 	corsMiddleware := cors.New(cors.Options{
 		AllowedOrigins: []string{
@@ -216,6 +220,7 @@ func main() {
 	r.With(InjectUserInMiddleware, auth.AuthMiddleware, auth.BlackListMiddleware).Get("/api/v1/users/me/pages", users.GetContextUserPages)
 	r.With(InjectUserInMiddleware, auth.AuthMiddleware, auth.BlackListMiddleware).Get("/api/v1/users/me/roles", users.GetUserMeRoles)
 	r.With(InjectUserInMiddleware, auth.AuthMiddleware, auth.BlackListMiddleware).Delete("/api/v1/users/me", users.DeleteUserMe)
+	r.With(InjectUserInMiddleware, auth.AuthMiddleware, auth.BlackListMiddleware).Get("/api/v1/users/me/export", users.GetUserMeExport)
 	r.With(InjectUserInMiddleware, auth.AuthMiddleware, auth.BlackListMiddleware).Get("/api/v1/users/me/prefs/sidebar", users.GetSidebarPrefs)
 	r.With(InjectUserInMiddleware, auth.AuthMiddleware, auth.BlackListMiddleware).Put("/api/v1/users/me/prefs/sidebar", users.PutSidebarPrefs)
 	r.With(InjectUserInMiddleware, auth.AuthMiddleware, auth.BlackListMiddleware).Get("/api/v1/users/me/sessions", users.GetUserSessions)
@@ -224,6 +229,9 @@ func main() {
 	r.With(InjectUserInMiddleware, auth.AuthMiddleware, auth.BlackListMiddleware).Get("/api/v1/ping", ping.Ping)
 	r.With(InjectUserInMiddleware, auth.AuthMiddleware, auth.BlackListMiddleware).Post("/api/v1/modules/pages/{slug}/session", modules.IssueModulePageSession)
 
+	// Forge push webhooks authenticate with the module webhook secret
+	r.Post("/api/v1/webhooks/modules/{moduleID}", modules.ReceiveModuleWebhook)
+
 	r.Route("/api/v1/admin", func(r chi.Router) {
 		r.Group(func(r chi.Router) {
 			r.Use(InjectUserInMiddleware, auth.AuthMiddleware, auth.BlackListMiddleware, auth.AdminMiddleware)
@@ -234,6 +242,7 @@ func main() {
 				oidc.RegisterAdminRoutes(r)
 			})
 			r.Route("/ssh-keys", sshkeys.RegisterRoutes)
+			r.Route("/git-credentials", gitcredentials.RegisterRoutes)
 			r.Get("/docker/ls", modules.GetAllContainers)
 			r.Delete("/docker/{containerName}/delete", modules.DeleteContainerGlobal)
 			r.Route("/users", users.RegisterRoutes)
@@ -244,8 +253,21 @@ func main() {
 	if err := core.BackfillOIDCClients(); err != nil {
 		log.Printf("OIDC backfill failed: %v", err)
 	}
+	if report, err := core.RewrapStoredSecrets(context.Background()); err != nil {
+		log.Printf("Stored secrets were not encrypted: %v", err)
+	} else if report.SSHKeysEncrypted+report.SSHKeysRewrapped+report.ModuleSecretsRewrapped+report.WebhooksRewrapped+report.GitCredentialsRewrapped+report.Failed > 0 {
+		log.Printf("Stored secrets: %d ssh keys encrypted, %d ssh keys, %d module secrets, %d webhook secrets and %d git credentials re-wrapped with key v%d, %d failed",
+			report.SSHKeysEncrypted, report.SSHKeysRewrapped, report.ModuleSecretsRewrapped, report.WebhooksRewrapped, report.GitCredentialsRewrapped, report.ActiveVersion, report.Failed)
+	}
 
 	core.StartDockerEventWatcher()
+	core.StartUserProfileSync()
+	core.StartUserLifecycleJob()
+	core.StartModuleJobWorkers()
+	core.StartPageHealthProbes()
+	core.StartModuleUpdateScheduler()
+	core.StartModulePreviewGC()
+	core.StartManagedDatabaseSweeper()
 	go websocket.Dispatch()
 
 	// Wire WS subscribe/unsubscribe hooks for container log streaming
@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE users
+  DROP COLUMN IF EXISTS profile_synced_at;
+
+ALTER TABLE user_roles
+  DROP COLUMN IF EXISTS source;
+
+COMMIT;
@@ -0,0 +1,24 @@
+BEGIN;
+
+-- Track how a user obtained a role so rule re-evaluation only touches its own links
+ALTER TABLE user_roles
+  ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'manual';
+
+UPDATE user_roles ur
+   SET source = 'default'
+  FROM roles r
+ WHERE r.id = ur.role_id
+   AND r.is_default = TRUE;
+
+UPDATE user_roles ur
+   SET source = 'rule'
+  FROM roles r
+ WHERE r.id = ur.role_id
+   AND r.is_default IS NOT TRUE
+   AND r.rules_json IS NOT NULL;
+
+-- Last time the 42 profile was fetched (login or background sync)
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS profile_synced_at TIMESTAMPTZ;
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+
+DROP TABLE IF EXISTS user_lifecycle_runs;
+
+DROP INDEX IF EXISTS idx_users_last_seen;
+
+ALTER TABLE users
+  DROP COLUMN IF EXISTS lifecycle_exempt_reason,
+  DROP COLUMN IF EXISTS lifecycle_exempt,
+  DROP COLUMN IF EXISTS anonymized_at,
+  DROP COLUMN IF EXISTS dormant_since;
+
+COMMIT;
@@ -0,0 +1,21 @@
+BEGIN;
+
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS dormant_since TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS lifecycle_exempt BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD COLUMN IF NOT EXISTS lifecycle_exempt_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_users_last_seen ON users(last_seen);
+
+CREATE TABLE IF NOT EXISTS user_lifecycle_runs (
+  id          TEXT PRIMARY KEY,
+  started_at  TIMESTAMPTZ NOT NULL,
+  finished_at TIMESTAMPTZ NOT NULL,
+  dry_run     BOOLEAN NOT NULL DEFAULT FALSE,
+  report      JSONB NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_lifecycle_runs_started_at ON user_lifecycle_runs(started_at DESC);
+
+COMMIT;
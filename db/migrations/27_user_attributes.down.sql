@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS user_attribute_values;
+DROP TABLE IF EXISTS user_attribute_defs;
+
+COMMIT;
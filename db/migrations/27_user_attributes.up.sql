@@ -0,0 +1,23 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS user_attribute_defs (
+  key              TEXT PRIMARY KEY,
+  label            TEXT NOT NULL,
+  type             TEXT NOT NULL CHECK (type IN ('string', 'number', 'bool', 'date')),
+  description      TEXT NOT NULL DEFAULT '',
+  expose_in_claims BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at       TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS user_attribute_values (
+  user_id    TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  attr_key   TEXT NOT NULL REFERENCES user_attribute_defs(key) ON DELETE CASCADE,
+  value      JSONB NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (user_id, attr_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_attribute_values_key ON user_attribute_values(attr_key);
+
+COMMIT;
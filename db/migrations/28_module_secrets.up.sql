@@ -0,0 +1,14 @@
+BEGIN;
+
+-- Per-module secrets, AES-GCM encrypted with the backend master key.
+CREATE TABLE IF NOT EXISTS module_secrets (
+  module_id  TEXT NOT NULL REFERENCES modules(id) ON DELETE CASCADE,
+  name       TEXT NOT NULL,
+  nonce      BYTEA NOT NULL,
+  ciphertext BYTEA NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (module_id, name)
+);
+
+COMMIT;
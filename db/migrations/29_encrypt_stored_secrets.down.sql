@@ -0,0 +1,17 @@
+BEGIN;
+
+-- Encrypted values cannot be restored in SQL: keys encrypted by the backend
+-- lose their private material, and module secrets must be set again.
+DELETE FROM module_secrets WHERE data_key IS NOT NULL;
+
+ALTER TABLE module_secrets
+    DROP COLUMN IF EXISTS key_version,
+    DROP COLUMN IF EXISTS data_key;
+
+ALTER TABLE ssh_keys
+    DROP COLUMN IF EXISTS key_version,
+    DROP COLUMN IF EXISTS data_key,
+    DROP COLUMN IF EXISTS private_key_nonce,
+    DROP COLUMN IF EXISTS private_key_enc;
+
+COMMIT;
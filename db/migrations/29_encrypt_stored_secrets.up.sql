@@ -0,0 +1,19 @@
+BEGIN;
+
+-- Envelope encryption for SSH private keys: the key is encrypted with a random
+-- data key, itself wrapped by master key `key_version`. Existing plaintext rows
+-- keep `private_key` until the backend encrypts them at startup (or via
+-- `backend rotate-keys`), which then clears the plaintext column.
+ALTER TABLE ssh_keys
+    ADD COLUMN IF NOT EXISTS private_key_enc   BYTEA,
+    ADD COLUMN IF NOT EXISTS private_key_nonce BYTEA,
+    ADD COLUMN IF NOT EXISTS data_key          BYTEA,
+    ADD COLUMN IF NOT EXISTS key_version       INT;
+
+-- Module secrets move to the same scheme. Rows without a data key were
+-- encrypted directly with master key version 1 and get re-wrapped the same way.
+ALTER TABLE module_secrets
+    ADD COLUMN IF NOT EXISTS data_key    BYTEA,
+    ADD COLUMN IF NOT EXISTS key_version INT;
+
+COMMIT;
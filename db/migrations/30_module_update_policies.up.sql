@@ -0,0 +1,20 @@
+BEGIN;
+
+-- Per-module automatic update policy, evaluated by the backend scheduler.
+CREATE TABLE IF NOT EXISTS module_update_policies (
+  module_id              TEXT PRIMARY KEY REFERENCES modules(id) ON DELETE CASCADE,
+  mode                   TEXT NOT NULL DEFAULT 'manual'
+                           CHECK (mode IN ('manual', 'notify', 'auto_pull', 'auto_pull_deploy')),
+  check_interval_seconds INT NOT NULL DEFAULT 3600 CHECK (check_interval_seconds > 0),
+  timezone               TEXT NOT NULL DEFAULT 'UTC',
+  maintenance_windows    JSONB NOT NULL DEFAULT '[]'::jsonb,
+  last_checked_at        TIMESTAMPTZ,
+  last_result            TEXT NOT NULL DEFAULT '',
+  last_error             TEXT NOT NULL DEFAULT '',
+  last_notified_hash     TEXT NOT NULL DEFAULT '',
+  updated_at             TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_module_update_policies_mode ON module_update_policies(mode);
+
+COMMIT;
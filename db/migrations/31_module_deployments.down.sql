@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_module_log_deployment_id;
+ALTER TABLE module_log DROP COLUMN IF EXISTS deployment_id;
+DROP TABLE IF EXISTS module_deployments;
+
+COMMIT;
@@ -0,0 +1,30 @@
+BEGIN;
+
+-- One row per deploy run, so previous deploys (and their logs) are kept.
+CREATE TABLE IF NOT EXISTS module_deployments (
+  id            TEXT PRIMARY KEY, -- deployment_ULID
+  module_id     TEXT NOT NULL REFERENCES modules(id) ON DELETE CASCADE,
+  actor_user_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+  source        TEXT NOT NULL DEFAULT 'manual', -- manual, rebuild, auto_update, rollback
+  rollback_of   TEXT REFERENCES module_deployments(id) ON DELETE SET NULL,
+  status        TEXT NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'success', 'failed')),
+  commit_before TEXT NOT NULL DEFAULT '',
+  commit_after  TEXT NOT NULL DEFAULT '',
+  compose_hash  TEXT NOT NULL DEFAULT '',
+  error         TEXT NOT NULL DEFAULT '',
+  started_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  finished_at   TIMESTAMPTZ,
+  duration_ms   BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_module_deployments_module_started
+  ON module_deployments(module_id, started_at DESC);
+
+-- Log lines written while a deployment runs point to it.
+ALTER TABLE module_log
+  ADD COLUMN IF NOT EXISTS deployment_id TEXT REFERENCES module_deployments(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_module_log_deployment_id
+  ON module_log(deployment_id) WHERE deployment_id IS NOT NULL;
+
+COMMIT;
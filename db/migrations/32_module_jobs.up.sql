@@ -0,0 +1,32 @@
+BEGIN;
+
+-- Persistent queue for long-running module operations (deploy, rebuild, down,
+-- pull, clone, rollback). Jobs of one module run one at a time, in order.
+CREATE TABLE IF NOT EXISTS module_jobs (
+  id               TEXT PRIMARY KEY, -- job_ULID
+  module_id        TEXT NOT NULL REFERENCES modules(id) ON DELETE CASCADE,
+  kind             TEXT NOT NULL CHECK (kind IN ('deploy', 'rebuild', 'down', 'pull', 'clone', 'rollback')),
+  params           JSONB NOT NULL DEFAULT '{}'::jsonb,
+  status           TEXT NOT NULL DEFAULT 'queued'
+                   CHECK (status IN ('queued', 'running', 'succeeded', 'failed', 'cancelled')),
+  actor_user_id    TEXT REFERENCES users(id) ON DELETE SET NULL,
+  error            TEXT NOT NULL DEFAULT '',
+  cancel_requested BOOLEAN NOT NULL DEFAULT FALSE,
+  worker_id        TEXT,
+  attempts         INT NOT NULL DEFAULT 0,
+  created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  started_at       TIMESTAMPTZ,
+  finished_at      TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_module_jobs_module_created
+  ON module_jobs(module_id, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_module_jobs_queued
+  ON module_jobs(id) WHERE status = 'queued';
+
+-- The lock: at most one running job per module.
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_module_jobs_running
+  ON module_jobs(module_id) WHERE status = 'running';
+
+COMMIT;
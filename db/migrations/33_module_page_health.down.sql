@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS module_page_health_history;
+DROP TABLE IF EXISTS module_page_health_checks;
+
+COMMIT;
@@ -0,0 +1,42 @@
+BEGIN;
+
+-- Opt-in HTTP probe per module page, with its latest result.
+CREATE TABLE IF NOT EXISTS module_page_health_checks (
+  page_id              TEXT PRIMARY KEY REFERENCES module_page(id) ON DELETE CASCADE,
+  module_id            TEXT NOT NULL REFERENCES modules(id) ON DELETE CASCADE,
+  enabled              BOOLEAN NOT NULL DEFAULT TRUE,
+  path                 TEXT NOT NULL DEFAULT '/',
+  expected_status      INT NOT NULL DEFAULT 200 CHECK (expected_status BETWEEN 100 AND 599),
+  interval_seconds     INT NOT NULL DEFAULT 60 CHECK (interval_seconds >= 10),
+  timeout_seconds      INT NOT NULL DEFAULT 5 CHECK (timeout_seconds BETWEEN 1 AND 60),
+  status               TEXT NOT NULL DEFAULT 'unknown' CHECK (status IN ('unknown', 'up', 'down')),
+  consecutive_failures INT NOT NULL DEFAULT 0,
+  last_checked_at      TIMESTAMPTZ,
+  last_status_code     INT,
+  last_latency_ms      INT,
+  last_error           TEXT NOT NULL DEFAULT '',
+  updated_at           TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_module_page_health_checks_module
+  ON module_page_health_checks(module_id);
+
+-- Every probe result, pruned after a retention period.
+CREATE TABLE IF NOT EXISTS module_page_health_history (
+  id          BIGSERIAL PRIMARY KEY,
+  page_id     TEXT NOT NULL REFERENCES module_page(id) ON DELETE CASCADE,
+  module_id   TEXT NOT NULL REFERENCES modules(id) ON DELETE CASCADE,
+  checked_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  ok          BOOLEAN NOT NULL,
+  status_code INT,
+  latency_ms  INT NOT NULL DEFAULT 0,
+  error       TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_module_page_health_history_page_checked
+  ON module_page_health_history(page_id, checked_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_module_page_health_history_checked
+  ON module_page_health_history(checked_at);
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+
+-- Per-module deploy strategy. active_project is the compose project currently
+-- serving the module; empty means the module slug.
+CREATE TABLE IF NOT EXISTS module_deploy_settings (
+  module_id              TEXT PRIMARY KEY REFERENCES modules(id) ON DELETE CASCADE,
+  strategy               TEXT NOT NULL DEFAULT 'recreate'
+                           CHECK (strategy IN ('recreate', 'blue_green')),
+  health_timeout_seconds INT NOT NULL DEFAULT 120
+                           CHECK (health_timeout_seconds BETWEEN 10 AND 3600),
+  active_project         TEXT NOT NULL DEFAULT '',
+  updated_at             TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMIT;
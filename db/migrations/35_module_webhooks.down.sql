@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS module_webhook_deliveries;
+DROP TABLE IF EXISTS module_webhooks;
+
+COMMIT;
@@ -0,0 +1,35 @@
+BEGIN;
+
+-- Inbound push webhook of a module. The secret is sealed like module_secrets
+-- (envelope encryption under a versioned master key).
+CREATE TABLE IF NOT EXISTS module_webhooks (
+  module_id   TEXT PRIMARY KEY REFERENCES modules(id) ON DELETE CASCADE,
+  enabled     BOOLEAN NOT NULL DEFAULT TRUE,
+  nonce       BYTEA NOT NULL,
+  ciphertext  BYTEA NOT NULL,
+  data_key    BYTEA NOT NULL,
+  key_version INT NOT NULL,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS module_webhook_deliveries (
+  id           BIGSERIAL PRIMARY KEY,
+  module_id    TEXT NOT NULL REFERENCES modules(id) ON DELETE CASCADE,
+  received_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  provider     TEXT NOT NULL DEFAULT '',
+  event        TEXT NOT NULL DEFAULT '',
+  delivery_id  TEXT NOT NULL DEFAULT '',
+  ref          TEXT NOT NULL DEFAULT '',
+  after_commit TEXT NOT NULL DEFAULT '',
+  status       TEXT NOT NULL
+                 CHECK (status IN ('rejected', 'ignored', 'accepted', 'succeeded', 'failed')),
+  result       TEXT NOT NULL DEFAULT '',
+  error        TEXT NOT NULL DEFAULT '',
+  finished_at  TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_module_webhook_deliveries_module
+  ON module_webhook_deliveries(module_id, id DESC);
+
+COMMIT;
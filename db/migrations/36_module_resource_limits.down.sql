@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS module_resource_limits;
+
+COMMIT;
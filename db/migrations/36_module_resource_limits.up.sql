@@ -0,0 +1,14 @@
+BEGIN;
+
+-- Admin-set resource limits applied to every container of a module. NULL
+-- means unlimited.
+CREATE TABLE IF NOT EXISTS module_resource_limits (
+  module_id  TEXT PRIMARY KEY REFERENCES modules(id) ON DELETE CASCADE,
+  cpus       DOUBLE PRECISION CHECK (cpus > 0),
+  memory_mb  INT CHECK (memory_mb >= 6),
+  pids       INT CHECK (pids > 0),
+  disk_mb    INT CHECK (disk_mb > 0),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMIT;
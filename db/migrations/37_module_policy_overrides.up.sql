@@ -0,0 +1,14 @@
+BEGIN;
+
+-- Compose policy rules an admin allowed for a module, with who allowed them
+-- and why. Violations of an overridden rule no longer block the deploy.
+CREATE TABLE IF NOT EXISTS module_policy_overrides (
+  module_id     TEXT NOT NULL REFERENCES modules(id) ON DELETE CASCADE,
+  rule          TEXT NOT NULL,
+  reason        TEXT NOT NULL,
+  actor_user_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+  created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (module_id, rule)
+);
+
+COMMIT;
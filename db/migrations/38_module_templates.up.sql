@@ -0,0 +1,18 @@
+BEGIN;
+
+-- Template repositories new modules can be created from. The template is
+-- cloned with ssh_key_id when set, so private templates work too.
+CREATE TABLE IF NOT EXISTS module_templates (
+  id             TEXT PRIMARY KEY, -- template_ULID
+  name           TEXT NOT NULL UNIQUE,
+  description    TEXT NOT NULL DEFAULT '',
+  git_url        TEXT NOT NULL,
+  git_branch     TEXT NOT NULL DEFAULT 'main',
+  ssh_key_id     TEXT REFERENCES ssh_keys(id) ON DELETE SET NULL,
+  default_port   INTEGER NOT NULL DEFAULT 80 CHECK (default_port BETWEEN 1 AND 65535),
+  actor_user_id  TEXT REFERENCES users(id) ON DELETE SET NULL,
+  created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE modules DROP COLUMN IF EXISTS git_credential_id;
+DROP TABLE IF EXISTS git_credentials;
+
+COMMIT;
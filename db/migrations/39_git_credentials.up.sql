@@ -0,0 +1,24 @@
+BEGIN;
+
+-- HTTPS username/token pairs for git remotes, an alternative to ssh_keys.
+-- The token is sealed like module_secrets (envelope encryption under a
+-- versioned master key).
+CREATE TABLE IF NOT EXISTS git_credentials (
+  id                 TEXT PRIMARY KEY, -- git-credential_ULID
+  name               TEXT NOT NULL UNIQUE,
+  username           TEXT NOT NULL,
+  nonce              BYTEA NOT NULL,
+  ciphertext         BYTEA NOT NULL,
+  data_key           BYTEA NOT NULL,
+  key_version        INT NOT NULL,
+  created_by_user_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+  created_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  last_used_at       TIMESTAMPTZ
+);
+
+-- Used instead of the SSH key when the module remote is an https:// URL.
+ALTER TABLE modules
+  ADD COLUMN IF NOT EXISTS git_credential_id TEXT REFERENCES git_credentials(id) ON DELETE RESTRICT;
+
+COMMIT;
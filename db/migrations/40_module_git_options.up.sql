@@ -0,0 +1,11 @@
+BEGIN;
+
+-- Opt-in repository features per module, applied on clone, pull and checkout.
+CREATE TABLE IF NOT EXISTS module_git_options (
+  module_id  TEXT PRIMARY KEY REFERENCES modules(id) ON DELETE CASCADE,
+  submodules BOOLEAN NOT NULL DEFAULT FALSE,
+  lfs        BOOLEAN NOT NULL DEFAULT FALSE,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMIT;
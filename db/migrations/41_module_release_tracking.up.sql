@@ -0,0 +1,12 @@
+BEGIN;
+
+-- Modules that follow git tags matching a semver constraint instead of their
+-- branch. The matching tags are tracked in modules.version/latest_version.
+CREATE TABLE IF NOT EXISTS module_release_tracking (
+  module_id          TEXT PRIMARY KEY REFERENCES modules(id) ON DELETE CASCADE,
+  version_constraint TEXT NOT NULL,
+  prereleases        BOOLEAN NOT NULL DEFAULT FALSE,
+  updated_at         TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMIT;
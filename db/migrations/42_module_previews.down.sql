@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE IF EXISTS module_previews;
+DROP TABLE IF EXISTS module_maintainer_roles;
+DROP TABLE IF EXISTS module_preview_settings;
+
+COMMIT;
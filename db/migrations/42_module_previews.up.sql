@@ -0,0 +1,31 @@
+BEGIN;
+
+-- Preview settings of a module: how long its branch previews live by default.
+CREATE TABLE IF NOT EXISTS module_preview_settings (
+  module_id   TEXT PRIMARY KEY REFERENCES modules(id) ON DELETE CASCADE,
+  ttl_seconds INTEGER NOT NULL DEFAULT 604800 CHECK (ttl_seconds > 0),
+  updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Roles maintaining a module. The pages of its previews are restricted to them.
+CREATE TABLE IF NOT EXISTS module_maintainer_roles (
+  module_id TEXT NOT NULL REFERENCES modules(id) ON DELETE CASCADE,
+  role_id   TEXT NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+  PRIMARY KEY (module_id, role_id)
+);
+
+-- Modules cloned from a branch of another module to preview it. They are
+-- deleted once expired or when the branch is gone from the remote.
+CREATE TABLE IF NOT EXISTS module_previews (
+  module_id     TEXT PRIMARY KEY REFERENCES modules(id) ON DELETE CASCADE,
+  parent_id     TEXT NOT NULL REFERENCES modules(id) ON DELETE CASCADE,
+  branch        TEXT NOT NULL,
+  expires_at    TIMESTAMPTZ NOT NULL,
+  actor_user_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+  created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  UNIQUE (parent_id, branch)
+);
+
+CREATE INDEX IF NOT EXISTS module_previews_expires_at_idx ON module_previews (expires_at);
+
+COMMIT;
@@ -0,0 +1,23 @@
+BEGIN;
+
+-- Databases provisioned for modules in the platform-owned Postgres. Rows
+-- outlive their module: a retired database is dumped, then dropped, and its
+-- dump is kept until purge_after.
+CREATE TABLE IF NOT EXISTS module_databases (
+  id          BIGSERIAL PRIMARY KEY,
+  module_id   TEXT REFERENCES modules(id) ON DELETE SET NULL,
+  module_slug TEXT NOT NULL,
+  db_name     TEXT NOT NULL UNIQUE,
+  role_name   TEXT NOT NULL UNIQUE,
+  network     TEXT NOT NULL,
+  status      TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'retired', 'dropped')),
+  dump_path   TEXT NOT NULL DEFAULT '',
+  error       TEXT NOT NULL DEFAULT '',
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  retired_at  TIMESTAMPTZ,
+  purge_after TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS module_databases_active_idx ON module_databases (module_id) WHERE status = 'active';
+
+COMMIT;
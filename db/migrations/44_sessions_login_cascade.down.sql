@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TABLE sessions DROP CONSTRAINT IF EXISTS sessions_ft_login_fkey;
+ALTER TABLE sessions
+  ADD CONSTRAINT sessions_ft_login_fkey FOREIGN KEY (ft_login)
+      REFERENCES users(ft_login);
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+-- Logins can be renamed on the 42 side; sessions follow the users row
+ALTER TABLE sessions DROP CONSTRAINT IF EXISTS sessions_ft_login_fkey;
+ALTER TABLE sessions
+  ADD CONSTRAINT sessions_ft_login_fkey FOREIGN KEY (ft_login)
+      REFERENCES users(ft_login) ON UPDATE CASCADE;
+
+COMMIT;
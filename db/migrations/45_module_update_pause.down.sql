@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE module_update_policies
+  DROP COLUMN IF EXISTS paused_reason,
+  DROP COLUMN IF EXISTS paused_at;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+-- A rollback pauses automatic pulls and deploys until an admin resumes them
+ALTER TABLE module_update_policies
+  ADD COLUMN IF NOT EXISTS paused_at     TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS paused_reason TEXT NOT NULL DEFAULT '';
+
+COMMIT;
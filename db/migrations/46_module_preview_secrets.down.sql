@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE module_preview_settings DROP COLUMN IF EXISTS preview_secrets;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+-- Previews build unreviewed branches: only the parent secrets listed here are
+-- copied into them
+ALTER TABLE module_preview_settings
+  ADD COLUMN IF NOT EXISTS preview_secrets TEXT[] NOT NULL DEFAULT '{}';
+
+COMMIT;
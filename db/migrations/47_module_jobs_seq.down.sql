@@ -0,0 +1,10 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_module_jobs_queued;
+CREATE INDEX IF NOT EXISTS idx_module_jobs_queued
+  ON module_jobs(id) WHERE status = 'queued';
+
+DROP INDEX IF EXISTS uniq_module_jobs_seq;
+ALTER TABLE module_jobs DROP COLUMN IF EXISTS seq;
+
+COMMIT;
@@ -0,0 +1,23 @@
+BEGIN;
+
+-- Job IDs made in the same millisecond do not sort in creation order, so the
+-- queue is ordered by an insertion sequence instead
+ALTER TABLE module_jobs ADD COLUMN IF NOT EXISTS seq BIGINT;
+CREATE SEQUENCE IF NOT EXISTS module_jobs_seq_seq OWNED BY module_jobs.seq;
+
+UPDATE module_jobs j
+   SET seq = o.n
+  FROM (SELECT id, row_number() OVER (ORDER BY created_at, id) AS n FROM module_jobs) o
+ WHERE o.id = j.id;
+SELECT setval('module_jobs_seq_seq', COALESCE((SELECT MAX(seq) FROM module_jobs), 0) + 1, false);
+
+ALTER TABLE module_jobs
+  ALTER COLUMN seq SET DEFAULT nextval('module_jobs_seq_seq'),
+  ALTER COLUMN seq SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_module_jobs_seq ON module_jobs(seq);
+
+DROP INDEX IF EXISTS idx_module_jobs_queued;
+CREATE INDEX IF NOT EXISTS idx_module_jobs_queued
+  ON module_jobs(seq) WHERE status = 'queued';
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+-- 42 profile the rule roles were last evaluated against, so attribute changes
+-- can re-evaluate the rules without calling the 42 API
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS rule_profile JSONB;
+
+COMMIT;